@@ -0,0 +1,102 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strconv"
+
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	format "ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	layersharing "ecr-lifecycle-cleaner/internal/layerSharing"
+
+	"github.com/spf13/cobra"
+)
+
+var analyzeLayersCmd = &cobra.Command{
+	Use:   "analyzeLayers",
+	Short: "Reports how image layers are shared across repositories.",
+	Long: `Reports how image layers are shared across repositories in Amazon Elastic Container Registry (ECR).
+
+ECR stores each unique layer once, no matter how many images reference it. This command reads
+the image manifests, counts the references to every layer and estimates the bytes saved by sharing,
+so the real storage cost can be compared with the sum of image sizes. Nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] analyzeLayers called")
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "analyze")
+			return
+		}
+
+		report, err := layersharing.AnalyzeLayerSharing(ctx, repos, client)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to analyze layers: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		printSharedLayers(cmd, report.TopSharedLayers)
+		writeOutputFile(cmd, report)
+
+		cmd.Println("[INFO] Finished layer sharing analysis.")
+	},
+}
+
+// --- prints the most referenced layers as a table ---
+func printSharedLayers(cmd *cobra.Command, layers []layersharing.LayerUsage) {
+	if len(layers) == 0 {
+		return
+	}
+	rows := make([][]string, 0, len(layers))
+	for _, layer := range layers {
+		rows = append(rows, []string{layer.Digest, strconv.FormatInt(layer.Size, 10), strconv.Itoa(layer.References), strconv.Itoa(len(layer.Repositories))})
+	}
+	if err := format.WriteTable(cmd.ErrOrStderr(), []string{"LAYER", "SIZE", "REFERENCES", "REPOSITORIES"}, rows); err != nil {
+		cmd.Printf("[ERROR] Failed to print shared layers: %v\n", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(analyzeLayersCmd)
+}
@@ -0,0 +1,130 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	format "ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+)
+
+var compareAccounts []string
+
+var auditPolicyCmd = &cobra.Command{
+	Use:   "auditPolicy",
+	Short: "Reports the repositories whose lifecycle policy deviates from a reference policy, across accounts.",
+	Long: `Compares the lifecycle policy of the selected repositories with a reference policy in Amazon Elastic Container Registry (ECR).
+
+With --compareAccounts the audit runs in the account of every given AWS profile, and the results are consolidated
+into one drift report keyed by account and repository. Policies are compared by content, so key order and whitespace
+do not count. Repositories without a policy are reported as missing. Nothing is changed, use setPolicy to fix the drift.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] auditPolicy called")
+
+		var policyText string
+		var err error
+		if policyJSON != "" {
+			policyText, err = readpolicyfile.ReadPolicyJSON(policyJSON)
+		} else {
+			policyText, err = readpolicyfile.ReadPolicyFile(policyFile)
+		}
+		if err != nil {
+			cmd.Printf("[ERROR] Reading policy: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		// --- without --compareAccounts only the account of the current configuration is audited ---
+		profiles := compareAccounts
+		if len(profiles) == 0 {
+			profiles = []string{""}
+		}
+		start := time.Now()
+		report := setlifecyclepolicy.DriftReport{PolicyChecksum: setlifecyclepolicy.PolicyChecksum(policyText)}
+		for _, profile := range profiles {
+			report.Accounts = append(report.Accounts, auditAccount(cmd, withProfile(newConfigLoader(metrics), profile), profile, policyText))
+		}
+		report.Duration = time.Since(start)
+
+		rows := [][]string{}
+		for _, account := range report.Accounts {
+			if account.Error != "" {
+				rows = append(rows, []string{account.Profile, account.Registry.Account, "-", "error", account.Error})
+				continue
+			}
+			for _, repo := range account.Repositories {
+				status, detail := string(repo.Status), ""
+				if repo.Error != "" {
+					status, detail = "error", repo.Error
+				}
+				rows = append(rows, []string{account.Profile, account.Registry.Account, repo.Repository, status, detail})
+			}
+		}
+		if err := format.WriteTable(cmd.ErrOrStderr(), []string{"PROFILE", "ACCOUNT", "REPOSITORY", "STATUS", "DETAIL"}, rows); err != nil {
+			cmd.Printf("[ERROR] Failed to print the drift report: %v\n", err)
+		}
+		for _, account := range report.Accounts {
+			for _, repo := range account.Repositories {
+				switch repo.Status {
+				case setlifecyclepolicy.PolicyDrifted:
+					cmd.Printf("[WARN] Account: %s - Repository: %s - Lifecycle policy differs from the reference policy\n", account.Registry.Account, repo.Repository)
+				case setlifecyclepolicy.PolicyMissing:
+					cmd.Printf("[WARN] Account: %s - Repository: %s - No lifecycle policy\n", account.Registry.Account, repo.Repository)
+				}
+			}
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+	},
+}
+
+// --- audits the selected repositories of the account reached through loader, a failure is recorded on the account ---
+func auditAccount(cmd *cobra.Command, loader initawsclient.ConfigLoader, profile string, policyText string) setlifecyclepolicy.AccountDrift {
+	result := setlifecyclepolicy.AccountDrift{Profile: profile}
+	client, account, region, err := initawsclient.NewECRClient(cmd.Context(), loader)
+	if err != nil {
+		cmd.Printf("[ERROR] Failed to initialize AWS client for profile %q: %v\n", profile, err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Registry = awsregistry.Registry{Account: account, Region: region}
+	cmd.Printf("[INFO] Auditing AWS account: %s, region: %s\n", account, region)
+	repos, ok := selectRepositories(cmd, client, "audit")
+	if !ok {
+		return result
+	}
+	result.Repositories = setlifecyclepolicy.AuditPolicy(cmd.Context(), client, policyText, repos, resolveConcurrency(cmd, len(repos)))
+	return result
+}
+
+// --- returns a loader using the given AWS shared config profile instead of --profile, the loader itself when profile is empty ---
+func withProfile(loader initawsclient.ConfigLoader, profile string) initawsclient.ConfigLoader {
+	if profile == "" {
+		return loader
+	}
+	return func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return loader(ctx, append(optFns, config.WithSharedConfigProfile(profile))...)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(auditPolicyCmd)
+
+	auditPolicyCmd.Flags().StringVarP(&policyFile, "policyFile", "f", "", "path to the JSON file containing the reference lifecycle policy, or env:VAR_NAME to read it from an environment variable")
+	auditPolicyCmd.Flags().StringVar(&policyJSON, "policyJson", "", "the reference lifecycle policy as an inline JSON string instead of --policyFile")
+	auditPolicyCmd.Flags().StringSliceVar(&compareAccounts, "compareAccounts", nil, "AWS profiles of the accounts to audit, repeatable or comma-separated, the repository selection applies in each, defaults to the current configuration")
+	auditPolicyCmd.MarkFlagsOneRequired("policyFile", "policyJson")
+	auditPolicyCmd.MarkFlagsMutuallyExclusive("policyFile", "policyJson")
+}
@@ -3,69 +3,731 @@
 package cmd
 
 import (
+	"bufio"
+	"strconv"
 	"strings"
+	"time"
 
+	apimetrics "ecr-lifecycle-cleaner/internal/apiMetrics"
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	"ecr-lifecycle-cleaner/internal/checkpoint"
+	"ecr-lifecycle-cleaner/internal/concurrency"
 	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	ecsprotection "ecr-lifecycle-cleaner/internal/ecsProtection"
+	eksprotection "ecr-lifecycle-cleaner/internal/eksProtection"
+	format "ecr-lifecycle-cleaner/internal/format"
 	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	runstate "ecr-lifecycle-cleaner/internal/runState"
+	"ecr-lifecycle-cleaner/internal/webhook"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/spf13/cobra"
 )
 
+var (
+	minAge               string
+	minAgePerRepoMap     string
+	stepMode             bool
+	skipPolicyManaged    bool
+	repoTagOverrides     bool
+	operationLimits      string
+	checkpointFile       string
+	resume               bool
+	groupByNamespace     bool
+	tagPatternKeep       string
+	tagPatternDelete     string
+	tagAgePolicies       []string
+	saveFailures         string
+	olderThanLatest      bool
+	latestTag            string
+	warnAboveCount       int
+	createdBefore        string
+	deleteEmptyRepos     bool
+	streamDeletion       bool
+	keepPerPrefix        int
+	prefixDelimiter      string
+	stateFile            string
+	pushedAfter          string
+	pushedBefore         string
+	sinceLastRun         bool
+	sinceLastRunOverlap  string
+	dryRunSummaryTable   bool
+	eksNamespaces        []string
+	ecsClusters          []string
+	protectManifestsFile string
+	notPulledSince       string
+	notPulledTagged      bool
+	kubeconfig           string
+	reclaimTarget        string
+	reclaimTargetGlobal  bool
+	listTagStatus        string
+	dropPlatforms        []string
+)
+
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Automates the cleanup of untagged images in ECR.",
 	Long: `Automates the cleanup of untagged images in Amazon Elastic Container Registry (ECR).
 
 It retrieves all repositories, identifies untagged images that are not referenced by any tagged images,
-and deletes those untagged images to help manage storage and maintain a clean registry.`,
+and deletes those untagged images to help manage storage and maintain a clean registry.
+With --tagPatternDelete, tagged images whose tags all match the patterns are deleted in the same pass,
+and --tagPatternKeep protects images with a matching tag, keep wins over delete.
+With --keepNewestPerPrefix, only the newest tagged images of each tag prefix group (e.g. build-*) are kept.
+
+Exit codes: 0 when every repository succeeded, 1 when some repositories failed and others succeeded,
+2 when every processed repository failed. With --failOnPartialSuccess=false a partial failure exits 0.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Println("[INFO] clean called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		opts := deleteuntaggedimages.CleanOptions{DryRun: dryRun, SkipPolicyManaged: skipPolicyManaged, RepoTagOverrides: repoTagOverrides, WarnAboveCount: warnAboveCount, Logs: logs}
+		if warnAboveCount < 0 {
+			cmd.Println("[ERROR] --warnAboveCount must not be negative")
+			exitCode = 1
+			return
+		}
+		if stepMode {
+			opts.Confirm = newStepPrompt(cmd)
+		}
+		if minAge != "" {
+			age, err := deleteuntaggedimages.ParseAge(minAge)
+			if err != nil {
+				cmd.Printf("[ERROR] Invalid --minAge: %v\n", err)
+				exitCode = 1
+				return
+			}
+			opts.MinAge = age
+		}
+		if notPulledSince != "" {
+			age, err := deleteuntaggedimages.ParseAge(notPulledSince)
+			if err != nil || age <= 0 {
+				cmd.Printf("[ERROR] Invalid --notPulledSince: %q must be a positive age\n", notPulledSince)
+				exitCode = 1
+				return
+			}
+			opts.NotPulled = deleteuntaggedimages.NotPulledFilter{Since: age, IncludeTagged: notPulledTagged}
+		} else if notPulledTagged {
+			cmd.Println("[ERROR] --notPulledIncludeTagged requires --notPulledSince")
+			exitCode = 1
+			return
+		}
+		if minAgePerRepoMap != "" {
+			rules, err := deleteuntaggedimages.LoadMinAgeRules(minAgePerRepoMap)
+			if err != nil {
+				cmd.Printf("[ERROR] Reading min age map: %v\n", err)
+				exitCode = 1
+				return
+			}
+			opts.MinAgeRules = rules
+		}
+
+		patterns, err := deleteuntaggedimages.ParseTagPatterns(tagPatternKeep, tagPatternDelete)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid tag patterns: %v\n", err)
+			exitCode = 1
+			return
+		}
+		opts.TagPatterns = patterns
+		if keepPerPrefix < 0 || (keepPerPrefix > 0 && prefixDelimiter == "") {
+			cmd.Println("[ERROR] --keepNewestPerPrefix must not be negative and needs a non-empty --tagPrefixDelimiter")
+			exitCode = 1
+			return
+		}
+		opts.PrefixRetention = deleteuntaggedimages.PrefixRetention{KeepNewest: keepPerPrefix, Delimiter: prefixDelimiter}
+		if len(tagAgePolicies) > 0 {
+			if len(patterns.Delete) == 0 && keepPerPrefix == 0 && !notPulledTagged {
+				cmd.Println("[ERROR] --tagAgePolicy sets the minimum age of tagged images being deleted, it requires --tagPatternDelete, --keepNewestPerPrefix or --notPulledIncludeTagged")
+				exitCode = 1
+				return
+			}
+			if opts.TagAgePolicies, err = deleteuntaggedimages.ParseTagAgePolicies(tagAgePolicies); err != nil {
+				cmd.Printf("[ERROR] Invalid --tagAgePolicy: %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
+		if opts.ListTagStatus, err = deleteuntaggedimages.ParseTagStatusFilter(listTagStatus); err != nil {
+			cmd.Printf("[ERROR] Invalid --listTagStatus: %v\n", err)
+			exitCode = 1
+			return
+		}
+		if opts.DropPlatforms, err = deleteuntaggedimages.ParsePlatforms(dropPlatforms); err != nil {
+			cmd.Printf("[ERROR] Invalid --dropPlatforms: %v\n", err)
+			exitCode = 1
+			return
+		}
+		tagRules := len(patterns.Delete) > 0 || keepPerPrefix > 0 || notPulledTagged
+		if opts.ListTagStatus == deleteuntaggedimages.TagStatusTagged && !tagRules {
+			cmd.Println("[ERROR] --listTagStatus TAGGED lists no orphans, it requires --tagPatternDelete, --keepNewestPerPrefix or --notPulledIncludeTagged")
+			exitCode = 1
+			return
+		}
+		if olderThanLatest {
+			opts.OlderThanTag = latestTag
+		}
+		if reclaimTarget != "" {
+			target, err := format.ParseBytes(reclaimTarget)
+			if err != nil || target <= 0 {
+				cmd.Printf("[ERROR] Invalid --reclaimTarget %q, expected a size such as 50GiB\n", reclaimTarget)
+				exitCode = 1
+				return
+			}
+			opts.ReclaimTarget = deleteuntaggedimages.NewReclaimTarget(target, reclaimTargetGlobal)
+		} else if reclaimTargetGlobal {
+			cmd.Println("[ERROR] --reclaimTargetGlobal requires --reclaimTarget")
+			exitCode = 1
+			return
+		}
 
-		if repoList != "" {
-			repositoryList = strings.Split(repoList, ",")
+		var cutoff time.Time
+		if createdBefore != "" {
+			cutoff, err = deleteuntaggedimages.ParseDate(createdBefore)
+			if err != nil {
+				cmd.Printf("[ERROR] Invalid --reposCreatedBefore: %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
+		for _, bound := range []struct {
+			flag, value string
+			target      *time.Time
+		}{{"--pushedAfter", pushedAfter, &opts.PushedRange.After}, {"--pushedBefore", pushedBefore, &opts.PushedRange.Before}} {
+			if bound.value == "" {
+				continue
+			}
+			if *bound.target, err = deleteuntaggedimages.ParseDate(bound.value); err != nil {
+				cmd.Printf("[ERROR] Invalid %s: %v\n", bound.flag, err)
+				exitCode = 1
+				return
+			}
+		}
+		if err := opts.PushedRange.Validate(); err != nil {
+			cmd.Printf("[ERROR] Invalid --pushedAfter and --pushedBefore: %v\n", err)
+			exitCode = 1
+			return
+		}
+		if deleteEmptyRepos && createdBefore == "" {
+			cmd.Println("[ERROR] --deleteEmptyRepos requires --reposCreatedBefore, so repositories waiting for their first push are never deleted")
+			exitCode = 1
+			return
+		}
+
+		if dryRunSummaryTable && !dryRun && !planOnly {
+			cmd.Println("[ERROR] --dryRunSummaryTable summarizes a plan that is not carried out, it requires --dryRun or --planOnly")
+			exitCode = 1
+			return
+		}
+		if streamDeletion && (stepMode || planOnly || planFile != "" || dryRunSummaryTable || len(eksNamespaces) > 0 || len(ecsClusters) > 0 || protectManifestsFile != "" || reclaimTarget != "" || opts.ListTagStatus != deleteuntaggedimages.TagStatusAny || len(opts.DropPlatforms) > 0 || notPulledTagged || len(patterns.Keep) > 0 || len(patterns.Delete) > 0 || keepPerPrefix > 0) {
+			cmd.Println("[ERROR] --stream deletes without building a plan, it cannot be combined with --step, --planOnly, --planFile, --dryRunSummaryTable, --protectEksNamespace, --protectEcsClusters, --protectManifestsFile, --reclaimTarget, --listTagStatus, --dropPlatforms, --notPulledIncludeTagged, --tagPatternKeep, --tagPatternDelete or --keepNewestPerPrefix")
+			exitCode = 1
+			return
+		}
+
+		if resume && checkpointFile == "" {
+			cmd.Println("[ERROR] --resume requires --checkpointFile")
+			exitCode = 1
+			return
+		}
+		if sinceLastRun && stateFile == "" {
+			cmd.Println("[ERROR] --sinceLastRun requires --stateFile")
+			exitCode = 1
+			return
+		}
+		overlap, err := deleteuntaggedimages.ParseAge(sinceLastRunOverlap)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid --sinceLastRunOverlap: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		limits, err := concurrency.ParseOperationLimits(operationLimits)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid --concurrencyLimitPerOperation: %v\n", err)
+			exitCode = 1
+			return
 		}
 
 		ctx := cmd.Context()
-		client, account, region, err := initawsclient.NewECRClient(ctx, config.LoadDefaultConfig)
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		ecrClient, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
 		if err != nil {
 			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
 			return
 		}
 		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+		opts.Registry = awsregistry.Registry{Account: account, Region: region}
+		client := deleteuntaggedimages.WithOperationLimits(ecrClient, limits)
+		if len(limits) > 0 {
+			cmd.Printf("[INFO] Using per-operation limits: %s\n", limits)
+		}
+
+		if len(eksNamespaces) > 0 {
+			opts.ProtectedDigests, err = eksProtectedDigests(cmd, opts.Registry)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to read the images running in Kubernetes: %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
+		if len(ecsClusters) > 0 {
+			digests, err := ecsProtectedDigests(cmd, metrics, opts.Registry)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to read the images of running ECS tasks: %v\n", err)
+				exitCode = 1
+				return
+			}
+			opts.ProtectedDigests = mergeDigests(opts.ProtectedDigests, digests)
+		}
+		if protectManifestsFile != "" {
+			digests, err := deleteuntaggedimages.LoadProtectedManifests(ctx, client, protectManifestsFile, opts.Registry.URI())
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to read --protectManifestsFile: %v\n", err)
+				exitCode = 1
+				return
+			}
+			cmd.Printf("[INFO] Protecting %d images listed in %s\n", len(digests), protectManifestsFile)
+			opts.ProtectedDigests = mergeDigests(opts.ProtectedDigests, digests)
+		}
 
 		var repos []string
 		if allRepos {
 			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
 			if err != nil {
 				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
 				return
 			}
-		} else if repoPattern != "" {
-			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPattern)
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
 			if err != nil {
 				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
 				return
 			}
 		} else {
 			repos = repositoryList
 		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		if createdBefore != "" {
+			repos, err = applyCreatedBefore(cmd, client, repos, cutoff)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to read repository creation dates: %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
+
+		// --- a resumed run left with nothing to do is done, not misconfigured ---
+		selected := len(repos)
+		if checkpointFile != "" {
+			cp, err := openCheckpoint()
+			if err != nil {
+				cmd.Printf("[ERROR] %v\n", err)
+				exitCode = 1
+				return
+			}
+			if resume {
+				var skipped int
+				repos, skipped = cp.Remaining(repos)
+				cmd.Printf("[INFO] Resuming from %s, skipping %d completed repositories\n", checkpointFile, skipped)
+			}
+			if dryRun {
+				cmd.Println("[INFO] Dry run, the checkpoint file is not updated.")
+			} else {
+				opts.OnRepositoryDone = func(result deleteuntaggedimages.RepositoryCleanResult) {
+					if result.Error != "" || result.Skipped || result.Interrupted {
+						return
+					}
+					if err := cp.MarkDone(result.Repository); err != nil {
+						cmd.Printf("[WARN] Repository: %s - %v\n", result.Repository, err)
+					}
+				}
+			}
+		}
+
+		var state *runstate.State
+		if stateFile != "" {
+			state, err = runstate.Load(stateFile)
+			if err != nil {
+				cmd.Printf("[ERROR] %v\n", err)
+				exitCode = 1
+				return
+			}
+			if sinceLastRun {
+				opts.PushedSince = state.Since(repos, overlap)
+				cmd.Printf("[INFO] Incremental run, %d of %d repositories have an earlier run in %s, the others are scanned in full\n", len(opts.PushedSince), len(repos), stateFile)
+			}
+		}
 
 		if len(repos) == 0 {
-			cmd.Println("[INFO] No repositories to clean.")
+			if selected > 0 {
+				cmd.Println("[INFO] No repositories to clean.")
+				return
+			}
+			noRepositories(cmd, "clean")
 			return
 		}
+		opts.Concurrency = resolveConcurrency(cmd, len(repos))
+		// --- recorded as the last run, so images pushed while this run is listing are looked at again next time ---
+		runStart := time.Now()
+
+		var release func()
+		opts.Stop, release = gracefulStop(cmd)
+		defer release()
 
-		err = deleteuntaggedimages.Main(client, allRepos, repos, repoPattern, dryRun)
+		var report deleteuntaggedimages.CleanReport
+		var plan deleteuntaggedimages.CleanPlan
+		var sizes map[string]int64
+		if streamDeletion {
+			cmd.Println("[INFO] Streaming deletion, images are deleted page by page without a plan")
+			report, err = deleteuntaggedimages.StreamCleanup(ctx, client, repos, opts)
+		} else {
+			plan = deleteuntaggedimages.PlanCleanup(ctx, client, repos, opts)
+			if dryRunSummaryTable {
+				logs.Filter(keepSummaryLog)
+			}
+			flushLogs(cmd, logs)
+			if dryRunSummaryTable {
+				sizes = printDryRunSummary(cmd, client, plan, opts.Concurrency)
+			} else {
+				printCleanPlan(cmd, plan)
+			}
+			writePlanFile(cmd, plan)
+			if planOnly {
+				cmd.Println("[INFO] Plan only, no images were deleted.")
+				return
+			}
+			if sizes == nil {
+				sizes = webhookSizes(cmd, client, plan, opts.Concurrency)
+			}
+			report, err = deleteuntaggedimages.ExecutePlan(ctx, client, plan, opts)
+			if dryRunSummaryTable {
+				logs.Filter(keepSummaryLog)
+			}
+		}
+		flushLogs(cmd, logs)
+		if deleteEmptyRepos && !report.Aborted && !report.Interrupted {
+			if deleteErr := deleteuntaggedimages.DeleteEmptyRepositories(ctx, client, &report, dryRun, logs); deleteErr != nil {
+				cmd.Printf("[ERROR] Failed to delete empty repositories: %v\n", deleteErr)
+			}
+			flushLogs(cmd, logs)
+		}
+		if state != nil {
+			recordRunState(cmd, state, report, runStart)
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		cmd.Printf("[INFO] Made about %d image API calls (ListImages pages, BatchGetImage, BatchDeleteImage and DescribeImages), per repository counts are in the report\n", report.TotalAPICallsEstimate())
+		if groupByNamespace {
+			report.Namespaces = report.ByNamespace()
+			printNamespaceTotals(cmd, report.Namespaces)
+		}
+		writeFailuresFile(cmd, report.Failures())
+		writeOutputFile(cmd, report)
+		notifyWebhook(cmd, cleanWebhookSummary(report, plan, sizes))
+		failed, processed := report.RepositoryOutcomes()
+		setRepositoryExitCode(cmd, failed, processed)
 		if err != nil {
 			cmd.Printf("[ERROR] Failed to clean ECR: %v\n", err)
 			return
 		}
+		if report.Aborted {
+			cmd.Println("[INFO] ECR untagged images cleanup aborted by operator.")
+			return
+		}
+		if reportInterrupted(cmd, report.Interrupted) {
+			return
+		}
 
 		cmd.Println("[INFO] Finished ECR untagged images cleanup.")
 	},
 }
 
+// --- keeps the repositories created before cutoff and lists their creation dates, so stale repositories can be identified ---
+func applyCreatedBefore(cmd *cobra.Command, client deleteuntaggedimages.ECRAPI, repos []string, cutoff time.Time) ([]string, error) {
+	created, err := deleteuntaggedimages.RepositoryCreationTimes(cmd.Context(), client)
+	if err != nil {
+		return nil, err
+	}
+	kept := deleteuntaggedimages.FilterCreatedBefore(repos, created, cutoff)
+	for _, repo := range kept {
+		cmd.Printf("[INFO] Repository: %s - Created %s\n", repo, displayTime(created[repo]).Format(time.DateOnly))
+	}
+	cmd.Printf("[INFO] %d of %d repositories were created before %s\n", len(kept), len(repos), displayTime(cutoff).Format(time.RFC3339))
+	return kept, nil
+}
+
+// --- lists the pods of the --protectEksNamespace namespaces and returns the digests of the registry's images they run ---
+func eksProtectedDigests(cmd *cobra.Command, registry awsregistry.Registry) (map[string]struct{}, error) {
+	k8sClient, err := eksprotection.NewClient(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	digests, err := eksprotection.EKSProtectedDigests(cmd.Context(), k8sClient, eksNamespaces, registry.URI())
+	if err != nil {
+		return nil, err
+	}
+	cmd.Printf("[INFO] Protecting %d images used by pods in namespaces %s\n", len(digests), strings.Join(eksNamespaces, ", "))
+	return digests, nil
+}
+
+// --- lists the running tasks of the --protectEcsClusters clusters and returns the digests of the registry's images they run ---
+func ecsProtectedDigests(cmd *cobra.Command, metrics *apimetrics.Collector, registry awsregistry.Registry) (map[string]struct{}, error) {
+	ecsClient, err := initawsclient.NewECSClient(cmd.Context(), newConfigLoader(metrics))
+	if err != nil {
+		return nil, err
+	}
+	digests, err := ecsprotection.ECSProtectedDigests(cmd.Context(), ecsClient, ecsClusters, registry.URI())
+	if err != nil {
+		return nil, err
+	}
+	cmd.Printf("[INFO] Protecting %d images used by running tasks in ECS clusters %s\n", len(digests), strings.Join(ecsClusters, ", "))
+	return digests, nil
+}
+
+// --- returns the union of two protected digest sets ---
+func mergeDigests(a, b map[string]struct{}) map[string]struct{} {
+	merged := make(map[string]struct{}, len(a)+len(b))
+	for _, set := range []map[string]struct{}{a, b} {
+		for digest := range set {
+			merged[digest] = struct{}{}
+		}
+	}
+	return merged
+}
+
+// --- writes the failed deletions to --saveFailures when set, for the retryFailed command ---
+func writeFailuresFile(cmd *cobra.Command, failures []deleteuntaggedimages.FailedDeletion) {
+	if saveFailures == "" {
+		return
+	}
+	if err := deleteuntaggedimages.SaveFailures(saveFailures, failures); err != nil {
+		cmd.Printf("[ERROR] Failed to write failures: %v\n", err)
+		return
+	}
+	if saveFailures != "-" {
+		cmd.Printf("[INFO] %d failed deletions written to %s\n", len(failures), saveFailures)
+	}
+}
+
+// --- records the start of the run for every repository cleaned without error in --stateFile ---
+// --- dry runs and aborted runs leave the state untouched, their images were not deleted ---
+func recordRunState(cmd *cobra.Command, state *runstate.State, report deleteuntaggedimages.CleanReport, runStart time.Time) {
+	if report.DryRun {
+		cmd.Println("[INFO] Dry run, the state file is not updated.")
+		return
+	}
+	if report.Aborted {
+		return
+	}
+	var cleaned []string
+	for _, repo := range report.Repositories {
+		if repo.Error == "" && !repo.Skipped && !repo.Interrupted {
+			cleaned = append(cleaned, repo.Repository)
+		}
+	}
+	state.Record(cleaned, runStart)
+	if err := state.Save(); err != nil {
+		cmd.Printf("[ERROR] %v\n", err)
+		return
+	}
+	cmd.Printf("[INFO] Recorded the run of %d repositories in %s\n", len(cleaned), stateFile)
+}
+
+// --- loads the checkpoint with --resume, or starts a new one that replaces the file on the first completed repository ---
+func openCheckpoint() (*checkpoint.Checkpoint, error) {
+	if !resume {
+		return checkpoint.New(checkpointFile), nil
+	}
+	return checkpoint.Load(checkpointFile)
+}
+
+// --- prints the pre-flight plan as a table, one row per repository ---
+func printCleanPlan(cmd *cobra.Command, plan deleteuntaggedimages.CleanPlan) {
+	rows := make([][]string, 0, len(plan.Repositories))
+	for _, repo := range plan.Repositories {
+		status := "delete"
+		switch {
+		case repo.Error != "":
+			status = "error"
+		case repo.Skipped:
+			status = "skip"
+		case len(repo.Images) == 0:
+			status = "nothing to do"
+		}
+		rows = append(rows, []string{repo.Repository, strconv.Itoa(repo.Tagged), strconv.Itoa(repo.Untagged), strconv.Itoa(len(repo.Images)), status})
+	}
+	cmd.Printf("[INFO] Plan: %d images to delete across %d repositories\n", plan.TotalImages(), len(plan.Repositories))
+	if err := format.WriteTable(cmd.ErrOrStderr(), []string{"REPOSITORY", "TAGGED", "UNTAGGED", "TO DELETE", "ACTION"}, rows); err != nil {
+		cmd.Printf("[ERROR] Failed to print plan: %v\n", err)
+	}
+}
+
+// --- drops the per-repository info and dry run lines the summary table replaces, warnings and errors are kept ---
+func keepSummaryLog(message string) bool {
+	return !strings.HasPrefix(message, "[INFO]") && !strings.HasPrefix(message, "[DRY RUN]")
+}
+
+// --- prints the plan as a summary table with the reclaimable size of each repository and a totals row, returning the sizes ---
+func printDryRunSummary(cmd *cobra.Command, client deleteuntaggedimages.ECRAPI, plan deleteuntaggedimages.CleanPlan, limit int) map[string]int64 {
+	sizes, errs := deleteuntaggedimages.ReclaimableSizes(cmd.Context(), client, plan, limit)
+	for _, err := range errs {
+		cmd.Printf("[WARN] Could not read image sizes: %v\n", err)
+	}
+	cmd.Printf("[INFO] Plan: %d images to delete across %d repositories\n", plan.TotalImages(), len(plan.Repositories))
+	if err := format.WriteTable(cmd.ErrOrStderr(), []string{"REPOSITORY", "TAGGED", "ORPHANS", "TO DELETE", "RECLAIMABLE"}, dryRunSummaryRows(plan, sizes)); err != nil {
+		cmd.Printf("[ERROR] Failed to print plan summary: %v\n", err)
+	}
+	return sizes
+}
+
+// --- measures the reclaimable sizes of the plan before it is executed, only when the summary goes to --webhookUrl ---
+func webhookSizes(cmd *cobra.Command, client deleteuntaggedimages.ECRAPI, plan deleteuntaggedimages.CleanPlan, limit int) map[string]int64 {
+	if webhookURL == "" {
+		return nil
+	}
+	sizes, errs := deleteuntaggedimages.ReclaimableSizes(cmd.Context(), client, plan, limit)
+	for _, err := range errs {
+		cmd.Printf("[WARN] Could not read image sizes: %v\n", err)
+	}
+	return sizes
+}
+
+// --- returns the webhook summary of a clean, empty or retryFailed report, the reclaimed size is left out when sizes were not measured ---
+func cleanWebhookSummary(report deleteuntaggedimages.CleanReport, plan deleteuntaggedimages.CleanPlan, sizes map[string]int64) webhook.Summary {
+	deleted, failed := report.Totals()
+	failedRepos, processed := report.RepositoryOutcomes()
+	summary := webhook.Summary{
+		Account:            report.Registry.Account,
+		Region:             report.Registry.Region,
+		DryRun:             report.DryRun,
+		Interrupted:        report.Interrupted,
+		Repositories:       processed,
+		FailedRepositories: failedRepos,
+		Deleted:            deleted,
+		Failed:             failed,
+	}
+	if sizes != nil {
+		summary.Reclaimed, summary.ReclaimedKnown = report.ReclaimedBytes(plan, sizes), true
+	}
+	if report.DryRun {
+		summary.Deleted = plan.TotalImages()
+	}
+	return summary
+}
+
+// --- returns one row per repository and a totals row, sizes missing from sizes are shown as - and left out of the total ---
+func dryRunSummaryRows(plan deleteuntaggedimages.CleanPlan, sizes map[string]int64) [][]string {
+	rows := make([][]string, 0, len(plan.Repositories)+1)
+	var tagged, orphans, toDelete int
+	var reclaimable int64
+	for _, repo := range plan.Repositories {
+		size := "-"
+		if bytes, ok := sizes[repo.Repository]; ok {
+			size = format.HumanBytes(bytes)
+			reclaimable += bytes
+		}
+		switch {
+		case repo.Error != "":
+			size = "error"
+		case repo.Skipped:
+			size = "skipped"
+		}
+		rows = append(rows, []string{repo.Repository, strconv.Itoa(repo.Tagged), strconv.Itoa(repo.Untagged), strconv.Itoa(len(repo.Images)), size})
+		tagged += repo.Tagged
+		orphans += repo.Untagged
+		toDelete += len(repo.Images)
+	}
+	return append(rows, []string{"TOTAL", strconv.Itoa(tagged), strconv.Itoa(orphans), strconv.Itoa(toDelete), format.HumanBytes(reclaimable)})
+}
+
+// --- prints the per-namespace rollup as a table ---
+func printNamespaceTotals(cmd *cobra.Command, totals []deleteuntaggedimages.NamespaceTotals) {
+	rows := make([][]string, 0, len(totals))
+	for _, ns := range totals {
+		name := ns.Namespace
+		if name == "" {
+			name = "(none)"
+		}
+		rows = append(rows, []string{name, strconv.Itoa(ns.Repositories), strconv.Itoa(ns.Deleted), strconv.Itoa(ns.Failed), strconv.Itoa(ns.Errors)})
+	}
+	if err := format.WriteTable(cmd.ErrOrStderr(), []string{"NAMESPACE", "REPOSITORIES", "DELETED", "FAILED", "ERRORS"}, rows); err != nil {
+		cmd.Printf("[ERROR] Failed to print namespace summary: %v\n", err)
+	}
+}
+
+// --- returns a prompt that asks the operator on stdin whether to delete a repository's images ---
+func newStepPrompt(cmd *cobra.Command) func(repo string, images []string) deleteuntaggedimages.StepDecision {
+	reader := bufio.NewReader(cmd.InOrStdin())
+	return func(repo string, images []string) deleteuntaggedimages.StepDecision {
+		for {
+			cmd.Printf("[STEP] Repository: %s - Delete %d untagged images? [y]es/[s]kip/[a]bort: ", repo, len(images))
+			answer, err := reader.ReadString('\n')
+			if err != nil && answer == "" {
+				cmd.Println()
+				return deleteuntaggedimages.StepAbort
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				return deleteuntaggedimages.StepProceed
+			case "s", "skip", "n", "no":
+				return deleteuntaggedimages.StepSkip
+			case "a", "abort", "q", "quit":
+				return deleteuntaggedimages.StepAbort
+			}
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().BoolVar(&skipPolicyManaged, "skipPolicyManaged", false, "skip repositories whose lifecycle policy already expires untagged images")
+	cleanCmd.Flags().BoolVar(&repoTagOverrides, "repoTagOverrides", false, "let repositories skip cleanup or override --minAge through their ecr-cleaner:skip=true and ecr-cleaner:minAge=90d resource tags (needs ecr:ListTagsForResource)")
+	cleanCmd.Flags().StringVar(&operationLimits, "concurrencyLimitPerOperation", "", "cap calls in flight per ECR operation on top of --maxConcurrency, in the planning phase and dry runs too (ListImages, BatchGetImage, BatchDeleteImage, DescribeImages, GetLifecyclePolicy, e.g. ListImages=10,BatchGetImage=3,DescribeImages=3)")
+	cleanCmd.Flags().StringVar(&checkpointFile, "checkpointFile", "", "record each repository as it finishes in this JSON file, so an interrupted run can be resumed")
+	cleanCmd.Flags().BoolVar(&resume, "resume", false, "skip repositories already completed according to --checkpointFile")
+	cleanCmd.Flags().BoolVar(&groupByNamespace, "groupByNamespace", false, "print deleted and failed totals per namespace (the part of the repository name before the first /)")
+	cleanCmd.Flags().BoolVar(&stepMode, "step", false, "process repositories one at a time and confirm each deletion interactively")
+	cleanCmd.Flags().StringVar(&tagPatternKeep, "tagPatternKeep", "", "comma-separated tag globs (e.g. release-*) whose images are never deleted, wins over --tagPatternDelete")
+	cleanCmd.Flags().StringVar(&reclaimTarget, "reclaimTarget", "", "delete only the oldest untagged images of each repository until their sizes add up to this (e.g. 50GiB or 500MB), instead of every untagged image")
+	cleanCmd.Flags().StringVar(&listTagStatus, "listTagStatus", "ANY", "images ListImages lists per repository: ANY lists untagged and tagged images in two filtered passes, TAGGED lists only tagged images and deletes only by tag rules, tagged images are always listed so the children of multi-arch images stay protected")
+	cleanCmd.Flags().StringSliceVar(&dropPlatforms, "dropPlatforms", nil, "platforms (os/architecture[/variant], e.g. linux/arm/v7) whose images in tagged multi-arch indexes are no longer protected and deleted like untagged images, the other platforms are kept, the index then fails to pull for them")
+	cleanCmd.Flags().BoolVar(&reclaimTargetGlobal, "reclaimTargetGlobal", false, "make --reclaimTarget one budget for the whole run instead of one per repository, repositories draw from it as they are planned")
+	cleanCmd.Flags().StringSliceVar(&tagAgePolicies, "tagAgePolicy", nil, "pattern:age pairs (e.g. release-*:90d,pr-*:1d) setting the minimum age of tagged images being deleted whose tags match the glob, used instead of --minAge for them, the longest matching age wins")
+	cleanCmd.Flags().StringVar(&tagPatternDelete, "tagPatternDelete", "", "comma-separated tag globs (e.g. tmp-*) whose images are deleted along with the orphans, an image is only deleted when all its tags match")
+	cleanCmd.Flags().IntVar(&keepPerPrefix, "keepNewestPerPrefix", 0, "group tagged images by the part of their tags before --tagPrefixDelimiter (build-41 and build-42 form the build group), keep this many newest per group and delete the older ones, images with a tag outside any group or matching --tagPatternKeep are kept, 0 disables")
+	cleanCmd.Flags().StringVar(&prefixDelimiter, "tagPrefixDelimiter", "-", "separates the group prefix from the rest of a tag for --keepNewestPerPrefix, the first occurrence counts")
+	cleanCmd.Flags().BoolVar(&olderThanLatest, "deleteOlderThanLatestTag", false, "keep the image tagged --latestTag and its children, delete only untagged images pushed before it, repositories without the tag are left alone")
+	cleanCmd.Flags().StringVar(&latestTag, "latestTag", "latest", "tag used by --deleteOlderThanLatestTag")
+	cleanCmd.Flags().BoolVar(&dryRunSummaryTable, "dryRunSummaryTable", false, "print the plan of a --dryRun or --planOnly run as one table with the tagged, orphan and to-delete counts and the reclaimable size per repository and a totals row, instead of the per-repository log lines")
+	cleanCmd.Flags().StringSliceVar(&eksNamespaces, "protectEksNamespace", nil, "never delete images of this registry that pods in these Kubernetes namespaces pin by digest or are running, repeatable or comma-separated (needs list access to pods)")
+	cleanCmd.Flags().StringVar(&protectManifestsFile, "protectManifestsFile", "", "never delete the images listed in this file, one per line: a digest, a reference pinned by digest, or repository:tag resolved in this registry, e.g. base images other systems build on")
+	cleanCmd.Flags().StringSliceVar(&ecsClusters, "protectEcsClusters", nil, "never delete images of this registry that running tasks in these ECS clusters use, repeatable or comma-separated (needs ecs:ListTasks and ecs:DescribeTasks)")
+	cleanCmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig used by --protectEksNamespace, defaults to the in-cluster service account when running in a pod, then $KUBECONFIG or ~/.kube/config")
+	cleanCmd.Flags().StringVar(&saveFailures, "saveFailures", "", "write the images that failed to delete as JSON to this file, to retry them with retryFailed")
+	cleanCmd.Flags().IntVar(&warnAboveCount, "warnAboveCount", 0, "warn about repositories holding more images (tagged and untagged) than this, also in dry runs and with --planOnly, 0 disables the check")
+	cleanCmd.Flags().StringVar(&createdBefore, "reposCreatedBefore", "", "only clean repositories created before this date (YYYY-MM-DD or RFC 3339), their creation dates are listed")
+	cleanCmd.Flags().BoolVar(&deleteEmptyRepos, "deleteEmptyRepos", false, "delete repositories the cleanup left without any image, requires --reposCreatedBefore")
+	cleanCmd.Flags().BoolVar(&streamDeletion, "stream", false, "delete untagged images page by page as they are confirmed orphans instead of planning first, keeps memory flat on repositories with millions of images, no plan is printed")
+	cleanCmd.Flags().StringVar(&pushedAfter, "pushedAfter", "", "only delete images pushed at or after this date (YYYY-MM-DD or RFC 3339), untagged and tagged alike, combine with --pushedBefore to clean a build window")
+	cleanCmd.Flags().StringVar(&pushedBefore, "pushedBefore", "", "only delete images pushed before this date (YYYY-MM-DD or RFC 3339), untagged and tagged alike")
+	cleanCmd.Flags().StringVar(&stateFile, "stateFile", "", "record when each repository was last cleaned successfully in this JSON file, read by --sinceLastRun, dry runs do not update it")
+	cleanCmd.Flags().BoolVar(&sinceLastRun, "sinceLastRun", false, "only evaluate untagged images pushed since the repository's last run in --stateFile, repositories without a recorded run are scanned in full")
+	cleanCmd.Flags().StringVar(&sinceLastRunOverlap, "sinceLastRunOverlap", "1h", "safety overlap subtracted from the last run for --sinceLastRun (e.g. 30m or 1d)")
+	cleanCmd.Flags().StringVar(&minAge, "minAge", "", "only delete untagged images older than this age (e.g. 36h or 7d)")
+	cleanCmd.Flags().StringVar(&notPulledSince, "notPulledSince", "", "only delete untagged images not pulled within this age (e.g. 90d), images never pulled count from their push date (needs ecr:DescribeImages)")
+	cleanCmd.Flags().BoolVar(&notPulledTagged, "notPulledIncludeTagged", false, "with --notPulledSince, also delete tagged images not pulled within the age, images with a tag matching --tagPatternKeep and the children of kept images are kept")
+	cleanCmd.Flags().StringVar(&minAgePerRepoMap, "minAgePerRepoMap", "", "path to a JSON file of [{\"pattern\": \"^prod-.*\", \"minAge\": \"30d\"}] rules overriding --minAge per repository, first match wins")
 }
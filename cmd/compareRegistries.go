@@ -0,0 +1,130 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"context"
+
+	compareregistries "ecr-lifecycle-cleaner/internal/compareRegistries"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sourceRegion     string
+	targetRegion     string
+	targetRegistryID string
+)
+
+var compareRegistriesCmd = &cobra.Command{
+	Use:   "compareRegistries",
+	Short: "Finds images present in a source registry but missing from a target registry.",
+	Long: `Finds images present in a source registry but missing from a target registry.
+
+Useful to verify replication during registry migrations. For each selected source repository it compares
+the image digests with the repository of the same name in the target region or account, and reports
+repositories missing from the target, repositories missing images and repositories fully in sync. Nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] compareRegistries called")
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		sourceClient, account, region, err := initawsclient.NewECRClient(ctx, withRegion(newConfigLoader(metrics), sourceRegion))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Source: AWS account %s, region %s\n", account, region)
+
+		targetClient, _, resolvedTargetRegion, err := initawsclient.NewECRClient(ctx, withRegion(newConfigLoader(metrics), targetRegion))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client for the target: %v\n", err)
+			exitCode = 1
+			return
+		}
+		targetAccount := targetRegistryID
+		if targetAccount == "" {
+			targetAccount = account
+		}
+		cmd.Printf("[INFO] Target: AWS account %s, region %s\n", targetAccount, resolvedTargetRegion)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, sourceClient)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, sourceClient, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "compare")
+			return
+		}
+
+		diff, err := compareregistries.Compare(ctx,
+			compareregistries.Registry{Client: sourceClient},
+			compareregistries.Registry{Client: targetClient, RegistryID: targetRegistryID},
+			repos, resolveConcurrency(cmd, len(repos)))
+		for _, repo := range diff.MissingRepositories {
+			cmd.Printf("[WARN] Repository: %s - Missing from target\n", repo)
+		}
+		for _, repo := range diff.MissingImages {
+			cmd.Printf("[WARN] Repository: %s - %d images missing from target\n", repo.Repository, len(repo.MissingDigests))
+		}
+		cmd.Printf("[INFO] %s\n", diff.Summary())
+		writeOutputFile(cmd, diff)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to compare registries: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished registry comparison.")
+	},
+}
+
+// --- returns loader with the region overridden when one is given ---
+func withRegion(loader initawsclient.ConfigLoader, region string) initawsclient.ConfigLoader {
+	if region == "" {
+		return loader
+	}
+	return func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return loader(ctx, append(optFns, config.WithRegion(region))...)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(compareRegistriesCmd)
+
+	compareRegistriesCmd.Flags().StringVar(&sourceRegion, "sourceRegion", "", "region of the source registry, defaults to the configured region")
+	compareRegistriesCmd.Flags().StringVar(&targetRegion, "targetRegion", "", "region of the target registry, defaults to the configured region")
+	compareRegistriesCmd.Flags().StringVar(&targetRegistryID, "targetRegistryId", "", "account id of the target registry when it lives in another account")
+	compareRegistriesCmd.MarkFlagsOneRequired("targetRegion", "targetRegistryId")
+}
@@ -0,0 +1,142 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"bufio"
+	"strings"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/spf13/cobra"
+)
+
+var emptyYes bool
+
+var emptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Deletes every image, tagged and untagged, from the selected repositories.",
+	Long: `Deletes every image, tagged and untagged, from the selected repositories in Amazon Elastic Container Registry (ECR).
+
+Use it to empty repositories before decommissioning them. Unlike clean it does not keep tagged images,
+so it requires --yes and asks to type each repository name before deleting its images.
+Repositories whose name is not typed back are skipped. With --dryRun nothing is asked or deleted.
+
+Exit codes: 0 when every repository succeeded, 1 when some repositories failed and others succeeded,
+2 when every processed repository failed. With --failOnPartialSuccess=false a partial failure exits 0.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] empty called")
+		if !emptyYes {
+			cmd.Println("[ERROR] Emptying repositories requires --yes")
+			exitCode = 1
+			return
+		}
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "empty")
+			return
+		}
+
+		opts := deleteuntaggedimages.CleanOptions{DryRun: dryRun, Logs: logs, Concurrency: resolveConcurrency(cmd, len(repos)), Registry: awsregistry.Registry{Account: account, Region: region}}
+		plan := deleteuntaggedimages.PlanEmpty(ctx, client, repos, opts)
+		flushLogs(cmd, logs)
+		printCleanPlan(cmd, plan)
+		writePlanFile(cmd, plan)
+		if planOnly {
+			cmd.Println("[INFO] Plan only, no images were deleted.")
+			return
+		}
+		if !dryRun {
+			opts.Confirm = newRepositoryNamePrompt(cmd)
+		}
+
+		var release func()
+		opts.Stop, release = gracefulStop(cmd)
+		defer release()
+		sizes := webhookSizes(cmd, client, plan, opts.Concurrency)
+		report, err := deleteuntaggedimages.ExecutePlan(ctx, client, plan, opts)
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		notifyWebhook(cmd, cleanWebhookSummary(report, plan, sizes))
+		failed, processed := report.RepositoryOutcomes()
+		setRepositoryExitCode(cmd, failed, processed)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to empty repositories: %v\n", err)
+			return
+		}
+		if reportInterrupted(cmd, report.Interrupted) {
+			return
+		}
+
+		cmd.Println("[INFO] Finished emptying repositories.")
+	},
+}
+
+// --- asks the operator to type the repository name before its images are deleted, anything else skips it ---
+func newRepositoryNamePrompt(cmd *cobra.Command) func(repo string, images []string) deleteuntaggedimages.StepDecision {
+	reader := bufio.NewReader(cmd.InOrStdin())
+	return func(repo string, images []string) deleteuntaggedimages.StepDecision {
+		cmd.Printf("[CONFIRM] Repository: %s - Delete ALL %d images? Type the repository name to confirm: ", repo, len(images))
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			cmd.Println()
+			return deleteuntaggedimages.StepAbort
+		}
+		if strings.TrimSpace(answer) != repo {
+			return deleteuntaggedimages.StepSkip
+		}
+		return deleteuntaggedimages.StepProceed
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(emptyCmd)
+
+	emptyCmd.Flags().BoolVar(&emptyYes, "yes", false, "confirm that every image of the selected repositories, including tagged ones, should be deleted")
+	emptyCmd.MarkFlagRequired("yes") // nolint:errcheck
+}
@@ -0,0 +1,96 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	tagimmutability "ecr-lifecycle-cleaner/internal/tagImmutability"
+
+	"github.com/spf13/cobra"
+)
+
+var makeMutable bool
+
+var enforceTagImmutabilityCmd = &cobra.Command{
+	Use:   "enforceTagImmutability",
+	Short: "Enables tag immutability on the selected repositories.",
+	Long: `Enables tag immutability on the selected repositories in Amazon Elastic Container Registry (ECR).
+
+Immutable tags cannot be overwritten by a later push. Repositories that are already immutable, including
+IMMUTABLE_WITH_EXCLUSION, are left untouched. Each repository is reported as ALREADY_IMMUTABLE, MADE_IMMUTABLE or FAILED.
+With --makeMutable the repositories are made mutable instead, e.g. for dev repositories that reuse tags.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] enforceTagImmutability called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "update")
+			return
+		}
+
+		report, err := tagimmutability.Enforce(ctx, client, repos, tagimmutability.Options{
+			Mutable:     makeMutable,
+			DryRun:      dryRun,
+			Concurrency: resolveConcurrency(cmd, len(repos)),
+			Logs:        logs,
+		})
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to enforce tag mutability: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished enforcing tag mutability.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(enforceTagImmutabilityCmd)
+
+	enforceTagImmutabilityCmd.Flags().BoolVar(&makeMutable, "makeMutable", false, "make the selected repositories mutable instead, e.g. for dev repositories")
+}
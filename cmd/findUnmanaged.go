@@ -0,0 +1,107 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	warnThresholdDays int
+	unmanagedMinCount int
+)
+
+var findUnmanagedCmd = &cobra.Command{
+	Use:   "findUnmanaged",
+	Short: "Finds repositories accumulating images with nothing expiring them.",
+	Long: `Finds repositories in Amazon Elastic Container Registry (ECR) that are likely accumulating cost.
+
+A repository is reported when its lifecycle policy does not expire untagged images (or it has no policy),
+it holds more than --minImages images and its oldest untagged image was pushed more than --warnThresholdDays days ago,
+meaning it has not been cleaned in that time. Nothing is changed, use setPolicy or clean on the reported repositories.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] findUnmanaged called")
+		if warnThresholdDays < 0 || unmanagedMinCount < 0 {
+			cmd.Println("[ERROR] --warnThresholdDays and --minImages must not be negative")
+			exitCode = 1
+			return
+		}
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "check")
+			return
+		}
+
+		report := deleteuntaggedimages.FindUnmanaged(ctx, client, repos, deleteuntaggedimages.UnmanagedOptions{
+			MinImages:     unmanagedMinCount,
+			ThresholdDays: warnThresholdDays,
+			Concurrency:   resolveConcurrency(cmd, len(repos)),
+			Location:      displayLocation,
+			Registry:      awsregistry.Registry{Account: account, Region: region},
+			Logs:          logs,
+		})
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		if len(report.Errors) > 0 {
+			cmd.Printf("[ERROR] Failed to check %d repositories\n", len(report.Errors))
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished checking for unmanaged repositories.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(findUnmanagedCmd)
+
+	findUnmanagedCmd.Flags().IntVar(&warnThresholdDays, "warnThresholdDays", 30, "report repositories whose oldest untagged image is older than this many days, 0 reports on the image count alone")
+	findUnmanagedCmd.Flags().IntVar(&unmanagedMinCount, "minImages", 100, "report repositories holding more than this many images, tagged and untagged")
+}
@@ -0,0 +1,73 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strings"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	format "ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+
+	"github.com/spf13/cobra"
+)
+
+var tagFilter string
+
+var getPolicyCmd = &cobra.Command{
+	Use:   "getPolicy",
+	Short: "Lists the lifecycle policies of repositories.",
+	Long: `Lists the lifecycle policies of repositories in Amazon Elastic Container Registry (ECR), one line per rule.
+
+With --tagFilter only repositories with a rule selecting images by that tag prefix are listed, through tagPrefixList
+or a tagPatternList entry prefix*, e.g. to find which repositories have a rule for pr-* images.
+Nothing is changed, --outputFile writes the full policy text of every listed repository.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] getPolicy called")
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		repos, ok := selectRepositories(cmd, client, "list")
+		if !ok {
+			return
+		}
+
+		report := setlifecyclepolicy.ListPolicies(ctx, client, repos, tagFilter, resolveConcurrency(cmd, len(repos)))
+		report.Registry = awsregistry.Registry{Account: account, Region: region}
+		rows := make([][]string, 0, len(report.Repositories))
+		for _, repo := range report.Repositories {
+			rules := strings.Join(repo.Rules, ", ")
+			if repo.Policy == "" {
+				rules = "no lifecycle policy"
+			}
+			rows = append(rows, []string{repo.Repository, rules})
+		}
+		if len(rows) > 0 {
+			if err := format.WriteTable(cmd.ErrOrStderr(), []string{"REPOSITORY", "RULES"}, rows); err != nil {
+				cmd.Printf("[ERROR] Failed to print policies: %v\n", err)
+			}
+		}
+		for _, failure := range report.Failed {
+			cmd.Printf("[ERROR] Repository: %s - %s\n", failure.Repository, failure.Message)
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(getPolicyCmd)
+
+	getPolicyCmd.Flags().StringVar(&tagFilter, "tagFilter", "", "only list repositories with a rule selecting images by this tag prefix, e.g. pr- or pr-*")
+}
@@ -0,0 +1,112 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strconv"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	"ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Classifies the orphaned images of repositories by why they are orphaned.",
+	Long: `Inspects the orphaned images in Amazon Elastic Container Registry (ECR) repositories, the untagged images no tagged image references.
+
+Each orphan is classified as:
+  never-tagged      pushed without a tag and never pulled, e.g. a build cache or a push by digest
+  tag-moved         pulled at some point, most likely under a tag that has since moved to a newer image
+  multi-arch-child  a platform image of an untagged multi-arch image index
+
+ECR keeps no tag history, so tag-moved and never-tagged are told apart by whether the image was ever pulled.
+Nothing is changed, the classification helps decide how aggressively to clean.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] inspect called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var repos []string
+		if allRepos {
+			repos, err = deleteuntaggedimages.ListRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = deleteuntaggedimages.ListRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "inspect")
+			return
+		}
+
+		report := deleteuntaggedimages.InspectOrphans(ctx, client, repos, deleteuntaggedimages.InspectOptions{
+			Concurrency: resolveConcurrency(cmd, len(repos)),
+			Registry:    awsregistry.Registry{Account: account, Region: region},
+			Logs:        logs,
+		})
+		flushLogs(cmd, logs)
+		if len(report.Repositories) > 0 {
+			if err := format.WriteTable(cmd.ErrOrStderr(), []string{"REPOSITORY", "NEVER-TAGGED", "TAG-MOVED", "MULTI-ARCH-CHILD"}, inspectRows(report)); err != nil {
+				cmd.Printf("[ERROR] Failed to print classification: %v\n", err)
+			}
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		if len(report.Errors) > 0 {
+			cmd.Printf("[ERROR] Failed to inspect %d repositories\n", len(report.Errors))
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished inspecting orphaned images.")
+	},
+}
+
+// --- returns the orphan counts of each repository by class ---
+func inspectRows(report deleteuntaggedimages.InspectReport) [][]string {
+	rows := make([][]string, 0, len(report.Repositories))
+	for _, repo := range report.Repositories {
+		rows = append(rows, []string{repo.Repository, strconv.Itoa(repo.NeverTagged), strconv.Itoa(repo.TagMoved), strconv.Itoa(repo.MultiArchChild)})
+	}
+	return rows
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
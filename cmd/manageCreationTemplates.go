@@ -0,0 +1,167 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strings"
+
+	creationtemplates "ecr-lifecycle-cleaner/internal/creationTemplates"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templatePrefix      string
+	templateAppliedFor  string
+	templateDescription string
+)
+
+var manageCreationTemplatesCmd = &cobra.Command{
+	Use:   "manageCreationTemplates",
+	Short: "Applies a lifecycle policy to new repositories through a repository creation template.",
+	Long: `Applies a lifecycle policy to new repositories through an Amazon ECR repository creation template.
+
+ECR applies the template to repositories it creates on push, for pull through cache rules or for replication
+whose name starts with --repoPrefix (ROOT matches every repository no other template matches).
+The template is created when missing, otherwise its lifecycle policy and --appliedFor list are updated,
+its other settings are kept. Existing repositories are not changed, use setPolicy for those.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] manageCreationTemplates called")
+
+		ctx := cmd.Context()
+		if err := creationtemplates.ValidatePrefix(templatePrefix); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		appliedFor, err := creationtemplates.ParseAppliedFor(templateAppliedFor)
+		if err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		policyText, err := readpolicyfile.ReadPolicyFile(policyFile)
+		if err != nil {
+			cmd.Printf("[ERROR] Reading policy file: %v\n", err)
+			exitCode = 1
+			return
+		}
+		if strictJSON {
+			if err := readpolicyfile.ValidateStrict(policyText); err != nil {
+				cmd.Printf("[ERROR] %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
+
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		result, err := creationtemplates.Apply(ctx, client, creationtemplates.ApplyOptions{
+			Prefix:      templatePrefix,
+			PolicyText:  policyText,
+			AppliedFor:  appliedFor,
+			Description: templateDescription,
+			DryRun:      dryRun,
+		})
+		writeOutputFile(cmd, result)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to apply repository creation template: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		summary := setlifecyclepolicy.SummarizePolicy(policyText)
+		switch {
+		case result.Action == creationtemplates.ActionUnchanged:
+			cmd.Printf("[INFO] Template: %s - Already applies this policy, nothing to change\n", templatePrefix)
+		case dryRun:
+			cmd.Printf("[DRY RUN] Template: %s - Would be %s, applied for %s. %s\n", templatePrefix, result.Action, joinAppliedFor(appliedFor), summary)
+		default:
+			cmd.Printf("[INFO] Template: %s - Template %s, applied for %s. %s\n", templatePrefix, result.Action, joinAppliedFor(appliedFor), summary)
+		}
+
+		cmd.Println("[INFO] Finished managing repository creation templates.")
+	},
+}
+
+var describeCreationTemplatesCmd = &cobra.Command{
+	Use:   "describeCreationTemplates",
+	Short: "Lists the repository creation templates and their lifecycle policies.",
+	Long: `Lists the Amazon ECR repository creation templates, or only the one for --repoPrefix, with the kinds of
+repository creation they apply to and a summary of their lifecycle policy. Nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] describeCreationTemplates called")
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		var prefixes []string
+		if templatePrefix != "" {
+			prefixes = append(prefixes, templatePrefix)
+		}
+		templates, err := creationtemplates.Describe(ctx, client, prefixes...)
+		if err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		for i := range templates {
+			templates[i].CreatedAt = displayTime(templates[i].CreatedAt)
+			templates[i].UpdatedAt = displayTime(templates[i].UpdatedAt)
+		}
+		for _, t := range templates {
+			policy := "no lifecycle policy"
+			if t.LifecyclePolicy != "" {
+				policy = setlifecyclepolicy.SummarizePolicy(t.LifecyclePolicy).String()
+			}
+			cmd.Printf("[INFO] Template: %s - Applied for %s, tag mutability %s. %s\n", t.Prefix, joinAppliedFor(t.AppliedFor), t.ImageTagMutability, policy)
+		}
+		cmd.Printf("[INFO] Found %d repository creation templates\n", len(templates))
+		writeOutputFile(cmd, templates)
+	},
+}
+
+// --- returns the applied-for list as CREATE_ON_PUSH, REPLICATION ---
+func joinAppliedFor(appliedFor []types.RCTAppliedFor) string {
+	names := make([]string, len(appliedFor))
+	for i, kind := range appliedFor {
+		names[i] = string(kind)
+	}
+	return strings.Join(names, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(manageCreationTemplatesCmd)
+	manageCreationTemplatesCmd.AddCommand(describeCreationTemplatesCmd)
+
+	manageCreationTemplatesCmd.Flags().StringVarP(&policyFile, "policyFile", "f", "", "path to the JSON file containing the lifecycle policy for new repositories, or env:VAR_NAME to read it from an environment variable")
+	manageCreationTemplatesCmd.Flags().BoolVar(&strictJSON, "strictJson", false, "reject policies with unknown or wrongly cased field names (e.g. rulePriorty or Rules), which ECR silently ignores")
+	manageCreationTemplatesCmd.Flags().StringVar(&templateAppliedFor, "appliedFor", "CREATE_ON_PUSH,PULL_THROUGH_CACHE,REPLICATION", "comma-separated kinds of repository creation the template applies to: CREATE_ON_PUSH, PULL_THROUGH_CACHE, REPLICATION")
+	manageCreationTemplatesCmd.Flags().StringVar(&templateDescription, "description", "", "description of the template, an existing description is kept when empty")
+	manageCreationTemplatesCmd.Flags().StringVar(&templatePrefix, "repoPrefix", "", "repository name prefix the template applies to, e.g. team or team/app, ROOT for every repository no other template matches")
+	describeCreationTemplatesCmd.Flags().StringVar(&templatePrefix, "repoPrefix", "", "only describe the template for this prefix")
+	manageCreationTemplatesCmd.MarkFlagRequired("policyFile") // nolint:errcheck
+	manageCreationTemplatesCmd.MarkFlagRequired("repoPrefix") // nolint:errcheck
+}
@@ -0,0 +1,93 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	orgaccounts "ecr-lifecycle-cleaner/internal/orgAccounts"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	useOrg            bool
+	excludeAccountIDs []string
+	orgRoleName       string
+	// --- role the AWS config loader assumes for every call, set per member account in --useOrg runs ---
+	assumeRoleARN string
+)
+
+// --- wraps the run of a command so that with --useOrg it runs once in every active account of the organization ---
+// --- a member account is entered by assuming --orgRoleName in it, the account running the tool uses its own credentials ---
+func acrossOrganization(run func(cmd *cobra.Command, args []string)) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if !useOrg {
+			if len(excludeAccountIDs) > 0 || cmd.Flags().Changed("orgRoleName") {
+				cmd.Println("[ERROR] --excludeAccountId and --orgRoleName require --useOrg")
+				exitCode = 1
+				return
+			}
+			run(cmd, args)
+			return
+		}
+		ctx := cmd.Context()
+		client, caller, region, err := initawsclient.NewOrganizationsClient(ctx, newConfigLoader(nil))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS Organizations client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		accounts, err := orgaccounts.OrgAccountLister(ctx, client)
+		if err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		accounts = excludeAccounts(cmd, accounts)
+		if len(accounts) == 0 {
+			cmd.Println("[INFO] No accounts of the organization to run in.")
+			return
+		}
+
+		cmd.Printf("[INFO] Running %s in %d accounts of the organization\n", cmd.Name(), len(accounts))
+		defer func() { assumeRoleARN = "" }()
+		for i, account := range accounts {
+			assumeRoleARN = ""
+			if account != caller {
+				assumeRoleARN = orgaccounts.RoleARN(region, account, orgRoleName)
+			}
+			cmd.Printf("[INFO] Account %s (%d of %d)\n", account, i+1, len(accounts))
+			run(cmd, args)
+		}
+	}
+}
+
+// --- drops the --excludeAccountId accounts, warning about excluded accounts that are not in the organization ---
+func excludeAccounts(cmd *cobra.Command, accounts []string) []string {
+	excluded := make(map[string]bool, len(excludeAccountIDs))
+	for _, id := range excludeAccountIDs {
+		excluded[id] = true
+	}
+	kept := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		if excluded[account] {
+			delete(excluded, account)
+			continue
+		}
+		kept = append(kept, account)
+	}
+	for _, id := range excludeAccountIDs {
+		if excluded[id] {
+			cmd.Printf("[WARN] Account %s in --excludeAccountId is not an active account of the organization\n", id)
+		}
+	}
+	return kept
+}
+
+func init() {
+	for _, c := range []*cobra.Command{cleanCmd, setPolicyCmd} {
+		c.Flags().BoolVar(&useOrg, "useOrg", false, "run in every active account of the AWS Organization, listed with organizations:ListAccounts, entering each member account by assuming --orgRoleName")
+		c.Flags().StringSliceVar(&excludeAccountIDs, "excludeAccountId", nil, "account IDs to skip with --useOrg, e.g. the management account, repeatable or comma-separated")
+		c.Flags().StringVar(&orgRoleName, "orgRoleName", "OrganizationAccountAccessRole", "name of the role assumed in each member account with --useOrg")
+	}
+}
@@ -0,0 +1,102 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	promoteimage "ecr-lifecycle-cleaner/internal/promoteImage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteImageRef string
+	promoteEnv      string
+	previousEnv     string
+	removePrevTag   bool
+	normalizeDigest bool
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promotes an image to an environment by tagging it.",
+	Long: `Promotes an image to an environment by tagging it in Amazon Elastic Container Registry (ECR).
+
+It verifies the image carries the previous environment tag, adds the environment tag to the same digest
+and records the promotion time and caller in the repository tag ecr-lifecycle-cleaner/promoted-to-<env>.
+With --removePrevTag the previous environment tag is removed afterwards.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] promote called")
+
+		parseImageRef := promoteimage.ParseImageRef
+		if normalizeDigest {
+			parseImageRef = promoteimage.ParseImageRefNormalized
+		}
+		repository, digest, err := parseImageRef(promoteImageRef)
+		if err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		loader := newConfigLoader(metrics)
+		client, account, region, err := initawsclient.NewECRClient(ctx, loader)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+		callerARN, err := initawsclient.CallerARN(ctx, loader)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to get caller identity: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		result, err := promoteimage.Promote(ctx, client, promoteimage.PromoteOptions{
+			Repository:        repository,
+			Digest:            digest,
+			Env:               promoteEnv,
+			PreviousEnv:       previousEnv,
+			RemovePreviousTag: removePrevTag,
+			DryRun:            dryRun,
+			CallerARN:         callerARN,
+		})
+		writeOutputFile(cmd, result)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to promote image: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		switch {
+		case result.DryRun:
+			cmd.Printf("[DRY RUN] Would promote %s@%s to %s\n", repository, digest, promoteEnv)
+		case result.AlreadyTagged:
+			cmd.Printf("[INFO] %s@%s was already tagged %s, promotion recorded\n", repository, digest, promoteEnv)
+		default:
+			cmd.Printf("[INFO] Promoted %s@%s to %s\n", repository, digest, promoteEnv)
+		}
+		if result.RemovedPreviousTag {
+			cmd.Printf("[INFO] Removed tag %s from %s\n", previousEnv, repository)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().StringVar(&promoteImageRef, "image", "", "image to promote as repository:sha256:<digest>")
+	promoteCmd.Flags().StringVar(&promoteEnv, "env", "", "environment tag to add to the image (e.g. prod)")
+	promoteCmd.Flags().StringVar(&previousEnv, "previousEnv", "", "environment tag the image must already carry (e.g. staging)")
+	promoteCmd.Flags().BoolVar(&removePrevTag, "removePrevTag", false, "remove the --previousEnv tag after promoting")
+	promoteCmd.Flags().BoolVar(&normalizeDigest, "normalizeDigests", false, "accept the --image digest in any pasted form (repo@digest, without sha256:, quoted or uppercase) and validate it as sha256:<64 hex characters>")
+	promoteCmd.MarkFlagRequired("image") // nolint:errcheck
+	promoteCmd.MarkFlagRequired("env")   // nolint:errcheck
+	promoteCmd.MarkFlagsRequiredTogether("removePrevTag", "previousEnv")
+}
@@ -0,0 +1,80 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strconv"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	"ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/spf13/cobra"
+)
+
+var reportByPrefixCmd = &cobra.Command{
+	Use:   "reportByPrefix",
+	Short: "Counts the images of repositories grouped by the first segment of their name.",
+	Long: `Counts the images in Amazon Elastic Container Registry (ECR) repositories, grouped by the first path segment of the
+repository name, e.g. the team in <team>/<service>. Repositories without a / form a group of their own.
+
+The groups are printed with the most images first, to show which teams are the biggest ECR consumers.
+Nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] reportByPrefix called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		repos, ok := selectRepositories(cmd, client, "count")
+		if !ok {
+			return
+		}
+
+		report := deleteuntaggedimages.CountByPrefix(ctx, client, repos, deleteuntaggedimages.PrefixCountOptions{
+			Concurrency: resolveConcurrency(cmd, len(repos)),
+			Registry:    awsregistry.Registry{Account: account, Region: region},
+			Logs:        logs,
+		})
+		flushLogs(cmd, logs)
+		if len(report.Prefixes) > 0 {
+			if err := format.WriteTable(cmd.ErrOrStderr(), []string{"PREFIX", "REPOSITORIES", "IMAGES", "UNTAGGED"}, prefixRows(report)); err != nil {
+				cmd.Printf("[ERROR] Failed to print image counts: %v\n", err)
+			}
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		if len(report.Errors) > 0 {
+			cmd.Printf("[ERROR] Failed to count %d repositories\n", len(report.Errors))
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished counting images by prefix.")
+	},
+}
+
+// --- returns the counts of each prefix ---
+func prefixRows(report deleteuntaggedimages.PrefixCountReport) [][]string {
+	rows := make([][]string, 0, len(report.Prefixes))
+	for _, prefix := range report.Prefixes {
+		rows = append(rows, []string{prefix.Prefix, strconv.Itoa(prefix.Repositories), strconv.Itoa(prefix.Images), strconv.Itoa(prefix.Untagged)})
+	}
+	return rows
+}
+
+func init() {
+	rootCmd.AddCommand(reportByPrefixCmd)
+}
@@ -0,0 +1,86 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+
+	"github.com/spf13/cobra"
+)
+
+var failuresFile string
+
+var retryFailedCmd = &cobra.Command{
+	Use:   "retryFailed",
+	Short: "Retries the image deletions that failed in a previous clean run.",
+	Long: `Retries the image deletions that failed in a previous clean run.
+
+It reads the file written by clean --saveFailures and deletes only those repository and digest combinations again.
+Use --saveFailures here as well to keep the deletions that still fail for another attempt.
+
+Exit codes: 0 when every repository succeeded, 1 when some repositories failed and others succeeded,
+2 when every processed repository failed. With --failOnPartialSuccess=false a partial failure exits 0.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] retryFailed called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		failures, err := deleteuntaggedimages.LoadFailures(failuresFile)
+		if err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+		plan := deleteuntaggedimages.RetryPlan(failures, dryRun)
+		if len(plan.Repositories) == 0 {
+			cmd.Println("[INFO] No failed deletions to retry.")
+			return
+		}
+		cmd.Printf("[INFO] Retrying %d failed deletions in %d repositories\n", plan.TotalImages(), len(plan.Repositories))
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		opts := deleteuntaggedimages.CleanOptions{DryRun: dryRun, Logs: logs, Concurrency: resolveConcurrency(cmd, len(plan.Repositories)), Registry: awsregistry.Registry{Account: account, Region: region}}
+		var release func()
+		opts.Stop, release = gracefulStop(cmd)
+		defer release()
+		sizes := webhookSizes(cmd, client, plan, opts.Concurrency)
+		report, err := deleteuntaggedimages.ExecutePlan(ctx, client, plan, opts)
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeFailuresFile(cmd, report.Failures())
+		writeOutputFile(cmd, report)
+		notifyWebhook(cmd, cleanWebhookSummary(report, plan, sizes))
+		failed, processed := report.RepositoryOutcomes()
+		setRepositoryExitCode(cmd, failed, processed)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to retry deletions: %v\n", err)
+			return
+		}
+		if reportInterrupted(cmd, report.Interrupted) {
+			return
+		}
+
+		cmd.Println("[INFO] Finished retrying failed deletions.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(retryFailedCmd)
+
+	retryFailedCmd.Flags().StringVar(&failuresFile, "failuresFile", "", "JSON file of failed deletions written by clean --saveFailures")
+	retryFailedCmd.Flags().StringVar(&saveFailures, "saveFailures", "", "write the images that still fail to delete as JSON to this file")
+	retryFailedCmd.MarkFlagRequired("failuresFile") // nolint:errcheck
+}
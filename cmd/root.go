@@ -3,17 +3,93 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
+	apimetrics "ecr-lifecycle-cleaner/internal/apiMetrics"
+	apitimeout "ecr-lifecycle-cleaner/internal/apiTimeout"
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	configfile "ecr-lifecycle-cleaner/internal/configFile"
+	format "ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+	reponame "ecr-lifecycle-cleaner/internal/repoName"
+	reportupload "ecr-lifecycle-cleaner/internal/reportUpload"
+	"ecr-lifecycle-cleaner/internal/reporter"
+	"ecr-lifecycle-cleaner/internal/tracing"
+	"ecr-lifecycle-cleaner/internal/webhook"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	dryRun         bool
-	allRepos       bool
-	repoList       string
-	repoPattern    string
-	repositoryList []string
+	dryRun          bool
+	allRepos        bool
+	repoList        string
+	repoPatterns    []string
+	repositoryList  []string
+	repoListLimit   int
+	allowLongList   bool
+	ignoreRepos     string
+	ignoredRepos    []string
+	continueFrom    string
+	failOnPartial   bool
+	debugAPIMetrics bool
+	apiTimeout      time.Duration
+	outputFile      string
+	outputName      string
+	output          format.OutputFormat
+	separatorName   string
+	separator       format.Separator
+	jsonModeName    string
+	jsonMode        format.JSONMode
+	outputTemplate  string
+	reportTemplate  *template.Template
+	reportS3URI     string
+	reportLocation  reportupload.Location
+	reportSpecs     []string
+	webhookURL      string
+	webhookType     string
+	webhookTimeout  time.Duration
+	webhookTarget   webhook.Target
+	reporters       []reporter.Reporter
+	maxConcurrency  int
+	autoConcurrency bool
+	sequential      bool
+	parallel        bool
+	planOnly        bool
+	planFile        string
+	otelEndpoint    string
+	logOrderName    string
+	logOrder        logbuffer.LogOrder
+	configPath      string
+	awsRegion       string
+	awsProfile      string
+	timezoneName    string
+	failOnZeroRepos bool
+	logsToStderr    bool
+	// --- location timestamps are displayed in, comparisons such as age filters always use UTC ---
+	displayLocation = time.UTC
+
+	shutdownTracing tracing.ShutdownFunc
+	commandSpan     trace.Span
+	// --- set by commands that report an error themselves but must still exit non-zero ---
+	exitCode int
 )
 
 var managementGroup = &cobra.Group{
@@ -28,12 +104,480 @@ var rootCmd = &cobra.Command{
 
 It can be used to apply lifecycle policies to ECR repositories,
 and clean up orphaned images from multi-platform builds.`,
+	PersistentPreRunE: validatePersistentFlags,
+	PersistentPostRun: finishTracing,
+}
+
+// --- returns the AWS config loader, instrumented with the metrics collector when one is given ---
+func newConfigLoader(metrics *apimetrics.Collector) initawsclient.ConfigLoader {
+	if metrics == nil {
+		metrics = newMetricsCollector()
+	}
+	// --- throttled calls lower the calls in flight of every client the loader builds ---
+	limit := concurrency.NewAdaptiveLimit(concurrency.MaxConcurrency)
+	metrics.OnThrottle(func(string) { limit.Throttled() })
+	return func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+		if awsRegion != "" {
+			optFns = append([]func(*config.LoadOptions) error{config.WithRegion(awsRegion)}, optFns...)
+		}
+		if awsProfile != "" {
+			optFns = append([]func(*config.LoadOptions) error{config.WithSharedConfigProfile(awsProfile)}, optFns...)
+		}
+		optFns = append(optFns, config.WithAPIOptions([]func(*middleware.Stack) error{metrics.AddTo, limit.AddTo}))
+		if apiTimeout > 0 {
+			optFns = append(optFns, config.WithAPIOptions([]func(*middleware.Stack) error{apitimeout.AddTo(apiTimeout)}))
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+		if err != nil || assumeRoleARN == "" {
+			return cfg, err
+		}
+		// --- in --useOrg runs the calls for a member account are made with the role assumed in it ---
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), assumeRoleARN))
+		return cfg, nil
+	}
+}
+
+// --- returns the collector of a run, its throttle counts feed the adaptive concurrency limit ---
+func newMetricsCollector() *apimetrics.Collector {
+	return apimetrics.New()
+}
+
+// --- prints the per-operation API metrics at the end of a run when --debugApiMetrics is set ---
+func printAPIMetrics(cmd *cobra.Command, metrics *apimetrics.Collector) {
+	if !debugAPIMetrics || metrics == nil {
+		return
+	}
+	for _, line := range metrics.Summary() {
+		cmd.Println(line)
+	}
+}
+
+// --- writes the structured report to --outputFile in the --output format and uploads it to --reportS3Uri, each when set ---
+// --- with --outputTemplate the rendered template is written instead, to stdout when --outputFile is not set ---
+func writeOutputFile(cmd *cobra.Command, report interface{}) {
+	if localizer, ok := report.(format.Localizer); ok {
+		report = localizer.InLocation(displayLocation)
+	}
+	uploadReport(cmd, report)
+	sendReports(cmd, report)
+	path := reportPath()
+	if path == "" {
+		return
+	}
+	if err := reporter.ForOutput(path, output, jsonMode, separator, reportTemplate).Report(report); err != nil {
+		var unsupported *reporter.UnsupportedError
+		if errors.As(err, &unsupported) {
+			cmd.Printf("[ERROR] Failed to write report: --output %s is not supported by %s\n", unsupported.Kind, cmd.Name())
+			return
+		}
+		cmd.Printf("[ERROR] Failed to write report: %v\n", err)
+		return
+	}
+	if path != "-" {
+		cmd.Printf("[INFO] Report written to %s\n", outputFile)
+	}
+}
+
+// --- hands the report to every --report destination, a failed destination is logged and does not stop the others ---
+func sendReports(cmd *cobra.Command, report interface{}) {
+	for _, r := range reporters {
+		if err := r.Report(report); err != nil {
+			var unsupported *reporter.UnsupportedError
+			if errors.As(err, &unsupported) {
+				err = fmt.Errorf("%s is not supported by %s", unsupported.Kind, cmd.Name())
+			}
+			cmd.Printf("[ERROR] Failed to write report to %s: %v\n", r, err)
+			continue
+		}
+		if file, ok := r.(reporter.File); ok && file.Path != "-" {
+			cmd.Printf("[INFO] Report written to %s\n", r)
+		}
+	}
+}
+
+// --- commands whose report is another command's, so they share its built-in templates ---
+var templateFamilies = map[string]string{
+	"retryFailed": "clean",
+	"empty":       "clean",
+}
+
+// --- parses --outputTemplate, a name after @ selects a built-in template for the command's report ---
+func parseOutputTemplate(cmd *cobra.Command) (*template.Template, error) {
+	name, builtin := strings.CutPrefix(outputTemplate, format.BuiltinTemplatePrefix)
+	if !builtin {
+		return format.ParseTemplate(outputTemplate)
+	}
+	family, ok := templateFamilies[cmd.Name()]
+	if !ok {
+		family = cmd.Name()
+	}
+	return format.ParseBuiltinTemplate(name, family)
+}
+
+// --- returns where the report is written, - for stdout, or an empty string when it is not written at all ---
+// --- a rendered --outputTemplate and --jsonLogsToStderr default to stdout when --outputFile is not set ---
+func reportPath() string {
+	if outputFile != "" {
+		return outputFile
+	}
+	if reportTemplate != nil || logsToStderr {
+		return "-"
+	}
+	return ""
+}
+
+// --- sends every log line to stderr, so stdout carries nothing but the report ---
+func routeLogsToStderr(cmd *cobra.Command) {
+	cmd.Root().SetOut(os.Stderr)
+	cmd.Root().SetErr(os.Stderr)
+	log.SetOutput(os.Stderr)
+}
+
+// --- returns t in the --timezone location, for display only ---
+func displayTime(t time.Time) time.Time {
+	return t.In(displayLocation)
+}
+
+// --- uploads the report to --reportS3Uri, a failed upload is logged but never fails the run ---
+func uploadReport(cmd *cobra.Command, report interface{}) {
+	if reportS3URI == "" {
+		return
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	client, account, region, err := initawsclient.NewS3Client(ctx, newConfigLoader(nil))
+	if err != nil {
+		cmd.Printf("[ERROR] Failed to upload report: failed to initialize S3 client: %v\n", err)
+		return
+	}
+	uri, err := reportupload.Upload(ctx, client, reportLocation, reportLocation.Key(cmd.Name(), account, region, time.Now()), report)
+	if err != nil {
+		cmd.Printf("[ERROR] Failed to upload report: %v\n", err)
+		return
+	}
+	cmd.Printf("[INFO] Report uploaded to %s\n", uri)
+}
+
+// --- posts the run summary to --webhookUrl, delivery is best-effort within --webhookTimeout and never fails the run ---
+func notifyWebhook(cmd *cobra.Command, summary webhook.Summary) {
+	if webhookURL == "" {
+		return
+	}
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	summary.Command = cmd.Name()
+	if err := webhook.Send(ctx, http.DefaultClient, webhookTarget, summary); err != nil {
+		cmd.Printf("[ERROR] Failed to send webhook summary: %v\n", err)
+		return
+	}
+	cmd.Printf("[INFO] Summary posted to the %s webhook\n", webhookTarget.Type)
+}
+
+// --- writes the pre-flight plan to --planFile when set ---
+func writePlanFile(cmd *cobra.Command, plan interface{}) {
+	if planFile == "" {
+		return
+	}
+	if err := format.WriteReport(planFile, plan); err != nil {
+		cmd.Printf("[ERROR] Failed to write plan: %v\n", err)
+		return
+	}
+	if planFile != "-" {
+		cmd.Printf("[INFO] Plan written to %s\n", planFile)
+	}
+}
+
+// --- returns the log buffer for a command, ordering each phase's messages by --logOrder ---
+func newLogBuffer() *logbuffer.LogBuffer {
+	return logbuffer.New(logbuffer.WithLogOrder(logOrder))
+}
+
+// --- writes buffered log messages, bounded by logbuffer.FlushTimeout so a stuck writer cannot hang the exit ---
+func flushLogs(cmd *cobra.Command, logs *logbuffer.LogBuffer) {
+	ctx, cancel := context.WithTimeout(context.Background(), logbuffer.FlushTimeout)
+	defer cancel()
+	if err := logs.Flush(ctx, log.Writer()); err != nil {
+		cmd.Printf("[WARN] Failed to flush logs: %v\n", err)
+	}
+}
+
+// --- marks commands that operate on repositories chosen with --allRepos, --repoList or --repoPattern ---
+const repoSelectionAnnotation = "repoSelection"
+
+var repoSelectionFlags = []string{"allRepos", "repoList", "repoPattern"}
+
+// --- requires one of the repository selection flags, for the commands that need them ---
+func validateRepoSelection(cmd *cobra.Command) error {
+	if _, ok := cmd.Annotations[repoSelectionAnnotation]; !ok {
+		return nil
+	}
+	for _, name := range repoSelectionFlags {
+		if cmd.Flags().Changed(name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("at least one of the flags in the group %v is required", repoSelectionFlags)
+}
+
+// --- validates persistent flags before any command makes an API call ---
+func validatePersistentFlags(cmd *cobra.Command, args []string) error {
+	if err := configfile.Apply(cmd.Flags(), configPath); err != nil {
+		return err
+	}
+	if err := concurrency.ValidateConcurrency(maxConcurrency); err != nil {
+		return err
+	}
+	if apiTimeout < 0 {
+		return fmt.Errorf("invalid --apiTimeout: must not be negative")
+	}
+	if err := validateRepoSelection(cmd); err != nil {
+		return err
+	}
+	if repoList != "" {
+		names, err := reponame.ParseList(repoList)
+		if err != nil {
+			return err
+		}
+		if err := checkRepoListLimit(cmd, len(names)); err != nil {
+			return err
+		}
+		repositoryList = names
+	}
+	order, err := logbuffer.ParseLogOrder(logOrderName)
+	if err != nil {
+		return err
+	}
+	logOrder = order
+	if displayLocation, err = format.ParseTimezone(timezoneName); err != nil {
+		return fmt.Errorf("invalid --timezone: %w", err)
+	}
+	if output, err = format.ParseOutputFormat(outputName); err != nil {
+		return fmt.Errorf("invalid --output: %w", err)
+	}
+	if separator, err = format.ParseSeparator(separatorName); err != nil {
+		return fmt.Errorf("invalid --outputSeparator: %w", err)
+	}
+	if jsonMode, err = format.ParseJSONMode(jsonModeName); err != nil {
+		return fmt.Errorf("invalid --jsonMode: %w", err)
+	}
+	reportTemplate = nil
+	if outputTemplate != "" {
+		tmpl, err := parseOutputTemplate(cmd)
+		if err != nil {
+			return fmt.Errorf("invalid --outputTemplate: %w", err)
+		}
+		reportTemplate = tmpl
+	}
+	if reportS3URI != "" {
+		location, err := reportupload.ParseURI(reportS3URI)
+		if err != nil {
+			return err
+		}
+		reportLocation = location
+	}
+	if webhookURL != "" {
+		target, err := webhook.Parse(webhookURL, webhookType)
+		if err != nil {
+			return fmt.Errorf("invalid --webhookUrl: %w", err)
+		}
+		webhookTarget = target
+		if webhookTimeout <= 0 {
+			return fmt.Errorf("--webhookTimeout must be positive, got %s", webhookTimeout)
+		}
+	}
+	if logsToStderr {
+		if planFile == "-" || saveFailures == "-" || (outputFile != "" && outputFile != "-") {
+			return fmt.Errorf("--jsonLogsToStderr writes the report to stdout, it cannot be combined with --planFile -, --saveFailures - or an --outputFile path")
+		}
+		routeLogsToStderr(cmd)
+	}
+	if reporters, err = reporter.ParseAll(reportSpecs, cmd.ErrOrStderr(), separator); err != nil {
+		return fmt.Errorf("invalid --report: %w", err)
+	}
+	ignoredRepos = nil
+	if ignoreRepos != "" {
+		names, err := reponame.ParseList(ignoreRepos)
+		if err != nil {
+			return fmt.Errorf("invalid --ignoreRepos: %w", err)
+		}
+		ignoredRepos = names
+	}
+	return startTracing(cmd)
+}
+
+// --- sorts the selected repositories and drops the ones before --continueFrom, a manual resume of a run that failed partway ---
+// --- the named repository must be in the selection, otherwise a typo would silently process everything or nothing ---
+func applyContinueFrom(cmd *cobra.Command, repos []string) ([]string, error) {
+	if continueFrom == "" {
+		return repos, nil
+	}
+	sorted := slices.Clone(repos)
+	slices.Sort(sorted)
+	index, found := slices.BinarySearch(sorted, continueFrom)
+	if !found {
+		return nil, fmt.Errorf("--continueFrom repository %s is not among the %d selected repositories", continueFrom, len(repos))
+	}
+	cmd.Printf("[INFO] Continuing from repository %s, skipping %d repositories before it\n", continueFrom, index)
+	return sorted[index:], nil
+}
+
+// --- rejects a --repoList longer than --repoListLimit, a pasted account-wide list is better served by --allRepos or --repoPattern ---
+// --- with --allowLongRepoList the run proceeds with a warning, still processing at most --maxConcurrency repositories at once ---
+func checkRepoListLimit(cmd *cobra.Command, count int) error {
+	if repoListLimit < 0 {
+		return fmt.Errorf("invalid --repoListLimit: must not be negative")
+	}
+	if repoListLimit == 0 || count <= repoListLimit {
+		return nil
+	}
+	if !allowLongList {
+		return fmt.Errorf("--repoList names %d repositories, more than --repoListLimit %d, use --allRepos or --repoPattern, or --allowLongRepoList to proceed", count, repoListLimit)
+	}
+	cmd.Printf("[WARN] --repoList names %d repositories, more than --repoListLimit %d\n", count, repoListLimit)
+	return nil
+}
+
+// --- drops the --ignoreRepos repositories from the selected ones, warning when one was asked for by name in --repoList ---
+func applyIgnoreRepos(cmd *cobra.Command, repos []string) []string {
+	if len(ignoredRepos) == 0 {
+		return repos
+	}
+	ignored := make(map[string]bool, len(ignoredRepos))
+	for _, name := range ignoredRepos {
+		ignored[name] = true
+	}
+	listed := make(map[string]bool, len(repositoryList))
+	for _, name := range repositoryList {
+		listed[name] = true
+	}
+
+	kept := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		if !ignored[repo] {
+			kept = append(kept, repo)
+			continue
+		}
+		if listed[repo] {
+			cmd.Printf("[WARN] Repository: %s - Listed in --repoList but ignored by --ignoreRepos\n", repo)
+		} else {
+			cmd.Printf("[DEBUG] Repository: %s - Ignored by --ignoreRepos\n", repo)
+		}
+	}
+	return kept
+}
+
+// --- sets up the OTLP exporter and opens the command span, nested under the CI trace when one is propagated ---
+func startTracing(cmd *cobra.Command) error {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	shutdown, err := tracing.Setup(ctx, otelEndpoint)
+	if err != nil {
+		return err
+	}
+	shutdownTracing = shutdown
+	ctx, commandSpan = tracing.Start(tracing.FromEnvironment(ctx), cmd.Name(), attribute.Bool("dry_run", dryRun))
+	cmd.SetContext(ctx)
+	return nil
+}
+
+// --- ends the command span and flushes pending spans to the collector ---
+func finishTracing(cmd *cobra.Command, args []string) {
+	if commandSpan != nil {
+		commandSpan.End()
+		commandSpan = nil
+	}
+	if shutdownTracing == nil {
+		return
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		cmd.Printf("[WARN] Failed to flush traces: %v\n", err)
+	}
+	shutdownTracing = nil
+}
+
+// --- returns the number of repositories to process at once, --maxConcurrency wins over --sequential, --parallel and --concurrencyAuto ---
+func resolveConcurrency(cmd *cobra.Command, repoCount int) int {
+	if cmd.Flags().Changed("maxConcurrency") {
+		if sequential || parallel {
+			cmd.Printf("[WARN] --maxConcurrency %d wins over --sequential and --parallel\n", maxConcurrency)
+		}
+		return maxConcurrency
+	}
+	switch {
+	case sequential:
+		cmd.Println("[INFO] Processing repositories one at a time")
+		return 1
+	case parallel:
+		return concurrency.DefaultConcurrency
+	case autoConcurrency:
+		n := concurrency.AutoConcurrency(repoCount)
+		cmd.Printf("[INFO] Using automatic concurrency of %d for %d repositories\n", n, repoCount)
+		return n
+	}
+	return maxConcurrency
+}
+
+// --- reports an empty repository selection, with --failOnZeroRepos as an error so a scheduled run with a wrong profile or pattern exits non-zero ---
+func noRepositories(cmd *cobra.Command, action string) {
+	if failOnZeroRepos {
+		cmd.Printf("[ERROR] No repositories to %s, failing because of --failOnZeroRepos\n", action)
+		exitCode = 1
+		return
+	}
+	cmd.Printf("[INFO] No repositories to %s.\n", action)
+}
+
+// --- exit codes of commands that process repositories, for Nagios-style monitoring ---
+const (
+	// --- some repositories failed, the others succeeded, 0 instead with --failOnPartialSuccess=false ---
+	exitPartialFailure = 1
+	// --- every processed repository failed ---
+	exitTotalFailure = 2
+)
+
+// --- sets the exit code from the failed and processed repositories of a report, an earlier higher code is kept ---
+func setRepositoryExitCode(cmd *cobra.Command, failed, processed int) {
+	switch {
+	case failed == 0:
+	case failed == processed:
+		exitCode = max(exitCode, exitTotalFailure)
+	case failOnPartial:
+		exitCode = max(exitCode, exitPartialFailure)
+	default:
+		cmd.Printf("[WARN] %d of %d repositories failed, exiting zero because of --failOnPartialSuccess=false\n", failed, processed)
+	}
+}
+
+// --- stops a command when --repoPattern matched no repositories, an empty match is almost always a wrong pattern ---
+// --- rather than nothing to do, so it is reported as an error and the process exits non-zero ---
+func patternMatchedNothing(cmd *cobra.Command, repos []string) bool {
+	if len(repoPatterns) == 0 || len(repos) > 0 {
+		return false
+	}
+	if len(repoPatterns) == 1 {
+		cmd.Printf("[ERROR] Pattern %q matched no repositories\n", repoPatterns[0])
+	} else {
+		cmd.Printf("[ERROR] None of the patterns %q matched a repository\n", repoPatterns)
+	}
+	exitCode = 1
+	return true
 }
 
 func Execute() {
 	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	if err != nil && exitCode == 0 {
+		exitCode = 1
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
 }
 
@@ -42,12 +586,64 @@ func init() {
 
 	cleanCmd.GroupID = managementGroup.ID
 	setPolicyCmd.GroupID = managementGroup.ID
+	setPolicyFromDirCmd.GroupID = managementGroup.ID
+	analyzeLayersCmd.GroupID = managementGroup.ID
+	compareRegistriesCmd.GroupID = managementGroup.ID
+	promoteCmd.GroupID = managementGroup.ID
+	retryFailedCmd.GroupID = managementGroup.ID
+	emptyCmd.GroupID = managementGroup.ID
+	enforceTagImmutabilityCmd.GroupID = managementGroup.ID
+	enableScanCmd.GroupID = managementGroup.ID
+	checkScanConfigCmd.GroupID = managementGroup.ID
+	findUnmanagedCmd.GroupID = managementGroup.ID
+	inspectCmd.GroupID = managementGroup.ID
+	manageCreationTemplatesCmd.GroupID = managementGroup.ID
+	auditPolicyCmd.GroupID = managementGroup.ID
+	reportByPrefixCmd.GroupID = managementGroup.ID
+	validatePolicyCmd.GroupID = managementGroup.ID
+	getPolicyCmd.GroupID = managementGroup.ID
+
+	for _, c := range []*cobra.Command{cleanCmd, setPolicyCmd, setPolicyFromDirCmd, analyzeLayersCmd, compareRegistriesCmd, emptyCmd, enforceTagImmutabilityCmd, enableScanCmd, checkScanConfigCmd, findUnmanagedCmd, inspectCmd, auditPolicyCmd, reportByPrefixCmd, getPolicyCmd} {
+		c.Annotations = map[string]string{repoSelectionAnnotation: "true"}
+	}
 
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", fmt.Sprintf("YAML, JSON or TOML file with flag defaults keyed by flag name (e.g. maxConcurrency: 10), overridden by %s_<FLAG> environment variables and command line flags", configfile.EnvPrefix))
+	rootCmd.PersistentFlags().StringVar(&awsRegion, "region", "", "AWS region to use, defaults to the region of the AWS configuration")
+	rootCmd.PersistentFlags().StringVar(&awsProfile, "profile", "", "AWS shared config profile to use, defaults to the AWS configuration")
 	rootCmd.PersistentFlags().BoolVarP(&allRepos, "allRepos", "a", false, "apply the changes to all repositories")
 	rootCmd.PersistentFlags().StringVarP(&repoList, "repoList", "l", "", "comma-separated list of repository names (e.g., repo1,repo2)")
-	rootCmd.PersistentFlags().StringVarP(&repoPattern, "repoPattern", "p", "", "regex pattern to match repository names (e.g., '^my-repo-.*'), make sure to quote the pattern to avoid shell interpretation")
+	rootCmd.PersistentFlags().StringArrayVarP(&repoPatterns, "repoPattern", "p", nil, "regex pattern to match repository names (e.g., '^my-repo-.*'), repeat the flag to select repositories matching any of the patterns, make sure to quote the pattern to avoid shell interpretation")
+	rootCmd.PersistentFlags().IntVar(&repoListLimit, "repoListLimit", reponame.DefaultListLimit, "maximum number of repositories --repoList may name, longer lists are rejected unless --allowLongRepoList is set, 0 disables the limit")
+	rootCmd.PersistentFlags().BoolVar(&allowLongList, "allowLongRepoList", false, "proceed with a warning when --repoList names more repositories than --repoListLimit")
+	rootCmd.PersistentFlags().BoolVar(&failOnPartial, "failOnPartialSuccess", true, "exit 1 when some repositories fail and others succeed, false exits 0 then and only a run where every repository failed exits non-zero (2), used by clean, empty, retryFailed, setPolicy and setPolicyFromDir")
+	rootCmd.PersistentFlags().StringVar(&continueFrom, "continueFrom", "", "sort the selected repositories by name and skip the ones before this repository, to rerun a run that failed partway, the repository must be in the selection")
+	rootCmd.PersistentFlags().StringVar(&ignoreRepos, "ignoreRepos", "", "comma-separated list of repository names to skip, applied after --allRepos, --repoList or --repoPattern")
+	rootCmd.PersistentFlags().BoolVar(&failOnZeroRepos, "failOnZeroRepos", false, "exit non-zero when no repository is selected, after --ignoreRepos and other filters, so scheduled runs with a wrong profile or pattern do not look healthy")
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dryRun", "d", false, "dry run mode, no changes will be applied")
+	rootCmd.PersistentFlags().IntVar(&maxConcurrency, "maxConcurrency", concurrency.DefaultConcurrency, fmt.Sprintf("maximum number of repositories processed at once (1-%d), repositories are processed in parallel by default, ECR throttles per account and region so higher values mostly add retries", concurrency.MaxConcurrency))
+	rootCmd.PersistentFlags().BoolVar(&autoConcurrency, "concurrencyAuto", false, "derive the concurrency from the number of repositories (repos/10, capped at 20), ignored when --maxConcurrency is set")
+	rootCmd.PersistentFlags().BoolVar(&sequential, "sequential", false, "process one repository at a time, same as --maxConcurrency 1, easier to follow when debugging and gentler on accounts close to their ECR rate limits, --maxConcurrency wins when both are set")
+	rootCmd.PersistentFlags().BoolVar(&parallel, "parallel", false, fmt.Sprintf("process repositories in parallel with the default concurrency of %d, which is already the default, spelled out for scripts, --maxConcurrency wins when both are set", concurrency.DefaultConcurrency))
+	rootCmd.PersistentFlags().StringVar(&outputFile, "outputFile", "", "write the final report to this file in the --output format once the run completes, use - for stdout (logs stay on stderr)")
+	rootCmd.PersistentFlags().StringVar(&outputName, "output", string(format.OutputJSON), "format of the report written to --outputFile: json, junit (one test case per repository) for CI test dashboards, supported by clean, setPolicy, retryFailed, enforceTagImmutability, enableScan, checkScanConfig and auditPolicy, or csv (one row per repository), supported by clean, retryFailed, empty, findUnmanaged and reportByPrefix")
+	rootCmd.PersistentFlags().StringVar(&separatorName, "outputSeparator", "comma", "delimiter of --output csv: comma, tab or pipe")
+	rootCmd.PersistentFlags().StringVar(&jsonModeName, "jsonMode", string(format.JSONArray), "layout of --output json: array (the report as one indented document) or lines (one compact JSON object per line, per repository for clean, retryFailed, empty and findUnmanaged)")
+	rootCmd.PersistentFlags().StringVar(&outputTemplate, "outputTemplate", "", "render the final report with this Go text/template file instead of JSON (functions: humanBytes, pluralize, join, timeAgo), written to --outputFile or stdout, see example/templates, or with a built-in template: @minimal, @slack, or @markdown-table for clean, retryFailed, empty and setPolicy")
+	rootCmd.PersistentFlags().BoolVar(&logsToStderr, "jsonLogsToStderr", false, "write the report to stdout in the --output format and every log line to stderr, so stdout can be piped or redirected as pure data (e.g. clean --jsonLogsToStderr > report.json)")
+	rootCmd.PersistentFlags().StringArrayVar(&reportSpecs, "report", nil, "send the final report to another destination, repeat the flag for several: console (summary and table), json:PATH, jsonl:PATH, csv:PATH, junit:PATH or prom:PATH (Prometheus text format, supported by clean and setPolicy), alongside --outputFile")
+	rootCmd.PersistentFlags().StringVar(&reportS3URI, "reportS3Uri", "", "upload the final report as JSON to s3://bucket/prefix, keyed by account, region, command and time, a failed upload is logged and does not fail the run")
+	rootCmd.PersistentFlags().StringVar(&webhookURL, "webhookUrl", "", "post a summary (repositories processed and failed, images deleted, failed deletions, estimated reclaimed size) to this Slack or Microsoft Teams incoming webhook after clean, empty or retryFailed, a failed post is logged and does not fail the run")
+	rootCmd.PersistentFlags().StringVar(&webhookType, "webhookType", "", "format of the --webhookUrl payload: slack or teams, detected from the webhook host when not set")
+	rootCmd.PersistentFlags().DurationVar(&webhookTimeout, "webhookTimeout", webhook.DefaultTimeout, "give up posting to --webhookUrl after this long")
+	rootCmd.PersistentFlags().BoolVar(&planOnly, "planOnly", false, "print the pre-flight plan and exit without changing anything")
+	rootCmd.PersistentFlags().StringVar(&planFile, "planFile", "", "write the pre-flight plan as JSON to this file before any change is made, use - for stdout")
+	rootCmd.PersistentFlags().StringVar(&otelEndpoint, "otelEndpoint", "", "send OpenTelemetry traces to this OTLP/HTTP endpoint (e.g. http://localhost:4318), a TRACEPARENT from the environment becomes the parent span")
+	rootCmd.PersistentFlags().StringVar(&timezoneName, "timezone", "", "IANA time zone (e.g. Europe/Berlin) timestamps are displayed in, in the logs and the report, defaults to UTC, age filters are not affected")
+	rootCmd.PersistentFlags().StringVar(&logOrderName, "logOrder", logbuffer.LogOrderAlphabetical.String(), "order of the log messages of each phase: alphabetical, chronological or repository (grouped by repository)")
+	rootCmd.PersistentFlags().BoolVar(&debugAPIMetrics, "debugApiMetrics", false, "log per-operation API call and throttle counts at the end of the run")
+	rootCmd.PersistentFlags().DurationVar(&apiTimeout, "apiTimeout", 0, "give every AWS API call, its retries included, its own timeout (e.g. 30s), so a stuck call fails its repository with a timeout instead of stalling the run, 0 disables it")
 
-	rootCmd.MarkFlagsOneRequired("allRepos", "repoList", "repoPattern")
-	rootCmd.MarkFlagsMutuallyExclusive("allRepos", "repoList", "repoPattern")
+	rootCmd.MarkFlagsMutuallyExclusive(repoSelectionFlags...)
+	rootCmd.MarkFlagsMutuallyExclusive("output", "outputTemplate")
+	rootCmd.MarkFlagsMutuallyExclusive("sequential", "parallel", "concurrencyAuto")
 }
@@ -4,8 +4,26 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"text/template"
+	"time"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	format "ecr-lifecycle-cleaner/internal/format"
+	layersharing "ecr-lifecycle-cleaner/internal/layerSharing"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+	"ecr-lifecycle-cleaner/internal/webhook"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func TestRootCmd_Help(t *testing.T) {
@@ -28,8 +46,9 @@ func TestCleanCmd_AllReposFlag(t *testing.T) {
 	rootCmd.SetArgs([]string{"clean", "--allRepos", "--dryRun"})
 	allRepos = true
 	repositoryList = nil
-	repoPattern = ""
+	repoPatterns = nil
 	dryRun = true
+	defer func() { exitCode = 0 }()
 	_ = rootCmd.Execute()
 	out := buf.String()
 	if !strings.Contains(out, "clean called") {
@@ -51,3 +70,866 @@ func TestSetPolicyCmd_MissingRequiredFlag(t *testing.T) {
 		t.Errorf("Expected required flag error, got: %s", out)
 	}
 }
+
+func TestSetPolicyCmd_PolicyJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, setPolicyCmd)
+	defer func() {
+		resetFlags(rootCmd, setPolicyCmd)
+		exitCode = 0
+	}()
+
+	// --- invalid inline JSON is rejected before AWS is called ---
+	rootCmd.SetArgs([]string{"setPolicy", "--repoList", "app", "--policyJson", `{"rules": [`})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[ERROR] Reading policy: invalid JSON in inline policy") || strings.Contains(buf.String(), "Using AWS account") {
+		t.Errorf("Expected inline policy error before AWS is called, got: %s", buf.String())
+	}
+
+	resetFlags(rootCmd, setPolicyCmd)
+	rootCmd.SetArgs([]string{"setPolicy", "--repoList", "app", "--policyJson", `{"rules": []}`, "--policyFile", "policy.json"})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "none of the others can be") {
+		t.Errorf("Expected --policyJson and --policyFile to be mutually exclusive, got: %v", err)
+	}
+}
+
+func TestValidatePolicyCmd(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, validatePolicyCmd)
+	defer func() {
+		resetFlags(rootCmd, validatePolicyCmd)
+		exitCode = 0
+	}()
+
+	rootCmd.SetArgs([]string{"validatePolicy", "--policyJson", `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"sinceImagePushed","countNumber":7},"action":{"type":"expire"}}]}`})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), `missing required field "countUnit"`) || exitCode != 1 {
+		t.Errorf("Expected a schema violation and a non-zero exit code, got %d: %s", exitCode, buf.String())
+	}
+
+	// --- a valid policy passes and the schema is written for editors ---
+	resetFlags(rootCmd, validatePolicyCmd)
+	buf.Reset()
+	exitCode = 0
+	path := filepath.Join(t.TempDir(), "schema.json")
+	rootCmd.SetArgs([]string{"validatePolicy", "--policyJson", `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":7},"action":{"type":"expire"}}]}`, "--writeSchema", path})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[INFO] Schema: "+readpolicyfile.SchemaURL) || !strings.Contains(buf.String(), "[INFO] All policies are valid.") || exitCode != 0 {
+		t.Errorf("Expected a valid policy and the schema location, got: %s", buf.String())
+	}
+	if written, err := os.ReadFile(path); err != nil || !bytes.Equal(written, readpolicyfile.Schema()) {
+		t.Errorf("Expected the schema to be written, got: %v", err)
+	}
+}
+
+func TestValidatePolicyCmd_Files(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, validatePolicyCmd)
+	defer func() {
+		resetFlags(rootCmd, validatePolicyCmd)
+		exitCode = 0
+	}()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"valid.json":    `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":7},"action":{"type":"expire"}}]}`,
+		"typo.json":     `{"rules":[{"rulePriorty":1}]}`,
+		"trailing.json": `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":7},"action":{"type":"expire"}}]} {}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write policy: %v", err)
+		}
+	}
+
+	// --- every file is checked, without AWS credentials, and one invalid file fails the run ---
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	rootCmd.SetArgs([]string{"validatePolicy", "--policyFile", filepath.Join(dir, "valid.json"), filepath.Join(dir, "typo.json"), filepath.Join(dir, "trailing.json")})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "valid.json: valid") || !strings.Contains(out, `unknown field "rulePriorty"`) || !strings.Contains(out, "trailing.json: invalid JSON in policy file") {
+		t.Errorf("Expected a result for every file, got: %s", out)
+	}
+	if !strings.Contains(out, "[ERROR] 2 of 3 policies are invalid") || exitCode != 1 {
+		t.Errorf("Expected a non-zero exit code, got %d: %s", exitCode, out)
+	}
+}
+
+func TestStepPrompt(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetIn(strings.NewReader("maybe\ny\nskip\na\n"))
+
+	prompt := newStepPrompt(cmd)
+	images := []string{"sha256:1", "sha256:2"}
+
+	// --- unknown answers are asked again ---
+	if got := prompt("repo1", images); got != deleteuntaggedimages.StepProceed {
+		t.Errorf("Expected StepProceed, got %v", got)
+	}
+	if got := prompt("repo2", images); got != deleteuntaggedimages.StepSkip {
+		t.Errorf("Expected StepSkip, got %v", got)
+	}
+	if got := prompt("repo3", images); got != deleteuntaggedimages.StepAbort {
+		t.Errorf("Expected StepAbort, got %v", got)
+	}
+	// --- end of input aborts ---
+	if got := prompt("repo4", images); got != deleteuntaggedimages.StepAbort {
+		t.Errorf("Expected StepAbort on EOF, got %v", got)
+	}
+	if !strings.Contains(buf.String(), "Repository: repo1 - Delete 2 untagged images?") {
+		t.Errorf("Expected prompt output, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidConcurrency(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--maxConcurrency", "500"})
+	defer func() { maxConcurrency = concurrency.DefaultConcurrency }()
+
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatalf("Expected error for out of range concurrency, got nil")
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidRepoList(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"clean", "--repoList", "good-repo,Bad_Repo"})
+	defer func() {
+		repoList = ""
+		repositoryList = nil
+	}()
+
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), `"Bad_Repo"`) {
+		t.Fatalf("Expected error naming the invalid repository, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_RepoListLimit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, analyzeLayersCmd)
+	defer resetFlags(rootCmd, analyzeLayersCmd)
+
+	rootCmd.SetArgs([]string{"analyzeLayers", "--repoList", "app1, app2,,app3", "--repoListLimit", "2"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--repoListLimit 2") {
+		t.Fatalf("Expected --repoListLimit error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "analyzeLayers called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+
+	// --- --allowLongRepoList turns the error into a warning ---
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	buf.Reset()
+	repoListLimit, allowLongList = 2, true
+	if err := checkRepoListLimit(cmd, 3); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[WARN] --repoList names 3 repositories, more than --repoListLimit 2") {
+		t.Errorf("Expected a warning, got: %s", buf.String())
+	}
+
+	// --- 0 disables the limit ---
+	repoListLimit, allowLongList = 0, false
+	if err := checkRepoListLimit(cmd, 5000); err != nil {
+		t.Errorf("Expected no limit, got: %v", err)
+	}
+}
+
+func TestRootCmd_ConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cleaner.yaml")
+	if err := os.WriteFile(path, []byte("maxConcurrency: 0\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--config", path})
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	// --- the config file value is validated like a command line value ---
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "concurrency") {
+		t.Fatalf("Expected concurrency error from the config file value, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+// --- restores flag defaults, the commands are shared between tests so set flags would leak into the next one ---
+func resetFlags(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			// --- Set appends to repeatable flags, so they are emptied instead ---
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				_ = slice.Replace(nil)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+			f.Changed = false
+		})
+	}
+	repositoryList = nil
+	ignoredRepos = nil
+}
+
+func TestApplyIgnoreRepos(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	defer resetFlags(rootCmd)
+
+	// --- repositories selected by pattern or --allRepos are dropped quietly ---
+	repositoryList = nil
+	ignoredRepos = []string{"legacy", "sandbox"}
+	got := applyIgnoreRepos(cmd, []string{"app", "legacy", "web", "sandbox"})
+	if strings.Join(got, ",") != "app,web" {
+		t.Errorf("Expected app,web, got %v", got)
+	}
+	if !strings.Contains(buf.String(), "[DEBUG] Repository: legacy - Ignored by --ignoreRepos") || strings.Contains(buf.String(), "[WARN]") {
+		t.Errorf("Expected only debug lines, got: %s", buf.String())
+	}
+
+	// --- a repository named in --repoList and --ignoreRepos is ignored with a warning ---
+	buf.Reset()
+	repositoryList = []string{"app", "legacy"}
+	got = applyIgnoreRepos(cmd, repositoryList)
+	if strings.Join(got, ",") != "app" {
+		t.Errorf("Expected app, got %v", got)
+	}
+	if !strings.Contains(buf.String(), "[WARN] Repository: legacy - Listed in --repoList but ignored by --ignoreRepos") {
+		t.Errorf("Expected warning for the listed repository, got: %s", buf.String())
+	}
+
+	// --- nothing to ignore keeps the selection as is ---
+	ignoredRepos = nil
+	if got = applyIgnoreRepos(cmd, []string{"app"}); len(got) != 1 {
+		t.Errorf("Expected selection to be kept, got %v", got)
+	}
+}
+
+func TestApplyContinueFrom(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	defer resetFlags(rootCmd)
+
+	// --- without the flag the selection and its order are kept ---
+	repos := []string{"web", "app", "team/api", "batch"}
+	if got, err := applyContinueFrom(cmd, repos); err != nil || !reflect.DeepEqual(got, repos) {
+		t.Errorf("Expected selection to be kept, got %v, %v", got, err)
+	}
+
+	continueFrom = "team/api"
+	got, err := applyContinueFrom(cmd, repos)
+	if err != nil || !reflect.DeepEqual(got, []string{"team/api", "web"}) {
+		t.Errorf("Expected [team/api web], got %v, %v", got, err)
+	}
+	if !strings.Contains(buf.String(), "[INFO] Continuing from repository team/api, skipping 2 repositories before it") {
+		t.Errorf("Expected the skipped repositories to be reported, got: %s", buf.String())
+	}
+	if !reflect.DeepEqual(repos, []string{"web", "app", "team/api", "batch"}) {
+		t.Errorf("Expected the selection not to be modified, got %v", repos)
+	}
+
+	continueFrom = "team/ap"
+	if _, err := applyContinueFrom(cmd, repos); err == nil || !strings.Contains(err.Error(), "team/ap is not among the 4 selected repositories") {
+		t.Errorf("Expected an error for a repository outside the selection, got: %v", err)
+	}
+}
+
+func TestSetRepositoryExitCode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	defer resetFlags(rootCmd)
+	defer func() { exitCode = 0 }()
+
+	cases := []struct {
+		name              string
+		failOnPartial     bool
+		failed, processed int
+		want              int
+	}{
+		{"all succeeded", true, 0, 3, 0},
+		{"nothing processed", true, 0, 0, 0},
+		{"partial failure", true, 1, 3, exitPartialFailure},
+		{"partial failure allowed", false, 1, 3, 0},
+		{"all failed", true, 3, 3, exitTotalFailure},
+		{"all failed with partial failure allowed", false, 3, 3, exitTotalFailure},
+	}
+	for _, c := range cases {
+		exitCode = 0
+		failOnPartial = c.failOnPartial
+		setRepositoryExitCode(cmd, c.failed, c.processed)
+		if exitCode != c.want {
+			t.Errorf("%s: expected exit code %d, got %d", c.name, c.want, exitCode)
+		}
+	}
+	if !strings.Contains(buf.String(), "[WARN] 1 of 3 repositories failed, exiting zero because of --failOnPartialSuccess=false") {
+		t.Errorf("Expected a warning for the allowed partial failure, got: %s", buf.String())
+	}
+
+	// --- an earlier higher exit code is kept ---
+	exitCode = exitTotalFailure
+	failOnPartial = true
+	setRepositoryExitCode(cmd, 1, 3)
+	if exitCode != exitTotalFailure {
+		t.Errorf("Expected exit code %d to be kept, got %d", exitTotalFailure, exitCode)
+	}
+}
+
+func TestRootCmd_InvalidWebhookUrl(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, analyzeLayersCmd)
+	defer resetFlags(rootCmd, analyzeLayersCmd)
+
+	rootCmd.SetArgs([]string{"analyzeLayers", "--repoList", "app", "--webhookUrl", "https://chat.example.com/hook"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "invalid --webhookUrl: cannot tell the platform") {
+		t.Fatalf("Expected an undetectable webhook platform to be rejected, got: %v", err)
+	}
+}
+
+func TestCleanWebhookSummary(t *testing.T) {
+	plan := deleteuntaggedimages.CleanPlan{Repositories: []deleteuntaggedimages.RepositoryPlan{
+		{Repository: "app", Images: []string{"a1", "a2"}},
+		{Repository: "web", Images: []string{"w1"}},
+	}}
+	report := deleteuntaggedimages.CleanReport{
+		Registry: awsregistry.Registry{Account: "123456789012", Region: "eu-west-1"},
+		Repositories: []deleteuntaggedimages.RepositoryCleanResult{
+			{Repository: "app", Deleted: 2},
+			{Repository: "web", Failed: 1},
+		},
+	}
+
+	summary := cleanWebhookSummary(report, plan, nil)
+	want := webhook.Summary{Account: "123456789012", Region: "eu-west-1", Repositories: 2, FailedRepositories: 1, Deleted: 2, Failed: 1}
+	if summary != want {
+		t.Errorf("Expected %+v, got %+v", want, summary)
+	}
+
+	report.DryRun = true
+	summary = cleanWebhookSummary(report, plan, map[string]int64{"app": 2048, "web": 1024})
+	if summary.Deleted != 3 || !summary.ReclaimedKnown || summary.Reclaimed != 3072 {
+		t.Errorf("Expected a dry run to report the planned images and sizes, got %+v", summary)
+	}
+}
+
+func TestRootCmd_InvalidIgnoreRepos(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, analyzeLayersCmd)
+	defer resetFlags(rootCmd, analyzeLayersCmd)
+
+	rootCmd.SetArgs([]string{"analyzeLayers", "--repoList", "app", "--ignoreRepos", "Bad_Repo"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--ignoreRepos") {
+		t.Fatalf("Expected invalid --ignoreRepos error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "analyzeLayers called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRetryFailedCmd_MissingFile(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, retryFailedCmd)
+	defer func() {
+		resetFlags(rootCmd, retryFailedCmd)
+		exitCode = 0
+	}()
+
+	rootCmd.SetArgs([]string{"retryFailed", "--failuresFile", filepath.Join(t.TempDir(), "missing.json")})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[ERROR] failed to read failures file") || strings.Contains(buf.String(), "Using AWS account") {
+		t.Errorf("Expected the command to stop before calling AWS, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidReportS3Uri(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--reportS3Uri", "audit/reports"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "s3://bucket/prefix") {
+		t.Fatalf("Expected invalid S3 URI error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestPatternMatchedNothing(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	defer func() {
+		repoPatterns = nil
+		exitCode = 0
+	}()
+
+	// --- no pattern or a non-empty match carries on ---
+	if patternMatchedNothing(cmd, nil) || exitCode != 0 {
+		t.Errorf("Expected no error without a pattern")
+	}
+	repoPatterns = []string{"^app-.*"}
+	if patternMatchedNothing(cmd, []string{"app-web"}) || exitCode != 0 {
+		t.Errorf("Expected no error when the pattern matched")
+	}
+
+	// --- a pattern matching nothing stops the command with a non-zero exit code ---
+	if !patternMatchedNothing(cmd, nil) || exitCode != 1 {
+		t.Errorf("Expected an error when the pattern matched nothing, exit code %d", exitCode)
+	}
+	if !strings.Contains(buf.String(), `[ERROR] Pattern "^app-.*" matched no repositories`) {
+		t.Errorf("Expected pattern error, got: %s", buf.String())
+	}
+	repoPatterns = []string{"^app-.*", "^web-.*"}
+	if !patternMatchedNothing(cmd, nil) || !strings.Contains(buf.String(), `[ERROR] None of the patterns ["^app-.*" "^web-.*"] matched a repository`) {
+		t.Errorf("Expected an error naming every pattern, got: %s", buf.String())
+	}
+}
+
+func TestNoRepositories(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	defer func() {
+		failOnZeroRepos = false
+		exitCode = 0
+	}()
+
+	// --- permissive by default for interactive use ---
+	noRepositories(cmd, "clean")
+	if exitCode != 0 || !strings.Contains(buf.String(), "[INFO] No repositories to clean.") {
+		t.Errorf("Expected an info message and exit code 0, got %d: %s", exitCode, buf.String())
+	}
+
+	failOnZeroRepos = true
+	noRepositories(cmd, "clean")
+	if exitCode != 1 || !strings.Contains(buf.String(), "[ERROR] No repositories to clean, failing because of --failOnZeroRepos") {
+		t.Errorf("Expected an error and exit code 1, got %d: %s", exitCode, buf.String())
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	cmd.Flags().IntVar(&maxConcurrency, "maxConcurrency", concurrency.DefaultConcurrency, "")
+	defer func() {
+		maxConcurrency = concurrency.DefaultConcurrency
+		sequential, parallel = false, false
+	}()
+
+	sequential = true
+	if n := resolveConcurrency(cmd, 100); n != 1 {
+		t.Errorf("Expected --sequential to process one repository at a time, got %d", n)
+	}
+	sequential, parallel = false, true
+	if n := resolveConcurrency(cmd, 100); n != concurrency.DefaultConcurrency {
+		t.Errorf("Expected --parallel to use the default concurrency, got %d", n)
+	}
+
+	// --- an explicit --maxConcurrency wins with a warning ---
+	sequential, parallel = true, false
+	if err := cmd.Flags().Set("maxConcurrency", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if n := resolveConcurrency(cmd, 100); n != 3 {
+		t.Errorf("Expected --maxConcurrency to win, got %d", n)
+	}
+	if !strings.Contains(buf.String(), "[WARN] --maxConcurrency 3 wins over --sequential and --parallel") {
+		t.Errorf("Expected warning, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidOutput(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--output", "yaml"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--output") {
+		t.Fatalf("Expected invalid --output error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidTimezone(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--timezone", "CEST"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--timezone") {
+		t.Fatalf("Expected invalid --timezone error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_RepeatedRepoPattern(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	// --- the invalid --output stops the command after the flags were parsed ---
+	rootCmd.SetArgs([]string{"clean", "--repoPattern", "^service-a-.*", "-p", "^service-b-[0-9]{1,3}$", "--output", "yaml"})
+	_ = rootCmd.Execute()
+	if want := []string{"^service-a-.*", "^service-b-[0-9]{1,3}$"}; !reflect.DeepEqual(repoPatterns, want) {
+		t.Errorf("Expected %q, got %q", want, repoPatterns)
+	}
+}
+
+func TestRepositoryNamePrompt(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	cmd.SetIn(strings.NewReader("app\nyes\n"))
+
+	prompt := newRepositoryNamePrompt(cmd)
+	images := []string{"sha256:1", "sha256:2"}
+
+	// --- only the exact repository name confirms, anything else skips and end of input aborts ---
+	if got := prompt("app", images); got != deleteuntaggedimages.StepProceed {
+		t.Errorf("Expected StepProceed, got %v", got)
+	}
+	if got := prompt("web", images); got != deleteuntaggedimages.StepSkip {
+		t.Errorf("Expected StepSkip, got %v", got)
+	}
+	if got := prompt("api", images); got != deleteuntaggedimages.StepAbort {
+		t.Errorf("Expected StepAbort on EOF, got %v", got)
+	}
+	if !strings.Contains(buf.String(), "Repository: app - Delete ALL 2 images?") {
+		t.Errorf("Expected prompt output, got: %s", buf.String())
+	}
+}
+
+func TestManageCreationTemplatesCmd_InvalidPrefix(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, manageCreationTemplatesCmd)
+	defer resetFlags(rootCmd, manageCreationTemplatesCmd)
+
+	// --- the prefix is checked before the policy file is read or AWS is called ---
+	rootCmd.SetArgs([]string{"manageCreationTemplates", "--repoPrefix", "Team/", "--policyFile", "missing.json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[ERROR] invalid --repoPrefix") || strings.Contains(buf.String(), "Using AWS account") {
+		t.Errorf("Expected prefix error before AWS is called, got: %s", buf.String())
+	}
+}
+
+func TestEmptyCmd_RequiresYes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, emptyCmd)
+	defer resetFlags(rootCmd, emptyCmd)
+
+	rootCmd.SetArgs([]string{"empty", "--repoList", "app"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "yes") {
+		t.Fatalf("Expected missing --yes error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "empty called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestCleanCmd_ValidationErrorExitCode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer func() {
+		resetFlags(rootCmd, cleanCmd)
+		exitCode = 0
+	}()
+
+	// --- a run stopped by an invalid flag exits non-zero, so CI and --watch see it as failed ---
+	exitCode = 0
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--warnAboveCount", "-1"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[ERROR] --warnAboveCount must not be negative") || exitCode != 1 {
+		t.Errorf("Expected a validation error and exit code 1, got %d: %s", exitCode, buf.String())
+	}
+}
+
+func TestExampleTemplates(t *testing.T) {
+	clean := deleteuntaggedimages.CleanReport{Repositories: []deleteuntaggedimages.RepositoryCleanResult{
+		{Repository: "app", Tagged: 3, Untagged: 2, Deleted: 2},
+		{Repository: "web", Deleted: 1, Failed: 1, Failures: []deleteuntaggedimages.FailedDeletion{{Repository: "web", Digest: "sha256:1", FailureCode: "ImageReferencedByManifestList", FailureReason: "in use", Timestamp: time.Now()}}},
+		{Repository: "api", Error: "access denied"},
+	}}
+	policy := setlifecyclepolicy.SetPolicyReport{Applied: []string{"app", "web"}, Failed: []setlifecyclepolicy.RepositoryError{{Repository: "api", Message: "denied"}}, PolicyID: "v2"}
+	layers := layersharing.LayerSharingReport{Images: 4, Repositories: 2, UniqueLayerCount: 6, UniqueBytes: 3 << 20, ReferencedBytes: 5 << 20, EstimatedDeduplicationSavings: 2 << 20}
+
+	// --- every shipped template renders against the report of its command ---
+	for name, report := range map[string]interface{}{
+		"clean-slack.tmpl":       clean,
+		"clean-jira.tmpl":        clean,
+		"setpolicy-summary.tmpl": policy,
+		"analyzelayers.tmpl":     layers,
+	} {
+		tmpl, err := format.ParseTemplate(filepath.Join("..", "example", "templates", name))
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		path := filepath.Join(t.TempDir(), "out.txt")
+		if err := format.WriteTemplate(path, tmpl, report); err != nil {
+			t.Errorf("Failed to render %s: %v", name, err)
+		}
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	clean := deleteuntaggedimages.CleanReport{Repositories: []deleteuntaggedimages.RepositoryCleanResult{
+		{Repository: "app", Tagged: 3, Untagged: 2, Deleted: 2},
+		{Repository: "api", Error: "access denied"},
+	}}
+	policy := setlifecyclepolicy.SetPolicyReport{Applied: []string{"app"}, Skipped: []string{"web"}, Failed: []setlifecyclepolicy.RepositoryError{{Repository: "api", Message: "denied"}}}
+	layers := layersharing.LayerSharingReport{Images: 4, Repositories: 2}
+
+	// --- every built-in template offered for a command renders against its report ---
+	for family, report := range map[string]interface{}{"clean": clean, "setPolicy": policy, "analyzeLayers": layers} {
+		for _, name := range format.BuiltinTemplates(family) {
+			tmpl, err := format.ParseBuiltinTemplate(name, family)
+			if err != nil {
+				t.Fatalf("Failed to parse @%s for %s: %v", name, family, err)
+			}
+			path := filepath.Join(t.TempDir(), "out.txt")
+			if err := format.WriteTemplate(path, tmpl, report); err != nil {
+				t.Errorf("Failed to render @%s for %s: %v", name, family, err)
+			}
+		}
+	}
+}
+
+func TestReportPath(t *testing.T) {
+	defer func() { outputFile, reportTemplate, logsToStderr = "", nil, false }()
+
+	cases := []struct {
+		outputFile   string
+		template     bool
+		logsToStderr bool
+		want         string
+	}{
+		{want: ""},
+		{outputFile: "report.json", want: "report.json"},
+		{template: true, want: "-"},
+		{logsToStderr: true, want: "-"},
+		{outputFile: "-", logsToStderr: true, want: "-"},
+	}
+	for _, c := range cases {
+		outputFile, reportTemplate, logsToStderr = c.outputFile, nil, c.logsToStderr
+		if c.template {
+			reportTemplate = template.Must(template.New("report").Parse("{{.}}"))
+		}
+		if got := reportPath(); got != c.want {
+			t.Errorf("reportPath() with %+v = %q, want %q", c, got, c.want)
+		}
+	}
+}
+
+func TestRootCmd_JSONLogsToStderrConflict(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rootCmd.SetOut(buf)
+	rootCmd.SetErr(buf)
+	resetFlags(rootCmd, cleanCmd)
+	defer resetFlags(rootCmd, cleanCmd)
+
+	// --- the plan and the report would both be written to stdout ---
+	rootCmd.SetArgs([]string{"clean", "--allRepos", "--jsonLogsToStderr", "--planFile", "-"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--jsonLogsToStderr") {
+		t.Fatalf("Expected --jsonLogsToStderr conflict error, got: %v", err)
+	}
+	if strings.Contains(buf.String(), "clean called") {
+		t.Errorf("Expected the command not to run, got: %s", buf.String())
+	}
+}
+
+func TestRootCmd_InvalidOutputLayout(t *testing.T) {
+	for flag, value := range map[string]string{"--outputSeparator": "semicolon", "--jsonMode": "ndjson"} {
+		buf := new(bytes.Buffer)
+		rootCmd.SetOut(buf)
+		rootCmd.SetErr(buf)
+		resetFlags(rootCmd, cleanCmd)
+
+		rootCmd.SetArgs([]string{"clean", "--allRepos", flag, value})
+		err := rootCmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), flag) {
+			t.Errorf("Expected invalid %s error, got: %v", flag, err)
+		}
+		if strings.Contains(buf.String(), "clean called") {
+			t.Errorf("Expected the command not to run, got: %s", buf.String())
+		}
+	}
+	resetFlags(rootCmd, cleanCmd)
+}
+
+func TestDryRunSummaryRows(t *testing.T) {
+	plan := deleteuntaggedimages.CleanPlan{Repositories: []deleteuntaggedimages.RepositoryPlan{
+		{Repository: "app", Tagged: 3, Untagged: 2, Images: []string{"d1", "d2"}},
+		{Repository: "web", Tagged: 1, Untagged: 1, Images: []string{"d3"}},
+		{Repository: "legacy", Skipped: true},
+	}}
+	rows := dryRunSummaryRows(plan, map[string]int64{"app": 2048, "web": 1024})
+	want := [][]string{
+		{"app", "3", "2", "2", "2.0 KiB"},
+		{"web", "1", "1", "1", "1.0 KiB"},
+		{"legacy", "0", "0", "0", "skipped"},
+		{"TOTAL", "4", "3", "3", "3.0 KiB"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Expected %v, got: %v", want, rows)
+	}
+
+	// --- info lines are replaced by the table, warnings stay ---
+	if keepSummaryLog("[INFO] Checking repository: app") || keepSummaryLog("[DRY RUN] Would delete 2 images from repository: app") || !keepSummaryLog("[WARN] Repository: app - Holds 5 images") {
+		t.Errorf("Expected only warnings and errors to be kept")
+	}
+}
+
+func TestMergeDigests(t *testing.T) {
+	merged := mergeDigests(map[string]struct{}{"sha256:a": {}}, map[string]struct{}{"sha256:a": {}, "sha256:b": {}})
+	if !reflect.DeepEqual(merged, map[string]struct{}{"sha256:a": {}, "sha256:b": {}}) {
+		t.Errorf("Expected the union of both sets, got: %v", merged)
+	}
+	if merged := mergeDigests(nil, nil); len(merged) != 0 {
+		t.Errorf("Expected an empty set, got: %v", merged)
+	}
+}
+
+func TestInspectRows(t *testing.T) {
+	report := deleteuntaggedimages.InspectReport{Repositories: []deleteuntaggedimages.InspectedRepository{
+		{Repository: "app", NeverTagged: 3, TagMoved: 2, MultiArchChild: 4},
+		{Repository: "web"},
+	}}
+	want := [][]string{{"app", "3", "2", "4"}, {"web", "0", "0", "0"}}
+	if got := inspectRows(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestWatchLoop(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	defer func() { exitCode = 0 }()
+
+	stop, cancel := context.WithCancel(context.Background())
+	ticks := make(chan time.Time)
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchLoop(stop, cmd, ticks, func() {
+			runs++
+			if runs == 2 {
+				exitCode = 1
+			}
+			if runs == 3 {
+				// --- stopping during a run lets it finish before the loop returns ---
+				cancel()
+			}
+		})
+	}()
+	ticks <- time.Now()
+	ticks <- time.Now()
+	<-done
+
+	if runs != 3 {
+		t.Errorf("expected 3 runs, got %d", runs)
+	}
+	if exitCode != 0 {
+		t.Errorf("expected a failed run not to fail the watch, exit code %d", exitCode)
+	}
+	for _, want := range []string{"[INFO] Watch run 1 finished", "[WARN] Watch run 2 failed", "[INFO] Stopped watching after 3 runs, 1 failed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in the output, got %s", want, buf.String())
+		}
+	}
+}
+
+func TestExcludeAccounts(t *testing.T) {
+	buf := new(bytes.Buffer)
+	cmd := &cobra.Command{}
+	cmd.SetOut(buf)
+	excludeAccountIDs = []string{"111111111111", "999999999999"}
+	defer func() { excludeAccountIDs = nil }()
+
+	got := excludeAccounts(cmd, []string{"111111111111", "222222222222", "333333333333"})
+	if want := []string{"222222222222", "333333333333"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if !strings.Contains(buf.String(), "[WARN] Account 999999999999 in --excludeAccountId is not an active account") {
+		t.Errorf("expected a warning about the unknown account, got %s", buf.String())
+	}
+}
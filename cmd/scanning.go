@@ -0,0 +1,176 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"strconv"
+
+	deleteuntaggedimages "ecr-lifecycle-cleaner/internal/deleteUntaggedImages"
+	"ecr-lifecycle-cleaner/internal/format"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	scanningconfig "ecr-lifecycle-cleaner/internal/scanningConfig"
+
+	"github.com/spf13/cobra"
+)
+
+var scanFrequency string
+
+var enableScanCmd = &cobra.Command{
+	Use:   "enableScan",
+	Short: "Sets how often the images of the selected repositories are scanned.",
+	Long: `Sets the scan frequency of the selected repositories in Amazon Elastic Container Registry (ECR).
+
+--scanFrequency is one of:
+  SCAN_ON_PUSH     every pushed image is scanned (default)
+  CONTINUOUS_SCAN  images are rescanned when new vulnerabilities are published, needs enhanced scanning
+  MANUAL           images are only scanned when a scan is started by hand
+
+With basic scanning the frequency is set per repository. With enhanced scanning the registry scanning rules decide
+the frequency, so repositories whose rules do not give the requested frequency are reported as FAILED with the rule to add.
+Each repository is reported as ALREADY_SET, CHANGED or FAILED.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] enableScan called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		frequency, err := scanningconfig.ParseScanFrequency(scanFrequency)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid --scanFrequency: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		repos, ok := selectRepositories(cmd, client, "update")
+		if !ok {
+			return
+		}
+
+		report, err := scanningconfig.SetScanFrequency(ctx, client, repos, frequency, scanningconfig.Options{
+			DryRun:      dryRun,
+			Concurrency: resolveConcurrency(cmd, len(repos)),
+			Logs:        logs,
+		})
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to set scan frequency: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] Finished setting scan frequency.")
+	},
+}
+
+var checkScanConfigCmd = &cobra.Command{
+	Use:   "checkScanConfig",
+	Short: "Reports whether scanning is enabled on the selected repositories and how often.",
+	Long: `Reports the scanning configuration of the selected repositories in Amazon Elastic Container Registry (ECR).
+
+For every repository the effective scan frequency (SCAN_ON_PUSH, CONTINUOUS_SCAN or MANUAL) is shown, taking the
+registry scanning rules into account. Repositories with MANUAL scanning are only scanned when someone starts a scan,
+so they are flagged as potentially non-compliant. Nothing is changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] checkScanConfig called")
+
+		ctx := cmd.Context()
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		client, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+
+		repos, ok := selectRepositories(cmd, client, "check")
+		if !ok {
+			return
+		}
+
+		report, err := scanningconfig.Check(ctx, client, repos)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to read scanning configuration: %v\n", err)
+			exitCode = 1
+			return
+		}
+		rows := make([][]string, 0, len(report.Repositories))
+		for _, repo := range report.Repositories {
+			status := "ok"
+			switch {
+			case repo.Error != "":
+				status = repo.Error
+			case !repo.Enabled:
+				status = "non-compliant"
+			}
+			rows = append(rows, []string{repo.Repository, string(repo.ScanFrequency), strconv.FormatBool(repo.ScanOnPush), status})
+		}
+		if err := format.WriteTable(cmd.ErrOrStderr(), []string{"REPOSITORY", "FREQUENCY", "SCAN-ON-PUSH", "STATUS"}, rows); err != nil {
+			cmd.Printf("[ERROR] Failed to print scanning configuration: %v\n", err)
+		}
+		for _, repo := range report.NonCompliant() {
+			cmd.Printf("[WARN] Repository: %s - MANUAL scanning, images are only scanned on demand (potentially non-compliant)\n", repo)
+		}
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+	},
+}
+
+// --- returns the repositories chosen with --allRepos, --repoPattern or --repoList, narrowed by --ignoreRepos and --continueFrom, ---
+// --- false when there is nothing to do ---
+func selectRepositories(cmd *cobra.Command, client deleteuntaggedimages.ECRAPI, action string) ([]string, bool) {
+	var repos []string
+	var err error
+	if allRepos {
+		repos, err = deleteuntaggedimages.ListRepositories(cmd.Context(), client)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+			return nil, false
+		}
+	} else if len(repoPatterns) > 0 {
+		repos, err = deleteuntaggedimages.ListRepositoriesByPattern(cmd.Context(), client, repoPatterns)
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+			return nil, false
+		}
+		if patternMatchedNothing(cmd, repos) {
+			return nil, false
+		}
+	} else {
+		repos = repositoryList
+	}
+	repos = applyIgnoreRepos(cmd, repos)
+	if repos, err = applyContinueFrom(cmd, repos); err != nil {
+		cmd.Printf("[ERROR] %v\n", err)
+		exitCode = 1
+		return nil, false
+	}
+
+	if len(repos) == 0 {
+		noRepositories(cmd, action)
+		return nil, false
+	}
+	return repos, true
+}
+
+func init() {
+	rootCmd.AddCommand(enableScanCmd)
+	rootCmd.AddCommand(checkScanConfigCmd)
+
+	enableScanCmd.Flags().StringVar(&scanFrequency, "scanFrequency", string(scanningconfig.ScanOnPush), "how often images are scanned: SCAN_ON_PUSH, CONTINUOUS_SCAN (needs enhanced scanning) or MANUAL")
+}
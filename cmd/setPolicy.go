@@ -3,18 +3,28 @@
 package cmd
 
 import (
-	"strings"
+	"slices"
+	"strconv"
 
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	concurrency "ecr-lifecycle-cleaner/internal/concurrency"
+	format "ecr-lifecycle-cleaner/internal/format"
 	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
 	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
 	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/spf13/cobra"
 )
 
 var (
-	policyFile string
+	policyFile         string
+	policyJSON         string
+	onlyIfPolicyAbsent bool
+	policyID           string
+	createMissingRepos bool
+	immutableOnly      bool
+	strictJSON         bool
+	previewImpact      bool
 )
 
 var setPolicyCmd = &cobra.Command{
@@ -22,51 +32,133 @@ var setPolicyCmd = &cobra.Command{
 	Short: "Automates the management of lifecycle policies in ECR.",
 	Long: `Automates the management of lifecycle policies in Amazon Elastic Container Registry (ECR).
 
-Based on the provided policy, it sets lifecycle policies for specified repositories in the account.`,
+Based on the provided policy, it sets lifecycle policies for specified repositories in the account.
+
+Exit codes: 0 when every repository succeeded, 1 when some repositories failed and others succeeded,
+2 when every processed repository failed. With --failOnPartialSuccess=false a partial failure exits 0.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Println("[INFO] setPolicy called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
 
-		if repoList != "" {
-			repositoryList = strings.Split(repoList, ",")
+		if previewImpact && !dryRun && !planOnly {
+			cmd.Println("[ERROR] --previewImpact estimates the impact of a policy before it is applied, it requires --dryRun or --planOnly")
+			exitCode = 1
+			return
 		}
 
 		ctx := cmd.Context()
-		policyText, err := readpolicyfile.ReadPolicyFile(policyFile)
+		var policyText string
+		var err error
+		if policyJSON != "" {
+			policyText, err = readpolicyfile.ReadPolicyJSON(policyJSON)
+		} else {
+			policyText, err = readpolicyfile.ReadPolicyFile(policyFile)
+		}
 		if err != nil {
-			cmd.Printf("[ERROR] Reading policy file: %v\n", err)
+			cmd.Printf("[ERROR] Reading policy: %v\n", err)
+			exitCode = 1
 			return
 		}
+		if strictJSON {
+			if err := readpolicyfile.ValidateStrict(policyText); err != nil {
+				cmd.Printf("[ERROR] %v\n", err)
+				exitCode = 1
+				return
+			}
+		}
 
-		client, account, region, err := initawsclient.NewECRClient(ctx, config.LoadDefaultConfig)
+		limits, err := concurrency.ParseOperationLimits(operationLimits)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid --concurrencyLimitPerOperation: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		ecrClient, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
 		if err != nil {
 			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
 			return
 		}
 		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+		client := setlifecyclepolicy.WithOperationLimits(ecrClient, limits)
+		if len(limits) > 0 {
+			cmd.Printf("[INFO] Using per-operation limits: %s\n", limits)
+		}
+
+		var filters []setlifecyclepolicy.RepositoryFilter
+		if immutableOnly {
+			filters = append(filters, setlifecyclepolicy.TagImmutabilityFilter(true))
+		}
 
 		var repos []string
 		if allRepos {
-			repos, err = setlifecyclepolicy.GetRepositories(ctx, client)
+			repos, err = setlifecyclepolicy.GetRepositories(ctx, client, filters...)
 			if err != nil {
 				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
 				return
 			}
-		} else if repoPattern != "" {
-			repos, err = setlifecyclepolicy.GetRepositoriesByPattern(ctx, client, repoPattern)
+		} else if len(repoPatterns) > 0 {
+			repos, err = setlifecyclepolicy.GetRepositoriesByPattern(ctx, client, repoPatterns, filters...)
 			if err != nil {
 				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else if immutableOnly {
+			repos, err = keepImmutable(cmd, client, repositoryList)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list immutable repositories: %v\n", err)
+				exitCode = 1
 				return
 			}
 		} else {
 			repos = repositoryList
 		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
 
 		if len(repos) == 0 {
-			cmd.Println("[INFO] No repositories to set policies for.")
+			noRepositories(cmd, "set policies for")
+			return
+		}
+
+		opts := setlifecyclepolicy.SetPolicyOptions{
+			DryRun:             dryRun,
+			OnlyIfAbsent:       onlyIfPolicyAbsent,
+			PolicyID:           policyID,
+			CreateMissingRepos: createMissingRepos,
+			Registry:           awsregistry.Registry{Account: account, Region: region},
+			Logs:               logs,
+			Concurrency:        resolveConcurrency(cmd, len(repos)),
+			PreviewImpact:      previewImpact,
+		}
+		plan := setlifecyclepolicy.PlanPolicy(ctx, client, policyText, repos, opts)
+		flushLogs(cmd, logs)
+		printPolicyPlan(cmd, plan)
+		writePlanFile(cmd, plan)
+		if planOnly {
+			cmd.Println("[INFO] Plan only, no policies were changed.")
 			return
 		}
 
-		err = setlifecyclepolicy.Main(client, policyText, allRepos, repos, repoPattern, dryRun)
+		report, err := setlifecyclepolicy.ExecutePolicyPlan(ctx, client, policyText, plan, opts)
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s (policy checksum: %s)\n", report.Summary(), report.PolicyChecksum)
+		writeOutputFile(cmd, report)
+		setRepositoryExitCode(cmd, len(report.Failed), len(report.Applied)+len(report.Skipped)+len(report.Failed))
 		if err != nil {
 			cmd.Printf("[ERROR] Failed to set lifecycle policies: %v\n", err)
 			return
@@ -76,9 +168,82 @@ Based on the provided policy, it sets lifecycle policies for specified repositor
 	},
 }
 
+// --- drops the repositories of --repoList that do not have tag immutability enabled ---
+func keepImmutable(cmd *cobra.Command, client setlifecyclepolicy.LifecyclePolicyAPI, repos []string) ([]string, error) {
+	immutable, err := setlifecyclepolicy.GetRepositories(cmd.Context(), client, setlifecyclepolicy.TagImmutabilityFilter(true))
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		if !slices.Contains(immutable, repo) {
+			cmd.Printf("[INFO] Repository: %s - Not immutable or does not exist, skipped by --immutableOnly\n", repo)
+			continue
+		}
+		kept = append(kept, repo)
+	}
+	return kept, nil
+}
+
+// --- prints the pre-flight plan as a table, one row per repository ---
+// --- with --previewImpact the images the policy would expire are shown per repository and in total ---
+func printPolicyPlan(cmd *cobra.Command, plan setlifecyclepolicy.PolicyPlan) {
+	expiring, previewed := plan.ExpiringImages()
+	header := []string{"REPOSITORY", "ACTION", "DETAIL"}
+	if previewImpact {
+		header = []string{"REPOSITORY", "ACTION", "EXPIRES", "DETAIL"}
+	}
+	rows := make([][]string, 0, len(plan.Repositories))
+	toApply := 0
+	for _, repo := range plan.Repositories {
+		action, detail := "skip", repo.Reason
+		switch {
+		case repo.Error != "":
+			action, detail = "error", repo.Error
+		case repo.Apply && repo.Create:
+			action, detail = "create", "creates repository with default settings"
+			toApply++
+		case repo.Apply && repo.HasPolicy:
+			action, detail = "apply", "replaces existing policy"
+			toApply++
+		case repo.Apply:
+			action, detail = "apply", "no existing policy"
+			toApply++
+		}
+		if !previewImpact {
+			rows = append(rows, []string{repo.Repository, action, detail})
+			continue
+		}
+		expires := "-"
+		switch {
+		case repo.ExpiringImages != nil:
+			expires = strconv.Itoa(*repo.ExpiringImages)
+		case repo.PreviewError != "":
+			expires = "?"
+		}
+		rows = append(rows, []string{repo.Repository, action, expires, detail})
+	}
+	cmd.Printf("[INFO] Plan: apply policy to %d of %d repositories\n", toApply, len(plan.Repositories))
+	if err := format.WriteTable(cmd.ErrOrStderr(), header, rows); err != nil {
+		cmd.Printf("[ERROR] Failed to print plan: %v\n", err)
+	}
+	if previewImpact {
+		cmd.Printf("[INFO] %d images would be expired under this policy across %d previewed repositories\n", expiring, previewed)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(setPolicyCmd)
 
-	setPolicyCmd.Flags().StringVarP(&policyFile, "policyFile", "f", "", "path to the JSON file containing the lifecycle policy")
-	setPolicyCmd.MarkFlagRequired("policyFile") // nolint:errcheck
+	setPolicyCmd.Flags().StringVarP(&policyFile, "policyFile", "f", "", "path to the JSON file containing the lifecycle policy, or env:VAR_NAME to read it from an environment variable")
+	setPolicyCmd.Flags().StringVar(&policyJSON, "policyJson", "", "the lifecycle policy as an inline JSON string instead of --policyFile, e.g. for CI one-liners")
+	setPolicyCmd.Flags().BoolVar(&onlyIfPolicyAbsent, "onlyIfPolicyAbsent", false, "only apply the policy to repositories that have no lifecycle policy yet, existing policies are never overwritten")
+	setPolicyCmd.Flags().StringVar(&policyID, "policyId", "", "label logged alongside each apply to identify the policy version (e.g. v3 or a git sha)")
+	setPolicyCmd.Flags().BoolVar(&createMissingRepos, "createMissingRepos", false, "create repositories listed in --repoList that do not exist yet (default settings) before setting the policy")
+	setPolicyCmd.Flags().BoolVar(&strictJSON, "strictJson", false, "reject policies with unknown or wrongly cased field names (e.g. rulePriorty or Rules), which ECR silently ignores")
+	setPolicyCmd.Flags().BoolVar(&previewImpact, "previewImpact", false, "with --dryRun or --planOnly, preview the policy on every repository it would be applied to with ECR's lifecycle policy preview and show how many images it would expire")
+	setPolicyCmd.Flags().BoolVar(&immutableOnly, "immutableOnly", false, "only apply the policy to repositories with tag immutability enabled")
+	setPolicyCmd.Flags().StringVar(&operationLimits, "concurrencyLimitPerOperation", "", "cap calls in flight per ECR operation on top of --maxConcurrency, in the plan and dry runs too (GetLifecyclePolicy, PutLifecyclePolicy, e.g. PutLifecyclePolicy=2)")
+	setPolicyCmd.MarkFlagsOneRequired("policyFile", "policyJson")
+	setPolicyCmd.MarkFlagsMutuallyExclusive("policyFile", "policyJson")
 }
@@ -0,0 +1,122 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	concurrency "ecr-lifecycle-cleaner/internal/concurrency"
+	initawsclient "ecr-lifecycle-cleaner/internal/initAwsClient"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+
+	"github.com/spf13/cobra"
+)
+
+var policyDir string
+
+var setPolicyFromDirCmd = &cobra.Command{
+	Use:   "setPolicyFromDir",
+	Short: "Applies different lifecycle policies to different groups of repositories from a directory.",
+	Long: `Applies lifecycle policies from a directory to Amazon Elastic Container Registry (ECR) repositories.
+
+Every .json file in the directory is a policy, its file name without the extension is a glob over repository names,
+e.g. prod-*.json applies to prod-api and prod-web, and * also matches the / of namespaced repositories.
+Files are tried in file name order and the first match wins, repositories no file matches are skipped.
+
+Exit codes: 0 when every repository succeeded, 1 when some repositories failed and others succeeded,
+2 when every processed repository failed. With --failOnPartialSuccess=false a partial failure exits 0.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] setPolicyFromDir called")
+		logs := newLogBuffer()
+		defer flushLogs(cmd, logs)
+
+		ctx := cmd.Context()
+		policies, err := setlifecyclepolicy.LoadPolicyDir(policyDir)
+		if err != nil {
+			cmd.Printf("[ERROR] Reading policy directory: %v\n", err)
+			exitCode = 1
+			return
+		}
+		for _, policy := range policies {
+			cmd.Printf("[INFO] Policy %s applies to repositories matching %s\n", policy.File, policy.Pattern)
+		}
+
+		limits, err := concurrency.ParseOperationLimits(operationLimits)
+		if err != nil {
+			cmd.Printf("[ERROR] Invalid --concurrencyLimitPerOperation: %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		metrics := newMetricsCollector()
+		defer printAPIMetrics(cmd, metrics)
+
+		ecrClient, account, region, err := initawsclient.NewECRClient(ctx, newConfigLoader(metrics))
+		if err != nil {
+			cmd.Printf("[ERROR] Failed to initialize AWS client: %v\n", err)
+			exitCode = 1
+			return
+		}
+		cmd.Printf("[INFO] Using AWS account: %s, region: %s\n", account, region)
+		client := setlifecyclepolicy.WithOperationLimits(ecrClient, limits)
+
+		var repos []string
+		if allRepos {
+			repos, err = setlifecyclepolicy.GetRepositories(ctx, client)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories: %v\n", err)
+				exitCode = 1
+				return
+			}
+		} else if len(repoPatterns) > 0 {
+			repos, err = setlifecyclepolicy.GetRepositoriesByPattern(ctx, client, repoPatterns)
+			if err != nil {
+				cmd.Printf("[ERROR] Failed to list repositories by pattern: %v\n", err)
+				exitCode = 1
+				return
+			}
+			if patternMatchedNothing(cmd, repos) {
+				return
+			}
+		} else {
+			repos = repositoryList
+		}
+		repos = applyIgnoreRepos(cmd, repos)
+		if repos, err = applyContinueFrom(cmd, repos); err != nil {
+			cmd.Printf("[ERROR] %v\n", err)
+			exitCode = 1
+			return
+		}
+
+		if len(repos) == 0 {
+			noRepositories(cmd, "set policies for")
+			return
+		}
+
+		report := setlifecyclepolicy.SetPolicyFromDir(ctx, client, policyDir, repos, setlifecyclepolicy.SetPolicyOptions{
+			DryRun:      dryRun,
+			PolicyID:    policyID,
+			Registry:    awsregistry.Registry{Account: account, Region: region},
+			Logs:        logs,
+			Concurrency: resolveConcurrency(cmd, len(repos)),
+		})
+		flushLogs(cmd, logs)
+		cmd.Printf("[INFO] %s\n", report.Summary())
+		writeOutputFile(cmd, report)
+		setRepositoryExitCode(cmd, len(report.Failed), len(report.Applied)+len(report.Skipped)+len(report.Failed))
+		if len(report.Failed) > 0 {
+			cmd.Printf("[ERROR] Failed to set lifecycle policies for %d repositories\n", len(report.Failed))
+			return
+		}
+
+		cmd.Println("[INFO] Finished ECR lifecycle policy setup from directory.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(setPolicyFromDirCmd)
+
+	setPolicyFromDirCmd.Flags().StringVarP(&policyDir, "policyDir", "d", "", "directory of lifecycle policy files named by repository glob, e.g. prod-*.json and staging-*.json")
+	setPolicyFromDirCmd.Flags().StringVar(&policyID, "policyId", "", "label logged alongside each apply instead of the policy file name (e.g. v3 or a git sha)")
+	setPolicyFromDirCmd.Flags().StringVar(&operationLimits, "concurrencyLimitPerOperation", "", "cap calls in flight per ECR operation on top of --maxConcurrency (GetLifecyclePolicy, PutLifecyclePolicy, e.g. PutLifecyclePolicy=2)")
+	setPolicyFromDirCmd.MarkFlagRequired("policyDir") // nolint:errcheck
+}
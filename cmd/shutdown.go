@@ -0,0 +1,44 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+// --- returns a channel closed on the first SIGTERM or interrupt, e.g. a pod eviction, for CleanOptions.Stop ---
+// --- the run context is left alone so BatchDeleteImage calls in flight finish instead of being cut off mid-call ---
+// --- a second signal gets the default behaviour and exits right away, release unregisters the handler ---
+func gracefulStop(cmd *cobra.Command) (stop <-chan struct{}, release func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cmd.Printf("[WARN] Received %s, finishing the deletions in flight without starting new ones, signal again to exit right away\n", sig)
+			close(stopped)
+		case <-done:
+		}
+	}()
+	return stopped, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// --- reports an interrupted run and makes the process exit non-zero, so a scheduler sees the work is unfinished ---
+func reportInterrupted(cmd *cobra.Command, interrupted bool) bool {
+	if !interrupted {
+		return false
+	}
+	cmd.Println("[WARN] Stopped by a signal before every repository was done, run again to finish")
+	exitCode = 1
+	return true
+}
@@ -0,0 +1,99 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"os"
+
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
+
+	"github.com/spf13/cobra"
+)
+
+var writeSchemaPath string
+
+var validatePolicyCmd = &cobra.Command{
+	Use:   "validatePolicy [policy files...]",
+	Short: "Validates lifecycle policies against the JSON Schema of the policy document, without AWS.",
+	Long: `Validates lifecycle policies against the JSON Schema (draft-07) of the Amazon Elastic Container Registry (ECR)
+lifecycle policy document and the strict field name check of --strictJson. Every violation is reported, not only the first.
+
+No AWS call is made and no credentials are needed, so policy files can be linted in pull requests. Policy files can be
+given as arguments as well as with --policyFile, and the process exits non-zero when any of them is invalid.
+
+The schema location is printed so editors can be pointed at it for in-IDE validation, e.g. with a "$schema" key
+or the editor's JSON schema settings. --writeSchema writes a local copy for offline use.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Println("[INFO] validatePolicy called")
+		cmd.Printf("[INFO] Schema: %s\n", readpolicyfile.SchemaURL)
+
+		if writeSchemaPath != "" {
+			if err := os.WriteFile(writeSchemaPath, readpolicyfile.Schema(), 0o644); err != nil {
+				cmd.Printf("[ERROR] Failed to write schema: %v\n", err)
+				exitCode = 1
+				return
+			}
+			cmd.Printf("[INFO] Wrote schema to %s\n", writeSchemaPath)
+		}
+
+		files := args
+		if policyFile != "" {
+			files = append([]string{policyFile}, args...)
+		}
+		if len(files) == 0 && policyJSON == "" {
+			if writeSchemaPath == "" {
+				cmd.Println("[ERROR] Nothing to validate, pass policy files, --policyFile or --policyJson")
+				exitCode = 1
+			}
+			return
+		}
+
+		checked, invalid := 0, 0
+		if policyJSON != "" {
+			checked++
+			if !validatePolicySource(cmd, "inline policy", readpolicyfile.ReadPolicyJSON, policyJSON) {
+				invalid++
+			}
+		}
+		for _, file := range files {
+			checked++
+			if !validatePolicySource(cmd, file, readpolicyfile.ReadPolicyFile, file) {
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			cmd.Printf("[ERROR] %d of %d policies are invalid\n", invalid, checked)
+			exitCode = 1
+			return
+		}
+
+		cmd.Println("[INFO] All policies are valid.")
+	},
+}
+
+// --- reads a policy with read and runs the schema validation, then the --strictJson check setPolicy would run ---
+// --- the strict check goes second as it stops at the first unknown field, which the schema already lists ---
+func validatePolicySource(cmd *cobra.Command, name string, read func(string) (string, error), source string) bool {
+	policyText, err := read(source)
+	if err == nil {
+		err = readpolicyfile.ValidatePolicySchema(policyText)
+	}
+	if err == nil {
+		err = readpolicyfile.ValidateStrict(policyText)
+	}
+	if err != nil {
+		cmd.Printf("[ERROR] %s: %v\n", name, err)
+		return false
+	}
+	cmd.Printf("[INFO] %s: valid\n", name)
+	return true
+}
+
+func init() {
+	rootCmd.AddCommand(validatePolicyCmd)
+
+	validatePolicyCmd.Flags().StringVarP(&policyFile, "policyFile", "f", "", "path to the JSON file containing the lifecycle policy, or env:VAR_NAME to read it from an environment variable")
+	validatePolicyCmd.Flags().StringVar(&policyJSON, "policyJson", "", "the lifecycle policy as an inline JSON string instead of --policyFile")
+	validatePolicyCmd.Flags().StringVar(&writeSchemaPath, "writeSchema", "", "write the JSON Schema of the lifecycle policy document to this path, e.g. "+readpolicyfile.SchemaFileName)
+	validatePolicyCmd.MarkFlagsMutuallyExclusive("policyFile", "policyJson")
+}
@@ -0,0 +1,88 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"ecr-lifecycle-cleaner/internal/tracing"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	watchMode     bool
+	watchInterval time.Duration
+)
+
+// --- wraps the run of a command so that with --watch it repeats every --interval until SIGTERM or an interrupt ---
+// --- every run builds its own clients and log buffer, so nothing is carried over and memory does not grow across runs ---
+func watched(run func(cmd *cobra.Command, args []string)) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if !watchMode {
+			run(cmd, args)
+			return
+		}
+		if watchInterval <= 0 {
+			cmd.Println("[ERROR] --interval must be positive")
+			exitCode = 1
+			return
+		}
+		// --- the signal stops the loop, the run in progress keeps its own context, clean only stops starting new deletions ---
+		stop, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer cancel()
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		cmd.Printf("[INFO] Watching, running %s every %s until stopped\n", cmd.Name(), watchInterval)
+		watchLoop(stop, cmd, ticker.C, func() { run(cmd, args) })
+	}
+}
+
+// --- runs once straight away and again on every tick, returns once stop is done and the current run has finished ---
+func watchLoop(stop context.Context, cmd *cobra.Command, ticks <-chan time.Time, run func()) {
+	base := cmd.Context()
+	if base == nil {
+		base = context.Background()
+	}
+	var runs, failed int
+	for {
+		runs++
+		exitCode = 0
+		started := time.Now()
+		cmd.Printf("[INFO] Watch run %d started\n", runs)
+		ctx, span := tracing.Start(base, "WatchRun", attribute.Int("watch.run", runs))
+		cmd.SetContext(ctx)
+		run()
+		span.End()
+		cmd.SetContext(base)
+		// --- a failed run is logged and counted, the next one still happens and the daemon exits cleanly when stopped ---
+		if exitCode != 0 {
+			failed++
+			exitCode = 0
+			cmd.Printf("[WARN] Watch run %d failed after %s\n", runs, time.Since(started).Round(time.Millisecond))
+		} else {
+			cmd.Printf("[INFO] Watch run %d finished in %s\n", runs, time.Since(started).Round(time.Millisecond))
+		}
+
+		select {
+		case <-stop.Done():
+			cmd.Printf("[INFO] Stopped watching after %d runs, %d failed\n", runs, failed)
+			return
+		case <-ticks:
+		}
+	}
+}
+
+func init() {
+	for _, c := range []*cobra.Command{cleanCmd, setPolicyCmd} {
+		// --- every watch run goes through all accounts of the organization with --useOrg ---
+		c.Run = watched(acrossOrganization(c.Run))
+		c.Flags().BoolVar(&watchMode, "watch", false, "keep running and repeat the command every --interval, SIGTERM or an interrupt exits after the current run, clean stops it once the deletions in flight are done")
+		c.Flags().DurationVar(&watchInterval, "interval", time.Hour, "time between the starts of two --watch runs (e.g. 30m, 6h), a run taking longer delays the next one")
+	}
+}
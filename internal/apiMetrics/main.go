@@ -0,0 +1,119 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package apimetrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// --- Collector counts API calls and throttled attempts per operation ---
+// --- it reads the retry attempt results the SDK attaches to the response metadata ---
+type Collector struct {
+	mu         sync.Mutex
+	calls      map[string]int
+	throttles  map[string]int
+	onThrottle []func(operation string)
+}
+
+// --- returns an empty collector ---
+func New() *Collector {
+	return &Collector{
+		calls:     map[string]int{},
+		throttles: map[string]int{},
+	}
+}
+
+// --- registers a callback invoked for every throttled attempt, the adaptive concurrency limit lowers itself through it ---
+func (c *Collector) OnThrottle(fn func(operation string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onThrottle = append(c.onThrottle, fn)
+}
+
+// --- adds the metrics middleware to an SDK middleware stack ---
+func (c *Collector) AddTo(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+		"APIMetrics",
+		func(ctx context.Context, input middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleInitialize(ctx, input)
+			c.record(middleware.GetOperationName(ctx), metadata, err)
+			return out, metadata, err
+		},
+	), middleware.After)
+}
+
+// --- records a single call and any throttled attempts it went through ---
+func (c *Collector) record(operation string, metadata middleware.Metadata, err error) {
+	throttled := 0
+	isThrottle := retry.IsErrorThrottles(retry.DefaultThrottles)
+	if results, ok := retry.GetAttemptResults(metadata); ok {
+		for _, attempt := range results.Results {
+			if attempt.Err != nil && isThrottle.IsErrorThrottle(attempt.Err) == aws.TrueTernary {
+				throttled++
+			}
+		}
+	} else if err != nil && isThrottle.IsErrorThrottle(err) == aws.TrueTernary {
+		throttled = 1
+	}
+
+	c.mu.Lock()
+	c.calls[operation]++
+	c.throttles[operation] += throttled
+	onThrottle := c.onThrottle
+	c.mu.Unlock()
+
+	for i := 0; i < throttled; i++ {
+		for _, fn := range onThrottle {
+			fn(operation)
+		}
+	}
+}
+
+// --- returns the number of calls recorded for an operation ---
+func (c *Collector) Calls(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[operation]
+}
+
+// --- returns the number of throttled attempts recorded for an operation ---
+func (c *Collector) Throttles(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.throttles[operation]
+}
+
+// --- returns the total number of throttled attempts across all operations ---
+func (c *Collector) TotalThrottles() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for _, n := range c.throttles {
+		total += n
+	}
+	return total
+}
+
+// --- returns one log line per operation, sorted by operation name ---
+func (c *Collector) Summary() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operations := make([]string, 0, len(c.calls))
+	for op := range c.calls {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+
+	lines := make([]string, 0, len(operations))
+	for _, op := range operations {
+		lines = append(lines, fmt.Sprintf("[DEBUG] API metrics - Operation: %s, calls: %d, throttles: %d", op, c.calls[op], c.throttles[op]))
+	}
+	return lines
+}
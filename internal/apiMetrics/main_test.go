@@ -0,0 +1,102 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package apimetrics
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// --- noBackoff lets the retryer retry immediately so tests stay fast ---
+type noBackoff struct{}
+
+func (noBackoff) BackoffDelay(int, error) (time.Duration, error) { return 0, nil }
+
+func TestCollector_CountsCallsAndThrottles(t *testing.T) {
+	var attempts int32
+
+	// --- the first ListImages attempt is throttled, the retry succeeds ---
+	listImagesMiddleware := middleware.FinalizeMiddlewareFunc(
+		"ListImagesMock",
+		func(ctx context.Context, input middleware.FinalizeInput, handler middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if middleware.GetOperationName(ctx) == "ListImages" {
+				if atomic.AddInt32(&attempts, 1) == 1 {
+					return middleware.FinalizeOutput{}, middleware.Metadata{}, &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down"}
+				}
+				return middleware.FinalizeOutput{Result: &ecr.ListImagesOutput{}}, middleware.Metadata{}, nil
+			}
+			return handler.HandleFinalize(ctx, input)
+		},
+	)
+
+	collector := New()
+	var observed int32
+	collector.OnThrottle(func(operation string) {
+		atomic.AddInt32(&observed, 1)
+	})
+
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion("us-west-2"),
+		config.WithCredentialsProvider(aws.AnonymousCredentials{}),
+		config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.Backoff = noBackoff{}
+			})
+		}),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			collector.AddTo,
+			func(stack *middleware.Stack) error {
+				return stack.Finalize.Add(listImagesMiddleware, middleware.After)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	client := ecr.NewFromConfig(cfg)
+	if _, err := client.ListImages(context.TODO(), &ecr.ListImagesInput{RepositoryName: aws.String("repo")}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if got := collector.Calls("ListImages"); got != 1 {
+		t.Errorf("Expected 1 ListImages call, got: %d", got)
+	}
+	if got := collector.Throttles("ListImages"); got != 1 {
+		t.Errorf("Expected 1 throttle, got: %d", got)
+	}
+	if got := collector.TotalThrottles(); got != 1 {
+		t.Errorf("Expected 1 total throttle, got: %d", got)
+	}
+	if atomic.LoadInt32(&observed) != 1 {
+		t.Errorf("Expected throttle callback to fire once, got: %d", observed)
+	}
+}
+
+func TestCollector_Summary(t *testing.T) {
+	collector := New()
+	collector.record("ListImages", middleware.Metadata{}, nil)
+	collector.record("BatchDeleteImage", middleware.Metadata{}, &smithy.GenericAPIError{Code: "ThrottlingException"})
+
+	lines := collector.Summary()
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 summary lines, got: %v", lines)
+	}
+	if !strings.Contains(lines[0], "BatchDeleteImage, calls: 1, throttles: 1") {
+		t.Errorf("Unexpected first line: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "ListImages, calls: 1, throttles: 0") {
+		t.Errorf("Unexpected second line: %s", lines[1])
+	}
+}
@@ -0,0 +1,54 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package apitimeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// --- CallTimeoutError is returned by a call that did not finish within its own timeout while the run went on ---
+// --- it tells a slow call, e.g. BatchGetImage stuck on a pathological manifest, apart from a call that failed ---
+type CallTimeoutError struct {
+	Operation string
+	Timeout   time.Duration
+	Err       error
+}
+
+func (e *CallTimeoutError) Error() string {
+	return fmt.Sprintf("%s call timed out after %s", e.Operation, e.Timeout)
+}
+
+func (e *CallTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// --- reports whether err, or any error it wraps, is a CallTimeoutError ---
+func IsCallTimeout(err error) bool {
+	var timeout *CallTimeoutError
+	return errors.As(err, &timeout)
+}
+
+// --- returns a function adding the timeout middleware to an SDK middleware stack, for config.WithAPIOptions ---
+// --- every call gets its own deadline derived from the caller's context, retries of the call included ---
+func AddTo(timeout time.Duration) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(
+			"APICallTimeout",
+			func(ctx context.Context, input middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				callCtx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				out, metadata, err := next.HandleInitialize(callCtx, input)
+				// --- only the call's own deadline counts, a cancelled or expired run context is reported as it is ---
+				if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+					err = &CallTimeoutError{Operation: middleware.GetOperationName(ctx), Timeout: timeout, Err: err}
+				}
+				return out, metadata, err
+			},
+		), middleware.Before)
+	}
+}
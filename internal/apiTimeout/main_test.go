@@ -0,0 +1,69 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package apitimeout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// --- returns an ECR client whose BatchGetImage hangs until its context is done and whose ListImages answers at once ---
+func newHangingClient(t *testing.T, timeout time.Duration) *ecr.Client {
+	hang := middleware.FinalizeMiddlewareFunc(
+		"HangingBatchGetImage",
+		func(ctx context.Context, input middleware.FinalizeInput, handler middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if middleware.GetOperationName(ctx) == "BatchGetImage" {
+				<-ctx.Done()
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, ctx.Err()
+			}
+			return middleware.FinalizeOutput{Result: &ecr.ListImagesOutput{}}, middleware.Metadata{}, nil
+		},
+	)
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion("us-west-2"),
+		config.WithCredentialsProvider(aws.AnonymousCredentials{}),
+		config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			AddTo(timeout),
+			func(stack *middleware.Stack) error {
+				return stack.Finalize.Add(hang, middleware.After)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unable to load SDK config: %v", err)
+	}
+	return ecr.NewFromConfig(cfg)
+}
+
+func TestAddTo(t *testing.T) {
+	client := newHangingClient(t, 20*time.Millisecond)
+
+	_, err := client.BatchGetImage(context.TODO(), &ecr.BatchGetImageInput{RepositoryName: aws.String("repo"), ImageIds: []types.ImageIdentifier{{ImageTag: aws.String("v1")}}})
+	var timeout *CallTimeoutError
+	if !errors.As(err, &timeout) || timeout.Operation != "BatchGetImage" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a BatchGetImage call timeout, got: %v", err)
+	}
+
+	// --- a fast call is unaffected ---
+	if _, err := client.ListImages(context.TODO(), &ecr.ListImagesInput{RepositoryName: aws.String("repo")}); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	// --- the caller's own deadline is not reported as a call timeout ---
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	client = newHangingClient(t, time.Minute)
+	if _, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{RepositoryName: aws.String("repo"), ImageIds: []types.ImageIdentifier{{ImageTag: aws.String("v1")}}}); err == nil || IsCallTimeout(err) {
+		t.Errorf("Expected the caller's deadline error, got: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package awsjson
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// --- Client calls an AWS JSON 1.1 API with requests signed by the credentials of an AWS config ---
+// --- it covers the few read calls of services whose full SDK would otherwise be pulled into the build for them ---
+type Client struct {
+	// --- URL the requests are sent to, e.g. https://ecs.eu-west-1.amazonaws.com/ ---
+	Endpoint string
+
+	config        aws.Config
+	service       string
+	signingRegion string
+	targetPrefix  string
+	http          *http.Client
+	signer        *v4.Signer
+}
+
+// --- returns a client for service, e.g. ecs, whose operations are named targetPrefix.Operation in the X-Amz-Target header ---
+func NewClient(cfg aws.Config, endpoint, service, signingRegion, targetPrefix string) *Client {
+	return &Client{
+		Endpoint:      endpoint,
+		config:        cfg,
+		service:       service,
+		signingRegion: signingRegion,
+		targetPrefix:  targetPrefix,
+		http:          &http.Client{Timeout: time.Minute},
+		signer:        v4.NewSigner(),
+	}
+}
+
+// --- returns the domain of AWS endpoints in the partition of region, amazonaws.com.cn for the China regions ---
+func Domain(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// --- sends a signed request for operation and decodes the response into out ---
+func (c *Client) Call(ctx context.Context, operation string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", operation, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build %s request: %w", operation, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", c.targetPrefix+"."+operation)
+
+	if c.config.Credentials == nil {
+		return fmt.Errorf("no AWS credentials to sign %s with", operation)
+	}
+	credentials, err := c.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), c.service, c.signingRegion, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign %s request: %w", operation, err)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", operation, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("%s failed: %s: %s %s", operation, resp.Status, apiErr.Type, apiErr.Message)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", operation, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package awsregistry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Registry identifies the AWS account and region a run operates on, reports embed it so results can be told apart ---
+type Registry struct {
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// --- returns e.g. "account 123456789012, region eu-west-1", leaving out the parts that are not known ---
+func (r Registry) String() string {
+	var parts []string
+	if r.Account != "" {
+		parts = append(parts, "account "+r.Account)
+	}
+	if r.Region != "" {
+		parts = append(parts, "region "+r.Region)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// --- returns the host images of the registry are pulled from, e.g. 123456789012.dkr.ecr.eu-west-1.amazonaws.com ---
+func (r Registry) URI() string {
+	domain := "amazonaws.com"
+	if strings.HasPrefix(r.Region, "cn-") {
+		domain = "amazonaws.com.cn"
+	}
+	return fmt.Sprintf("%s.dkr.ecr.%s.%s", r.Account, r.Region, domain)
+}
+
+// --- prefixes a per-repository error with the account and region, errors are returned as they are when neither is known ---
+func (r Registry) Wrap(err error) error {
+	if err == nil || (r.Account == "" && r.Region == "") {
+		return err
+	}
+	return fmt.Errorf("%s: %w", r, err)
+}
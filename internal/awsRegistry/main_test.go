@@ -0,0 +1,38 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package awsregistry
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry_Wrap(t *testing.T) {
+	base := errors.New("failed to list images for repository app")
+	cases := map[Registry]string{
+		{Account: "123456789012", Region: "eu-west-1"}: "account 123456789012, region eu-west-1: failed to list images for repository app",
+		{Region: "us-east-1"}:                          "region us-east-1: failed to list images for repository app",
+		{}:                                             "failed to list images for repository app",
+	}
+	for registry, want := range cases {
+		err := registry.Wrap(base)
+		if err.Error() != want || !errors.Is(err, base) {
+			t.Errorf("%+v: Wrap() = %q, want %q wrapping the original error", registry, err, want)
+		}
+	}
+	if (Registry{Account: "123456789012"}).Wrap(nil) != nil {
+		t.Errorf("Expected a nil error to stay nil")
+	}
+}
+
+func TestRegistry_URI(t *testing.T) {
+	cases := map[Registry]string{
+		{Account: "123456789012", Region: "eu-west-1"}:  "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+		{Account: "123456789012", Region: "cn-north-1"}: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn",
+	}
+	for registry, want := range cases {
+		if got := registry.URI(); got != want {
+			t.Errorf("%+v: URI() = %q, want %q", registry, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	format "ecr-lifecycle-cleaner/internal/format"
+)
+
+// --- Checkpoint records the repositories a run has finished, so an interrupted run can be resumed ---
+type Checkpoint struct {
+	mu        sync.Mutex
+	path      string
+	completed map[string]bool
+}
+
+// --- the on-disk form of a checkpoint ---
+type state struct {
+	Completed []string  `json:"completed"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// --- returns an empty checkpoint that will be written to path ---
+func New(path string) *Checkpoint {
+	return &Checkpoint{path: path, completed: map[string]bool{}}
+}
+
+// --- reads the checkpoint at path, a missing file yields an empty checkpoint ---
+func Load(path string) (*Checkpoint, error) {
+	c := New(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file %s: %w", path, err)
+	}
+	for _, repo := range s.Completed {
+		c.completed[repo] = true
+	}
+	return c, nil
+}
+
+// --- reports whether the repository was completed by an earlier run ---
+func (c *Checkpoint) Done(repository string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.completed[repository]
+}
+
+// --- returns the repositories that still need processing and how many were already completed ---
+func (c *Checkpoint) Remaining(repositories []string) ([]string, int) {
+	var remaining []string
+	skipped := 0
+	for _, repo := range repositories {
+		if c.Done(repo) {
+			skipped++
+			continue
+		}
+		remaining = append(remaining, repo)
+	}
+	return remaining, skipped
+}
+
+// --- records the repository as completed and rewrites the checkpoint file atomically ---
+func (c *Checkpoint) MarkDone(repository string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed[repository] = true
+	return c.save()
+}
+
+// --- writes the current state, callers hold the lock ---
+func (c *Checkpoint) save() error {
+	s := state{Completed: make([]string, 0, len(c.completed)), UpdatedAt: time.Now().UTC()}
+	for repo := range c.completed {
+		s.Completed = append(s.Completed, repo)
+	}
+	sort.Strings(s.Completed)
+	if err := format.WriteReport(c.path, s); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCheckpoint_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected missing file to load as empty, got: %v", err)
+	}
+	if c.Done("repo-a") {
+		t.Errorf("Expected empty checkpoint")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.MarkDone(fmt.Sprintf("repo-%d", i)); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	resumed, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	remaining, skipped := resumed.Remaining([]string{"repo-1", "repo-10", "repo-9", "repo-11"})
+	if !reflect.DeepEqual(remaining, []string{"repo-10", "repo-11"}) || skipped != 2 {
+		t.Errorf("Unexpected remaining repos: %v (skipped %d)", remaining, skipped)
+	}
+}
+
+func TestCheckpoint_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte("not-json"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Expected error for invalid checkpoint file")
+	}
+}
@@ -0,0 +1,163 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package compareregistries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"ecr-lifecycle-cleaner/internal/concurrency"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to compare registries ---
+type ECRAPI interface {
+	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+	ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+}
+
+// --- Registry is one side of the comparison, an empty RegistryID means the caller's own account ---
+type Registry struct {
+	Client     ECRAPI
+	RegistryID string
+}
+
+// --- RepositoryDiff lists the digests of a source repository that are missing from the target ---
+type RepositoryDiff struct {
+	Repository     string   `json:"repository"`
+	MissingDigests []string `json:"missingDigests"`
+}
+
+// --- RegistryDiff captures which source repositories and images have been replicated to the target ---
+type RegistryDiff struct {
+	MissingRepositories []string         `json:"missingRepositories"`
+	MissingImages       []RepositoryDiff `json:"missingImages"`
+	InSync              []string         `json:"inSync"`
+}
+
+// --- returns a one-line human readable summary of the diff ---
+func (d RegistryDiff) Summary() string {
+	missing := 0
+	for _, repo := range d.MissingImages {
+		missing += len(repo.MissingDigests)
+	}
+	return fmt.Sprintf("%d repos in sync, %d repos missing from target, %d repos missing %d images", len(d.InSync), len(d.MissingRepositories), len(d.MissingImages), missing)
+}
+
+// --- returns all repository names of the registry ---
+func ListRepositories(ctx context.Context, registry Registry) ([]string, error) {
+	var repositories []string
+	paginator := ecr.NewDescribeRepositoriesPaginator(registry.Client, &ecr.DescribeRepositoriesInput{RegistryId: registryID(registry)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			repositories = append(repositories, aws.ToString(repo.RepositoryName))
+		}
+	}
+	return repositories, nil
+}
+
+// --- compares the given source repositories with the target registry, limit caps the repositories compared at once ---
+func Compare(ctx context.Context, source, target Registry, repositories []string, limit int) (RegistryDiff, error) {
+	var diff RegistryDiff
+	targetRepositories, err := ListRepositories(ctx, target)
+	if err != nil {
+		return diff, fmt.Errorf("failed to list target repositories: %w", err)
+	}
+	inTarget := make(map[string]bool, len(targetRepositories))
+	for _, repo := range targetRepositories {
+		inTarget[repo] = true
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	concurrency.ForEach(repositories, limit, func(repo string) {
+		if !inTarget[repo] {
+			mu.Lock()
+			diff.MissingRepositories = append(diff.MissingRepositories, repo)
+			mu.Unlock()
+			return
+		}
+		missing, err := missingDigests(ctx, source, target, repo)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case len(missing) > 0:
+			diff.MissingImages = append(diff.MissingImages, RepositoryDiff{Repository: repo, MissingDigests: missing})
+		default:
+			diff.InSync = append(diff.InSync, repo)
+		}
+	})
+
+	sort.Strings(diff.MissingRepositories)
+	sort.Strings(diff.InSync)
+	sort.Slice(diff.MissingImages, func(i, j int) bool {
+		return diff.MissingImages[i].Repository < diff.MissingImages[j].Repository
+	})
+
+	if len(errs) > 0 {
+		return diff, fmt.Errorf("encountered errors during comparison: %v", errs)
+	}
+	return diff, nil
+}
+
+// --- returns the digests present in the source repository but not in the target one ---
+func missingDigests(ctx context.Context, source, target Registry, repository string) ([]string, error) {
+	sourceDigests, err := imageDigests(ctx, source, repository)
+	if err != nil {
+		return nil, err
+	}
+	targetDigests, err := imageDigests(ctx, target, repository)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for digest := range sourceDigests {
+		if !targetDigests[digest] {
+			missing = append(missing, digest)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// --- returns the set of image digests in a repository ---
+func imageDigests(ctx context.Context, registry Registry, repository string) (map[string]bool, error) {
+	digests := map[string]bool{}
+	paginator := ecr.NewListImagesPaginator(registry.Client, &ecr.ListImagesInput{
+		RepositoryName: aws.String(repository),
+		RegistryId:     registryID(registry),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var notFound *types.RepositoryNotFoundException
+			if errors.As(err, &notFound) {
+				return digests, nil
+			}
+			return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
+		}
+		for _, image := range page.ImageIds {
+			digests[aws.ToString(image.ImageDigest)] = true
+		}
+	}
+	return digests, nil
+}
+
+func registryID(registry Registry) *string {
+	if registry.RegistryID == "" {
+		return nil
+	}
+	return aws.String(registry.RegistryID)
+}
@@ -0,0 +1,88 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package compareregistries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- mock registry holding digests per repository ---
+type mockECRClient struct {
+	repositories map[string][]string
+	listErr      error
+	registryIDs  []string
+}
+
+func (m *mockECRClient) DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	m.registryIDs = append(m.registryIDs, aws.ToString(in.RegistryId))
+	out := &ecr.DescribeRepositoriesOutput{}
+	for name := range m.repositories {
+		out.Repositories = append(out.Repositories, types.Repository{RepositoryName: aws.String(name)})
+	}
+	return out, nil
+}
+
+func (m *mockECRClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	out := &ecr.ListImagesOutput{}
+	for _, digest := range m.repositories[aws.ToString(in.RepositoryName)] {
+		out.ImageIds = append(out.ImageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	}
+	return out, nil
+}
+
+func TestCompare(t *testing.T) {
+	source := &mockECRClient{repositories: map[string][]string{
+		"synced":  {"d1", "d2"},
+		"partial": {"d1", "d2", "d3"},
+		"absent":  {"d1"},
+	}}
+	target := &mockECRClient{repositories: map[string][]string{
+		"synced":  {"d2", "d1"},
+		"partial": {"d2"},
+		"extra":   {"d9"},
+	}}
+
+	diff, err := Compare(context.TODO(), Registry{Client: source}, Registry{Client: target, RegistryID: "123456789012"}, []string{"synced", "partial", "absent"}, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := RegistryDiff{
+		MissingRepositories: []string{"absent"},
+		MissingImages:       []RepositoryDiff{{Repository: "partial", MissingDigests: []string{"d1", "d3"}}},
+		InSync:              []string{"synced"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("Expected %+v, got: %+v", want, diff)
+	}
+	if !reflect.DeepEqual(target.registryIDs, []string{"123456789012"}) {
+		t.Errorf("Expected target registry id to be passed, got: %v", target.registryIDs)
+	}
+	if got := diff.Summary(); got != "1 repos in sync, 1 repos missing from target, 1 repos missing 2 images" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+
+	data, err := json.Marshal(diff)
+	if err != nil || !strings.Contains(string(data), `"missingDigests":["d1","d3"]`) {
+		t.Errorf("Unexpected JSON: %s, %v", data, err)
+	}
+}
+
+func TestCompare_Error(t *testing.T) {
+	source := &mockECRClient{repositories: map[string][]string{"repo": {"d1"}}, listErr: errors.New("fail")}
+	target := &mockECRClient{repositories: map[string][]string{"repo": {"d1"}}}
+	if _, err := Compare(context.TODO(), Registry{Client: source}, Registry{Client: target}, []string{"repo"}, 0); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
@@ -0,0 +1,255 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	// --- ECR throttles per account and region, the busiest calls (BatchGetImage, ListImages) start ---
+	// --- throttling in the low tens of requests per second, more than 50 workers only buys retries ---
+	MaxConcurrency = 50
+	// --- a safe default that keeps large runs fast without tripping the throttles ---
+	DefaultConcurrency = 10
+	// --- upper bound used by AutoConcurrency ---
+	maxAutoConcurrency = 20
+)
+
+// --- checks that n is within 1 and MaxConcurrency ---
+func ValidateConcurrency(n int) error {
+	if n < 1 || n > MaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d, got %d", MaxConcurrency, n)
+	}
+	return nil
+}
+
+// --- returns a concurrency suited to the number of repositories, min(repos/10, 20) but at least 1 ---
+func AutoConcurrency(repoCount int) int {
+	n := repoCount / 10
+	if n > maxAutoConcurrency {
+		n = maxAutoConcurrency
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// --- calls fn for every item with at most limit calls in flight, limit <= 0 means unbounded ---
+func ForEach[T any](items []T, limit int, fn func(T)) {
+	var wg sync.WaitGroup
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+	for _, item := range items {
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		wg.Add(1)
+		go func(item T) {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+			fn(item)
+		}(item)
+	}
+	wg.Wait()
+}
+
+// --- operations that accept their own limit via ParseOperationLimits ---
+var limitedOperations = []string{"ListImages", "BatchGetImage", "BatchDeleteImage", "DescribeImages", "GetLifecyclePolicy", "PutLifecyclePolicy"}
+
+// --- OperationLimits maps an ECR operation name to the maximum number of its calls in flight ---
+type OperationLimits map[string]int
+
+// --- parses "ListImages=10,BatchGetImage=3" into OperationLimits, an empty value means no limits ---
+func ParseOperationLimits(value string) (OperationLimits, error) {
+	limits := OperationLimits{}
+	if strings.TrimSpace(value) == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		name, rawLimit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid operation limit %q, expected Operation=N", pair)
+		}
+		if !isLimitedOperation(name) {
+			return nil, fmt.Errorf("unsupported operation %q, expected one of %s", name, strings.Join(limitedOperations, ", "))
+		}
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit for %s: %w", name, err)
+		}
+		if err := ValidateConcurrency(n); err != nil {
+			return nil, fmt.Errorf("invalid limit for %s: %w", name, err)
+		}
+		limits[name] = n
+	}
+	return limits, nil
+}
+
+func isLimitedOperation(name string) bool {
+	for _, op := range limitedOperations {
+		if op == name {
+			return true
+		}
+	}
+	return false
+}
+
+// --- returns the limits as "Operation=N" pairs in a stable order, for logging ---
+func (l OperationLimits) String() string {
+	pairs := make([]string, 0, len(l))
+	for name, n := range l {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// --- Semaphore bounds the number of concurrent holders, a nil Semaphore never blocks ---
+type Semaphore chan struct{}
+
+// --- returns a semaphore for n holders, nil when n <= 0 ---
+func NewSemaphore(n int) Semaphore {
+	if n <= 0 {
+		return nil
+	}
+	return make(Semaphore, n)
+}
+
+// --- waits for a free slot or for ctx to be done ---
+func (s Semaphore) Acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// --- frees a slot taken by Acquire ---
+func (s Semaphore) Release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// --- Limiter holds one semaphore per limited operation, operations without a limit never block ---
+type Limiter map[string]Semaphore
+
+// --- returns a limiter for limits, nil when there are no limits ---
+func NewLimiter(limits OperationLimits) Limiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	limiter := Limiter{}
+	for name, n := range limits {
+		limiter[name] = NewSemaphore(n)
+	}
+	return limiter
+}
+
+// --- runs call once a slot for op is free, returning ctx's error when it is done first ---
+func Do[T any](ctx context.Context, l Limiter, op string, call func() (T, error)) (T, error) {
+	sem := l[op]
+	if err := sem.Acquire(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer sem.Release()
+	return call()
+}
+
+// --- AdaptiveLimit bounds the API calls in flight and halves the bound every time the API throttles ---
+// --- each time as many calls finish as the bound allows, it is raised by one again, up to the bound it started with ---
+type AdaptiveLimit struct {
+	mu        sync.Mutex
+	max       int
+	limit     int
+	inFlight  int
+	completed int
+	// --- closed and replaced whenever a slot may have become free ---
+	wake chan struct{}
+}
+
+// --- returns a limit starting at max calls in flight ---
+func NewAdaptiveLimit(max int) *AdaptiveLimit {
+	return &AdaptiveLimit{max: max, limit: max, wake: make(chan struct{})}
+}
+
+// --- waits until fewer calls than the current bound are in flight, or for ctx to be done ---
+func (a *AdaptiveLimit) Acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		if a.inFlight < a.limit {
+			a.inFlight++
+			a.mu.Unlock()
+			return nil
+		}
+		wake := a.wake
+		a.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// --- frees a slot taken by Acquire and raises the bound once enough calls finished since it was last changed ---
+func (a *AdaptiveLimit) Release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inFlight--
+	a.completed++
+	if a.completed >= a.limit && a.limit < a.max {
+		a.limit++
+		a.completed = 0
+	}
+	close(a.wake)
+	a.wake = make(chan struct{})
+}
+
+// --- halves the bound, never below one call in flight ---
+func (a *AdaptiveLimit) Throttled() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limit = max(1, a.limit/2)
+	a.completed = 0
+}
+
+// --- returns the current bound ---
+func (a *AdaptiveLimit) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limit
+}
+
+// --- adds the limit to an SDK middleware stack, it holds a slot for each attempt once the request is signed ---
+// --- so credentials resolved by another client built from the same config never wait on a slot held by the call ---
+func (a *AdaptiveLimit) AddTo(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc(
+		"AdaptiveConcurrency",
+		func(ctx context.Context, input middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if err := a.Acquire(ctx); err != nil {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+			defer a.Release()
+			return next.HandleFinalize(ctx, input)
+		},
+	), middleware.After)
+}
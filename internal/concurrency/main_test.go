@@ -0,0 +1,205 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package concurrency
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateConcurrency(t *testing.T) {
+	for _, n := range []int{1, 10, MaxConcurrency} {
+		if err := ValidateConcurrency(n); err != nil {
+			t.Errorf("ValidateConcurrency(%d) = %v; want nil", n, err)
+		}
+	}
+	for _, n := range []int{-1, 0, MaxConcurrency + 1} {
+		if err := ValidateConcurrency(n); err == nil {
+			t.Errorf("ValidateConcurrency(%d) = nil; want error", n)
+		}
+	}
+}
+
+func TestAutoConcurrency(t *testing.T) {
+	tests := map[int]int{0: 1, 5: 1, 10: 1, 55: 5, 200: 20, 5000: 20}
+	for repos, want := range tests {
+		if got := AutoConcurrency(repos); got != want {
+			t.Errorf("AutoConcurrency(%d) = %d; want %d", repos, got, want)
+		}
+	}
+}
+
+func TestForEach_Limit(t *testing.T) {
+	var inFlight, maxSeen int32
+	var mu sync.Mutex
+	var seen []int
+
+	items := make([]int, 20)
+	for i := range items {
+		items[i] = i
+	}
+	ForEach(items, 3, func(i int) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			old := atomic.LoadInt32(&maxSeen)
+			if n <= old || atomic.CompareAndSwapInt32(&maxSeen, old, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		mu.Lock()
+		seen = append(seen, i)
+		mu.Unlock()
+	})
+
+	if len(seen) != len(items) {
+		t.Errorf("Expected %d items processed, got %d", len(items), len(seen))
+	}
+	if maxSeen > 3 {
+		t.Errorf("Expected at most 3 concurrent calls, saw %d", maxSeen)
+	}
+}
+
+func TestForEach_Unbounded(t *testing.T) {
+	var count int32
+	ForEach([]string{"a", "b", "c"}, 0, func(string) {
+		atomic.AddInt32(&count, 1)
+	})
+	if count != 3 {
+		t.Errorf("Expected 3 calls, got %d", count)
+	}
+}
+
+func TestParseOperationLimits(t *testing.T) {
+	limits, err := ParseOperationLimits("ListImages=10, BatchGetImage=3")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(limits, OperationLimits{"ListImages": 10, "BatchGetImage": 3}) {
+		t.Errorf("Unexpected limits: %v", limits)
+	}
+	if limits.String() != "BatchGetImage=3,ListImages=10" {
+		t.Errorf("Unexpected string form: %s", limits.String())
+	}
+	if limits, err := ParseOperationLimits("DescribeImages=2,GetLifecyclePolicy=4"); err != nil || limits["DescribeImages"] != 2 || limits["GetLifecyclePolicy"] != 4 {
+		t.Errorf("Expected planning operations to be limitable, got: %v, %v", limits, err)
+	}
+	if limits, err := ParseOperationLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("Expected no limits for empty value, got: %v, %v", limits, err)
+	}
+	for _, value := range []string{"ListImages", "PutImage=3", "ListImages=abc", "BatchDeleteImage=0", "BatchDeleteImage=500"} {
+		if _, err := ParseOperationLimits(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	var nilSemaphore Semaphore
+	if err := nilSemaphore.Acquire(context.TODO()); err != nil {
+		t.Errorf("Expected nil semaphore to never block, got: %v", err)
+	}
+	nilSemaphore.Release()
+
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.TODO()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(ctx); err == nil {
+		t.Errorf("Expected Acquire on a full semaphore to honor the context")
+	}
+	sem.Release()
+	if err := sem.Acquire(context.TODO()); err != nil {
+		t.Errorf("Expected slot to be free after Release, got: %v", err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	if NewLimiter(nil) != nil {
+		t.Errorf("Expected no limiter without limits")
+	}
+
+	limiter := NewLimiter(OperationLimits{"PutLifecyclePolicy": 2})
+	var inFlight, peak int32
+	ForEach(make([]int, 10), 0, func(int) {
+		_, err := Do(context.TODO(), limiter, "PutLifecyclePolicy", func() (struct{}, error) {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			return struct{}{}, nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+	if peak > 2 {
+		t.Errorf("Expected at most 2 calls in flight, got: %d", peak)
+	}
+
+	// --- operations without a limit run straight away ---
+	n, err := Do(context.TODO(), limiter, "ListImages", func() (int, error) { return 1, nil })
+	if n != 1 || err != nil {
+		t.Errorf("Expected the unlimited call to run, got: %d, %v", n, err)
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	limit := NewAdaptiveLimit(8)
+	limit.Throttled()
+	limit.Throttled()
+	if got := limit.Limit(); got != 2 {
+		t.Fatalf("Expected two throttles to lower the limit to 2, got: %d", got)
+	}
+
+	// --- a third call waits until one of the two in flight is released ---
+	for i := 0; i < 2; i++ {
+		if err := limit.Acquire(context.TODO()); err != nil {
+			t.Fatalf("Expected a free slot, got: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limit.Acquire(ctx); err == nil {
+		t.Fatal("Expected the third call to wait for a slot")
+	}
+	acquired := make(chan error)
+	go func() { acquired <- limit.Acquire(context.TODO()) }()
+	limit.Release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("Expected the waiting call to get the released slot, got: %v", err)
+	}
+	limit.Release()
+	limit.Release()
+
+	// --- two calls finishing at a limit of 2 raise it by one ---
+	if got := limit.Limit(); got != 3 {
+		t.Errorf("Expected the limit to be raised to 3, got: %d", got)
+	}
+	for i := 0; i < 100; i++ {
+		_ = limit.Acquire(context.TODO())
+		limit.Release()
+	}
+	if got := limit.Limit(); got != 8 {
+		t.Errorf("Expected the limit to recover to 8 and no further, got: %d", got)
+	}
+	limit.Throttled()
+	for i := 0; i < 5; i++ {
+		limit.Throttled()
+	}
+	if got := limit.Limit(); got != 1 {
+		t.Errorf("Expected the limit never to drop below 1, got: %d", got)
+	}
+}
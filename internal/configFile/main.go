@@ -0,0 +1,63 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package configfile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// --- prefix of the environment variables overriding config file values, e.g. ECR_CLEANER_MAXCONCURRENCY ---
+const EnvPrefix = "ECR_CLEANER"
+
+// --- fills every flag not set on the command line from the environment, then from the config file at path ---
+// --- precedence is command line > environment > config file > flag default, an empty path only reads the environment ---
+func Apply(flags *pflag.FlagSet, path string) error {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.AutomaticEnv()
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+	if err := v.BindPFlags(flags); err != nil {
+		return err
+	}
+
+	var errs []string
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || !v.IsSet(flag.Name) {
+			return
+		}
+		// --- repeatable flags such as --repoPattern take each list item as one value, items may contain commas ---
+		if list, ok := v.Get(flag.Name).([]interface{}); ok && flag.Value.Type() == "stringArray" {
+			for _, item := range cast.ToStringSlice(list) {
+				if err := flags.Set(flag.Name, item); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %v", flag.Name, err))
+				}
+			}
+			return
+		}
+		if err := flags.Set(flag.Name, value(v.Get(flag.Name))); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", flag.Name, err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config values: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// --- converts a config value to its flag form, lists become comma-separated like --repoList expects ---
+func value(raw interface{}) string {
+	if list, ok := raw.([]interface{}); ok {
+		return strings.Join(cast.ToStringSlice(list), ",")
+	}
+	return cast.ToString(raw)
+}
@@ -0,0 +1,84 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func newFlags() (*pflag.FlagSet, *int, *string, *bool) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	maxConcurrency := flags.Int("maxConcurrency", 5, "")
+	repoList := flags.String("repoList", "", "")
+	dryRun := flags.Bool("dryRun", false, "")
+	flags.String("minAge", "", "")
+	flags.StringArray("repoPattern", nil, "")
+	return flags, maxConcurrency, repoList, dryRun
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "cleaner.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestApply_Precedence(t *testing.T) {
+	path := writeConfig(t, "maxConcurrency: 8\nrepoList:\n  - app\n  - web\nrepoPattern:\n  - '^app-.*'\n  - '^web-[a-z]{1,3}$'\ndryRun: true\nminAge: 7d\nunknownKey: ignored\n")
+	t.Setenv("ECR_CLEANER_MINAGE", "30d")
+
+	flags, maxConcurrency, repoList, dryRun := newFlags()
+	if err := flags.Parse([]string{"--maxConcurrency", "3"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if err := Apply(flags, path); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if *maxConcurrency != 3 {
+		t.Errorf("Expected command line value 3, got %d", *maxConcurrency)
+	}
+	if got, _ := flags.GetString("minAge"); got != "30d" {
+		t.Errorf("Expected environment value 30d, got %s", got)
+	}
+	if *repoList != "app,web" || !*dryRun {
+		t.Errorf("Expected config file values, got repoList=%s dryRun=%v", *repoList, *dryRun)
+	}
+	if !flags.Changed("repoList") {
+		t.Errorf("Expected config file values to mark the flag as set")
+	}
+	// --- each item of a repeatable flag is one value, commas inside a pattern are kept ---
+	if got, _ := flags.GetStringArray("repoPattern"); !reflect.DeepEqual(got, []string{"^app-.*", "^web-[a-z]{1,3}$"}) {
+		t.Errorf("Expected both patterns, got %q", got)
+	}
+}
+
+func TestApply_NoConfigFile(t *testing.T) {
+	flags, maxConcurrency, _, _ := newFlags()
+	if err := Apply(flags, ""); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if *maxConcurrency != 5 || flags.Changed("maxConcurrency") {
+		t.Errorf("Expected default to be kept, got %d", *maxConcurrency)
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	flags, _, _, _ := newFlags()
+	if err := Apply(flags, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected error for missing config file")
+	}
+
+	flags, _, _, _ = newFlags()
+	err := Apply(flags, writeConfig(t, "maxConcurrency: lots\n"))
+	if err == nil || !strings.Contains(err.Error(), "maxConcurrency") {
+		t.Errorf("Expected invalid value error naming the flag, got: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package creationtemplates
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	reponame "ecr-lifecycle-cleaner/internal/repoName"
+	setlifecyclepolicy "ecr-lifecycle-cleaner/internal/setLifecyclePolicy"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- RootPrefix is the template prefix ECR applies to repositories no other template matches ---
+const RootPrefix = "ROOT"
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to read and write repository creation templates ---
+type ECRAPI interface {
+	DescribeRepositoryCreationTemplates(ctx context.Context, in *ecr.DescribeRepositoryCreationTemplatesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoryCreationTemplatesOutput, error)
+	CreateRepositoryCreationTemplate(ctx context.Context, in *ecr.CreateRepositoryCreationTemplateInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryCreationTemplateOutput, error)
+	UpdateRepositoryCreationTemplate(ctx context.Context, in *ecr.UpdateRepositoryCreationTemplateInput, optFns ...func(*ecr.Options)) (*ecr.UpdateRepositoryCreationTemplateOutput, error)
+}
+
+var _ ECRAPI = (*ecr.Client)(nil)
+
+// --- Template is the part of a repository creation template this tool manages ---
+type Template struct {
+	Prefix             string                   `json:"prefix"`
+	Description        string                   `json:"description,omitempty"`
+	AppliedFor         []types.RCTAppliedFor    `json:"appliedFor"`
+	ImageTagMutability types.ImageTagMutability `json:"imageTagMutability,omitempty"`
+	LifecyclePolicy    string                   `json:"lifecyclePolicy,omitempty"`
+	CreatedAt          time.Time                `json:"createdAt,omitempty"`
+	UpdatedAt          time.Time                `json:"updatedAt,omitempty"`
+}
+
+// --- ApplyOptions describes the template to create or update ---
+type ApplyOptions struct {
+	Prefix      string
+	PolicyText  string
+	AppliedFor  []types.RCTAppliedFor
+	Description string
+	DryRun      bool
+}
+
+// --- ApplyResult records what Apply did to the template ---
+type ApplyResult struct {
+	Prefix         string                `json:"prefix"`
+	Action         string                `json:"action"`
+	AppliedFor     []types.RCTAppliedFor `json:"appliedFor"`
+	PolicyChecksum string                `json:"policyChecksum"`
+	DryRun         bool                  `json:"dryRun"`
+}
+
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionUnchanged = "unchanged"
+)
+
+// --- checks a template prefix, ROOT or the leading namespace of repository names such as team or team/app ---
+func ValidatePrefix(prefix string) error {
+	if prefix == RootPrefix {
+		return nil
+	}
+	if err := reponame.Validate(prefix); err != nil {
+		return fmt.Errorf("invalid --repoPrefix, expected %s or a repository namespace: %w", RootPrefix, err)
+	}
+	return nil
+}
+
+// --- parses a comma-separated list of CREATE_ON_PUSH, PULL_THROUGH_CACHE and REPLICATION, case-insensitive ---
+func ParseAppliedFor(value string) ([]types.RCTAppliedFor, error) {
+	var appliedFor []types.RCTAppliedFor
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		kind := types.RCTAppliedFor(item)
+		if !slices.Contains(kind.Values(), kind) {
+			return nil, fmt.Errorf("invalid --appliedFor %q, expected a comma-separated list of %v", item, kind.Values())
+		}
+		if !slices.Contains(appliedFor, kind) {
+			appliedFor = append(appliedFor, kind)
+		}
+	}
+	if len(appliedFor) == 0 {
+		return nil, fmt.Errorf("--appliedFor must name at least one of %v", types.RCTAppliedFor("").Values())
+	}
+	return appliedFor, nil
+}
+
+// --- returns the creation templates, all of them when no prefix is given, sorted by prefix ---
+func Describe(ctx context.Context, client ECRAPI, prefixes ...string) ([]Template, error) {
+	var templates []Template
+	paginator := ecr.NewDescribeRepositoryCreationTemplatesPaginator(client, &ecr.DescribeRepositoryCreationTemplatesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repository creation templates: %w", err)
+		}
+		for _, t := range page.RepositoryCreationTemplates {
+			prefix := aws.ToString(t.Prefix)
+			if len(prefixes) > 0 && !slices.Contains(prefixes, prefix) {
+				continue
+			}
+			templates = append(templates, Template{
+				Prefix:             prefix,
+				Description:        aws.ToString(t.Description),
+				AppliedFor:         t.AppliedFor,
+				ImageTagMutability: t.ImageTagMutability,
+				LifecyclePolicy:    aws.ToString(t.LifecyclePolicy),
+				CreatedAt:          aws.ToTime(t.CreatedAt),
+				UpdatedAt:          aws.ToTime(t.UpdatedAt),
+			})
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Prefix < templates[j].Prefix
+	})
+	return templates, nil
+}
+
+// --- reports whether both lists name the same kinds of repository creation, in any order ---
+func sameAppliedFor(a, b []types.RCTAppliedFor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, kind := range a {
+		if !slices.Contains(b, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- creates the template for the prefix, or updates its lifecycle policy and applied-for list when it exists ---
+// --- other settings of an existing template, such as encryption or tags, are left as they are ---
+func Apply(ctx context.Context, client ECRAPI, opts ApplyOptions) (ApplyResult, error) {
+	checksum := setlifecyclepolicy.PolicyChecksum(opts.PolicyText)
+	result := ApplyResult{Prefix: opts.Prefix, AppliedFor: opts.AppliedFor, PolicyChecksum: checksum, DryRun: opts.DryRun}
+
+	existing, err := Describe(ctx, client, opts.Prefix)
+	if err != nil {
+		return result, err
+	}
+
+	switch {
+	case len(existing) == 0:
+		result.Action = ActionCreated
+		if opts.DryRun {
+			return result, nil
+		}
+		_, err = client.CreateRepositoryCreationTemplate(ctx, &ecr.CreateRepositoryCreationTemplateInput{
+			Prefix:          aws.String(opts.Prefix),
+			AppliedFor:      opts.AppliedFor,
+			LifecyclePolicy: aws.String(opts.PolicyText),
+			Description:     descriptionOrNil(opts.Description),
+		})
+	case setlifecyclepolicy.PolicyChecksum(existing[0].LifecyclePolicy) == checksum && sameAppliedFor(existing[0].AppliedFor, opts.AppliedFor):
+		result.Action = ActionUnchanged
+		return result, nil
+	default:
+		result.Action = ActionUpdated
+		if opts.DryRun {
+			return result, nil
+		}
+		_, err = client.UpdateRepositoryCreationTemplate(ctx, &ecr.UpdateRepositoryCreationTemplateInput{
+			Prefix:          aws.String(opts.Prefix),
+			AppliedFor:      opts.AppliedFor,
+			LifecyclePolicy: aws.String(opts.PolicyText),
+			Description:     descriptionOrNil(opts.Description),
+		})
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to apply repository creation template %s: %w", opts.Prefix, err)
+	}
+	return result, nil
+}
+
+// --- an empty description is not sent, so updating a template keeps the description it has ---
+func descriptionOrNil(description string) *string {
+	if description == "" {
+		return nil
+	}
+	return aws.String(description)
+}
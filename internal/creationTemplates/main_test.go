@@ -0,0 +1,116 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package creationtemplates
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+const policy = `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}]}`
+
+// --- in-memory registry of creation templates keyed by prefix ---
+type mockECRClient struct {
+	templates map[string]types.RepositoryCreationTemplate
+	created   []string
+	updated   []string
+}
+
+func (m *mockECRClient) DescribeRepositoryCreationTemplates(ctx context.Context, in *ecr.DescribeRepositoryCreationTemplatesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoryCreationTemplatesOutput, error) {
+	out := &ecr.DescribeRepositoryCreationTemplatesOutput{}
+	for _, t := range m.templates {
+		out.RepositoryCreationTemplates = append(out.RepositoryCreationTemplates, t)
+	}
+	return out, nil
+}
+
+func (m *mockECRClient) CreateRepositoryCreationTemplate(ctx context.Context, in *ecr.CreateRepositoryCreationTemplateInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryCreationTemplateOutput, error) {
+	prefix := aws.ToString(in.Prefix)
+	m.created = append(m.created, prefix)
+	m.templates[prefix] = types.RepositoryCreationTemplate{Prefix: in.Prefix, AppliedFor: in.AppliedFor, LifecyclePolicy: in.LifecyclePolicy, Description: in.Description}
+	return &ecr.CreateRepositoryCreationTemplateOutput{}, nil
+}
+
+func (m *mockECRClient) UpdateRepositoryCreationTemplate(ctx context.Context, in *ecr.UpdateRepositoryCreationTemplateInput, optFns ...func(*ecr.Options)) (*ecr.UpdateRepositoryCreationTemplateOutput, error) {
+	prefix := aws.ToString(in.Prefix)
+	m.updated = append(m.updated, prefix)
+	t := m.templates[prefix]
+	t.AppliedFor, t.LifecyclePolicy = in.AppliedFor, in.LifecyclePolicy
+	if in.Description != nil {
+		t.Description = in.Description
+	}
+	m.templates[prefix] = t
+	return &ecr.UpdateRepositoryCreationTemplateOutput{}, nil
+}
+
+func TestParseAppliedFor(t *testing.T) {
+	got, err := ParseAppliedFor(" create_on_push, REPLICATION,CREATE_ON_PUSH,")
+	want := []types.RCTAppliedFor{types.RCTAppliedForCreateOnPush, types.RCTAppliedForReplication}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAppliedFor() = %v, %v; want %v", got, err, want)
+	}
+	for _, value := range []string{"", " , ", "ON_PULL"} {
+		if _, err := ParseAppliedFor(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestValidatePrefix(t *testing.T) {
+	for _, prefix := range []string{RootPrefix, "team", "team/app"} {
+		if err := ValidatePrefix(prefix); err != nil {
+			t.Errorf("Expected %q to be valid, got: %v", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"", "Team", "team/"} {
+		if err := ValidatePrefix(prefix); err == nil || !strings.Contains(err.Error(), "--repoPrefix") {
+			t.Errorf("Expected error for %q, got: %v", prefix, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	client := &mockECRClient{templates: map[string]types.RepositoryCreationTemplate{
+		"legacy": {Prefix: aws.String("legacy"), AppliedFor: []types.RCTAppliedFor{types.RCTAppliedForCreateOnPush}, Description: aws.String("kept")},
+	}}
+	appliedFor := []types.RCTAppliedFor{types.RCTAppliedForCreateOnPush, types.RCTAppliedForPullThroughCache}
+	ctx := context.TODO()
+
+	// --- dry run changes nothing ---
+	result, err := Apply(ctx, client, ApplyOptions{Prefix: "team", PolicyText: policy, AppliedFor: appliedFor, DryRun: true})
+	if err != nil || result.Action != ActionCreated || len(client.created) != 0 {
+		t.Fatalf("Unexpected dry run: %+v, %v, created %v", result, err, client.created)
+	}
+
+	result, err = Apply(ctx, client, ApplyOptions{Prefix: "team", PolicyText: policy, AppliedFor: appliedFor})
+	if err != nil || result.Action != ActionCreated || !reflect.DeepEqual(client.created, []string{"team"}) {
+		t.Fatalf("Expected the template to be created: %+v, %v", result, err)
+	}
+
+	// --- the same policy with different whitespace and the applied-for list in another order is unchanged ---
+	reordered := []types.RCTAppliedFor{types.RCTAppliedForPullThroughCache, types.RCTAppliedForCreateOnPush}
+	result, err = Apply(ctx, client, ApplyOptions{Prefix: "team", PolicyText: " " + policy + "\n", AppliedFor: reordered})
+	if err != nil || result.Action != ActionUnchanged || len(client.updated) != 0 {
+		t.Fatalf("Expected the template to be unchanged: %+v, %v", result, err)
+	}
+
+	// --- an existing template gets the policy, its description is kept ---
+	result, err = Apply(ctx, client, ApplyOptions{Prefix: "legacy", PolicyText: policy, AppliedFor: appliedFor})
+	if err != nil || result.Action != ActionUpdated || !reflect.DeepEqual(client.updated, []string{"legacy"}) {
+		t.Fatalf("Expected the template to be updated: %+v, %v", result, err)
+	}
+	if aws.ToString(client.templates["legacy"].LifecyclePolicy) != policy || aws.ToString(client.templates["legacy"].Description) != "kept" {
+		t.Errorf("Unexpected template: %+v", client.templates["legacy"])
+	}
+
+	templates, err := Describe(ctx, client)
+	if err != nil || len(templates) != 2 || templates[0].Prefix != "legacy" || templates[1].Prefix != "team" {
+		t.Errorf("Expected templates sorted by prefix, got %+v, %v", templates, err)
+	}
+}
@@ -5,348 +5,3396 @@ package deleteuntaggedimages
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	apitimeout "ecr-lifecycle-cleaner/internal/apiTimeout"
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	format "ecr-lifecycle-cleaner/internal/format"
+	imageref "ecr-lifecycle-cleaner/internal/imageReference"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
+	"ecr-lifecycle-cleaner/internal/sliceutil"
+	"ecr-lifecycle-cleaner/internal/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// --- ECRAPI defines the subset of ecr.Client methods used for testability ---
+type ECRAPI interface {
+	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+	ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+	BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+	BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+	DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error)
+	GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error)
+	DeleteRepository(ctx context.Context, in *ecr.DeleteRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.DeleteRepositoryOutput, error)
+	ListTagsForResource(ctx context.Context, in *ecr.ListTagsForResourceInput, optFns ...func(*ecr.Options)) (*ecr.ListTagsForResourceOutput, error)
+}
+
+// --- limitedClient caps the calls in flight per operation, so expensive calls can be shaped tighter than cheap ones ---
+// --- the read calls of the planning phase are limited as well, dry runs hit them just as hard as real runs ---
+type limitedClient struct {
+	ECRAPI
+	limiter concurrency.Limiter
+}
+
+// --- wraps client with per-operation limits, operations without a limit pass straight through ---
+func WithOperationLimits(client ECRAPI, limits concurrency.OperationLimits) ECRAPI {
+	if len(limits) == 0 {
+		return client
+	}
+	return &limitedClient{ECRAPI: client, limiter: concurrency.NewLimiter(limits)}
+}
+
+func (c *limitedClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "ListImages", func() (*ecr.ListImagesOutput, error) {
+		return c.ECRAPI.ListImages(ctx, in, optFns...)
+	})
+}
+
+func (c *limitedClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "BatchGetImage", func() (*ecr.BatchGetImageOutput, error) {
+		return c.ECRAPI.BatchGetImage(ctx, in, optFns...)
+	})
+}
+
+func (c *limitedClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "BatchDeleteImage", func() (*ecr.BatchDeleteImageOutput, error) {
+		return c.ECRAPI.BatchDeleteImage(ctx, in, optFns...)
+	})
+}
+
+func (c *limitedClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "DescribeImages", func() (*ecr.DescribeImagesOutput, error) {
+		return c.ECRAPI.DescribeImages(ctx, in, optFns...)
+	})
+}
+
+func (c *limitedClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "GetLifecyclePolicy", func() (*ecr.GetLifecyclePolicyOutput, error) {
+		return c.ECRAPI.GetLifecyclePolicy(ctx, in, optFns...)
+	})
+}
+
+// --- PaginationStats counts the image API calls made for one repository, so the cost of a run can be estimated ---
+type PaginationStats struct {
+	ListImagesPages       int `json:"listImagesPages"`
+	BatchGetImageCalls    int `json:"batchGetImageCalls"`
+	BatchDeleteImageCalls int `json:"batchDeleteImageCalls"`
+	DescribeImagesCalls   int `json:"describeImagesCalls"`
+}
+
+// --- returns the number of calls across all operations ---
+func (s PaginationStats) Total() int {
+	return s.ListImagesPages + s.BatchGetImageCalls + s.BatchDeleteImageCalls + s.DescribeImagesCalls
+}
+
+// --- returns the sum of both stats ---
+func (s PaginationStats) Add(other PaginationStats) PaginationStats {
+	return PaginationStats{
+		ListImagesPages:       s.ListImagesPages + other.ListImagesPages,
+		BatchGetImageCalls:    s.BatchGetImageCalls + other.BatchGetImageCalls,
+		BatchDeleteImageCalls: s.BatchDeleteImageCalls + other.BatchDeleteImageCalls,
+		DescribeImagesCalls:   s.DescribeImagesCalls + other.DescribeImagesCalls,
+	}
+}
+
+// --- countingClient counts the image API calls made through it, a repository's goroutines may share one ---
+type countingClient struct {
+	ECRAPI
+	mu    sync.Mutex
+	stats PaginationStats
+}
+
+// --- wraps client so the image API calls of one repository are counted ---
+func newCountingClient(client ECRAPI) *countingClient {
+	return &countingClient{ECRAPI: client}
+}
+
+// --- returns the calls counted so far ---
+func (c *countingClient) Stats() PaginationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *countingClient) count(field *int) {
+	c.mu.Lock()
+	*field++
+	c.mu.Unlock()
+}
+
+func (c *countingClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	c.count(&c.stats.ListImagesPages)
+	return c.ECRAPI.ListImages(ctx, in, optFns...)
+}
+
+func (c *countingClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	c.count(&c.stats.BatchGetImageCalls)
+	return c.ECRAPI.BatchGetImage(ctx, in, optFns...)
+}
+
+func (c *countingClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	c.count(&c.stats.BatchDeleteImageCalls)
+	return c.ECRAPI.BatchDeleteImage(ctx, in, optFns...)
+}
+
+func (c *countingClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	c.count(&c.stats.DescribeImagesCalls)
+	return c.ECRAPI.DescribeImages(ctx, in, optFns...)
+}
+
+// --- DescribeImagesMaxResults is the largest page DescribeImages returns, larger pages mean fewer calls to be throttled ---
+const DescribeImagesMaxResults = 1000
+
+// --- DescribeImagesPaginator pages through DescribeImages with the largest page size, following nextToken to the last page ---
+type DescribeImagesPaginator struct {
+	repository string
+	pages      *ecr.DescribeImagesPaginator
+}
+
+// --- returns a paginator over the images of in.RepositoryName, in must not list ImageIds as those cannot be paged ---
+func NewDescribeImagesPaginator(client ECRAPI, in *ecr.DescribeImagesInput) *DescribeImagesPaginator {
+	return &DescribeImagesPaginator{
+		repository: aws.ToString(in.RepositoryName),
+		pages: ecr.NewDescribeImagesPaginator(client, in, func(o *ecr.DescribeImagesPaginatorOptions) {
+			o.Limit = DescribeImagesMaxResults
+		}),
+	}
+}
+
+// --- reports whether another page is left ---
+func (p *DescribeImagesPaginator) HasMorePages() bool {
+	return p.pages.HasMorePages()
+}
+
+// --- returns the image details of the next page ---
+func (p *DescribeImagesPaginator) NextPage(ctx context.Context) ([]types.ImageDetail, error) {
+	page, err := p.pages.NextPage(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe images for repository %s: %w", p.repository, err)
+	}
+	return page.ImageDetails, nil
+}
+
+// --- returns the image details of every page, nothing is returned unless all pages were read ---
+func (p *DescribeImagesPaginator) All(ctx context.Context) ([]types.ImageDetail, error) {
+	var details []types.ImageDetail
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, page...)
+	}
+	return details, nil
+}
+
+// --- CleanOptions controls how repositories are cleaned ---
+type CleanOptions struct {
+	DryRun bool
+	// --- maximum number of repositories processed at once, 0 means unbounded ---
+	Concurrency int
+	// --- untagged images pushed more recently than this are kept ---
+	MinAge time.Duration
+	// --- per-repository overrides of MinAge, first matching pattern wins ---
+	MinAgeRules []MinAgeRule
+	// --- tagged images to delete alongside the orphans, and tagged images to always keep ---
+	TagPatterns TagPatterns
+	// --- minimum ages of tagged images with a matching tag, used instead of MinAge for them ---
+	TagAgePolicies []TagAgePolicy
+	// --- keeps the newest tagged images per tag prefix group and deletes the older ones ---
+	PrefixRetention PrefixRetention
+	// --- when set, only untagged images pushed before the image carrying this tag are deleted ---
+	// --- repositories without the tag keep all their untagged images ---
+	OlderThanTag string
+	// --- incremental runs, only untagged images pushed at or after the repository's time are evaluated as orphans ---
+	// --- repositories without an entry are scanned in full ---
+	PushedSince map[string]time.Time
+	// --- only images pushed within the range are deleted, untagged and tagged alike, images without a push date are kept ---
+	PushedRange DateRangeFilter
+	// --- only untagged images not pulled recently are deleted, optionally tagged ones not pulled recently are deleted too ---
+	NotPulled NotPulledFilter
+	// --- digests that are never deleted, e.g. images running in Kubernetes, their children are kept as well ---
+	ProtectedDigests map[string]struct{}
+	// --- children kept indexes list for one of these platforms are not protected by them, so untagged ones are deleted ---
+	// --- like orphans while the other platforms of the index are kept ---
+	DropPlatforms []Platform
+	// --- when set, only the oldest untagged images are deleted, just enough for their sizes to reach the target ---
+	ReclaimTarget *ReclaimTarget
+	// --- the images each repository is listed for, empty means ANY ---
+	ListTagStatus TagStatusFilter
+	// --- skip repositories whose lifecycle policy already expires untagged images ---
+	SkipPolicyManaged bool
+	// --- read each repository's ecr-cleaner:* resource tags and let them skip the repository or override its minimum age ---
+	RepoTagOverrides bool
+	// --- warn about repositories holding more images (tagged and untagged) than this, 0 disables the check ---
+	WarnAboveCount int
+	// --- when set, repositories are cleaned one at a time and each deletion must be confirmed ---
+	Confirm func(repo string, images []string) StepDecision
+	// --- called as soon as each repository has been cleaned, e.g. to record a checkpoint ---
+	OnRepositoryDone func(result RepositoryCleanResult)
+	// --- once closed, no new repository or delete batch is started, the BatchDeleteImage calls in flight finish ---
+	// --- since ctx is left alone, so a shutdown never leaves a deletion half done ---
+	Stop <-chan struct{}
+	// --- account and region the client operates on, added to per-repository errors and the report ---
+	Registry awsregistry.Registry
+	// --- when set, log messages are buffered for the caller to flush instead of printed, step mode still prints right away ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- ReclaimTarget caps a cleanup at the storage it needs to reclaim, per repository or for the whole run ---
+type ReclaimTarget struct {
+	Bytes int64
+	// --- one budget shared by every repository of the run instead of one per repository ---
+	Global bool
+
+	mu        sync.Mutex
+	remaining int64
+}
+
+// --- returns a target of bytes per repository, or for the whole run when global is set ---
+func NewReclaimTarget(bytes int64, global bool) *ReclaimTarget {
+	return &ReclaimTarget{Bytes: bytes, Global: global, remaining: bytes}
+}
+
+// --- claims size bytes from the shared budget, false once the budget is used up ---
+func (t *ReclaimTarget) claim(size int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.remaining <= 0 {
+		return false
+	}
+	t.remaining -= size
+	return true
+}
+
+// --- keeps the oldest orphans until their sizes reach the target, the image crossing the target is deleted too ---
+// --- orphans without a known push date or size are kept, with a global target repositories draw from one budget ---
+// --- in the order they are planned, so it stops early but is not the globally oldest set ---
+func filterToReclaimTarget(ctx context.Context, repository string, orphans []string, target *ReclaimTarget, client ECRAPI) ([]string, int64, error) {
+	if len(orphans) == 0 {
+		return orphans, 0, nil
+	}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusUntagged},
+	}).All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	candidates := make(map[string]struct{}, len(orphans))
+	for _, orphan := range orphans {
+		candidates[orphan] = struct{}{}
+	}
+	var sized []types.ImageDetail
+	for _, detail := range details {
+		if _, ok := candidates[aws.ToString(detail.ImageDigest)]; ok && detail.ImagePushedAt != nil && detail.ImageSizeInBytes != nil {
+			sized = append(sized, detail)
+		}
+	}
+	// --- oldest first, the larger image first when two were pushed at the same time ---
+	sort.Slice(sized, func(i, j int) bool {
+		if !sized[i].ImagePushedAt.Equal(*sized[j].ImagePushedAt) {
+			return sized[i].ImagePushedAt.Before(*sized[j].ImagePushedAt)
+		}
+		return aws.ToInt64(sized[i].ImageSizeInBytes) > aws.ToInt64(sized[j].ImageSizeInBytes)
+	})
+	var selected []string
+	var reclaimed int64
+	for _, detail := range sized {
+		size := aws.ToInt64(detail.ImageSizeInBytes)
+		if target.Global {
+			if !target.claim(size) {
+				break
+			}
+		} else if reclaimed >= target.Bytes {
+			break
+		}
+		selected = append(selected, aws.ToString(detail.ImageDigest))
+		reclaimed += size
+	}
+	return selected, reclaimed, nil
+}
+
+// --- MinAgeRule overrides the minimum age for repositories matching a pattern ---
+type MinAgeRule struct {
+	Pattern string `json:"pattern"`
+	MinAge  string `json:"minAge"`
+
+	re     *regexp.Regexp
+	minAge time.Duration
+}
+
+// --- returns the effective minimum age for a repository ---
+func (o CleanOptions) MinAgeFor(repository string) time.Duration {
+	for _, rule := range o.MinAgeRules {
+		if rule.re != nil && rule.re.MatchString(repository) {
+			return rule.minAge
+		}
+	}
+	return o.MinAge
+}
+
+// --- TagPatterns selects tagged images to delete together with the orphans, using shell globs such as tmp-* ---
+type TagPatterns struct {
+	// --- an image with any tag matching one of these is never deleted, keep wins over delete ---
+	Keep []string
+	// --- an image is deleted when every one of its tags matches one of these ---
+	Delete []string
+}
+
+// --- parses comma-separated keep and delete glob lists ---
+func ParseTagPatterns(keep, delete string) (TagPatterns, error) {
+	var patterns TagPatterns
+	var err error
+	if patterns.Keep, err = splitGlobs(keep); err != nil {
+		return TagPatterns{}, err
+	}
+	if patterns.Delete, err = splitGlobs(delete); err != nil {
+		return TagPatterns{}, err
+	}
+	return patterns, nil
+}
+
+// --- TagStatusFilter is the tag status ListImages is asked for, so images the run never looks at are not transferred ---
+type TagStatusFilter string
+
+const (
+	// --- every image, untagged images as orphans and tagged images as the sources of protected children, the default ---
+	TagStatusAny TagStatusFilter = "ANY"
+	// --- only tagged images, orphans are not listed so only tag-based rules delete anything ---
+	TagStatusTagged TagStatusFilter = "TAGGED"
 )
 
-// --- ECRAPI defines the subset of ecr.Client methods used for testability ---
-type ECRAPI interface {
-	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
-	ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
-	BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
-	BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+// --- parses ANY or TAGGED, case-insensitive, an empty value is ANY ---
+func ParseTagStatusFilter(value string) (TagStatusFilter, error) {
+	switch filter := TagStatusFilter(strings.ToUpper(strings.TrimSpace(value))); filter {
+	case "":
+		return TagStatusAny, nil
+	case TagStatusAny, TagStatusTagged:
+		return filter, nil
+	}
+	return "", fmt.Errorf("invalid tag status %q, expected ANY or TAGGED", value)
+}
+
+// --- returns the ListImages tag statuses to list, untagged before tagged so an image tagged in between is still protected ---
+func (f TagStatusFilter) listedStatuses() []types.TagStatus {
+	if f == TagStatusTagged {
+		return []types.TagStatus{types.TagStatusTagged}
+	}
+	return []types.TagStatus{types.TagStatusUntagged, types.TagStatusTagged}
+}
+
+// --- splits a comma-separated list of globs and checks each is well formed ---
+func splitGlobs(value string) ([]string, error) {
+	var globs []string
+	for _, glob := range strings.Split(value, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %w", glob, err)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
+
+// --- reports whether an image with these tags should be deleted ---
+// --- a tag matching no pattern protects the image, deleting by digest would remove that tag as well ---
+func (p TagPatterns) ShouldDelete(tags []string) bool {
+	if len(p.Delete) == 0 || len(tags) == 0 {
+		return false
+	}
+	for _, tag := range tags {
+		if matchesAny(p.Keep, tag) {
+			return false
+		}
+	}
+	for _, tag := range tags {
+		if !matchesAny(p.Delete, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAny(globs []string, tag string) bool {
+	for _, glob := range globs {
+		// --- the globs were validated when parsed, so Match cannot fail ---
+		if matched, _ := path.Match(glob, tag); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// --- DateRangeFilter selects images pushed at or after After and before Before, a zero bound leaves that side open ---
+type DateRangeFilter struct {
+	After  time.Time
+	Before time.Time
+}
+
+// --- reports whether neither bound is set, so every image is in range ---
+func (f DateRangeFilter) IsZero() bool {
+	return f.After.IsZero() && f.Before.IsZero()
+}
+
+// --- reports whether an image pushed at pushedAt is in range ---
+func (f DateRangeFilter) Contains(pushedAt time.Time) bool {
+	if !f.After.IsZero() && pushedAt.Before(f.After) {
+		return false
+	}
+	return f.Before.IsZero() || pushedAt.Before(f.Before)
+}
+
+// --- checks the bounds are in order ---
+func (f DateRangeFilter) Validate() error {
+	if !f.After.IsZero() && !f.Before.IsZero() && !f.After.Before(f.Before) {
+		return fmt.Errorf("the start of the push date range %s is not before its end %s", f.After.Format(time.RFC3339), f.Before.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// --- describes the range for log messages ---
+func (f DateRangeFilter) String() string {
+	switch {
+	case f.After.IsZero():
+		return "before " + f.Before.UTC().Format(time.RFC3339)
+	case f.Before.IsZero():
+		return "since " + f.After.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("between %s and %s", f.After.UTC().Format(time.RFC3339), f.Before.UTC().Format(time.RFC3339))
+}
+
+// --- NotPulledFilter selects images nobody pulled recently, by the last pull time ECR records ---
+type NotPulledFilter struct {
+	// --- images pulled within this long are kept, 0 disables the filter ---
+	Since time.Duration
+	// --- also delete tagged images not pulled within Since, images with a tag matching the keep patterns are kept ---
+	IncludeTagged bool
+}
+
+// --- reports whether the filter is enabled ---
+func (f NotPulledFilter) Enabled() bool {
+	return f.Since > 0
+}
+
+// --- reports whether an image was not pulled within Since of now ---
+// --- images never pulled count from their push date, so fresh pushes are not selected before anyone could pull them ---
+// --- images without either date are never selected ---
+func (f NotPulledFilter) Selects(detail types.ImageDetail, now time.Time) bool {
+	last := detail.LastRecordedPullTime
+	if last == nil {
+		last = detail.ImagePushedAt
+	}
+	return last != nil && last.Before(now.Add(-f.Since))
+}
+
+// --- returns the candidates the filter selects, tagged candidates carrying a tag matching keep are left out ---
+func filterNotPulled(ctx context.Context, repository string, candidates []string, status types.TagStatus, filter NotPulledFilter, keep []string, client ECRAPI) ([]string, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		Filter:         &types.DescribeImagesFilter{TagStatus: status},
+	}).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	selected := make(map[string]struct{}, len(candidates))
+	for _, detail := range details {
+		if !filter.Selects(detail, now) || slices.ContainsFunc(detail.ImageTags, func(tag string) bool { return matchesAny(keep, tag) }) {
+			continue
+		}
+		selected[aws.ToString(detail.ImageDigest)] = struct{}{}
+	}
+	result := make([]string, 0, len(candidates))
+	for _, digest := range candidates {
+		if _, ok := selected[digest]; ok {
+			result = append(result, digest)
+		}
+	}
+	return result, nil
+}
+
+// --- PrefixRetention keeps the newest tagged images of each tag prefix group, build-41 and build-42 form the build group ---
+type PrefixRetention struct {
+	// --- number of images kept per group, 0 disables the rule ---
+	KeepNewest int
+	// --- separates the group from the rest of the tag, the first occurrence counts ---
+	Delimiter string
+}
+
+// --- returns the group a tag belongs to, false for tags without the delimiter such as latest ---
+func (r PrefixRetention) groupOf(tag string) (string, bool) {
+	group, _, found := strings.Cut(tag, r.Delimiter)
+	return group, found && group != ""
+}
+
+// --- returns the candidates older than the newest KeepNewest images of every group their tags belong to ---
+// --- an image is kept when one of its tags is among the newest of its group, has no group or matches a keep pattern ---
+// --- images without a known push date are kept ---
+func expiredByPrefix(ctx context.Context, repository string, candidates []string, retention PrefixRetention, keep []string, client ECRAPI) ([]string, error) {
+	if retention.KeepNewest <= 0 || len(candidates) == 0 {
+		return nil, nil
+	}
+	wanted := make(map[string]bool, len(candidates))
+	for _, digest := range candidates {
+		wanted[digest] = true
+	}
+
+	type taggedImage struct {
+		digest   string
+		tags     []string
+		pushedAt time.Time
+	}
+	var images []taggedImage
+	groups := map[string][]int{}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusTagged},
+	}).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, detail := range details {
+		digest := aws.ToString(detail.ImageDigest)
+		if !wanted[digest] || detail.ImagePushedAt == nil {
+			continue
+		}
+		wanted[digest] = false
+		images = append(images, taggedImage{digest: digest, tags: detail.ImageTags, pushedAt: *detail.ImagePushedAt})
+		seen := map[string]bool{}
+		for _, tag := range detail.ImageTags {
+			if group, ok := retention.groupOf(tag); ok && !seen[group] {
+				seen[group] = true
+				groups[group] = append(groups[group], len(images)-1)
+			}
+		}
+	}
+
+	kept := make([]bool, len(images))
+	for _, members := range groups {
+		sort.Slice(members, func(i, j int) bool {
+			a, b := images[members[i]], images[members[j]]
+			if !a.pushedAt.Equal(b.pushedAt) {
+				return a.pushedAt.After(b.pushedAt)
+			}
+			return a.digest < b.digest
+		})
+		for _, i := range members[:min(retention.KeepNewest, len(members))] {
+			kept[i] = true
+		}
+	}
+
+	var expired []string
+	for i, image := range images {
+		if kept[i] || len(image.tags) == 0 {
+			continue
+		}
+		protected := false
+		for _, tag := range image.tags {
+			if _, ok := retention.groupOf(tag); !ok || matchesAny(keep, tag) {
+				protected = true
+				break
+			}
+		}
+		if !protected {
+			expired = append(expired, image.digest)
+		}
+	}
+	return expired, nil
+}
+
+// --- parses an age such as 90m, 36h or 7d, days are not supported by time.ParseDuration ---
+func ParseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return d, nil
+}
+
+// --- parses a date such as 2024-01-31 (midnight UTC) or an RFC 3339 time ---
+func ParseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// --- TagAgePolicy keeps tagged images with a tag matching Pattern until they are older than MinAge ---
+type TagAgePolicy struct {
+	Pattern string
+	MinAge  time.Duration
+}
+
+// --- parses "pattern:age" pairs such as release-*:90d or pr-*:1d, the pattern is a glob like the tag patterns ---
+func ParseTagAgePolicies(specs []string) ([]TagAgePolicy, error) {
+	policies := make([]TagAgePolicy, 0, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid tag age policy %q, expected pattern:age", spec)
+		}
+		pattern := strings.TrimSpace(spec[:i])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %w", pattern, err)
+		}
+		age, err := ParseAge(strings.TrimSpace(spec[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag age policy %q: %w", spec, err)
+		}
+		policies = append(policies, TagAgePolicy{Pattern: pattern, MinAge: age})
+	}
+	return policies, nil
+}
+
+// --- returns the minimum age of an image with these tags, the longest of the matching policies or fallback when none match ---
+func tagMinAge(policies []TagAgePolicy, tags []string, fallback time.Duration) time.Duration {
+	matched := false
+	var age time.Duration
+	for _, policy := range policies {
+		for _, tag := range tags {
+			// --- the patterns were validated when parsed, so Match cannot fail ---
+			if ok, _ := path.Match(policy.Pattern, tag); ok {
+				if !matched || policy.MinAge > age {
+					age = policy.MinAge
+				}
+				matched = true
+				break
+			}
+		}
+	}
+	if !matched {
+		return fallback
+	}
+	return age
+}
+
+// --- drops tagged candidates younger than the minimum age of their tags, images without a known push date are kept ---
+func filterByTagAge(ctx context.Context, repository string, candidates []string, policies []TagAgePolicy, fallback time.Duration, client ECRAPI) ([]string, error) {
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusTagged},
+	}).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	old := make(map[string]struct{}, len(candidates))
+	for _, detail := range details {
+		if detail.ImagePushedAt != nil && detail.ImagePushedAt.Before(now.Add(-tagMinAge(policies, detail.ImageTags, fallback))) {
+			old[aws.ToString(detail.ImageDigest)] = struct{}{}
+		}
+	}
+	result := make([]string, 0, len(candidates))
+	for _, digest := range candidates {
+		if _, ok := old[digest]; ok {
+			result = append(result, digest)
+		}
+	}
+	return result, nil
+}
+
+// --- reads a JSON list of {"pattern": "...", "minAge": "..."} rules and validates them ---
+func LoadMinAgeRules(filePath string) ([]MinAgeRule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read min age map: %w", err)
+	}
+	var rules []MinAgeRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid JSON in min age map: %w", err)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// --- reads a file of images to protect in every repository, one per line, blank lines and lines starting with # are skipped ---
+// --- a line is a digest (sha256:...), a reference pinned by digest, or a repository:tag of the registry resolved with BatchGetImage ---
+func LoadProtectedManifests(ctx context.Context, client ECRAPI, filePath, registryURI string) (map[string]struct{}, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protected manifests file: %w", err)
+	}
+	digests := map[string]struct{}{}
+	tagsByRepo := map[string][]string{}
+	var repos []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "sha256:") {
+			digests[line] = struct{}{}
+			continue
+		}
+		ref, err := imageref.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of protected manifests file: %w", i+1, err)
+		}
+		switch {
+		case ref.Digest != "":
+			digests[ref.Digest] = struct{}{}
+		case ref.Tag == "":
+			return nil, fmt.Errorf("line %d of protected manifests file: %q has neither a tag nor a digest", i+1, line)
+		case ref.Registry != "" && !strings.EqualFold(ref.Registry, registryURI):
+			return nil, fmt.Errorf("line %d of protected manifests file: %q is not in registry %s, its tag cannot be resolved", i+1, line, registryURI)
+		default:
+			if _, ok := tagsByRepo[ref.Repository]; !ok {
+				repos = append(repos, ref.Repository)
+			}
+			tagsByRepo[ref.Repository] = append(tagsByRepo[ref.Repository], ref.Tag)
+		}
+	}
+
+	for _, repo := range repos {
+		for _, tags := range sliceutil.Partition(sliceutil.Unique(tagsByRepo[repo]), 100) {
+			ids := make([]types.ImageIdentifier, len(tags))
+			for i, tag := range tags {
+				ids[i] = types.ImageIdentifier{ImageTag: aws.String(tag)}
+			}
+			out, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{RepositoryName: aws.String(repo), ImageIds: ids, AcceptedMediaTypes: acceptedManifestMediaTypes})
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve protected tags of repository %s: %w", repo, err)
+			}
+			// --- a tag that no longer resolves would silently lose its protection, so the run stops instead ---
+			if len(out.Failures) > 0 {
+				failure := out.Failures[0]
+				var tag string
+				if failure.ImageId != nil {
+					tag = aws.ToString(failure.ImageId.ImageTag)
+				}
+				return nil, fmt.Errorf("failed to resolve protected tag %s:%s: %s", repo, tag, aws.ToString(failure.FailureReason))
+			}
+			for _, image := range out.Images {
+				if image.ImageId != nil {
+					digests[aws.ToString(image.ImageId.ImageDigest)] = struct{}{}
+				}
+			}
+		}
+	}
+	return digests, nil
+}
+
+// --- validates the pattern and age of a rule ---
+func (r *MinAgeRule) compile() error {
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q in min age map: %w", r.Pattern, err)
+	}
+	minAge, err := ParseAge(r.MinAge)
+	if err != nil {
+		return fmt.Errorf("invalid min age for pattern %q: %w", r.Pattern, err)
+	}
+	r.re = re
+	r.minAge = minAge
+	return nil
+}
+
+// --- resource tag keys repository owners set to customize cleanup of their repository ---
+const (
+	SkipTagKey   = "ecr-cleaner:skip"
+	MinAgeTagKey = "ecr-cleaner:minAge"
+)
+
+// --- RepoTagOverride is the cleanup behavior a repository asks for through its resource tags ---
+type RepoTagOverride struct {
+	Skip   bool
+	MinAge time.Duration
+	// --- whether MinAge was set by a tag, a tag of 0d turns the age check off for the repository ---
+	HasMinAge bool
+}
+
+// --- reads the ecr-cleaner:* tags, other tags are ignored and malformed values are rejected ---
+func ParseRepoTagOverride(tags []types.Tag) (RepoTagOverride, error) {
+	var override RepoTagOverride
+	for _, tag := range tags {
+		value := strings.TrimSpace(aws.ToString(tag.Value))
+		switch aws.ToString(tag.Key) {
+		case SkipTagKey:
+			skip, err := strconv.ParseBool(value)
+			if err != nil {
+				return RepoTagOverride{}, fmt.Errorf("invalid value %q for tag %s, expected true or false", value, SkipTagKey)
+			}
+			override.Skip = skip
+		case MinAgeTagKey:
+			minAge, err := ParseAge(value)
+			if err != nil {
+				return RepoTagOverride{}, fmt.Errorf("invalid value for tag %s: %w", MinAgeTagKey, err)
+			}
+			override.MinAge = minAge
+			override.HasMinAge = true
+		}
+	}
+	return override, nil
+}
+
+// --- returns the options for a single repository with its tag override applied, the tag wins over --minAge and the min age map ---
+func (o CleanOptions) withOverride(override RepoTagOverride) CleanOptions {
+	if override.HasMinAge {
+		o.MinAge = override.MinAge
+		o.MinAgeRules = nil
+	}
+	return o
+}
+
+// --- looks up the repository's ARN and reads its cleanup tags ---
+func repoTagOverride(ctx context.Context, client ECRAPI, repository string) (RepoTagOverride, error) {
+	repos, err := client.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{repository},
+	})
+	if err != nil {
+		return RepoTagOverride{}, fmt.Errorf("failed to describe repository %s: %w", repository, err)
+	}
+	if repos == nil || len(repos.Repositories) == 0 {
+		return RepoTagOverride{}, fmt.Errorf("repository %s not found", repository)
+	}
+	out, err := client.ListTagsForResource(ctx, &ecr.ListTagsForResourceInput{
+		ResourceArn: repos.Repositories[0].RepositoryArn,
+	})
+	if err != nil {
+		return RepoTagOverride{}, fmt.Errorf("failed to list tags for repository %s: %w", repository, err)
+	}
+	if out == nil {
+		return RepoTagOverride{}, nil
+	}
+	return ParseRepoTagOverride(out.Tags)
+}
+
+// --- applies the repository's tags to opts when enabled, a failed lookup only warns and the repository is cleaned as configured ---
+func applyRepoTags(ctx context.Context, client ECRAPI, repository string, opts CleanOptions, logf func(format string, args ...interface{})) (CleanOptions, bool) {
+	if !opts.RepoTagOverrides {
+		return opts, false
+	}
+	override, err := repoTagOverride(ctx, client, repository)
+	if err != nil {
+		logf("[WARN] Repository: %s - Could not apply repository tag overrides: %v", repository, err)
+		return opts, false
+	}
+	if override.Skip {
+		logf("[INFO] Repository: %s - Skipping, opted out via the %s tag", repository, SkipTagKey)
+		return opts, true
+	}
+	if override.HasMinAge {
+		logf("[INFO] Repository: %s - Using a minimum age of %s from the %s tag", repository, override.MinAge, MinAgeTagKey)
+	}
+	return opts.withOverride(override), false
+}
+
+// --- RepositoryCleanResult is the outcome of cleaning a single repository ---
+type RepositoryCleanResult struct {
+	Repository string `json:"repository"`
+	Tagged     int    `json:"tagged"`
+	Untagged   int    `json:"untagged"`
+	Orphans    int    `json:"orphans"`
+	Deleted    int    `json:"deleted"`
+	Failed     int    `json:"failed"`
+	Error      string `json:"error,omitempty"`
+	// --- the error is a call that ran past its own timeout, not a call that failed ---
+	TimedOut bool `json:"timedOut,omitempty"`
+	// --- the repository's lifecycle policy already expires untagged images ---
+	PolicyManaged bool `json:"policyManaged,omitempty"`
+	Skipped       bool `json:"skipped,omitempty"`
+	// --- the repository held more images than CleanOptions.WarnAboveCount ---
+	ImageCountWarn bool `json:"imageCountWarn,omitempty"`
+	// --- the run was stopped before every planned image of the repository was deleted ---
+	Interrupted bool `json:"interrupted,omitempty"`
+	// --- the images BatchDeleteImage refused to delete, so they can be retried ---
+	Failures []FailedDeletion `json:"failures,omitempty"`
+	// --- image API calls made for the repository, planning and deletion together ---
+	APICalls PaginationStats `json:"apiCalls"`
+}
+
+// --- FailedDeletion is an image BatchDeleteImage reported as not deleted ---
+type FailedDeletion struct {
+	Repository    string    `json:"repository"`
+	Digest        string    `json:"digest"`
+	FailureCode   string    `json:"failureCode"`
+	FailureReason string    `json:"failureReason"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// --- writes failed deletions as JSON to path, "-" writes to stdout ---
+func SaveFailures(path string, failures []FailedDeletion) error {
+	if failures == nil {
+		failures = []FailedDeletion{}
+	}
+	return format.WriteReport(path, failures)
+}
+
+// --- reads failed deletions written by SaveFailures ---
+func LoadFailures(filePath string) ([]FailedDeletion, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read failures file: %w", err)
+	}
+	var failures []FailedDeletion
+	if err := json.Unmarshal(data, &failures); err != nil {
+		return nil, fmt.Errorf("failed to parse failures file %s: %w", filePath, err)
+	}
+	for i, failure := range failures {
+		if failure.Repository == "" || failure.Digest == "" {
+			return nil, fmt.Errorf("failures file %s: entry %d needs a repository and a digest", filePath, i)
+		}
+	}
+	return failures, nil
+}
+
+// --- turns failed deletions into a plan, so retrying them goes through ExecutePlan like any other deletion ---
+// --- duplicate entries are dropped and repositories are sorted ---
+func RetryPlan(failures []FailedDeletion, dryRun bool) CleanPlan {
+	digests := map[string][]string{}
+	seen := map[FailedDeletion]bool{}
+	for _, failure := range failures {
+		key := FailedDeletion{Repository: failure.Repository, Digest: failure.Digest}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		digests[failure.Repository] = append(digests[failure.Repository], failure.Digest)
+	}
+	plan := CleanPlan{DryRun: dryRun}
+	for repository, images := range digests {
+		plan.Repositories = append(plan.Repositories, RepositoryPlan{Repository: repository, Images: images})
+	}
+	sort.Slice(plan.Repositories, func(i, j int) bool {
+		return plan.Repositories[i].Repository < plan.Repositories[j].Repository
+	})
+	return plan
+}
+
+// --- CleanReport summarizes the outcome of a cleanup run ---
+type CleanReport struct {
+	Repositories []RepositoryCleanResult `json:"repositories"`
+	DryRun       bool                    `json:"dryRun"`
+	Aborted      bool                    `json:"aborted,omitempty"`
+	Duration     time.Duration           `json:"duration"`
+	// --- the run was stopped through CleanOptions.Stop, some repositories were left as they were ---
+	Interrupted bool `json:"interrupted,omitempty"`
+	// --- per-namespace rollup, only filled in when requested ---
+	Namespaces []NamespaceTotals `json:"namespaces,omitempty"`
+	// --- repositories deleted because the cleanup left them empty ---
+	DeletedRepositories []string `json:"deletedRepositories,omitempty"`
+	// --- account and region the run operated on ---
+	Registry awsregistry.Registry `json:"registry"`
+}
+
+// --- returns a copy of the report with the failure timestamps in loc, for display ---
+func (r CleanReport) InLocation(loc *time.Location) interface{} {
+	repositories := make([]RepositoryCleanResult, len(r.Repositories))
+	for i, result := range r.Repositories {
+		if len(result.Failures) > 0 {
+			failures := make([]FailedDeletion, len(result.Failures))
+			for j, failure := range result.Failures {
+				failure.Timestamp = failure.Timestamp.In(loc)
+				failures[j] = failure
+			}
+			result.Failures = failures
+		}
+		repositories[i] = result
+	}
+	r.Repositories = repositories
+	return r
+}
+
+// --- NamespaceTotals rolls up the results of all repositories sharing a namespace ---
+type NamespaceTotals struct {
+	Namespace    string `json:"namespace"`
+	Repositories int    `json:"repositories"`
+	Deleted      int    `json:"deleted"`
+	Failed       int    `json:"failed"`
+	Errors       int    `json:"errors"`
+}
+
+// --- returns the part of a repository name before the first /, empty for repositories without a namespace ---
+func Namespace(repository string) string {
+	namespace, _, found := strings.Cut(repository, "/")
+	if !found {
+		return ""
+	}
+	return namespace
+}
+
+// --- aggregates the results by namespace, sorted by namespace name ---
+func (r CleanReport) ByNamespace() []NamespaceTotals {
+	index := map[string]int{}
+	var totals []NamespaceTotals
+	for _, repo := range r.Repositories {
+		namespace := Namespace(repo.Repository)
+		i, ok := index[namespace]
+		if !ok {
+			i = len(totals)
+			index[namespace] = i
+			totals = append(totals, NamespaceTotals{Namespace: namespace})
+		}
+		totals[i].Repositories++
+		totals[i].Deleted += repo.Deleted
+		totals[i].Failed += repo.Failed
+		if repo.Error != "" {
+			totals[i].Errors++
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Namespace < totals[j].Namespace
+	})
+	return totals
+}
+
+// --- returns the failed deletions of all repositories ---
+func (r CleanReport) Failures() []FailedDeletion {
+	var failures []FailedDeletion
+	for _, repo := range r.Repositories {
+		failures = append(failures, repo.Failures...)
+	}
+	return failures
+}
+
+// --- returns the image API calls made across all repositories, an estimate as retries of throttled calls are not counted ---
+func (r CleanReport) TotalAPICallsEstimate() int {
+	total := 0
+	for _, repo := range r.Repositories {
+		total += repo.APICalls.Total()
+	}
+	return total
+}
+
+// --- returns the total number of deleted and failed images across all repositories ---
+func (r CleanReport) Totals() (int, int) {
+	deleted, failed := 0, 0
+	for _, repo := range r.Repositories {
+		deleted += repo.Deleted
+		failed += repo.Failed
+	}
+	return deleted, failed
+}
+
+// --- returns the repositories that failed, with an error or failed deletions, and the repositories processed ---
+// --- skipped and interrupted repositories count as neither ---
+func (r CleanReport) RepositoryOutcomes() (failed, processed int) {
+	for _, repo := range r.Repositories {
+		if repo.Skipped || repo.Interrupted {
+			continue
+		}
+		processed++
+		if repo.Error != "" || repo.Failed > 0 {
+			failed++
+		}
+	}
+	return failed, processed
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r CleanReport) Summary() string {
+	deleted, failed := r.Totals()
+	summary := fmt.Sprintf("Processed %d repos, deleted %d images, failed to delete %d", len(r.Repositories), deleted, failed)
+	timedOut, interrupted := 0, 0
+	for _, repo := range r.Repositories {
+		if repo.TimedOut {
+			timedOut++
+		}
+		if repo.Interrupted {
+			interrupted++
+		}
+	}
+	if timedOut > 0 {
+		summary += fmt.Sprintf(", %d repos failed on a call timeout", timedOut)
+	}
+	if r.Interrupted {
+		summary += fmt.Sprintf(", stopped before finishing %d repos", interrupted)
+	}
+	return summary
+}
+
+// --- returns the report as JUnit test results, a repository fails when it errored or an image could not be deleted ---
+func (r CleanReport) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner clean", Duration: r.Duration}
+	for _, repo := range r.Repositories {
+		tc := format.JUnitCase{Name: repo.Repository, ClassName: "clean"}
+		switch {
+		case repo.Error != "":
+			tc.Failure = repo.Error
+		case repo.Failed > 0:
+			tc.Failure = fmt.Sprintf("failed to delete %d of %d images", repo.Failed, repo.Deleted+repo.Failed)
+			var details []string
+			for _, failure := range repo.Failures {
+				details = append(details, fmt.Sprintf("%s: %s (%s)", failure.Digest, failure.FailureReason, failure.FailureCode))
+			}
+			tc.Details = strings.Join(details, "\n")
+		case repo.Skipped:
+			tc.Skipped = "skipped"
+		case repo.Interrupted:
+			tc.Skipped = "stopped before all images were deleted"
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return suite
+}
+
+// --- returns the report as Prometheus gauges, per repository and for the run as a whole ---
+func (r CleanReport) Metrics() []format.Metric {
+	var metrics []format.Metric
+	for _, repo := range r.Repositories {
+		labels := map[string]string{"repository": repo.Repository}
+		repoError := 0.0
+		if repo.Error != "" {
+			repoError = 1
+		}
+		metrics = append(metrics,
+			format.Metric{Name: "ecr_lifecycle_cleaner_images_deleted", Help: "Images deleted from the repository by the last clean run.", Labels: labels, Value: float64(repo.Deleted)},
+			format.Metric{Name: "ecr_lifecycle_cleaner_images_failed", Help: "Images the last clean run failed to delete from the repository.", Labels: labels, Value: float64(repo.Failed)},
+			format.Metric{Name: "ecr_lifecycle_cleaner_repository_error", Help: "1 when the last clean run could not process the repository.", Labels: labels, Value: repoError},
+		)
+	}
+	dryRun := 0.0
+	if r.DryRun {
+		dryRun = 1
+	}
+	return append(metrics,
+		format.Metric{Name: "ecr_lifecycle_cleaner_clean_duration_seconds", Help: "Duration of the last clean run.", Value: r.Duration.Seconds()},
+		format.Metric{Name: "ecr_lifecycle_cleaner_clean_dry_run", Help: "1 when the last clean run was a dry run.", Value: dryRun},
+	)
+}
+
+// --- returns one record per repository, for --jsonMode lines ---
+func (r CleanReport) Records() []interface{} {
+	records := make([]interface{}, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		records = append(records, repo)
+	}
+	return records
+}
+
+// --- returns one row per repository, for --output csv ---
+func (r CleanReport) Table() ([]string, [][]string) {
+	headers := []string{"repository", "tagged", "untagged", "orphans", "deleted", "failed", "skipped", "error"}
+	rows := make([][]string, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		rows = append(rows, []string{
+			repo.Repository,
+			strconv.Itoa(repo.Tagged),
+			strconv.Itoa(repo.Untagged),
+			strconv.Itoa(repo.Orphans),
+			strconv.Itoa(repo.Deleted),
+			strconv.Itoa(repo.Failed),
+			strconv.FormatBool(repo.Skipped),
+			repo.Error,
+		})
+	}
+	return headers, rows
+}
+
+// --- the entry point for deleting untagged images from ECR repositories ---
+// --- it fetches the list of repositories and deletes the untagged images from each ---
+func Main(client *ecr.Client, allRepos bool, repositoryList []string, repoPatterns []string, opts CleanOptions) (CleanReport, error) {
+	ctx := context.TODO()
+	if allRepos {
+		var err error
+		repositoryList, err = getRepositories(ctx, client)
+		if err != nil {
+			return CleanReport{DryRun: opts.DryRun}, err
+		}
+	} else if len(repoPatterns) > 0 {
+		var err error
+		repositoryList, err = ListRepositoriesByPattern(ctx, client, repoPatterns)
+		if err != nil {
+			return CleanReport{DryRun: opts.DryRun}, err
+		}
+	}
+
+	if len(repositoryList) == 0 {
+		return CleanReport{DryRun: opts.DryRun}, nil
+	}
+
+	return CleanECRWithLogging(ctx, client, repositoryList, opts)
+}
+
+// --- returns all repository names ---
+func getRepositories(ctx context.Context, client *ecr.Client) (repositories []string, err error) {
+	ctx, span := tracing.Start(ctx, "ListRepositories")
+	defer func() {
+		span.SetAttributes(attribute.Int("ecr.repository.count", len(repositories)))
+		tracing.End(span, err)
+	}()
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			repositories = append(repositories, aws.ToString(repo.RepositoryName))
+		}
+	}
+	return repositories, nil
+}
+
+// --- returns a map of tagged, orphan and tag-pattern-deletable ("tagDelete") image digests ---
+// --- ListImages returns one entry per tag, so tags are grouped by digest before the patterns are applied ---
+// --- untagged and tagged images are listed in separate filtered passes, with TAGGED only the tagged pass runs and nothing is an orphan ---
+// --- the tagged pass always runs, the children of the indexes it lists are protected from deletion ---
+func getImages(ctx context.Context, repository string, client ECRAPI, patterns TagPatterns, filter TagStatusFilter) (images map[string][]string, err error) {
+	ctx, span := tracing.Start(ctx, "DiscoverImages", attribute.String("ecr.repository", repository))
+	defer func() {
+		span.SetAttributes(attribute.Int("ecr.images.tagged", len(images["tagged"])+len(images["tagDelete"])), attribute.Int("ecr.images.untagged", len(images["orphan"])))
+		tracing.End(span, err)
+	}()
+	images = map[string][]string{"tagged": {}, "orphan": {}, "tagDelete": {}}
+	tags := map[string][]string{}
+	var taggedDigests, untaggedDigests []string
+	untagged := map[string]bool{}
+	for _, status := range filter.listedStatuses() {
+		paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{
+			RepositoryName: aws.String(repository),
+			Filter:         &types.ListImagesFilter{TagStatus: status},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
+			}
+			for _, image := range page.ImageIds {
+				digest := aws.ToString(image.ImageDigest)
+				if image.ImageTag == nil {
+					if !untagged[digest] {
+						untagged[digest] = true
+						untaggedDigests = append(untaggedDigests, digest)
+					}
+					continue
+				}
+				tag := aws.ToString(image.ImageTag)
+				if _, seen := tags[digest]; !seen {
+					taggedDigests = append(taggedDigests, digest)
+				}
+				if !slices.Contains(tags[digest], tag) {
+					tags[digest] = append(tags[digest], tag)
+				}
+			}
+		}
+	}
+	for _, digest := range taggedDigests {
+		if patterns.ShouldDelete(tags[digest]) {
+			images["tagDelete"] = append(images["tagDelete"], digest)
+		} else {
+			images["tagged"] = append(images["tagged"], digest)
+		}
+	}
+	// --- a digest is only an orphan when none of its entries, on any page, carries a tag ---
+	for _, digest := range untaggedDigests {
+		if _, tagged := tags[digest]; !tagged {
+			images["orphan"] = append(images["orphan"], digest)
+		}
+	}
+	return images, nil
+}
+
+// --- MaxManifestSize is the largest image manifest ECR accepts, 4 MiB ---
+const MaxManifestSize = 4 * 1024 * 1024
+
+// --- checks an image manifest is no larger than ECR allows, is a JSON object and has manifests, layers or config ---
+func ValidateManifest(manifestJSON string) error {
+	_, err := parseManifest(manifestJSON)
+	return err
+}
+
+// --- parses a validated image manifest ---
+func parseManifest(manifestJSON string) (map[string]interface{}, error) {
+	if len(manifestJSON) > MaxManifestSize {
+		return nil, fmt.Errorf("manifest is %d bytes, larger than the %d bytes ECR allows", len(manifestJSON), MaxManifestSize)
+	}
+	var manifest map[string]interface{}
+	if err := json.Unmarshal([]byte(manifestJSON), &manifest); err != nil {
+		return nil, fmt.Errorf("manifest is not valid JSON: %w", err)
+	}
+	for _, key := range []string{"manifests", "layers", "config"} {
+		if _, ok := manifest[key]; ok {
+			return manifest, nil
+		}
+	}
+	return nil, fmt.Errorf("manifest has none of manifests, layers or config")
+}
+
+// --- Platform is the os/architecture[/variant] an image index lists a child image for, e.g. linux/arm/v7 ---
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// --- reports whether a child listed for platform is selected by p, a p without variant selects every variant ---
+func (p Platform) matches(platform Platform) bool {
+	return p.OS == platform.OS && p.Architecture == platform.Architecture && (p.Variant == "" || p.Variant == platform.Variant)
+}
+
+// --- parses platforms such as linux/arm/v7 or linux/386 ---
+func ParsePlatforms(values []string) ([]Platform, error) {
+	platforms := make([]Platform, 0, len(values))
+	for _, value := range values {
+		parts := strings.Split(strings.TrimSpace(value), "/")
+		if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+			return nil, fmt.Errorf("invalid platform %q: expected os/architecture[/variant], e.g. linux/arm/v7", value)
+		}
+		platform := Platform{OS: parts[0], Architecture: parts[1]}
+		if len(parts) == 3 {
+			platform.Variant = parts[2]
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms, nil
+}
+
+// --- reads the platform of a manifests[] entry of an image index, false when the entry has none ---
+func entryPlatform(entry map[string]interface{}) (Platform, bool) {
+	raw, ok := entry["platform"].(map[string]interface{})
+	if !ok {
+		return Platform{}, false
+	}
+	platform := Platform{}
+	platform.OS, _ = raw["os"].(string)
+	platform.Architecture, _ = raw["architecture"].(string)
+	platform.Variant, _ = raw["variant"].(string)
+	return platform, true
+}
+
+// --- returns child image digests for a set of images, children listed for one of the drop platforms are returned ---
+// --- as dropped instead, so they are not protected by their index ---
+// --- a corrupt or oversized manifest whose media type is not an index has no children and is logged and skipped ---
+// --- one that is or may be an index fails the lookup, its children could otherwise be deleted as orphans ---
+func getChildImages(ctx context.Context, repository string, images []string, client ECRAPI, drop []Platform, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (children, dropped []string, err error) {
+	ctx, span := tracing.Start(ctx, "ResolveChildImages", attribute.String("ecr.repository", repository), attribute.Int("ecr.images.parents", len(images)))
+	defer func() {
+		span.SetAttributes(attribute.Int("ecr.images.children", len(children)))
+		tracing.End(span, err)
+	}()
+	imageIds := []types.ImageIdentifier{}
+	for _, digest := range images {
+		imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	}
+	input := &ecr.BatchGetImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       imageIds,
+	}
+	result, err := client.BatchGetImage(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to batch get images for repository %s: %w", repository, err)
+	}
+	for _, image := range result.Images {
+		manifest, err := parseManifest(aws.ToString(image.ImageManifest))
+		if err != nil {
+			var digest string
+			if image.ImageId != nil {
+				digest = aws.ToString(image.ImageId.ImageDigest)
+			}
+			if mayBeIndex(aws.ToString(image.ImageManifestMediaType)) {
+				return nil, nil, fmt.Errorf("corrupt manifest of %s in repository %s may list children that cannot be protected: %w", digest, repository, err)
+			}
+			logMessage := fmt.Sprintf("[WARN] Repository: %s - Skipping corrupt manifest of %s, it is not an image index so it has no children: %v", repository, digest, err)
+			mu.Lock()
+			*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+			mu.Unlock()
+			continue
+		}
+		if manifests, ok := manifest["manifests"].([]interface{}); ok {
+			for _, m := range manifests {
+				entry, _ := m.(map[string]interface{})
+				digest, ok := entry["digest"].(string)
+				if !ok {
+					continue
+				}
+				if platform, ok := entryPlatform(entry); ok && slices.ContainsFunc(drop, func(p Platform) bool { return p.matches(platform) }) {
+					dropped = append(dropped, digest)
+					continue
+				}
+				children = append(children, digest)
+			}
+		}
+	}
+	return children, dropped, nil
+}
+
+// --- media types of manifests that list child images ---
+var indexMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// --- reports whether a manifest of this media type can list children, an unknown media type may ---
+func mayBeIndex(mediaType string) bool {
+	return mediaType == "" || slices.Contains(indexMediaTypes, mediaType)
+}
+
+// --- BatchGetImage calls a repository makes at once to resolve children, fixed so that the repositories processed ---
+// --- in parallel add up to a multiple of --maxConcurrency rather than its square ---
+const childLookupConcurrency = 4
+
+// --- resolves the children of the images in batches of 100, with at most limit BatchGetImage calls in flight, 0 means unbounded ---
+// --- large tagged sets no longer wait on one batch after the other, the first failed batch fails the whole lookup ---
+func getChildImagesConcurrently(ctx context.Context, repository string, images []string, client ECRAPI, limit int, drop []Platform, logMessages *[]logbuffer.Entry, mu *sync.Mutex) ([]string, []string, error) {
+	parts := sliceutil.Partition(images, 100)
+	if len(parts) == 1 {
+		return getChildImages(ctx, repository, parts[0], client, drop, logMessages, mu)
+	}
+
+	var resultMu sync.Mutex
+	var children, dropped []string
+	var firstErr error
+	concurrency.ForEach(parts, limit, func(part []string) {
+		found, foundDropped, err := getChildImages(ctx, repository, part, client, drop, logMessages, mu)
+		resultMu.Lock()
+		defer resultMu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		children = append(children, found...)
+		dropped = append(dropped, foundDropped...)
+	})
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return children, dropped, nil
+}
+
+// --- media types accepted when fetching manifests, so ECR returns each one as stored instead of converting it ---
+var acceptedManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	OCIArtifactManifestMediaType,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v1+json",
+}
+
+// --- fetches the manifests of the given images and returns them with their media and artifact types ---
+// --- an image with a corrupt manifest is returned without types, so it is treated as a regular image ---
+func getImageMediaTypes(ctx context.Context, repository string, digests []string, client ECRAPI) ([]Image, error) {
+	var images []Image
+	for _, part := range sliceutil.Partition(digests, 100) {
+		imageIds := make([]types.ImageIdentifier, 0, len(part))
+		for _, digest := range part {
+			imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+		}
+		result, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+			RepositoryName:     aws.String(repository),
+			ImageIds:           imageIds,
+			AcceptedMediaTypes: acceptedManifestMediaTypes,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to batch get images for repository %s: %w", repository, err)
+		}
+		if result == nil {
+			continue
+		}
+		for _, image := range result.Images {
+			var img Image
+			if image.ImageId != nil {
+				img.Digest = aws.ToString(image.ImageId.ImageDigest)
+			}
+			img.MediaType = aws.ToString(image.ImageManifestMediaType)
+			var manifest struct {
+				MediaType    string `json:"mediaType"`
+				ArtifactType string `json:"artifactType"`
+				Config       struct {
+					MediaType string `json:"mediaType"`
+				} `json:"config"`
+			}
+			if err := json.Unmarshal([]byte(aws.ToString(image.ImageManifest)), &manifest); err == nil {
+				if manifest.MediaType != "" {
+					img.MediaType = manifest.MediaType
+				}
+				img.ArtifactType = manifest.ArtifactType
+				if img.ArtifactType == "" {
+					img.ArtifactType = manifest.Config.MediaType
+				}
+			}
+			images = append(images, img)
+		}
+	}
+	return images, nil
+}
+
+// --- returns the raw manifest JSON of one image and its media type, as stored, for tools analysing images on top of this package ---
+// --- the media type ECR reports wins, the manifest's own mediaType field is used when ECR reports none ---
+func GetRepositoryImageManifest(ctx context.Context, repo string, digest string, client ECRAPI) (string, string, error) {
+	result, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+		RepositoryName:     aws.String(repo),
+		ImageIds:           []types.ImageIdentifier{{ImageDigest: aws.String(digest)}},
+		AcceptedMediaTypes: acceptedManifestMediaTypes,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get manifest of %s in repository %s: %w", digest, repo, err)
+	}
+	if len(result.Failures) > 0 {
+		failure := result.Failures[0]
+		return "", "", fmt.Errorf("failed to get manifest of %s in repository %s: %s - %s", digest, repo, failure.FailureCode, aws.ToString(failure.FailureReason))
+	}
+	if len(result.Images) == 0 {
+		return "", "", fmt.Errorf("failed to get manifest of %s in repository %s: no image returned", digest, repo)
+	}
+	image := result.Images[0]
+	manifestJSON := aws.ToString(image.ImageManifest)
+	mediaType := aws.ToString(image.ImageManifestMediaType)
+	if mediaType == "" {
+		var manifest struct {
+			MediaType string `json:"mediaType"`
+		}
+		if err := json.Unmarshal([]byte(manifestJSON), &manifest); err == nil {
+			mediaType = manifest.MediaType
+		}
+	}
+	return manifestJSON, mediaType, nil
+}
+
+// --- drops OCI artifacts from the orphans, SBOMs, signatures and attestations are never deleted as orphans ---
+func filterArtifacts(ctx context.Context, repository string, orphans []string, client ECRAPI) (kept []string, artifacts int, err error) {
+	if len(orphans) == 0 {
+		return orphans, 0, nil
+	}
+	images, err := getImageMediaTypes(ctx, repository, orphans, client)
+	if err != nil {
+		return nil, 0, err
+	}
+	var protected []string
+	for _, image := range images {
+		if image.IsArtifact() {
+			protected = append(protected, image.Digest)
+		}
+	}
+	return filterOrphans(orphans, protected), len(protected), nil
+}
+
+// --- drops images pushed less than minAge ago, images without a known push date are kept ---
+// --- status limits DescribeImages to the kind of images being filtered, so tagged images are only described when needed ---
+func filterByAge(ctx context.Context, repository string, orphans []string, minAge time.Duration, status types.TagStatus, client ECRAPI) ([]string, error) {
+	if minAge <= 0 {
+		return orphans, nil
+	}
+	return filterPushedBefore(ctx, repository, orphans, time.Now().Add(-minAge), status, client)
+}
+
+// --- drops images pushed at or after cutoff, images without a known push date are kept ---
+func filterPushedBefore(ctx context.Context, repository string, orphans []string, cutoff time.Time, status types.TagStatus, client ECRAPI) ([]string, error) {
+	return filterPushed(ctx, repository, orphans, status, client, func(pushedAt time.Time) bool { return pushedAt.Before(cutoff) })
+}
+
+// --- drops images pushed before since, images without a known push date are kept ---
+func filterPushedSince(ctx context.Context, repository string, orphans []string, since time.Time, status types.TagStatus, client ECRAPI) ([]string, error) {
+	return filterPushed(ctx, repository, orphans, status, client, func(pushedAt time.Time) bool { return !pushedAt.Before(since) })
+}
+
+// --- returns the candidates whose push date satisfies keep, candidates without a known push date are left out so they are never deleted ---
+func filterPushed(ctx context.Context, repository string, orphans []string, status types.TagStatus, client ECRAPI, keep func(pushedAt time.Time) bool) ([]string, error) {
+	if len(orphans) == 0 {
+		return orphans, nil
+	}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		Filter:         &types.DescribeImagesFilter{TagStatus: status},
+	}).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matching := make(map[string]struct{}, len(orphans))
+	for _, detail := range details {
+		if detail.ImagePushedAt != nil && keep(*detail.ImagePushedAt) {
+			matching[aws.ToString(detail.ImageDigest)] = struct{}{}
+		}
+	}
+	result := make([]string, 0, len(orphans))
+	for _, orphan := range orphans {
+		if _, ok := matching[orphan]; ok {
+			result = append(result, orphan)
+		}
+	}
+	return result, nil
+}
+
+// --- returns when the image carrying tag was pushed, false when no image in the repository has the tag ---
+func tagPushedAt(ctx context.Context, repository, tag string, client ECRAPI) (time.Time, bool, error) {
+	resp, err := client.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       []types.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	var notFound *types.ImageNotFoundException
+	if errors.As(err, &notFound) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to describe image tagged %s in repository %s: %w", tag, repository, err)
+	}
+	for _, detail := range resp.ImageDetails {
+		if detail.ImagePushedAt != nil && slices.Contains(detail.ImageTags, tag) {
+			return *detail.ImagePushedAt, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// --- above this many children the sorted variant is used ---
+// --- the map variant is faster, but its hash table grows in many steps and shows up as GC pressure ---
+// --- on repositories with tens of thousands of images, with 50k orphans and 50k children ---
+// --- (BenchmarkFilterOrphans) the sorted variant is ~40% slower but uses ~40% less memory in 2 allocations instead of ~130 ---
+const sortedFilterThreshold = 10000
+
+// --- filter orphans not referenced by children ---
+func filterOrphans(orphans, children []string) []string {
+	if len(children) > sortedFilterThreshold {
+		return filterOrphansSorted(orphans, children)
+	}
+	return filterOrphansMap(orphans, children)
+}
+
+// --- O(n+m) time, allocates a set holding every child ---
+func filterOrphansMap(orphans, children []string) []string {
+	result := make([]string, 0, len(orphans))
+	childSet := make(map[string]struct{}, len(children))
+	for _, c := range children {
+		childSet[c] = struct{}{}
+	}
+	for _, orphan := range orphans {
+		if _, found := childSet[orphan]; !found {
+			result = append(result, orphan)
+		}
+	}
+	return result
+}
+
+// --- O((n+m) log m) time, only allocates a sorted copy of the children slice headers ---
+func filterOrphansSorted(orphans, children []string) []string {
+	result := make([]string, 0, len(orphans))
+	sorted := make([]string, len(children))
+	copy(sorted, children)
+	sort.Strings(sorted)
+	for _, orphan := range orphans {
+		i := sort.SearchStrings(sorted, orphan)
+		if i == len(sorted) || sorted[i] != orphan {
+			result = append(result, orphan)
+		}
+	}
+	return result
+}
+
+// --- keeps the tagDelete images missing from remaining as tagged images and returns how many were kept ---
+func keepTagDelete(images map[string][]string, remaining []string) int {
+	kept := filterOrphans(images["tagDelete"], remaining)
+	images["tagged"] = append(images["tagged"], kept...)
+	images["tagDelete"] = remaining
+	return len(kept)
+}
+
+// --- returns images to delete along with the tagged and untagged image counts ---
+// --- tagged images matching the delete patterns come first, so an index is deleted before the children it no longer protects ---
+func imagesToDeleteWithLogging(ctx context.Context, repository string, client ECRAPI, opts CleanOptions, logMessages *[]logbuffer.Entry, mu *sync.Mutex) ([]string, int, int, error) {
+	minAge := opts.MinAgeFor(repository)
+	images, err := getImages(ctx, repository, client, opts.TagPatterns, opts.ListTagStatus)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to get images for repository %s: %w", repository, err)
+	}
+	tagged, untagged := len(images["tagged"])+len(images["tagDelete"]), len(images["orphan"])
+	logMessage := fmt.Sprintf("[INFO] Repository: %s - Found %d tagged and %d untagged images", repository, tagged, untagged)
+	mu.Lock()
+	*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+	mu.Unlock()
+	if len(images["tagDelete"]) > 0 {
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Found %d tagged images matching the delete tag patterns", repository, len(images["tagDelete"]))
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if opts.PrefixRetention.KeepNewest > 0 {
+		expired, err := expiredByPrefix(ctx, repository, images["tagged"], opts.PrefixRetention, opts.TagPatterns.Keep, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		images["tagged"] = filterOrphans(images["tagged"], expired)
+		images["tagDelete"] = append(images["tagDelete"], expired...)
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Found %d tagged images older than the newest %d of their tag prefix group", repository, len(expired), opts.PrefixRetention.KeepNewest)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if opts.NotPulled.Enabled() && opts.NotPulled.IncludeTagged {
+		stale, err := filterNotPulled(ctx, repository, images["tagged"], types.TagStatusTagged, opts.NotPulled, opts.TagPatterns.Keep, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		images["tagged"] = filterOrphans(images["tagged"], stale)
+		images["tagDelete"] = append(images["tagDelete"], stale...)
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Found %d tagged images not pulled in %s", repository, len(stale), opts.NotPulled.Since)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if len(opts.ProtectedDigests) > 0 {
+		var protected []string
+		for _, key := range []string{"orphan", "tagDelete"} {
+			for _, digest := range images[key] {
+				if _, ok := opts.ProtectedDigests[digest]; ok {
+					protected = append(protected, digest)
+				}
+			}
+		}
+		if len(protected) > 0 {
+			images["orphan"] = filterOrphans(images["orphan"], protected)
+			images["tagDelete"] = filterOrphans(images["tagDelete"], protected)
+			// --- a protected index is resolved like a tagged one, so the images it points to are kept too ---
+			images["tagged"] = append(images["tagged"], protected...)
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d protected images in use", repository, len(protected))
+			mu.Lock()
+			*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+			mu.Unlock()
+		}
+	}
+
+	// --- tagged images kept by the filters below are resolved with the other tagged images, so their children stay protected ---
+	if !opts.PushedRange.IsZero() && len(images["tagDelete"]) > 0 {
+		remaining, err := filterPushed(ctx, repository, images["tagDelete"], types.TagStatusTagged, client, opts.PushedRange.Contains)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d tagged images not pushed %s", repository, keepTagDelete(images, remaining), opts.PushedRange)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	if minAge > 0 && len(images["tagDelete"]) > 0 && len(opts.TagAgePolicies) == 0 {
+		remaining, err := filterByAge(ctx, repository, images["tagDelete"], minAge, types.TagStatusTagged, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d tagged images younger than %s", repository, keepTagDelete(images, remaining), minAge)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	// --- a tag age policy replaces the minimum age for the tagged images it matches, the others keep minAge ---
+	if len(images["tagDelete"]) > 0 && len(opts.TagAgePolicies) > 0 {
+		remaining, err := filterByTagAge(ctx, repository, images["tagDelete"], opts.TagAgePolicies, minAge, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d tagged images younger than the minimum age of their tags", repository, keepTagDelete(images, remaining))
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if len(images["tagged"]) > 0 {
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Finding children of the tagged images", repository)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+
+		children, dropped, err := getChildImagesConcurrently(ctx, repository, images["tagged"], client, childLookupConcurrency, opts.DropPlatforms, logMessages, mu)
+		if err != nil {
+			return nil, tagged, untagged, fmt.Errorf("failed to get child images for repository %s: %w", repository, err)
+		}
+		// --- a child another kept index lists for a platform that is not dropped stays protected ---
+		if dropped = filterOrphans(dropped, children); len(dropped) > 0 {
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - %d children of kept indexes are for a dropped platform and no longer protected by them", repository, len(dropped))
+			mu.Lock()
+			*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+			mu.Unlock()
+		}
+		images["orphan"] = filterOrphans(images["orphan"], children)
+		// --- a kept index protects its children even when they carry a delete tag ---
+		images["tagDelete"] = filterOrphans(images["tagDelete"], children)
+	}
+
+	if since, ok := opts.PushedSince[repository]; ok {
+		candidates := len(images["orphan"])
+		images["orphan"], err = filterPushedSince(ctx, repository, images["orphan"], since, types.TagStatusUntagged, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Incremental run, skipping %d untagged images pushed before %s", repository, candidates-len(images["orphan"]), since.UTC().Format(time.RFC3339))
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if !opts.PushedRange.IsZero() && len(images["orphan"]) > 0 {
+		candidates := len(images["orphan"])
+		images["orphan"], err = filterPushed(ctx, repository, images["orphan"], types.TagStatusUntagged, client, opts.PushedRange.Contains)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d untagged images not pushed %s", repository, candidates-len(images["orphan"]), opts.PushedRange)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if opts.NotPulled.Enabled() && len(images["orphan"]) > 0 {
+		candidates := len(images["orphan"])
+		images["orphan"], err = filterNotPulled(ctx, repository, images["orphan"], types.TagStatusUntagged, opts.NotPulled, nil, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d untagged images pulled in the last %s", repository, candidates-len(images["orphan"]), opts.NotPulled.Since)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if opts.OlderThanTag != "" {
+		pushedAt, found, err := tagPushedAt(ctx, repository, opts.OlderThanTag, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		if found {
+			candidates := len(images["orphan"])
+			images["orphan"], err = filterPushedBefore(ctx, repository, images["orphan"], pushedAt, types.TagStatusUntagged, client)
+			if err != nil {
+				return nil, tagged, untagged, err
+			}
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d untagged images pushed after the image tagged %s", repository, candidates-len(images["orphan"]), opts.OlderThanTag)
+		} else {
+			images["orphan"] = nil
+			logMessage = fmt.Sprintf("[WARN] Repository: %s - No image tagged %s, keeping all untagged images", repository, opts.OlderThanTag)
+		}
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	var artifacts int
+	images["orphan"], artifacts, err = filterArtifacts(ctx, repository, images["orphan"], client)
+	if err != nil {
+		return nil, tagged, untagged, err
+	}
+	if artifacts > 0 {
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d untagged OCI artifacts (SBOMs, signatures, attestations)", repository, artifacts)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+
+	if minAge > 0 {
+		candidates := len(images["orphan"])
+		images["orphan"], err = filterByAge(ctx, repository, images["orphan"], minAge, types.TagStatusUntagged, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Keeping %d untagged images younger than %s", repository, candidates-len(images["orphan"]), minAge)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	if opts.ReclaimTarget != nil && len(images["orphan"]) > 0 {
+		candidates := len(images["orphan"])
+		var reclaimed int64
+		images["orphan"], reclaimed, err = filterToReclaimTarget(ctx, repository, images["orphan"], opts.ReclaimTarget, client)
+		if err != nil {
+			return nil, tagged, untagged, err
+		}
+		logMessage = fmt.Sprintf("[INFO] Repository: %s - Deleting the oldest %d untagged images to reclaim %s, keeping %d beyond the %s target", repository, len(images["orphan"]), format.HumanBytes(reclaimed), candidates-len(images["orphan"]), format.HumanBytes(opts.ReclaimTarget.Bytes))
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	return append(images["tagDelete"], images["orphan"]...), tagged, untagged, nil
+}
+
+// --- deletes images from a repository, returns (deleted, failures, error) ---
+// --- once stop is closed no further batch is sent and interrupted is set, the batch in flight finishes ---
+func deleteImagesWithLogging(ctx context.Context, repository string, images []string, client ECRAPI, dryRun bool, stop <-chan struct{}, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (deleted int, failures []FailedDeletion, interrupted bool, err error) {
+	ctx, span := tracing.Start(ctx, "DeleteImages", attribute.String("ecr.repository", repository), attribute.Int("ecr.images.requested", len(images)), attribute.Bool("dry_run", dryRun))
+	defer func() {
+		span.SetAttributes(attribute.Int("ecr.images.deleted", deleted), attribute.Int("ecr.images.failed", len(failures)))
+		tracing.End(span, err)
+	}()
+	if dryRun {
+		logMessage := fmt.Sprintf("[DRY RUN] Would delete %d images from repository: %s", len(images), repository)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+		return 0, nil, false, nil
+	}
+	for _, part := range sliceutil.Partition(images, 100) {
+		if stopRequested(stop) {
+			logMessage := fmt.Sprintf("[WARN] Repository: %s - Stopped after deleting %d of %d images", repository, deleted+len(failures), len(images))
+			mu.Lock()
+			*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+			mu.Unlock()
+			return deleted, failures, true, nil
+		}
+		partDeleted, partFailures, err := deleteBatch(ctx, repository, part, client, logMessages, mu)
+		deleted += partDeleted
+		failures = append(failures, partFailures...)
+		if err != nil {
+			return deleted, failures, false, err
+		}
+	}
+	logMessage := fmt.Sprintf("[INFO] Repository: %s - Deleted %d images, failed to delete %d images", repository, deleted, len(failures))
+	mu.Lock()
+	*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+	mu.Unlock()
+	return deleted, failures, false, nil
+}
+
+// --- reports whether stop has been closed, a nil channel never is ---
+func stopRequested(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// --- deletes up to 100 images with a single BatchDeleteImage call, returns (deleted, failures, error) ---
+func deleteBatch(ctx context.Context, repository string, digests []string, client ECRAPI, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (int, []FailedDeletion, error) {
+	imageIds := make([]types.ImageIdentifier, 0, len(digests))
+	for _, digest := range digests {
+		imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	}
+	input := &ecr.BatchDeleteImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       imageIds,
+	}
+	logMessage := fmt.Sprintf("[INFO] Repository: %s - Deleting %d images", repository, len(digests))
+	mu.Lock()
+	*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+	mu.Unlock()
+	result, err := client.BatchDeleteImage(ctx, input)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to batch delete images for repository %s: %w", repository, err)
+	}
+	var failures []FailedDeletion
+	for _, failure := range result.Failures {
+		var digest string
+		if failure.ImageId != nil {
+			digest = aws.ToString(failure.ImageId.ImageDigest)
+		}
+		failures = append(failures, FailedDeletion{
+			Repository:    repository,
+			Digest:        digest,
+			FailureCode:   string(failure.FailureCode),
+			FailureReason: aws.ToString(failure.FailureReason),
+			Timestamp:     time.Now().UTC(),
+		})
+		logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to delete %s: %s - %s", repository, digest, string(failure.FailureCode), aws.ToString(failure.FailureReason))
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	return len(result.ImageIds), failures, nil
+}
+
+// --- MultiError collects independent per-repository failures so one failure does not hide the others ---
+type MultiError []error
+
+func (m MultiError) Error() string {
+	messages := make([]string, 0, len(m))
+	for _, err := range m {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// --- lets errors.Is and errors.As look at every collected error ---
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// --- returns the lifecycle policy text of a repository, empty when it has none ---
+func GetPolicyForRepository(ctx context.Context, client ECRAPI, repository string) (string, error) {
+	resp, err := client.GetLifecyclePolicy(ctx, &ecr.GetLifecyclePolicyInput{RepositoryName: aws.String(repository)})
+	if err != nil {
+		var notFound *types.LifecyclePolicyNotFoundException
+		if errors.As(err, &notFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get lifecycle policy for repository %s: %w", repository, err)
+	}
+	return aws.ToString(resp.LifecyclePolicyText), nil
+}
+
+// --- fetches the lifecycle policies of all repositories with at most limit requests in flight ---
+// --- repositories without a policy map to an empty string, failed ones are left out and reported in the MultiError ---
+func ConcurrentGetPolicies(ctx context.Context, client ECRAPI, repositories []string, limit int) (map[string]string, MultiError) {
+	var mu sync.Mutex
+	var errs MultiError
+	policies := make(map[string]string, len(repositories))
+
+	concurrency.ForEach(repositories, limit, func(repo string) {
+		policy, err := GetPolicyForRepository(ctx, client, repo)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		policies[repo] = policy
+	})
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return policies, errs
+}
+
+// --- reports whether the repository's lifecycle policy already expires untagged images ---
+func policyExpiresUntagged(ctx context.Context, client ECRAPI, repository string) (bool, error) {
+	policyText, err := GetPolicyForRepository(ctx, client, repository)
+	if err != nil {
+		return false, err
+	}
+	return policyTextExpiresUntagged(repository, policyText)
+}
+
+// --- reports whether the policy text expires untagged images, an empty policy does not ---
+func policyTextExpiresUntagged(repository, policyText string) (bool, error) {
+	if policyText == "" {
+		return false, nil
+	}
+	policy, err := readpolicyfile.ParsePolicy(policyText)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse lifecycle policy for repository %s: %w", repository, err)
+	}
+	return policy.ExpiresUntagged(), nil
+}
+
+// --- UnmanagedOptions sets when a repository without an untagged expiry counts as accumulating cost ---
+type UnmanagedOptions struct {
+	// --- repositories holding this many images or fewer are ignored ---
+	MinImages int
+	// --- repositories whose oldest untagged image is newer than this were cleaned recently, 0 ignores the age ---
+	ThresholdDays int
+	Concurrency   int
+	// --- location the oldest push dates are logged in, UTC when nil ---
+	Location *time.Location
+	// --- account and region the client operates on, added to per-repository errors and the report ---
+	Registry awsregistry.Registry
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- UnmanagedRepository is a repository accumulating images with nothing expiring them ---
+type UnmanagedRepository struct {
+	Repository     string    `json:"repository"`
+	Images         int       `json:"images"`
+	Untagged       int       `json:"untagged"`
+	OldestUntagged time.Time `json:"oldestUntagged,omitempty"`
+	// --- the repository has a lifecycle policy, but none of its rules expire untagged images ---
+	HasPolicy bool `json:"hasPolicy"`
+}
+
+// --- UnmanagedReport lists the repositories likely accumulating cost ---
+type UnmanagedReport struct {
+	Checked       int                   `json:"checked"`
+	MinImages     int                   `json:"minImages"`
+	ThresholdDays int                   `json:"thresholdDays"`
+	Repositories  []UnmanagedRepository `json:"repositories"`
+	Errors        []RepositoryError     `json:"errors,omitempty"`
+	Registry      awsregistry.Registry  `json:"registry"`
+}
+
+// --- RepositoryError records a repository that could not be checked ---
+type RepositoryError struct {
+	Repository string `json:"repository"`
+	Message    string `json:"error"`
+}
+
+// --- returns a copy of the report with the oldest push dates in loc, for display ---
+func (r UnmanagedReport) InLocation(loc *time.Location) interface{} {
+	repositories := make([]UnmanagedRepository, len(r.Repositories))
+	for i, repo := range r.Repositories {
+		repo.OldestUntagged = repo.OldestUntagged.In(loc)
+		repositories[i] = repo
+	}
+	r.Repositories = repositories
+	return r
+}
+
+// --- returns one record per unmanaged repository, for --jsonMode lines ---
+func (r UnmanagedReport) Records() []interface{} {
+	records := make([]interface{}, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		records = append(records, repo)
+	}
+	return records
+}
+
+// --- returns one row per unmanaged repository, for --output csv ---
+func (r UnmanagedReport) Table() ([]string, [][]string) {
+	headers := []string{"repository", "images", "untagged", "oldestUntagged", "hasPolicy"}
+	rows := make([][]string, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		oldest := ""
+		if !repo.OldestUntagged.IsZero() {
+			oldest = repo.OldestUntagged.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{repo.Repository, strconv.Itoa(repo.Images), strconv.Itoa(repo.Untagged), oldest, strconv.FormatBool(repo.HasPolicy)})
+	}
+	return headers, rows
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r UnmanagedReport) Summary() string {
+	return fmt.Sprintf("Checked %d repos, %d unmanaged with more than %d images, %d could not be checked", r.Checked, len(r.Repositories), r.MinImages, len(r.Errors))
+}
+
+// --- counts the images of a repository and finds the push date of its oldest untagged image ---
+func imageCounts(ctx context.Context, client ECRAPI, repository string) (total, untagged int, oldestUntagged time.Time, err error) {
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{RepositoryName: aws.String(repository)}).All(ctx)
+	if err != nil {
+		return 0, 0, time.Time{}, err
+	}
+	for _, detail := range details {
+		total++
+		if len(detail.ImageTags) > 0 {
+			continue
+		}
+		untagged++
+		if pushed := aws.ToTime(detail.ImagePushedAt); !pushed.IsZero() && (oldestUntagged.IsZero() || pushed.Before(oldestUntagged)) {
+			oldestUntagged = pushed
+		}
+	}
+	return total, untagged, oldestUntagged, nil
+}
+
+// --- read-only check for repositories without a policy expiring untagged images, holding more than MinImages images ---
+// --- and, with ThresholdDays, whose untagged images have been piling up for longer than that ---
+func FindUnmanaged(ctx context.Context, client ECRAPI, repositories []string, opts UnmanagedOptions) UnmanagedReport {
+	report := UnmanagedReport{Checked: len(repositories), MinImages: opts.MinImages, ThresholdDays: opts.ThresholdDays, Registry: opts.Registry}
+	cutoff := time.Now().AddDate(0, 0, -opts.ThresholdDays)
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		var logMessage string
+		var found *UnmanagedRepository
+		var checkErr error
+		policyText, err := GetPolicyForRepository(ctx, client, repo)
+		managed := false
+		if err == nil {
+			managed, err = policyTextExpiresUntagged(repo, policyText)
+		}
+		if err != nil {
+			checkErr = err
+		} else if !managed {
+			total, untagged, oldest, err := imageCounts(ctx, client, repo)
+			switch {
+			case err != nil:
+				checkErr = err
+			case total <= opts.MinImages:
+			case opts.ThresholdDays > 0 && (untagged == 0 || !oldest.Before(cutoff)):
+			default:
+				found = &UnmanagedRepository{Repository: repo, Images: total, Untagged: untagged, OldestUntagged: oldest, HasPolicy: policyText != ""}
+				logMessage = fmt.Sprintf("[WARN] Repository: %s - Holds %d images (%d untagged) and nothing expires untagged images", repo, total, untagged)
+				if !oldest.IsZero() {
+					logMessage += fmt.Sprintf(", oldest untagged image pushed %s", oldest.In(location).Format(time.DateOnly))
+				}
+			}
+		}
+		if checkErr != nil {
+			checkErr = opts.Registry.Wrap(checkErr)
+			logMessage = fmt.Sprintf("[ERROR] Repository: %s - Could not be checked: %v", repo, checkErr)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if found != nil {
+			report.Repositories = append(report.Repositories, *found)
+		}
+		if checkErr != nil {
+			report.Errors = append(report.Errors, RepositoryError{Repository: repo, Message: checkErr.Error()})
+		}
+		if logMessage != "" {
+			logMessages = append(logMessages, logbuffer.NewEntry(logMessage))
+		}
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	// --- largest first, they are the likeliest cost drivers ---
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		a, b := report.Repositories[i], report.Repositories[j]
+		if a.Images != b.Images {
+			return a.Images > b.Images
+		}
+		return a.Repository < b.Repository
+	})
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Repository < report.Errors[j].Repository
+	})
+	return report
+}
+
+// --- PrefixCountOptions controls how repositories are counted by prefix ---
+type PrefixCountOptions struct {
+	Concurrency int
+	// --- account and region the client operates on, added to per-repository errors and the report ---
+	Registry awsregistry.Registry
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- PrefixCount totals the repositories and images sharing a first path segment, e.g. a team ---
+type PrefixCount struct {
+	Prefix       string `json:"prefix"`
+	Repositories int    `json:"repositories"`
+	Images       int    `json:"images"`
+	Untagged     int    `json:"untagged"`
+}
+
+// --- PrefixCountReport lists the image counts of each repository prefix, for capacity planning ---
+type PrefixCountReport struct {
+	Checked  int                  `json:"checked"`
+	Prefixes []PrefixCount        `json:"prefixes"`
+	Errors   []RepositoryError    `json:"errors,omitempty"`
+	Registry awsregistry.Registry `json:"registry"`
+}
+
+// --- returns one record per prefix, for --jsonMode lines ---
+func (r PrefixCountReport) Records() []interface{} {
+	records := make([]interface{}, 0, len(r.Prefixes))
+	for _, prefix := range r.Prefixes {
+		records = append(records, prefix)
+	}
+	return records
+}
+
+// --- returns one row per prefix, for --output csv ---
+func (r PrefixCountReport) Table() ([]string, [][]string) {
+	headers := []string{"prefix", "repositories", "images", "untagged"}
+	rows := make([][]string, 0, len(r.Prefixes))
+	for _, prefix := range r.Prefixes {
+		rows = append(rows, []string{prefix.Prefix, strconv.Itoa(prefix.Repositories), strconv.Itoa(prefix.Images), strconv.Itoa(prefix.Untagged)})
+	}
+	return headers, rows
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r PrefixCountReport) Summary() string {
+	images := 0
+	for _, prefix := range r.Prefixes {
+		images += prefix.Images
+	}
+	return fmt.Sprintf("Counted %d images in %d repos under %d prefixes, %d repos could not be counted", images, r.Checked-len(r.Errors), len(r.Prefixes), len(r.Errors))
+}
+
+// --- returns the first path segment of a repository name, the whole name when it has no / ---
+func RepositoryPrefix(repository string) string {
+	prefix, _, _ := strings.Cut(repository, "/")
+	return prefix
+}
+
+// --- read-only count of the images of every repository, grouped by RepositoryPrefix ---
+func CountByPrefix(ctx context.Context, client ECRAPI, repositories []string, opts PrefixCountOptions) PrefixCountReport {
+	report := PrefixCountReport{Checked: len(repositories), Registry: opts.Registry}
+	counts := map[string]*PrefixCount{}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		total, untagged, _, err := imageCounts(ctx, client, repo)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			err = opts.Registry.Wrap(err)
+			report.Errors = append(report.Errors, RepositoryError{Repository: repo, Message: err.Error()})
+			logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[ERROR] Repository: %s - Could not be counted: %v", repo, err)))
+			return
+		}
+		prefix := RepositoryPrefix(repo)
+		count, ok := counts[prefix]
+		if !ok {
+			count = &PrefixCount{Prefix: prefix}
+			counts[prefix] = count
+		}
+		count.Repositories++
+		count.Images += total
+		count.Untagged += untagged
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	report.Prefixes = make([]PrefixCount, 0, len(counts))
+	for _, count := range counts {
+		report.Prefixes = append(report.Prefixes, *count)
+	}
+	// --- biggest consumers first ---
+	sort.Slice(report.Prefixes, func(i, j int) bool {
+		a, b := report.Prefixes[i], report.Prefixes[j]
+		if a.Images != b.Images {
+			return a.Images > b.Images
+		}
+		return a.Prefix < b.Prefix
+	})
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Repository < report.Errors[j].Repository
+	})
+	return report
+}
+
+// --- OrphanClass is the likely reason an untagged image exists ---
+type OrphanClass string
+
+const (
+	// --- pushed without a tag, e.g. a build cache or a push by digest ---
+	OrphanNeverTagged OrphanClass = "never-tagged"
+	// --- was in use under a tag that has since been pushed to a newer image ---
+	OrphanTagMoved OrphanClass = "tag-moved"
+	// --- a platform image referenced by an untagged multi-arch index ---
+	OrphanMultiArchChild OrphanClass = "multi-arch-child"
+)
+
+// --- returns the class of each orphan, keyed by digest, children are the digests referenced by the orphans' image indexes ---
+// --- ECR keeps no tag history, so an orphan that was ever pulled is taken to have lost its tag and one never pulled to never have had one ---
+func ClassifyOrphans(ctx context.Context, repo string, orphans []Image, children []string, client ECRAPI) (map[string]OrphanClass, error) {
+	classes := make(map[string]OrphanClass, len(orphans))
+	if len(orphans) == 0 {
+		return classes, nil
+	}
+	childSet := make(map[string]struct{}, len(children))
+	for _, c := range children {
+		childSet[c] = struct{}{}
+	}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repo),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusUntagged},
+	}).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pulled := map[string]bool{}
+	for _, detail := range details {
+		pulled[aws.ToString(detail.ImageDigest)] = detail.LastRecordedPullTime != nil
+	}
+	for _, orphan := range orphans {
+		switch _, child := childSet[orphan.Digest]; {
+		case child:
+			classes[orphan.Digest] = OrphanMultiArchChild
+		case pulled[orphan.Digest]:
+			classes[orphan.Digest] = OrphanTagMoved
+		default:
+			classes[orphan.Digest] = OrphanNeverTagged
+		}
+	}
+	return classes, nil
+}
+
+// --- InspectOptions controls how repositories are inspected ---
+type InspectOptions struct {
+	Concurrency int
+	// --- account and region the client operates on, added to per-repository errors and the report ---
+	Registry awsregistry.Registry
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- InspectedRepository counts the orphans of a repository by class ---
+type InspectedRepository struct {
+	Repository     string                 `json:"repository"`
+	Tagged         int                    `json:"tagged"`
+	NeverTagged    int                    `json:"neverTagged"`
+	TagMoved       int                    `json:"tagMoved"`
+	MultiArchChild int                    `json:"multiArchChild"`
+	Orphans        map[string]OrphanClass `json:"orphans,omitempty"`
+}
+
+// --- returns the number of orphans of the repository ---
+func (r InspectedRepository) TotalOrphans() int {
+	return r.NeverTagged + r.TagMoved + r.MultiArchChild
+}
+
+// --- InspectReport lists the orphans of each inspected repository and why they are orphaned ---
+type InspectReport struct {
+	Checked      int                   `json:"checked"`
+	Repositories []InspectedRepository `json:"repositories"`
+	Errors       []RepositoryError     `json:"errors,omitempty"`
+	Registry     awsregistry.Registry  `json:"registry"`
+}
+
+// --- returns one record per inspected repository, for --jsonMode lines ---
+func (r InspectReport) Records() []interface{} {
+	records := make([]interface{}, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		records = append(records, repo)
+	}
+	return records
+}
+
+// --- returns one row per orphan, for --output csv ---
+func (r InspectReport) Table() ([]string, [][]string) {
+	headers := []string{"repository", "digest", "class"}
+	var rows [][]string
+	for _, repo := range r.Repositories {
+		digests := make([]string, 0, len(repo.Orphans))
+		for digest := range repo.Orphans {
+			digests = append(digests, digest)
+		}
+		sort.Strings(digests)
+		for _, digest := range digests {
+			rows = append(rows, []string{repo.Repository, digest, string(repo.Orphans[digest])})
+		}
+	}
+	return headers, rows
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r InspectReport) Summary() string {
+	var neverTagged, tagMoved, multiArchChild int
+	for _, repo := range r.Repositories {
+		neverTagged += repo.NeverTagged
+		tagMoved += repo.TagMoved
+		multiArchChild += repo.MultiArchChild
+	}
+	return fmt.Sprintf("Inspected %d repos, %d never-tagged, %d tag-moved and %d multi-arch-child orphans, %d could not be inspected", r.Checked, neverTagged, tagMoved, multiArchChild, len(r.Errors))
+}
+
+// --- finds the orphans of a repository, the untagged images no tagged image references, and classifies them ---
+func inspectRepository(ctx context.Context, client ECRAPI, repo string, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (InspectedRepository, error) {
+	inspected := InspectedRepository{Repository: repo}
+	images, err := getImages(ctx, repo, client, TagPatterns{}, TagStatusAny)
+	if err != nil {
+		return inspected, err
+	}
+	tagged := images["tagged"]
+	inspected.Tagged = len(tagged)
+	taggedChildren, _, err := getChildImagesConcurrently(ctx, repo, tagged, client, childLookupConcurrency, nil, logMessages, mu)
+	if err != nil {
+		return inspected, err
+	}
+	orphanDigests := filterOrphans(images["orphan"], taggedChildren)
+	orphanChildren, _, err := getChildImagesConcurrently(ctx, repo, orphanDigests, client, childLookupConcurrency, nil, logMessages, mu)
+	if err != nil {
+		return inspected, err
+	}
+	orphans := make([]Image, len(orphanDigests))
+	for i, digest := range orphanDigests {
+		orphans[i] = Image{Digest: digest}
+	}
+	classes, err := ClassifyOrphans(ctx, repo, orphans, orphanChildren, client)
+	if err != nil {
+		return inspected, err
+	}
+	for _, class := range classes {
+		switch class {
+		case OrphanNeverTagged:
+			inspected.NeverTagged++
+		case OrphanTagMoved:
+			inspected.TagMoved++
+		case OrphanMultiArchChild:
+			inspected.MultiArchChild++
+		}
+	}
+	if len(classes) > 0 {
+		inspected.Orphans = classes
+	}
+	return inspected, nil
+}
+
+// --- read-only inspection of the orphans of each repository, classified by the likely reason they exist ---
+func InspectOrphans(ctx context.Context, client ECRAPI, repositories []string, opts InspectOptions) InspectReport {
+	report := InspectReport{Checked: len(repositories), Registry: opts.Registry}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		inspected, err := inspectRepository(ctx, client, repo, &logMessages, &mu)
+		var logMessage string
+		if err != nil {
+			err = opts.Registry.Wrap(err)
+			logMessage = fmt.Sprintf("[ERROR] Repository: %s - Could not be inspected: %v", repo, err)
+		} else {
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - %d orphans: %d never-tagged, %d tag-moved, %d multi-arch-child", repo, inspected.TotalOrphans(), inspected.NeverTagged, inspected.TagMoved, inspected.MultiArchChild)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			report.Errors = append(report.Errors, RepositoryError{Repository: repo, Message: err.Error()})
+		} else {
+			report.Repositories = append(report.Repositories, inspected)
+		}
+		logMessages = append(logMessages, logbuffer.NewEntry(logMessage))
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	// --- most orphans first ---
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		a, b := report.Repositories[i], report.Repositories[j]
+		if a.TotalOrphans() != b.TotalOrphans() {
+			return a.TotalOrphans() > b.TotalOrphans()
+		}
+		return a.Repository < b.Repository
+	})
+	sort.Slice(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Repository < report.Errors[j].Repository
+	})
+	return report
+}
+
+// --- RepositoryPlan is what the planning phase found for a single repository ---
+type RepositoryPlan struct {
+	Repository     string   `json:"repository"`
+	Tagged         int      `json:"tagged"`
+	Untagged       int      `json:"untagged"`
+	Images         []string `json:"images"`
+	PolicyManaged  bool     `json:"policyManaged,omitempty"`
+	Skipped        bool     `json:"skipped,omitempty"`
+	ImageCountWarn bool     `json:"imageCountWarn,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	// --- the error is a call that ran past its own timeout, not a call that failed ---
+	TimedOut bool `json:"timedOut,omitempty"`
+	// --- image API calls made while planning ---
+	APICalls PaginationStats `json:"apiCalls"`
+}
+
+// --- CleanPlan lists every deletion a run would make, computed before anything is deleted ---
+type CleanPlan struct {
+	Repositories []RepositoryPlan     `json:"repositories"`
+	DryRun       bool                 `json:"dryRun"`
+	Registry     awsregistry.Registry `json:"registry"`
+}
+
+// --- returns the total number of images the plan would delete ---
+func (p CleanPlan) TotalImages() int {
+	total := 0
+	for _, repo := range p.Repositories {
+		total += len(repo.Images)
+	}
+	return total
+}
+
+// --- StepDecision is the operator's answer when confirming a repository in step mode ---
+type StepDecision int
+
+const (
+	StepProceed StepDecision = iota
+	StepSkip
+	StepAbort
+)
+
+// --- read-only phase for a single repository, finds the images that would be deleted ---
+func planRepository(ctx context.Context, client ECRAPI, repo string, opts CleanOptions, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (plan RepositoryPlan) {
+	plan = RepositoryPlan{Repository: repo}
+	counting := newCountingClient(client)
+	client = counting
+	defer func() { plan.APICalls = counting.Stats() }()
+
+	opts, plan.Skipped = applyRepoTags(ctx, client, repo, opts, func(format string, args ...interface{}) {
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(fmt.Sprintf(format, args...)))
+		mu.Unlock()
+	})
+	if plan.Skipped {
+		return plan
+	}
+
+	// --- preflight, a failed policy check only warns so missing permissions never block cleanup ---
+	managed, err := policyExpiresUntagged(ctx, client, repo)
+	if err != nil {
+		logMessage := fmt.Sprintf("[WARN] Repository: %s - Could not check lifecycle policy: %v", repo, err)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	} else if managed {
+		plan.PolicyManaged = true
+		logMessage := fmt.Sprintf("[WARN] Repository %s already expires untagged images via lifecycle policy; cleanup may be unnecessary.", repo)
+		if opts.SkipPolicyManaged {
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - Skipping, lifecycle policy already expires untagged images", repo)
+			plan.Skipped = true
+		}
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+		if plan.Skipped {
+			return plan
+		}
+	}
+
+	logMessage := fmt.Sprintf("[INFO] Checking repository: %s", repo)
+	mu.Lock()
+	*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+	mu.Unlock()
+
+	images, tagged, untagged, err := imagesToDeleteWithLogging(ctx, repo, client, opts, logMessages, mu)
+	err = opts.Registry.Wrap(err)
+	plan.Tagged, plan.Untagged = tagged, untagged
+	if opts.WarnAboveCount > 0 && tagged+untagged > opts.WarnAboveCount {
+		plan.ImageCountWarn = true
+		logMessage = fmt.Sprintf("[WARN] Repository: %s - Holds %d images, above the watermark of %d, check its lifecycle policy", repo, tagged+untagged, opts.WarnAboveCount)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+	}
+	if err != nil {
+		logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to get images to delete: %v", repo, err)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+		plan.Error = err.Error()
+		plan.TimedOut = apitimeout.IsCallTimeout(err)
+		return plan
+	}
+	plan.Images = images
+	return plan
+}
+
+// --- sums the sizes DescribeImages reports for the images the plan would delete, per repository ---
+// --- layers shared with images that are kept are counted too, so the sizes are an upper bound on the space freed ---
+// --- repositories with nothing to delete are left out, failed ones are left out and reported in the MultiError ---
+func ReclaimableSizes(ctx context.Context, client ECRAPI, plan CleanPlan, limit int) (map[string]int64, MultiError) {
+	var mu sync.Mutex
+	var errs MultiError
+	sizes := make(map[string]int64, len(plan.Repositories))
+
+	concurrency.ForEach(plan.Repositories, limit, func(entry RepositoryPlan) {
+		if len(entry.Images) == 0 {
+			return
+		}
+		details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+			RepositoryName: aws.String(entry.Repository),
+		}).All(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		planned := make(map[string]struct{}, len(entry.Images))
+		for _, digest := range entry.Images {
+			planned[digest] = struct{}{}
+		}
+		var size int64
+		for _, detail := range details {
+			if _, ok := planned[aws.ToString(detail.ImageDigest)]; ok {
+				size += aws.ToInt64(detail.ImageSizeInBytes)
+			}
+		}
+		sizes[entry.Repository] = size
+	})
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return sizes, errs
+}
+
+// --- estimates the storage a run freed from the sizes ReclaimableSizes measured for its plan ---
+// --- a repository that deleted part of its planned images counts the same part of its size, a dry run counts what it would free ---
+func (r CleanReport) ReclaimedBytes(plan CleanPlan, sizes map[string]int64) int64 {
+	planned := make(map[string]int, len(plan.Repositories))
+	for _, entry := range plan.Repositories {
+		planned[entry.Repository] = len(entry.Images)
+	}
+	var total int64
+	for _, repo := range r.Repositories {
+		size, count := sizes[repo.Repository], planned[repo.Repository]
+		if size == 0 || count == 0 || repo.Error != "" || repo.Skipped {
+			continue
+		}
+		deleted := repo.Deleted
+		if r.DryRun {
+			deleted = count
+		}
+		total += size * int64(min(deleted, count)) / int64(count)
+	}
+	return total
+}
+
+// --- builds the cleanup plan for all repositories without deleting anything ---
+func PlanCleanup(ctx context.Context, client ECRAPI, repositories []string, opts CleanOptions) CleanPlan {
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	plan := CleanPlan{DryRun: opts.DryRun, Registry: opts.Registry}
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		entry := planRepository(ctx, client, repo, opts, &logMessages, &mu)
+		mu.Lock()
+		plan.Repositories = append(plan.Repositories, entry)
+		mu.Unlock()
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(plan.Repositories, func(i, j int) bool {
+		return plan.Repositories[i].Repository < plan.Repositories[j].Repository
+	})
+	return plan
+}
+
+// --- builds a plan that deletes every image of each repository, tagged and untagged, without deleting anything yet ---
+// --- tagged images come first so image indexes are deleted before the untagged children they reference ---
+func PlanEmpty(ctx context.Context, client ECRAPI, repositories []string, opts CleanOptions) CleanPlan {
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	plan := CleanPlan{DryRun: opts.DryRun, Registry: opts.Registry}
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		entry := RepositoryPlan{Repository: repo}
+		counting := newCountingClient(client)
+		images, err := listImages(ctx, repo, counting)
+		entry.APICalls = counting.Stats()
+		var logMessage string
+		if err != nil {
+			err = opts.Registry.Wrap(err)
+			entry.Error = err.Error()
+			entry.TimedOut = apitimeout.IsCallTimeout(err)
+			logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to list images: %v", repo, err)
+		} else {
+			// --- an image with several tags is listed once per tag ---
+			tagged := sliceutil.Unique(images["tagged"])
+			entry.Tagged, entry.Untagged = len(tagged), len(images["orphan"])
+			entry.Images = append(tagged, images["orphan"]...)
+			logMessage = fmt.Sprintf("[INFO] Repository: %s - Found %d tagged and %d untagged images to delete", repo, entry.Tagged, entry.Untagged)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		plan.Repositories = append(plan.Repositories, entry)
+		logMessages = append(logMessages, logbuffer.NewEntry(logMessage))
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(plan.Repositories, func(i, j int) bool {
+		return plan.Repositories[i].Repository < plan.Repositories[j].Repository
+	})
+	return plan
+}
+
+// --- write phase for a single planned repository ---
+func executeRepository(ctx context.Context, client ECRAPI, entry RepositoryPlan, dryRun bool, stop <-chan struct{}, registry awsregistry.Registry, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (RepositoryCleanResult, error) {
+	result := RepositoryCleanResult{
+		Repository:     entry.Repository,
+		Tagged:         entry.Tagged,
+		Untagged:       entry.Untagged,
+		Orphans:        len(entry.Images),
+		PolicyManaged:  entry.PolicyManaged,
+		Skipped:        entry.Skipped,
+		ImageCountWarn: entry.ImageCountWarn,
+		Error:          entry.Error,
+		TimedOut:       entry.TimedOut,
+		APICalls:       entry.APICalls,
+	}
+	if entry.Error != "" {
+		return result, fmt.Errorf("failed to plan repository %s: %s", entry.Repository, entry.Error)
+	}
+	if entry.Skipped {
+		return result, nil
+	}
+	if len(entry.Images) == 0 {
+		logMessage := fmt.Sprintf("[INFO] Repository: %s - Nothing to delete", entry.Repository)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+		return result, nil
+	}
+
+	var err error
+	counting := newCountingClient(client)
+	result.Deleted, result.Failures, result.Interrupted, err = deleteImagesWithLogging(ctx, entry.Repository, entry.Images, counting, dryRun, stop, logMessages, mu)
+	result.Failed = len(result.Failures)
+	result.APICalls = result.APICalls.Add(counting.Stats())
+	if err != nil {
+		err = registry.Wrap(err)
+		logMessage := fmt.Sprintf("[ERROR] Repository: %s - Failed to delete images: %v", entry.Repository, err)
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(logMessage))
+		mu.Unlock()
+		result.Error = err.Error()
+		result.TimedOut = apitimeout.IsCallTimeout(err)
+		return result, err
+	}
+	return result, nil
+}
+
+// --- deletes the images of a previously computed plan ---
+// --- with opts.Confirm set, repositories are processed one at a time and each deletion must be confirmed ---
+func ExecutePlan(ctx context.Context, client ECRAPI, plan CleanPlan, opts CleanOptions) (CleanReport, error) {
+	if opts.Confirm != nil {
+		return executeStepwise(ctx, client, plan, opts)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var logMessages []logbuffer.Entry
+	report := CleanReport{DryRun: opts.DryRun, Registry: opts.Registry}
+	start := time.Now()
+
+	concurrency.ForEach(plan.Repositories, opts.Concurrency, func(entry RepositoryPlan) {
+		result, err := executeRepository(ctx, client, entry, opts.DryRun, opts.Stop, opts.Registry, &logMessages, &mu)
+		if opts.OnRepositoryDone != nil {
+			opts.OnRepositoryDone(result)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Repositories = append(report.Repositories, result)
+		report.Interrupted = report.Interrupted || result.Interrupted
+		if err != nil {
+			errs = append(errs, err)
+		}
+	})
+	report.Duration = time.Since(start)
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+
+	if len(errs) > 0 {
+		return report, fmt.Errorf("encountered errors during cleanup: %v", errs)
+	}
+	return report, nil
+}
+
+// --- runs the cleanup process for all repositories, planning first and deleting second ---
+func CleanECRWithLogging(ctx context.Context, client ECRAPI, repositories []string, opts CleanOptions) (CleanReport, error) {
+	start := time.Now()
+	plan := PlanCleanup(ctx, client, repositories, opts)
+	report, err := ExecutePlan(ctx, client, plan, opts)
+	report.Duration = time.Since(start)
+	return report, err
 }
 
-// --- the entry point for deleting untagged images from ECR repositories ---
-// --- it fetches the list of repositories and deletes the untagged images from each ---
-func Main(client *ecr.Client, allRepos bool, repositoryList []string, repoPattern string, dryRun bool) error {
-	ctx := context.TODO()
-	if allRepos {
-		var err error
-		repositoryList, err = getRepositories(ctx, client)
-		if err != nil {
-			return err
+// --- executes the plan sequentially, asking opts.Confirm before each deletion ---
+func executeStepwise(ctx context.Context, client ECRAPI, plan CleanPlan, opts CleanOptions) (CleanReport, error) {
+	var mu sync.Mutex
+	var errs []error
+	report := CleanReport{DryRun: opts.DryRun, Registry: opts.Registry}
+	start := time.Now()
+
+	for _, entry := range plan.Repositories {
+		if stopRequested(opts.Stop) {
+			log.Printf("[WARN] Stopped before repository: %s", entry.Repository)
+			report.Interrupted = true
+			break
 		}
-	} else if len(repoPattern) > 0 {
-		var err error
-		repositoryList, err = getRepositoriesByPatterns(ctx, client, repoPattern)
+		var logMessages []logbuffer.Entry
+		if entry.Error == "" && !entry.Skipped && len(entry.Images) > 0 {
+			decision := opts.Confirm(entry.Repository, entry.Images)
+			if decision == StepAbort {
+				log.Printf("[INFO] Aborted by operator at repository: %s", entry.Repository)
+				report.Aborted = true
+				break
+			}
+			if decision == StepSkip {
+				log.Printf("[INFO] Repository: %s - Skipped by operator", entry.Repository)
+				entry.Skipped = true
+			}
+		}
+		result, err := executeRepository(ctx, client, entry, opts.DryRun, opts.Stop, opts.Registry, &logMessages, &mu)
+		for _, entry := range logMessages {
+			log.Println(entry.Message)
+		}
+		if opts.OnRepositoryDone != nil {
+			opts.OnRepositoryDone(result)
+		}
+		report.Repositories = append(report.Repositories, result)
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
+	report.Duration = time.Since(start)
 
-	if len(repositoryList) == 0 {
-		return nil
+	if len(errs) > 0 {
+		return report, fmt.Errorf("encountered errors during cleanup: %v", errs)
 	}
+	return report, nil
+}
 
-	if err := CleanECRWithLogging(ctx, client, repositoryList, dryRun); err != nil {
-		return err
+// --- number of images BatchDeleteImage accepts per call, a streamed batch is deleted as soon as it is full ---
+const deleteBatchSize = 100
+
+// --- cleans every repository without building a plan first, see streamRepository ---
+func StreamCleanup(ctx context.Context, client ECRAPI, repositories []string, opts CleanOptions) (CleanReport, error) {
+	var mu sync.Mutex
+	var errs []error
+	var logMessages []logbuffer.Entry
+	report := CleanReport{DryRun: opts.DryRun, Registry: opts.Registry}
+	start := time.Now()
+
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		result, err := streamRepository(ctx, client, repo, opts, &logMessages, &mu)
+		if opts.OnRepositoryDone != nil {
+			opts.OnRepositoryDone(result)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Repositories = append(report.Repositories, result)
+		report.Interrupted = report.Interrupted || result.Interrupted
+		if err != nil {
+			errs = append(errs, err)
+		}
+	})
+	report.Duration = time.Since(start)
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+
+	if len(errs) > 0 {
+		return report, fmt.Errorf("encountered errors during cleanup: %v", errs)
 	}
-	return nil
+	return report, nil
 }
 
-// --- returns all repository names ---
-func getRepositories(ctx context.Context, client *ecr.Client) ([]string, error) {
-	var repositories []string
-	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+// --- cleans a repository page by page so memory stays flat however many untagged images it holds ---
+// --- the tagged images are read first, only the children they reference are kept in memory, then the untagged images ---
+// --- are paged through DescribeImages and deleted in batches of 100 as soon as they are confirmed orphans ---
+// --- images moved between pages by the deletions are picked up on the next run ---
+func streamRepository(ctx context.Context, client ECRAPI, repo string, opts CleanOptions, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (result RepositoryCleanResult, err error) {
+	result.Repository = repo
+	logf := func(format string, args ...interface{}) {
+		mu.Lock()
+		*logMessages = append(*logMessages, logbuffer.NewEntry(fmt.Sprintf(format, args...)))
+		mu.Unlock()
+	}
+	counting := newCountingClient(client)
+	client = counting
+	defer func() {
+		result.APICalls = counting.Stats()
 		if err != nil {
-			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+			err = opts.Registry.Wrap(err)
+			result.Error = err.Error()
+			result.TimedOut = apitimeout.IsCallTimeout(err)
+			logf("[ERROR] Repository: %s - Failed to clean images: %v", repo, err)
 		}
-		for _, repo := range page.Repositories {
-			repositories = append(repositories, aws.ToString(repo.RepositoryName))
+	}()
+
+	if stopRequested(opts.Stop) {
+		result.Interrupted = true
+		logf("[WARN] Repository: %s - Stopped before it was cleaned", repo)
+		return result, nil
+	}
+
+	opts, result.Skipped = applyRepoTags(ctx, client, repo, opts, logf)
+	if result.Skipped {
+		return result, nil
+	}
+
+	managed, policyErr := policyExpiresUntagged(ctx, client, repo)
+	if policyErr != nil {
+		logf("[WARN] Repository: %s - Could not check lifecycle policy: %v", repo, policyErr)
+	} else if managed {
+		result.PolicyManaged = true
+		if opts.SkipPolicyManaged {
+			result.Skipped = true
+			logf("[INFO] Repository: %s - Skipping, lifecycle policy already expires untagged images", repo)
+			return result, nil
 		}
+		logf("[WARN] Repository %s already expires untagged images via lifecycle policy; cleanup may be unnecessary.", repo)
 	}
-	return repositories, nil
-}
+	logf("[INFO] Checking repository: %s", repo)
 
-// --- returns repositories matching a pattern ---
-func getRepositoriesByPatterns(ctx context.Context, client *ecr.Client, repoPattern string) ([]string, error) {
-	var repositories []string
-	allRepositories, err := getRepositories(ctx, client)
+	children, tagged, err := streamChildImages(ctx, client, repo, logMessages, mu)
 	if err != nil {
-		return nil, err
+		return result, err
 	}
+	result.Tagged = tagged
 
-	for _, repo := range allRepositories {
-		matched, err := regexp.MatchString(repoPattern, repo)
+	var cutoff time.Time
+	if minAge := opts.MinAgeFor(repo); minAge > 0 {
+		cutoff = time.Now().Add(-minAge)
+	}
+	keepAll := false
+	if opts.OlderThanTag != "" {
+		pushedAt, found, err := tagPushedAt(ctx, repo, opts.OlderThanTag, client)
 		if err != nil {
-			return nil, fmt.Errorf("failed to match pattern %s: %w", repoPattern, err)
+			return result, err
 		}
-		if matched {
-			repositories = append(repositories, repo)
+		if !found {
+			keepAll = true
+			logf("[WARN] Repository: %s - No image tagged %s, keeping all untagged images", repo, opts.OlderThanTag)
+		} else if cutoff.IsZero() || pushedAt.Before(cutoff) {
+			cutoff = pushedAt
 		}
 	}
-	return repositories, nil
-}
 
-// --- returns a map of tagged and orphan image digests ---
-func getImages(ctx context.Context, repository string, client ECRAPI) (map[string][]string, error) {
-	images := map[string][]string{"tagged": {}, "orphan": {}}
-	paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{RepositoryName: aws.String(repository)})
+	since, hasSince := opts.PushedSince[repo]
+	now := time.Now()
+	artifacts := 0
+	batch := make([]string, 0, deleteBatchSize)
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+		// --- once stopped the batch is dropped and paging ends, its images are picked up on the next run ---
+		if stopRequested(opts.Stop) {
+			result.Interrupted = true
+			batch = batch[:0]
+			return nil
+		}
+		deleted, failures, err := deleteBatch(ctx, repo, batch, client, logMessages, mu)
+		result.Deleted += deleted
+		result.Failures = append(result.Failures, failures...)
+		result.Failed = len(result.Failures)
+		batch = batch[:0]
+		return err
+	}
 
-	for paginator.HasMorePages() {
+	// --- pages are processed as they arrive, so memory stays flat ---
+	paginator := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repo),
+		Filter:         &types.DescribeImagesFilter{TagStatus: types.TagStatusUntagged},
+	})
+	for paginator.HasMorePages() && !result.Interrupted {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
+			return result, err
 		}
-		for _, image := range page.ImageIds {
-			if image.ImageTag != nil {
-				images["tagged"] = append(images["tagged"], aws.ToString(image.ImageDigest))
-			} else {
-				images["orphan"] = append(images["orphan"], aws.ToString(image.ImageDigest))
+		for _, detail := range page {
+			result.Untagged++
+			digest := aws.ToString(detail.ImageDigest)
+			if keepAll || children[digest] {
+				continue
+			}
+			// --- DescribeImages reports the config media type as the artifact media type, no manifest has to be fetched ---
+			artifact := Image{MediaType: aws.ToString(detail.ImageManifestMediaType), ArtifactType: aws.ToString(detail.ArtifactMediaType)}
+			if artifact.IsArtifact() {
+				artifacts++
+				continue
+			}
+			if !cutoff.IsZero() && (detail.ImagePushedAt == nil || !detail.ImagePushedAt.Before(cutoff)) {
+				continue
+			}
+			if hasSince && (detail.ImagePushedAt == nil || detail.ImagePushedAt.Before(since)) {
+				continue
+			}
+			if !opts.PushedRange.IsZero() && (detail.ImagePushedAt == nil || !opts.PushedRange.Contains(*detail.ImagePushedAt)) {
+				continue
+			}
+			if opts.NotPulled.Enabled() && !opts.NotPulled.Selects(detail, now) {
+				continue
+			}
+			result.Orphans++
+			batch = append(batch, digest)
+			if len(batch) == deleteBatchSize {
+				if err := flush(); err != nil {
+					return result, err
+				}
+				if result.Interrupted {
+					break
+				}
 			}
 		}
 	}
-	return images, nil
-}
-
-// --- returns child image digests for a set of images ---
-func getChildImages(ctx context.Context, repository string, images []string, client ECRAPI) ([]string, error) {
-	var children []string
-	imageIds := []types.ImageIdentifier{}
-	for _, digest := range images {
-		imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	if err := flush(); err != nil {
+		return result, err
 	}
-	input := &ecr.BatchGetImageInput{
-		RepositoryName: aws.String(repository),
-		ImageIds:       imageIds,
+	if result.Interrupted {
+		logf("[WARN] Repository: %s - Stopped after deleting %d images, failed to delete %d images", repo, result.Deleted, result.Failed)
+		return result, nil
 	}
-	result, err := client.BatchGetImage(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to batch get images for repository %s: %w", repository, err)
+	if artifacts > 0 {
+		logf("[INFO] Repository: %s - Keeping %d untagged OCI artifacts (SBOMs, signatures, attestations)", repo, artifacts)
 	}
-	for _, image := range result.Images {
-		var manifest map[string]interface{}
-		err := json.Unmarshal([]byte(aws.ToString(image.ImageManifest)), &manifest)
+
+	if opts.WarnAboveCount > 0 && result.Tagged+result.Untagged > opts.WarnAboveCount {
+		result.ImageCountWarn = true
+		logf("[WARN] Repository: %s - Holds %d images, above the watermark of %d, check its lifecycle policy", repo, result.Tagged+result.Untagged, opts.WarnAboveCount)
+	}
+	if opts.DryRun {
+		logf("[DRY RUN] Would delete %d images from repository: %s", result.Orphans, repo)
+	} else {
+		logf("[INFO] Repository: %s - Found %d tagged and %d untagged images, deleted %d images, failed to delete %d images", repo, result.Tagged, result.Untagged, result.Deleted, result.Failed)
+	}
+	return result, nil
+}
+
+// --- pages through the tagged images and returns the set of child digests they reference and the number of tagged images ---
+func streamChildImages(ctx context.Context, client ECRAPI, repo string, logMessages *[]logbuffer.Entry, mu *sync.Mutex) (map[string]bool, int, error) {
+	children := map[string]bool{}
+	seen := map[string]bool{}
+	paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{
+		RepositoryName: aws.String(repo),
+		Filter:         &types.ListImagesFilter{TagStatus: types.TagStatusTagged},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal image manifest for repository %s: %w", repository, err)
+			return nil, 0, fmt.Errorf("failed to list images for repository %s: %w", repo, err)
 		}
-		if manifests, ok := manifest["manifests"].([]interface{}); ok {
-			for _, m := range manifests {
-				if digest, ok := m.(map[string]interface{})["digest"].(string); ok {
-					children = append(children, digest)
-				}
+		// --- ListImages returns one entry per tag, each digest is resolved once ---
+		var parents []string
+		for _, image := range page.ImageIds {
+			digest := aws.ToString(image.ImageDigest)
+			if !seen[digest] {
+				seen[digest] = true
+				parents = append(parents, digest)
 			}
 		}
+		found, _, err := getChildImagesConcurrently(ctx, repo, parents, client, childLookupConcurrency, nil, logMessages, mu)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get child images for repository %s: %w", repo, err)
+		}
+		for _, child := range found {
+			children[child] = true
+		}
 	}
-	return children, nil
+	return children, len(seen), nil
 }
 
-// --- splits a list into chunks of a given size ---
-func partitionList(lst []string, size int) [][]string {
-	var partitions [][]string
-	for i := 0; i < len(lst); i += size {
-		end := i + size
-		if end > len(lst) {
-			end = len(lst)
+// --- returns repositories, error only ---
+func ListRepositories(ctx context.Context, client ECRAPI) (repositories []string, err error) {
+	ctx, span := tracing.Start(ctx, "ListRepositories")
+	defer func() {
+		span.SetAttributes(attribute.Int("ecr.repository.count", len(repositories)))
+		tracing.End(span, err)
+	}()
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			repositories = append(repositories, aws.ToString(repo.RepositoryName))
 		}
-		partitions = append(partitions, lst[i:end])
 	}
-	return partitions
+	return repositories, nil
 }
 
-// --- filter orphans not referenced by children ---
-func filterOrphans(orphans, children []string) []string {
-	result := make([]string, 0, len(orphans))
-	childSet := make(map[string]struct{}, len(children))
-	for _, c := range children {
-		childSet[c] = struct{}{}
-	}
-	for _, orphan := range orphans {
-		if _, found := childSet[orphan]; !found {
-			result = append(result, orphan)
+// --- returns the creation time of every repository ---
+func RepositoryCreationTimes(ctx context.Context, client ECRAPI) (map[string]time.Time, error) {
+	created := map[string]time.Time{}
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			created[aws.ToString(repo.RepositoryName)] = aws.ToTime(repo.CreatedAt)
 		}
 	}
-	return result
+	return created, nil
 }
 
-// --- returns orphan images to delete ---
-func imagesToDeleteWithLogging(ctx context.Context, repository string, client ECRAPI, logMessages *[]string, mu *sync.Mutex) ([]string, error) {
-	images, err := getImages(ctx, repository, client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get images for repository %s: %w", repository, err)
-	}
-	logMessage := fmt.Sprintf("[INFO] Repository: %s - Found %d tagged and %d untagged images", repository, len(images["tagged"]), len(images["orphan"]))
-	mu.Lock()
-	*logMessages = append(*logMessages, logMessage)
-	mu.Unlock()
-
-	for _, part := range partitionList(images["tagged"], 100) {
-		logMessage = fmt.Sprintf("[INFO] Repository: %s - Finding children of the tagged images", repository)
-		mu.Lock()
-		*logMessages = append(*logMessages, logMessage)
-		mu.Unlock()
-
-		children, err := getChildImages(ctx, repository, part, client)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get child images for repository %s: %w", repository, err)
+// --- keeps the repositories created before cutoff, repositories with an unknown creation time are dropped ---
+func FilterCreatedBefore(repositories []string, created map[string]time.Time, cutoff time.Time) []string {
+	var kept []string
+	for _, repo := range repositories {
+		if at, ok := created[repo]; ok && !at.IsZero() && at.Before(cutoff) {
+			kept = append(kept, repo)
 		}
-		images["orphan"] = filterOrphans(images["orphan"], children)
 	}
-	return images["orphan"], nil
+	return kept
 }
 
-// --- deletes images from a repository ---
-func deleteImagesWithLogging(ctx context.Context, repository string, images []string, client ECRAPI, dryRun bool, logMessages *[]string, mu *sync.Mutex) error {
-	if dryRun {
-		logMessage := fmt.Sprintf("[DRY RUN] Would delete %d images from repository: %s", len(images), repository)
-		mu.Lock()
-		*logMessages = append(*logMessages, logMessage)
-		mu.Unlock()
-		return nil
+// --- reports whether the cleanup removed every image of the repository ---
+func (r RepositoryCleanResult) emptied(dryRun bool) bool {
+	if r.Error != "" || r.Skipped || r.Tagged > 0 || r.Failed > 0 || r.Orphans != r.Untagged {
+		return false
 	}
-	deleted := 0
-	failed := 0
-	for _, part := range partitionList(images, 100) {
-		imageIds := []types.ImageIdentifier{}
-		for _, digest := range part {
-			imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	return dryRun || r.Deleted == r.Orphans
+}
+
+// --- deletes the repositories the cleanup left without images and records them in the report ---
+// --- DeleteRepository is called without force, so ECR refuses repositories that received images in the meantime ---
+func DeleteEmptyRepositories(ctx context.Context, client ECRAPI, report *CleanReport, dryRun bool, logs *logbuffer.LogBuffer) error {
+	var errs []error
+	var logMessages []logbuffer.Entry
+	for _, result := range report.Repositories {
+		if !result.emptied(dryRun) {
+			continue
 		}
-		input := &ecr.BatchDeleteImageInput{
-			RepositoryName: aws.String(repository),
-			ImageIds:       imageIds,
+		if dryRun {
+			logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[DRY RUN] Would delete empty repository: %s", result.Repository)))
+			continue
 		}
-		logMessage := fmt.Sprintf("[INFO] Repository: %s - Deleting %d images", repository, len(part))
-		mu.Lock()
-		*logMessages = append(*logMessages, logMessage)
-		mu.Unlock()
-		result, err := client.BatchDeleteImage(ctx, input)
+		_, err := client.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{RepositoryName: aws.String(result.Repository)})
 		if err != nil {
-			return fmt.Errorf("failed to batch delete images for repository %s: %w", repository, err)
-		}
-		for _, failure := range result.Failures {
-			logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to delete %s: %s - %s", repository, aws.ToString(failure.ImageId.ImageDigest), string(failure.FailureCode), aws.ToString(failure.FailureReason))
-			mu.Lock()
-			*logMessages = append(*logMessages, logMessage)
-			mu.Unlock()
+			logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[ERROR] Repository: %s - Failed to delete empty repository: %v", result.Repository, err)))
+			errs = append(errs, fmt.Errorf("failed to delete repository %s: %w", result.Repository, err))
+			continue
 		}
-		deleted += len(result.ImageIds)
-		failed += len(result.Failures)
+		logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[INFO] Repository: %s - Deleted empty repository", result.Repository)))
+		report.DeletedRepositories = append(report.DeletedRepositories, result.Repository)
+	}
+	logs.Emit(logMessages)
+	if len(errs) > 0 {
+		return MultiError(errs)
 	}
-	logMessage := fmt.Sprintf("[INFO] Repository: %s - Deleted %d images, failed to delete %d images", repository, deleted, failed)
-	mu.Lock()
-	*logMessages = append(*logMessages, logMessage)
-	mu.Unlock()
 	return nil
 }
 
-// --- runs the cleanup process for all repositories ---
-func CleanECRWithLogging(ctx context.Context, client ECRAPI, repositories []string, dryRun bool) error {
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var errs []error
-	var logMessages []string
-
-	for _, repository := range repositories {
-		wg.Add(1)
-		go func(repo string) {
-			defer wg.Done()
-			if dryRun {
-				logMessage := fmt.Sprintf("[DRY RUN] Would delete untagged images from repository: %s", repo)
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				mu.Unlock()
-				return
-			}
-			logMessage := fmt.Sprintf("[INFO] Checking repository: %s", repo)
-			mu.Lock()
-			logMessages = append(logMessages, logMessage)
-			mu.Unlock()
-
-			images, err := imagesToDeleteWithLogging(ctx, repo, client, &logMessages, &mu)
-			if err != nil {
-				logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to get images to delete: %v", repo, err)
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				errs = append(errs, err)
-				mu.Unlock()
-				return
-			}
-			if len(images) > 0 {
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				mu.Unlock()
-
-				err := deleteImagesWithLogging(ctx, repo, images, client, dryRun, &logMessages, &mu)
-				if err != nil {
-					logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to delete images: %v", repo, err)
-					mu.Lock()
-					logMessages = append(logMessages, logMessage)
-					errs = append(errs, err)
-					mu.Unlock()
-				}
-			} else {
-				logMessage = fmt.Sprintf("[INFO] Repository: %s - Nothing to delete", repo)
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				mu.Unlock()
+// --- returns repositories matching any of the patterns ---
+func ListRepositoriesByPattern(ctx context.Context, client ECRAPI, repoPatterns []string) ([]string, error) {
+	patterns, err := compileRepoPatterns(repoPatterns)
+	if err != nil {
+		return nil, err
+	}
+	allRepositories, err := ListRepositories(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var repositories []string
+	for _, repo := range allRepositories {
+		for _, pattern := range patterns {
+			if pattern.MatchString(repo) {
+				repositories = append(repositories, repo)
+				break
 			}
-		}(repository)
+		}
 	}
-	wg.Wait()
+	return repositories, nil
+}
 
-	sort.Slice(logMessages, func(i, j int) bool {
-		return logMessages[i] < logMessages[j]
-	})
+// --- Image is an image with all of its tags, untagged images have none ---
+// --- MediaType and ArtifactType are only set once the manifest was fetched ---
+type Image struct {
+	Digest    string   `json:"digest"`
+	Tags      []string `json:"tags,omitempty"`
+	MediaType string   `json:"mediaType,omitempty"`
+	// --- artifactType of the manifest, or the media type of its config when none is set ---
+	ArtifactType string `json:"artifactType,omitempty"`
+}
 
-	for _, logMessage := range logMessages {
-		log.Println(logMessage)
-	}
+// --- OCIArtifactManifestMediaType is the media type of OCI artifact manifests ---
+const OCIArtifactManifestMediaType = "application/vnd.oci.artifact.manifest.v1+json"
 
-	if len(errs) > 0 {
-		return fmt.Errorf("encountered errors during cleanup: %v", errs)
+// --- config media types of runnable container images, any other config marks an artifact ---
+var imageConfigMediaTypes = map[string]bool{
+	"application/vnd.oci.image.config.v1+json":       true,
+	"application/vnd.docker.container.image.v1+json": true,
+}
+
+// --- reports whether the image is an OCI artifact such as an SBOM, signature or attestation rather than a runnable image ---
+func (i Image) IsArtifact() bool {
+	if i.MediaType == OCIArtifactManifestMediaType {
+		return true
 	}
-	return nil
+	return i.ArtifactType != "" && !imageConfigMediaTypes[i.ArtifactType]
 }
 
-// --- returns repositories, error only ---
-func ListRepositories(ctx context.Context, client ECRAPI) ([]string, error) {
-	var repositories []string
-	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+// --- RepositoryImages holds the images of one repository, or the error listing them ---
+type RepositoryImages struct {
+	Repository string
+	Images     []Image
+	Err        error
+}
+
+// --- lists the images of every repository and sends each repository's result as soon as it is done, in no particular order ---
+// --- at most maxConcurrency repositories are listed at once, 0 means unbounded, the channel is closed once all are done ---
+// --- callers must drain the channel or cancel ctx, a cancelled context stops sending ---
+func GetImagesForAllRepositories(ctx context.Context, repos []string, client ECRAPI, maxConcurrency int) <-chan RepositoryImages {
+	results := make(chan RepositoryImages)
+	go func() {
+		defer close(results)
+		concurrency.ForEach(repos, maxConcurrency, func(repo string) {
+			images, err := listRepositoryImages(ctx, repo, client)
+			select {
+			case results <- RepositoryImages{Repository: repo, Images: images, Err: err}:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return results
+}
+
+// --- returns the images of a repository in listing order, ListImages returns one entry per tag so tags are grouped by digest ---
+func listRepositoryImages(ctx context.Context, repository string, client ECRAPI) ([]Image, error) {
+	var images []Image
+	index := map[string]int{}
+	paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{RepositoryName: aws.String(repository)})
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+			return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
 		}
-		for _, repo := range page.Repositories {
-			repositories = append(repositories, aws.ToString(repo.RepositoryName))
+		for _, id := range page.ImageIds {
+			digest := aws.ToString(id.ImageDigest)
+			i, seen := index[digest]
+			if !seen {
+				i = len(images)
+				index[digest] = i
+				images = append(images, Image{Digest: digest})
+			}
+			if id.ImageTag != nil {
+				images[i].Tags = append(images[i].Tags, aws.ToString(id.ImageTag))
+			}
 		}
 	}
-	return repositories, nil
+	return images, nil
 }
 
-// --- returns repositories matching pattern ---
-func ListRepositoriesByPattern(ctx context.Context, client ECRAPI, repoPattern string) ([]string, error) {
-	allRepositories, err := ListRepositories(ctx, client)
-	if err != nil {
-		return nil, err
-	}
-	var repositories []string
-	for _, repo := range allRepositories {
-		matched, err := regexp.MatchString(repoPattern, repo)
+// --- compiles repository name patterns before any repository is listed, so a typo fails fast ---
+func compileRepoPatterns(repoPatterns []string) ([]*regexp.Regexp, error) {
+	patterns := make([]*regexp.Regexp, len(repoPatterns))
+	for i, repoPattern := range repoPatterns {
+		pattern, err := regexp.Compile(repoPattern)
 		if err != nil {
-			return nil, fmt.Errorf("failed to match pattern %s: %w", repoPattern, err)
-		}
-		if matched {
-			repositories = append(repositories, repo)
+			return nil, fmt.Errorf("invalid pattern %s: %w", repoPattern, err)
 		}
+		patterns[i] = pattern
 	}
-	return repositories, nil
+	return patterns, nil
 }
 
 // --- returns map of tagged/orphan digests ---
+// --- entries are grouped by digest across pages, a digest is tagged when any of its entries has a tag ---
 func listImages(ctx context.Context, repository string, client ECRAPI) (map[string][]string, error) {
 	images := map[string][]string{"tagged": {}, "orphan": {}}
+	tagged := map[string]bool{}
+	var digests []string
 	paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{RepositoryName: aws.String(repository)})
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
@@ -354,63 +3402,48 @@ func listImages(ctx context.Context, repository string, client ECRAPI) (map[stri
 			return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
 		}
 		for _, image := range page.ImageIds {
-			if image.ImageTag != nil {
-				images["tagged"] = append(images["tagged"], aws.ToString(image.ImageDigest))
-			} else {
-				images["orphan"] = append(images["orphan"], aws.ToString(image.ImageDigest))
+			digest := aws.ToString(image.ImageDigest)
+			if _, seen := tagged[digest]; !seen {
+				digests = append(digests, digest)
 			}
+			tagged[digest] = tagged[digest] || image.ImageTag != nil
+		}
+	}
+	for _, digest := range digests {
+		if tagged[digest] {
+			images["tagged"] = append(images["tagged"], digest)
+		} else {
+			images["orphan"] = append(images["orphan"], digest)
 		}
 	}
 	return images, nil
 }
 
-// --- returns child digests ---
-func listChildImages(ctx context.Context, repository string, images []string, client ECRAPI) ([]string, error) {
-	var children []string
-	imageIds := make([]types.ImageIdentifier, len(images))
-	for i, digest := range images {
-		imageIds[i] = types.ImageIdentifier{ImageDigest: aws.String(digest)}
-	}
-	input := &ecr.BatchGetImageInput{
-		RepositoryName: aws.String(repository),
-		ImageIds:       imageIds,
-	}
-	result, err := client.BatchGetImage(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to batch get images for repository %s: %w", repository, err)
-	}
-	for _, image := range result.Images {
-		var manifest map[string]interface{}
-		err := json.Unmarshal([]byte(aws.ToString(image.ImageManifest)), &manifest)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal image manifest for repository %s: %w", repository, err)
-		}
-		if manifests, ok := manifest["manifests"].([]interface{}); ok {
-			for _, m := range manifests {
-				if digest, ok := m.(map[string]interface{})["digest"].(string); ok {
-					children = append(children, digest)
-				}
-			}
-		}
-	}
-	return children, nil
+// --- returns child digests, a corrupt manifest is handled as by getChildImages ---
+func listChildImages(ctx context.Context, repository string, images []string, client ECRAPI, logMessages *[]logbuffer.Entry, mu *sync.Mutex) ([]string, error) {
+	children, _, err := getChildImages(ctx, repository, images, client, nil, logMessages, mu)
+	return children, err
 }
 
 // --- returns orphan digests to delete ---
-func imagesToDelete(ctx context.Context, repository string, client ECRAPI) ([]string, int, int, error) {
+func imagesToDelete(ctx context.Context, repository string, client ECRAPI, minAge time.Duration, logMessages *[]logbuffer.Entry, mu *sync.Mutex) ([]string, int, int, error) {
 	images, err := listImages(ctx, repository, client)
 	if err != nil {
 		return nil, 0, 0, err
 	}
 	tagged := images["tagged"]
 	orphans := images["orphan"]
-	for _, part := range partitionList(tagged, 100) {
-		children, err := listChildImages(ctx, repository, part, client)
+	for _, part := range sliceutil.Partition(tagged, 100) {
+		children, err := listChildImages(ctx, repository, part, client, logMessages, mu)
 		if err != nil {
 			return nil, 0, 0, err
 		}
 		orphans = filterOrphans(orphans, children)
 	}
+	orphans, err = filterByAge(ctx, repository, orphans, minAge, types.TagStatusUntagged, client)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	return orphans, len(tagged), len(images["orphan"]), nil
 }
 
@@ -418,7 +3451,7 @@ func imagesToDelete(ctx context.Context, repository string, client ECRAPI) ([]st
 func deleteImages(ctx context.Context, repository string, images []string, client ECRAPI, dryRun bool) (int, int, error) {
 	deleted := 0
 	failed := 0
-	for _, part := range partitionList(images, 100) {
+	for _, part := range sliceutil.Partition(images, 100) {
 		imageIds := make([]types.ImageIdentifier, len(part))
 		for i, digest := range part {
 			imageIds[i] = types.ImageIdentifier{ImageDigest: aws.String(digest)}
@@ -3,13 +3,27 @@
 package deleteuntaggedimages
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	apitimeout "ecr-lifecycle-cleaner/internal/apiTimeout"
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsMiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
@@ -17,19 +31,28 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // --- mock ECR client ---
 type mockECRClient struct {
 	ecr.Client
-	describeReposOut *ecr.DescribeRepositoriesOutput
-	describeReposErr error
-	listImagesOut    *ecr.ListImagesOutput
-	listImagesErr    error
-	batchGetOut      *ecr.BatchGetImageOutput
-	batchGetErr      error
-	batchDeleteOut   *ecr.BatchDeleteImageOutput
-	batchDeleteErr   error
+	describeReposOut  *ecr.DescribeRepositoriesOutput
+	describeReposErr  error
+	listImagesOut     *ecr.ListImagesOutput
+	listImagesErr     error
+	batchGetOut       *ecr.BatchGetImageOutput
+	batchGetErr       error
+	batchDeleteOut    *ecr.BatchDeleteImageOutput
+	batchDeleteErr    error
+	describeImagesOut *ecr.DescribeImagesOutput
+	describeImagesErr error
+	getPolicyOut      *ecr.GetLifecyclePolicyOutput
+	getPolicyErr      error
+	listTagsOut       *ecr.ListTagsForResourceOutput
+	listTagsErr       error
 }
 
 func (m *mockECRClient) DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
@@ -45,6 +68,21 @@ func (m *mockECRClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDelet
 	return m.batchDeleteOut, m.batchDeleteErr
 }
 
+func (m *mockECRClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	return m.describeImagesOut, m.describeImagesErr
+}
+
+func (m *mockECRClient) ListTagsForResource(ctx context.Context, in *ecr.ListTagsForResourceInput, optFns ...func(*ecr.Options)) (*ecr.ListTagsForResourceOutput, error) {
+	return m.listTagsOut, m.listTagsErr
+}
+
+func (m *mockECRClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	if m.getPolicyOut == nil && m.getPolicyErr == nil {
+		return nil, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+	}
+	return m.getPolicyOut, m.getPolicyErr
+}
+
 func TestDeleteUntaggedImages(t *testing.T) {
 	describeRepositoriesMiddleware := middleware.FinalizeMiddlewareFunc(
 		"DescribeRepositoriesMock",
@@ -117,6 +155,17 @@ func TestDeleteUntaggedImages(t *testing.T) {
 		},
 	)
 
+	getLifecyclePolicyMiddleware := middleware.FinalizeMiddlewareFunc(
+		"GetLifecyclePolicyMock",
+		func(ctx context.Context, input middleware.FinalizeInput, handler middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			operationName := awsMiddleware.GetOperationName(ctx)
+			if operationName == "GetLifecyclePolicy" {
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+			}
+			return handler.HandleFinalize(ctx, input)
+		},
+	)
+
 	cfg, err := config.LoadDefaultConfig(
 		context.TODO(),
 		config.WithRegion("us-west-2"),
@@ -125,6 +174,9 @@ func TestDeleteUntaggedImages(t *testing.T) {
 				if err := stack.Finalize.Add(describeRepositoriesMiddleware, middleware.Before); err != nil {
 					return err
 				}
+				if err := stack.Finalize.Add(getLifecyclePolicyMiddleware, middleware.Before); err != nil {
+					return err
+				}
 				if err := stack.Finalize.Add(listImagesMiddleware, middleware.Before); err != nil {
 					return err
 				}
@@ -146,15 +198,19 @@ func TestDeleteUntaggedImages(t *testing.T) {
 
 	// --- test with allRepos = true ---
 	t.Run("Test with allRepos = true", func(t *testing.T) {
-		err := Main(client, true, nil, "", false)
+		report, err := Main(client, true, nil, nil, CleanOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
+		want := []RepositoryCleanResult{{Repository: "test-repo", Tagged: 1, Untagged: 1, APICalls: PaginationStats{ListImagesPages: 2, BatchGetImageCalls: 1}}}
+		if !reflect.DeepEqual(report.Repositories, want) {
+			t.Errorf("Expected %+v, got: %+v", want, report.Repositories)
+		}
 	})
 
 	// --- test with specific repository list ---
 	t.Run("Test with specific repository list", func(t *testing.T) {
-		err := Main(client, false, []string{"test-repo"}, "", false)
+		_, err := Main(client, false, []string{"test-repo"}, nil, CleanOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -162,7 +218,7 @@ func TestDeleteUntaggedImages(t *testing.T) {
 
 	// --- test with repository pattern ---
 	t.Run("Test with repository pattern", func(t *testing.T) {
-		err := Main(client, false, nil, "test-.*", false)
+		_, err := Main(client, false, nil, []string{"test-.*"}, CleanOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -170,7 +226,7 @@ func TestDeleteUntaggedImages(t *testing.T) {
 
 	// --- test with dryRun = true ---
 	t.Run("Test with dryRun = true", func(t *testing.T) {
-		err := Main(client, true, nil, "", true)
+		_, err := Main(client, true, nil, nil, CleanOptions{DryRun: true})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -206,13 +262,23 @@ func TestListRepositoriesByPattern(t *testing.T) {
 	ctx := context.TODO()
 	client := &mockECRClient{
 		describeReposOut: &ecr.DescribeRepositoriesOutput{
-			Repositories: []types.Repository{{RepositoryName: aws.String("foo")}, {RepositoryName: aws.String("bar")}},
+			Repositories: []types.Repository{{RepositoryName: aws.String("foo")}, {RepositoryName: aws.String("bar")}, {RepositoryName: aws.String("baz")}},
 		},
 	}
-	got, err := ListRepositoriesByPattern(ctx, client, "^f")
+	got, err := ListRepositoriesByPattern(ctx, client, []string{"^f"})
 	if err != nil || !reflect.DeepEqual(got, []string{"foo"}) {
 		t.Errorf("ListRepositoriesByPattern = %v, %v; want [foo], nil", got, err)
 	}
+
+	// --- a repository matching any pattern is selected once ---
+	got, err = ListRepositoriesByPattern(ctx, client, []string{"^f", "r$", "^fo{1,2}$"})
+	if err != nil || !reflect.DeepEqual(got, []string{"foo", "bar"}) {
+		t.Errorf("ListRepositoriesByPattern = %v, %v; want [foo bar], nil", got, err)
+	}
+
+	if _, err := ListRepositoriesByPattern(ctx, client, []string{"^f", "("}); err == nil || !strings.Contains(err.Error(), "invalid pattern (") {
+		t.Errorf("Expected an invalid pattern error, got: %v", err)
+	}
 }
 
 func TestListImages(t *testing.T) {
@@ -239,12 +305,68 @@ func TestListChildImages(t *testing.T) {
 			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d2"}]}`)}},
 		},
 	}
-	got, err := listChildImages(ctx, "repo", []string{"d1"}, client)
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	got, err := listChildImages(ctx, "repo", []string{"d1"}, client, &logMessages, &mu)
 	if err != nil || !reflect.DeepEqual(got, []string{"d2"}) {
 		t.Errorf("ListChildImages = %v, %v; want [d2], nil", got, err)
 	}
 }
 
+func TestValidateManifest(t *testing.T) {
+	for _, manifest := range []string{
+		`{"manifests":[{"digest":"d2"}]}`,
+		`{"schemaVersion":2,"config":{"digest":"c1"},"layers":[]}`,
+	} {
+		if err := ValidateManifest(manifest); err != nil {
+			t.Errorf("Expected %s to be valid, got: %v", manifest, err)
+		}
+	}
+	invalid := map[string]string{
+		`{"manifests":[{"dig`: "not valid JSON",
+		`[]`:                  "not valid JSON",
+		`{"schemaVersion":2}`: "none of manifests, layers or config",
+		`{"layers":"` + strings.Repeat("a", MaxManifestSize) + `"}`: "larger than",
+	}
+	for manifest, want := range invalid {
+		if err := ValidateManifest(manifest); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestGetChildImages_CorruptManifest(t *testing.T) {
+	const index, manifest = "application/vnd.oci.image.index.v1+json", "application/vnd.oci.image.manifest.v1+json"
+	client := &mockECRClient{
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{
+				{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("d1")}, ImageManifestMediaType: aws.String(manifest), ImageManifest: aws.String(`{"layers":[`)},
+				{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("d3")}, ImageManifestMediaType: aws.String(index), ImageManifest: aws.String(`{"manifests":[{"digest":"d4"}]}`)},
+			},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	children, _, err := getChildImages(context.TODO(), "repo", []string{"d1", "d3"}, client, nil, &logMessages, &mu)
+	if err != nil || !reflect.DeepEqual(children, []string{"d4"}) {
+		t.Fatalf("getChildImages = %v, %v; want [d4], nil", children, err)
+	}
+	if len(logMessages) != 1 || !strings.Contains(logMessages[0].Message, "[WARN] Repository: repo - Skipping corrupt manifest of d1, it is not an image index") {
+		t.Errorf("Expected a warning naming the corrupt image, got: %+v", logMessages)
+	}
+
+	// --- a corrupt index, or a manifest of unknown media type, could list children, so the repository fails ---
+	for _, mediaType := range []*string{aws.String(index), nil} {
+		client.batchGetOut.Images[0] = types.Image{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("d1")}, ImageManifestMediaType: mediaType, ImageManifest: aws.String(`{"manifests":[{"digest":"d2"}`)}
+		if _, _, err := getChildImages(context.TODO(), "repo", []string{"d1", "d3"}, client, nil, &logMessages, &mu); err == nil || !strings.Contains(err.Error(), "corrupt manifest of d1 in repository repo may list children") {
+			t.Errorf("Expected a corrupt index to fail the lookup, got: %v", err)
+		}
+		if _, err := listChildImages(context.TODO(), "repo", []string{"d1", "d3"}, client, &logMessages, &mu); err == nil {
+			t.Error("Expected a corrupt index to fail the streaming lookup")
+		}
+	}
+}
+
 func TestImagesToDelete(t *testing.T) {
 	ctx := context.TODO()
 	client := &mockECRClient{
@@ -258,7 +380,9 @@ func TestImagesToDelete(t *testing.T) {
 			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d2"}]}`)}},
 		},
 	}
-	orphans, tagged, orphanCount, err := imagesToDelete(ctx, "repo", client)
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	orphans, tagged, orphanCount, err := imagesToDelete(ctx, "repo", client, 0, &logMessages, &mu)
 	if err != nil || tagged != 1 || orphanCount != 1 || !reflect.DeepEqual(orphans, []string{}) {
 		t.Errorf("ImagesToDelete = %v, %d, %d, %v; want [], 1, 1, nil", orphans, tagged, orphanCount, err)
 	}
@@ -282,9 +406,9 @@ func TestImagesToDeleteWithLogging(t *testing.T) {
 			ImageIds: []types.ImageIdentifier{},
 		},
 	}
-	var logMessages []string
+	var logMessages []logbuffer.Entry
 	var mu sync.Mutex
-	orphans, err := imagesToDeleteWithLogging(ctx, "repo", client, &logMessages, &mu)
+	orphans, _, _, err := imagesToDeleteWithLogging(ctx, "repo", client, CleanOptions{}, &logMessages, &mu)
 	if err != nil {
 		t.Errorf("Expected no error, got: %v", err)
 	}
@@ -296,9 +420,9 @@ func TestImagesToDeleteWithLogging(t *testing.T) {
 func TestDeleteImagesWithLogging_DryRun(t *testing.T) {
 	ctx := context.TODO()
 	client := &ecr.Client{}
-	var logMessages []string
+	var logMessages []logbuffer.Entry
 	var mu sync.Mutex
-	err := deleteImagesWithLogging(ctx, "repo", []string{"sha256:deadbeef"}, client, true, &logMessages, &mu)
+	_, _, _, err := deleteImagesWithLogging(ctx, "repo", []string{"sha256:deadbeef"}, client, true, nil, &logMessages, &mu)
 	if err != nil {
 		t.Errorf("Expected no error in dry run, got: %v", err)
 	}
@@ -307,7 +431,7 @@ func TestDeleteImagesWithLogging_DryRun(t *testing.T) {
 func TestCleanECRWithLogging_EmptyRepos(t *testing.T) {
 	ctx := context.TODO()
 	client := &ecr.Client{}
-	err := CleanECRWithLogging(ctx, client, []string{}, true)
+	_, err := CleanECRWithLogging(ctx, client, []string{}, CleanOptions{DryRun: true})
 	if err != nil {
 		t.Errorf("Expected no error for empty repo list, got: %v", err)
 	}
@@ -322,3 +446,2621 @@ func TestFilterOrphans(t *testing.T) {
 		t.Errorf("filterOrphans = %v; want %v", filtered, want)
 	}
 }
+
+func TestFilterOrphansSorted(t *testing.T) {
+	orphans := []string{"d", "a", "c", "b", "e"}
+	children := []string{"e", "b", "x"}
+	want := []string{"d", "a", "c"}
+	if got := filterOrphansSorted(orphans, children); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterOrphansSorted = %v; want %v", got, want)
+	}
+	// --- the caller's children slice must not be reordered ---
+	if !reflect.DeepEqual(children, []string{"e", "b", "x"}) {
+		t.Errorf("filterOrphansSorted modified children: %v", children)
+	}
+
+	// --- both variants agree above the threshold ---
+	bigOrphans, bigChildren := syntheticDigests(sortedFilterThreshold + 1)
+	if !reflect.DeepEqual(filterOrphans(bigOrphans, bigChildren), filterOrphansMap(bigOrphans, bigChildren)) {
+		t.Errorf("filterOrphans and filterOrphansMap disagree above the threshold")
+	}
+}
+
+// --- returns n orphans and n children where every other orphan is a child ---
+func syntheticDigests(n int) ([]string, []string) {
+	orphans := make([]string, n)
+	children := make([]string, n)
+	for i := 0; i < n; i++ {
+		orphans[i] = fmt.Sprintf("sha256:%064d", i)
+		children[i] = fmt.Sprintf("sha256:%064d", i*2)
+	}
+	return orphans, children
+}
+
+func BenchmarkFilterOrphans(b *testing.B) {
+	orphans, children := syntheticDigests(50000)
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = filterOrphansMap(orphans, children)
+		}
+	})
+	b.Run("sorted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = filterOrphansSorted(orphans, children)
+		}
+	})
+}
+
+func TestCleanECRWithLogging_Report(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d3")},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d2"}]}`)}},
+		},
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d3")}},
+		},
+	}
+	report, err := CleanECRWithLogging(ctx, client, []string{"repo-b", "repo-a"}, CleanOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// --- one ListImages page per tag status, one BatchGetImage resolves the children of the tagged image, one checks the orphan for OCI artifacts ---
+	calls := PaginationStats{ListImagesPages: 2, BatchGetImageCalls: 2, BatchDeleteImageCalls: 1}
+	want := []RepositoryCleanResult{
+		{Repository: "repo-a", Tagged: 1, Untagged: 2, Orphans: 1, Deleted: 1, APICalls: calls},
+		{Repository: "repo-b", Tagged: 1, Untagged: 2, Orphans: 1, Deleted: 1, APICalls: calls},
+	}
+	if !reflect.DeepEqual(report.Repositories, want) {
+		t.Errorf("Expected %+v, got: %+v", want, report.Repositories)
+	}
+	if got := report.Summary(); got != "Processed 2 repos, deleted 2 images, failed to delete 0" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+
+	// --- errors are recorded per repository ---
+	client.batchDeleteErr = errors.New("fail")
+	report, err = CleanECRWithLogging(ctx, client, []string{"repo-a"}, CleanOptions{})
+	if err == nil || report.Repositories[0].Error == "" {
+		t.Errorf("Expected error recorded in report, got: %+v, %v", report, err)
+	}
+}
+
+func TestCleanECRWithLogging_Step(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}},
+		},
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}},
+		},
+	}
+	var asked []string
+	decisions := map[string]StepDecision{"repo-a": StepProceed, "repo-b": StepSkip, "repo-c": StepAbort}
+	opts := CleanOptions{Confirm: func(repo string, images []string) StepDecision {
+		asked = append(asked, repo)
+		return decisions[repo]
+	}}
+
+	report, err := CleanECRWithLogging(ctx, client, []string{"repo-a", "repo-b", "repo-c", "repo-d"}, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// --- repositories are asked in order and processing stops at the abort ---
+	if !reflect.DeepEqual(asked, []string{"repo-a", "repo-b", "repo-c"}) {
+		t.Errorf("Unexpected prompt order: %v", asked)
+	}
+	if !report.Aborted || len(report.Repositories) != 2 {
+		t.Fatalf("Expected aborted report with 2 repositories, got: %+v", report)
+	}
+	if report.Repositories[0].Deleted != 1 || report.Repositories[1].Deleted != 0 {
+		t.Errorf("Expected only repo-a to be deleted, got: %+v", report.Repositories)
+	}
+}
+
+func TestCleanECRWithLogging_PolicyManaged(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		getPolicyOut: &ecr.GetLifecyclePolicyOutput{
+			LifecyclePolicyText: aws.String(`{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":1},"action":{"type":"expire"}}]}`),
+		},
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}},
+		},
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}},
+		},
+	}
+
+	// --- without the skip flag the repository is still cleaned, only flagged ---
+	report, err := CleanECRWithLogging(ctx, client, []string{"repo"}, CleanOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := report.Repositories[0]; !got.PolicyManaged || got.Skipped || got.Deleted != 1 {
+		t.Errorf("Expected policy managed repository to be cleaned, got: %+v", got)
+	}
+
+	report, err = CleanECRWithLogging(ctx, client, []string{"repo"}, CleanOptions{SkipPolicyManaged: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := report.Repositories[0]; !got.PolicyManaged || !got.Skipped || got.Deleted != 0 {
+		t.Errorf("Expected policy managed repository to be skipped, got: %+v", got)
+	}
+
+	// --- a failing policy check does not block cleanup ---
+	client.getPolicyOut = nil
+	client.getPolicyErr = errors.New("access denied")
+	report, err = CleanECRWithLogging(ctx, client, []string{"repo"}, CleanOptions{SkipPolicyManaged: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := report.Repositories[0]; got.PolicyManaged || got.Deleted != 1 {
+		t.Errorf("Expected cleanup to proceed when the policy check fails, got: %+v", got)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0d", 0, false},
+		{"-1d", 0, true},
+		{"seven", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadMinAgeRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "min-age.json")
+	content := `[{"pattern": "^prod-", "minAge": "30d"}, {"pattern": "^prod-tmp", "minAge": "1d"}, {"pattern": "^tmp-", "minAge": "12h"}]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write min age map: %v", err)
+	}
+	rules, err := LoadMinAgeRules(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	opts := CleanOptions{MinAge: 7 * 24 * time.Hour, MinAgeRules: rules}
+	if got := opts.MinAgeFor("prod-api"); got != 30*24*time.Hour {
+		t.Errorf("MinAgeFor(prod-api) = %v; want 720h", got)
+	}
+	// --- first match wins, the more specific prod-tmp rule is never reached ---
+	if got := opts.MinAgeFor("prod-tmp-x"); got != 30*24*time.Hour {
+		t.Errorf("MinAgeFor(prod-tmp-x) = %v; want 720h", got)
+	}
+	if got := opts.MinAgeFor("tmp-build"); got != 12*time.Hour {
+		t.Errorf("MinAgeFor(tmp-build) = %v; want 12h", got)
+	}
+	if got := opts.MinAgeFor("other"); got != 7*24*time.Hour {
+		t.Errorf("MinAgeFor(other) = %v; want fallback 168h", got)
+	}
+
+	for name, bad := range map[string]string{
+		"invalid json":    `{`,
+		"invalid pattern": `[{"pattern": "(", "minAge": "1d"}]`,
+		"invalid age":     `[{"pattern": "x", "minAge": "soon"}]`,
+	} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatalf("Failed to write min age map: %v", err)
+		}
+		if _, err := LoadMinAgeRules(path); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestFilterByAge(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("old"), ImagePushedAt: aws.Time(time.Now().Add(-48 * time.Hour))},
+				{ImageDigest: aws.String("new"), ImagePushedAt: aws.Time(time.Now().Add(-1 * time.Hour))},
+			},
+		},
+	}
+	got, err := filterByAge(ctx, "repo", []string{"old", "new", "unknown"}, 24*time.Hour, types.TagStatusUntagged, client)
+	if err != nil || !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("filterByAge = %v, %v; want [old], nil", got, err)
+	}
+
+	// --- a zero min age keeps the list untouched without calling DescribeImages ---
+	got, err = filterByAge(ctx, "repo", []string{"a"}, 0, types.TagStatusUntagged, &mockECRClient{describeImagesErr: errors.New("fail")})
+	if err != nil || !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("filterByAge with zero age = %v, %v; want [a], nil", got, err)
+	}
+}
+
+func TestPlanCleanup(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d3")},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d2"}]}`)}},
+		},
+		// --- planning must never delete, any call would surface as an error in the plan ---
+		batchDeleteErr: errors.New("unexpected delete"),
+	}
+	plan := PlanCleanup(ctx, client, []string{"repo-b", "repo-a"}, CleanOptions{DryRun: true})
+	calls := PaginationStats{ListImagesPages: 2, BatchGetImageCalls: 2}
+	want := []RepositoryPlan{
+		{Repository: "repo-a", Tagged: 1, Untagged: 2, Images: []string{"d3"}, APICalls: calls},
+		{Repository: "repo-b", Tagged: 1, Untagged: 2, Images: []string{"d3"}, APICalls: calls},
+	}
+	if !reflect.DeepEqual(plan.Repositories, want) || !plan.DryRun {
+		t.Errorf("Expected %+v, got: %+v", want, plan)
+	}
+	if plan.TotalImages() != 2 {
+		t.Errorf("Expected 2 images in plan, got: %d", plan.TotalImages())
+	}
+}
+
+func TestPlanCleanup_WarnAboveCount(t *testing.T) {
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d3")},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{},
+	}
+	logs := logbuffer.New()
+
+	// --- three images are above a watermark of two, the warning is logged in dry runs too ---
+	plan := PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, WarnAboveCount: 2, Logs: logs})
+	if !plan.Repositories[0].ImageCountWarn {
+		t.Errorf("Expected the watermark to be exceeded, got: %+v", plan.Repositories[0])
+	}
+	var out bytes.Buffer
+	if err := logs.Flush(context.TODO(), &out); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(out.String(), "[WARN] Repository: repo-a - Holds 3 images, above the watermark of 2") {
+		t.Errorf("Expected watermark warning, got: %s", out.String())
+	}
+	report, _ := ExecutePlan(context.TODO(), client, plan, CleanOptions{DryRun: true, Logs: logs})
+	if !report.Repositories[0].ImageCountWarn {
+		t.Errorf("Expected the warning to be carried into the report, got: %+v", report.Repositories[0])
+	}
+
+	// --- at the watermark or with the check disabled nothing is flagged ---
+	for _, limit := range []int{3, 0} {
+		plan = PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, WarnAboveCount: limit, Logs: logbuffer.New()})
+		if plan.Repositories[0].ImageCountWarn {
+			t.Errorf("Expected no warning for watermark %d", limit)
+		}
+	}
+}
+
+func TestPlanEmpty(t *testing.T) {
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("latest")},
+				{ImageDigest: aws.String("d3")},
+			},
+		},
+		// --- planning must never delete ---
+		batchDeleteErr: errors.New("unexpected delete"),
+	}
+	plan := PlanEmpty(context.TODO(), client, []string{"repo-a"}, CleanOptions{})
+
+	// --- tagged images come first and an image with two tags is deleted once ---
+	want := []RepositoryPlan{{Repository: "repo-a", Tagged: 1, Untagged: 2, Images: []string{"d1", "d2", "d3"}, APICalls: PaginationStats{ListImagesPages: 1}}}
+	if !reflect.DeepEqual(plan.Repositories, want) {
+		t.Errorf("Expected %+v, got: %+v", want, plan.Repositories)
+	}
+
+	client = &mockECRClient{listImagesErr: errors.New("denied")}
+	plan = PlanEmpty(context.TODO(), client, []string{"repo-a"}, CleanOptions{})
+	if len(plan.Repositories) != 1 || plan.Repositories[0].Error == "" || plan.TotalImages() != 0 {
+		t.Errorf("Expected planning error, got: %+v", plan)
+	}
+}
+
+func TestCleanECRWithLogging_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	log.SetOutput(io.Discard)
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d2")},
+			},
+		},
+		batchGetOut:    &ecr.BatchGetImageOutput{},
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d2")}}},
+	}
+	if _, err := CleanECRWithLogging(context.TODO(), client, []string{"repo-a"}, CleanOptions{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	attrs := map[string]map[string]string{}
+	for _, span := range recorder.Ended() {
+		values := map[string]string{}
+		for _, kv := range span.Attributes() {
+			values[string(kv.Key)] = kv.Value.Emit()
+		}
+		attrs[span.Name()] = values
+	}
+	if attrs["DiscoverImages"]["ecr.repository"] != "repo-a" || attrs["DiscoverImages"]["ecr.images.untagged"] != "1" {
+		t.Errorf("Unexpected DiscoverImages attributes: %v", attrs["DiscoverImages"])
+	}
+	if _, ok := attrs["ResolveChildImages"]; !ok {
+		t.Errorf("Expected a ResolveChildImages span, got: %v", attrs)
+	}
+	if attrs["DeleteImages"]["ecr.images.deleted"] != "1" {
+		t.Errorf("Unexpected DeleteImages attributes: %v", attrs["DeleteImages"])
+	}
+}
+
+// --- returns a policy per repository and records the peak number of calls in flight ---
+type policyMockClient struct {
+	mockECRClient
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	policies map[string]string
+	failing  map[string]bool
+}
+
+func (m *policyMockClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.peak {
+		m.peak = m.inFlight
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	repo := aws.ToString(in.RepositoryName)
+	if m.failing[repo] {
+		return nil, errors.New("access denied")
+	}
+	if policy, ok := m.policies[repo]; ok {
+		return &ecr.GetLifecyclePolicyOutput{LifecyclePolicyText: aws.String(policy)}, nil
+	}
+	return nil, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+}
+
+func TestConcurrentGetPolicies(t *testing.T) {
+	client := &policyMockClient{
+		policies: map[string]string{},
+		failing:  map[string]bool{"repo-3": true, "repo-7": true},
+	}
+	var repos []string
+	for i := 0; i < 20; i++ {
+		repo := fmt.Sprintf("repo-%d", i)
+		repos = append(repos, repo)
+		if i%2 == 0 {
+			client.policies[repo] = "policy-" + repo
+		}
+	}
+
+	policies, errs := ConcurrentGetPolicies(context.TODO(), client, repos, 4)
+	if client.peak > 4 {
+		t.Errorf("Expected at most 4 calls in flight, got: %d", client.peak)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got: %v", errs)
+	}
+	if len(policies) != 18 {
+		t.Errorf("Expected 18 repositories fetched despite failures, got: %d", len(policies))
+	}
+	if policies["repo-0"] != "policy-repo-0" {
+		t.Errorf("Expected policy for repo-0, got: %q", policies["repo-0"])
+	}
+	if policy, ok := policies["repo-1"]; !ok || policy != "" {
+		t.Errorf("Expected empty policy for repo-1, got: %q, %v", policy, ok)
+	}
+	if _, ok := policies["repo-3"]; ok {
+		t.Errorf("Expected failed repo-3 to be left out")
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	err := error(MultiError{errors.New("a"), fmt.Errorf("b: %w", sentinel)})
+	if err.Error() != "a; b: sentinel" {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+	if !errors.Is(err, sentinel) {
+		t.Errorf("Expected errors.Is to find the wrapped error")
+	}
+}
+
+// --- records the peak number of BatchGetImage calls in flight ---
+type batchGetCountingClient struct {
+	mockECRClient
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (m *batchGetCountingClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.peak {
+		m.peak = m.inFlight
+	}
+	m.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+	return &ecr.BatchGetImageOutput{}, nil
+}
+
+func TestWithOperationLimits(t *testing.T) {
+	base := &batchGetCountingClient{}
+	if WithOperationLimits(base, nil) != ECRAPI(base) {
+		t.Errorf("Expected client to be returned unchanged without limits")
+	}
+
+	client := WithOperationLimits(base, concurrency.OperationLimits{"BatchGetImage": 2})
+	repos := make([]string, 10)
+	concurrency.ForEach(repos, 0, func(string) {
+		if _, err := client.BatchGetImage(context.TODO(), &ecr.BatchGetImageInput{}); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+	if base.peak > 2 {
+		t.Errorf("Expected at most 2 BatchGetImage calls in flight, got: %d", base.peak)
+	}
+
+	// --- operations without a limit are not wrapped ---
+	if _, err := client.ListImages(context.TODO(), &ecr.ListImagesInput{}); err != nil {
+		t.Errorf("Expected ListImages to pass through, got: %v", err)
+	}
+}
+
+// --- records the peak number of calls in flight per read operation of the planning phase ---
+type planCountingClient struct {
+	mockECRClient
+	mu       sync.Mutex
+	inFlight map[string]int
+	peak     map[string]int
+}
+
+func (m *planCountingClient) track(op string) func() {
+	m.mu.Lock()
+	m.inFlight[op]++
+	if m.inFlight[op] > m.peak[op] {
+		m.peak[op] = m.inFlight[op]
+	}
+	m.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	return func() {
+		m.mu.Lock()
+		m.inFlight[op]--
+		m.mu.Unlock()
+	}
+}
+
+func (m *planCountingClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	defer m.track("BatchGetImage")()
+	return &ecr.BatchGetImageOutput{}, nil
+}
+
+func (m *planCountingClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	defer m.track("DescribeImages")()
+	return m.describeImagesOut, nil
+}
+
+func (m *planCountingClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	defer m.track("GetLifecyclePolicy")()
+	return nil, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+}
+
+func TestWithOperationLimits_Planning(t *testing.T) {
+	base := &planCountingClient{
+		mockECRClient: mockECRClient{
+			listImagesOut: &ecr.ListImagesOutput{ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("t1")},
+				{ImageDigest: aws.String("d2")},
+			}},
+			describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("d2"), ImagePushedAt: aws.Time(time.Now().Add(-48 * time.Hour))},
+			}},
+			batchDeleteErr: errors.New("unexpected delete"),
+		},
+		inFlight: map[string]int{},
+		peak:     map[string]int{},
+	}
+	limits := concurrency.OperationLimits{"BatchGetImage": 2, "DescribeImages": 1, "GetLifecyclePolicy": 3}
+	client := WithOperationLimits(base, limits)
+
+	// --- a dry run plans every repository at once, the limits still apply to the read calls ---
+	log.SetOutput(io.Discard)
+	repos := make([]string, 10)
+	for i := range repos {
+		repos[i] = fmt.Sprintf("repo-%d", i)
+	}
+	plan := PlanCleanup(context.TODO(), client, repos, CleanOptions{DryRun: true, MinAge: time.Hour, Concurrency: len(repos)})
+	if plan.TotalImages() != len(repos) {
+		t.Fatalf("Expected one image per repository in the plan, got: %+v", plan)
+	}
+	for op, limit := range limits {
+		if base.peak[op] == 0 {
+			t.Errorf("Expected %s to be called while planning", op)
+		}
+		if base.peak[op] > limit {
+			t.Errorf("Expected at most %d %s calls in flight, got: %d", limit, op, base.peak[op])
+		}
+	}
+}
+
+func TestExecutePlan_OnRepositoryDone(t *testing.T) {
+	var mu sync.Mutex
+	var done []string
+	client := &mockECRClient{
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}}},
+	}
+	plan := CleanPlan{Repositories: []RepositoryPlan{
+		{Repository: "repo-a", Images: []string{"d1"}},
+		{Repository: "repo-b", Error: "planning failed"},
+	}}
+	opts := CleanOptions{OnRepositoryDone: func(result RepositoryCleanResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, fmt.Sprintf("%s:%d:%t", result.Repository, result.Deleted, result.Error != ""))
+	}}
+	log.SetOutput(io.Discard)
+	if _, err := ExecutePlan(context.TODO(), client, plan, opts); err == nil {
+		t.Errorf("Expected the planning error to be reported")
+	}
+	sort.Strings(done)
+	if !reflect.DeepEqual(done, []string{"repo-a:1:false", "repo-b:0:true"}) {
+		t.Errorf("Unexpected callbacks: %v", done)
+	}
+}
+
+func TestCleanReport_ByNamespace(t *testing.T) {
+	for repo, want := range map[string]string{"team/app": "team", "org/team/app": "org", "standalone": ""} {
+		if got := Namespace(repo); got != want {
+			t.Errorf("Namespace(%q) = %q, want %q", repo, got, want)
+		}
+	}
+
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "web/frontend", Deleted: 3, Failed: 1},
+		{Repository: "api/users", Deleted: 2},
+		{Repository: "web/backend", Deleted: 4, Error: "boom"},
+		{Repository: "tools"},
+	}}
+	want := []NamespaceTotals{
+		{Namespace: "", Repositories: 1},
+		{Namespace: "api", Repositories: 1, Deleted: 2},
+		{Namespace: "web", Repositories: 2, Deleted: 7, Failed: 1, Errors: 1},
+	}
+	if got := report.ByNamespace(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got: %+v", want, got)
+	}
+}
+
+func TestCleanReport_JUnit(t *testing.T) {
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", Deleted: 2},
+		{Repository: "web", Deleted: 1, Failed: 1, Failures: []FailedDeletion{{Repository: "web", Digest: "sha256:1", FailureCode: "ImageReferencedByManifestList", FailureReason: "in use"}}},
+		{Repository: "api", Error: "access denied"},
+		{Repository: "legacy", Skipped: true},
+	}}
+	cases := report.JUnit().Cases
+	if len(cases) != 4 {
+		t.Fatalf("Expected one case per repository, got: %+v", cases)
+	}
+	if cases[0].Failure != "" || cases[0].Skipped != "" {
+		t.Errorf("Expected app to pass, got: %+v", cases[0])
+	}
+	if cases[1].Failure != "failed to delete 1 of 2 images" || !strings.Contains(cases[1].Details, "sha256:1: in use") {
+		t.Errorf("Expected web to fail with the failed image, got: %+v", cases[1])
+	}
+	if cases[2].Failure != "access denied" || cases[3].Skipped == "" {
+		t.Errorf("Expected api to fail and legacy to be skipped, got: %+v", cases[2:])
+	}
+}
+
+func TestCleanReport_InLocation(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", Deleted: 2},
+		{Repository: "web", Failures: []FailedDeletion{{Repository: "web", Digest: "sha256:1", Timestamp: at}}},
+	}}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v", err)
+	}
+
+	local := report.InLocation(berlin).(CleanReport)
+	got := local.Repositories[1].Failures[0].Timestamp
+	if got.Format(time.RFC3339) != "2025-03-01T13:00:00+01:00" || !got.Equal(at) {
+		t.Errorf("Expected the same instant shown in Berlin time, got: %s", got.Format(time.RFC3339))
+	}
+	if report.Repositories[1].Failures[0].Timestamp.Location() != time.UTC {
+		t.Errorf("Expected the original report to stay in UTC")
+	}
+}
+
+func TestExecutePlan_LogsBufferedOnError(t *testing.T) {
+	logs := logbuffer.New()
+	client := &mockECRClient{batchDeleteErr: errors.New("fail")}
+	plan := CleanPlan{Repositories: []RepositoryPlan{{Repository: "repo-a", Images: []string{"d1"}}}}
+
+	_, err := ExecutePlan(context.TODO(), client, plan, CleanOptions{Logs: logs})
+	if err == nil {
+		t.Fatalf("Expected error, got nil")
+	}
+
+	// --- the messages of the failed run are still there for the caller's deferred flush ---
+	var out bytes.Buffer
+	if flushErr := logs.Flush(context.TODO(), &out); flushErr != nil {
+		t.Fatalf("Expected no flush error, got: %v", flushErr)
+	}
+	if !strings.Contains(out.String(), "[ERROR] Repository: repo-a") {
+		t.Errorf("Expected the error to be logged on flush, got: %s", out.String())
+	}
+}
+
+func TestTagPatterns_ShouldDelete(t *testing.T) {
+	patterns, err := ParseTagPatterns("release-*, *-pinned", "tmp-*,*-rc")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	tests := []struct {
+		name string
+		tags []string
+		want bool
+	}{
+		{"delete pattern only", []string{"tmp-1"}, true},
+		{"every tag matches a delete pattern", []string{"tmp-1", "v2-rc"}, true},
+		{"tag matches both keep and delete", []string{"release-rc"}, false},
+		{"keep tag on another tag of the image", []string{"tmp-1", "release-1"}, false},
+		{"unmatched tag protects the image", []string{"tmp-1", "latest"}, false},
+		{"no matching tag", []string{"latest"}, false},
+		{"tmp-pinned matches keep and delete", []string{"tmp-pinned"}, false},
+	}
+	for _, tt := range tests {
+		if got := patterns.ShouldDelete(tt.tags); got != tt.want {
+			t.Errorf("%s: ShouldDelete(%v) = %v, want %v", tt.name, tt.tags, got, tt.want)
+		}
+	}
+
+	// --- keep patterns alone never select anything for deletion ---
+	if (TagPatterns{Keep: []string{"*"}}).ShouldDelete([]string{"tmp-1"}) {
+		t.Errorf("Expected no deletion without delete patterns")
+	}
+}
+
+func TestParseTagPatterns_Invalid(t *testing.T) {
+	if _, err := ParseTagPatterns("", "tmp-["); err == nil || !strings.Contains(err.Error(), "tmp-[") {
+		t.Errorf("Expected invalid pattern error, got: %v", err)
+	}
+	patterns, err := ParseTagPatterns(" , ", "")
+	if err != nil || len(patterns.Keep) != 0 || len(patterns.Delete) != 0 {
+		t.Errorf("Expected empty patterns, got: %+v, %v", patterns, err)
+	}
+}
+
+func TestImagesToDeleteWithLogging_TagPatterns(t *testing.T) {
+	ctx := context.TODO()
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("tmp-1")},
+				{ImageDigest: aws.String("d2"), ImageTag: aws.String("tmp-2")},
+				{ImageDigest: aws.String("d2"), ImageTag: aws.String("release-2")},
+				{ImageDigest: aws.String("d3"), ImageTag: aws.String("latest")},
+				{ImageDigest: aws.String("d4")},
+				{ImageDigest: aws.String("d6"), ImageTag: aws.String("tmp-6")},
+			},
+		},
+		// --- d6 is a child of a kept index, so its delete tag does not matter ---
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d6"}]}`)}},
+		},
+	}
+	patterns := TagPatterns{Keep: []string{"release-*"}, Delete: []string{"tmp-*"}}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	images, tagged, untagged, err := imagesToDeleteWithLogging(ctx, "repo", client, CleanOptions{TagPatterns: patterns}, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"d1", "d4"}) {
+		t.Errorf("Expected [d1 d4], got: %v", images)
+	}
+	if tagged != 4 || untagged != 1 {
+		t.Errorf("Expected 4 tagged and 1 untagged, got: %d, %d", tagged, untagged)
+	}
+}
+
+func TestFailures_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failures.json")
+	failures := []FailedDeletion{
+		{Repository: "app", Digest: "sha256:1", FailureCode: "ImageReferencedByManifestList", FailureReason: "referenced", Timestamp: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{Repository: "web", Digest: "sha256:2", FailureCode: "KmsError", FailureReason: "denied", Timestamp: time.Date(2025, 3, 1, 12, 0, 1, 0, time.UTC)},
+	}
+	if err := SaveFailures(path, failures); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	got, err := LoadFailures(path)
+	if err != nil || !reflect.DeepEqual(got, failures) {
+		t.Errorf("Expected %+v, got: %+v, %v", failures, got, err)
+	}
+
+	// --- a run without failures still writes an empty list ---
+	if err := SaveFailures(path, nil); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != "[]" {
+		t.Errorf("Expected an empty list, got: %s", data)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"repository":"app"}]`), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadFailures(path); err == nil {
+		t.Errorf("Expected error for an entry without a digest")
+	}
+}
+
+func TestRetryPlan(t *testing.T) {
+	plan := RetryPlan([]FailedDeletion{
+		{Repository: "web", Digest: "d3"},
+		{Repository: "app", Digest: "d1"},
+		{Repository: "app", Digest: "d2"},
+		{Repository: "app", Digest: "d1", FailureCode: "retried"},
+	}, true)
+	want := CleanPlan{DryRun: true, Repositories: []RepositoryPlan{
+		{Repository: "app", Images: []string{"d1", "d2"}},
+		{Repository: "web", Images: []string{"d3"}},
+	}}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("Expected %+v, got: %+v", want, plan)
+	}
+}
+
+func TestExecutePlan_RecordsFailures(t *testing.T) {
+	client := &mockECRClient{
+		batchDeleteOut: &ecr.BatchDeleteImageOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("d1")}},
+			Failures: []types.ImageFailure{{
+				ImageId:       &types.ImageIdentifier{ImageDigest: aws.String("d2")},
+				FailureCode:   types.ImageFailureCodeImageReferencedByManifestList,
+				FailureReason: aws.String("referenced by an index"),
+			}},
+		},
+	}
+	plan := RetryPlan([]FailedDeletion{{Repository: "app", Digest: "d1"}, {Repository: "app", Digest: "d2"}}, false)
+	report, err := ExecutePlan(context.TODO(), client, plan, CleanOptions{Logs: logbuffer.New()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	failures := report.Failures()
+	if len(failures) != 1 || failures[0].Repository != "app" || failures[0].Digest != "d2" || failures[0].FailureCode != "ImageReferencedByManifestList" || failures[0].Timestamp.IsZero() {
+		t.Errorf("Unexpected failures: %+v", failures)
+	}
+	if report.Repositories[0].Deleted != 1 || report.Repositories[0].Failed != 1 {
+		t.Errorf("Unexpected counts: %+v", report.Repositories[0])
+	}
+}
+
+// --- closes stop during the first BatchDeleteImage call, as a SIGTERM arriving mid-batch would, and deletes every image asked for ---
+type stoppingDeleteClient struct {
+	mockECRClient
+	stop  chan struct{}
+	calls int
+}
+
+func (m *stoppingDeleteClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	m.calls++
+	if m.calls == 1 {
+		close(m.stop)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &ecr.BatchDeleteImageOutput{ImageIds: in.ImageIds}, nil
+}
+
+func TestExecutePlan_Stop(t *testing.T) {
+	client := &stoppingDeleteClient{stop: make(chan struct{})}
+	images := make([]string, 250)
+	for i := range images {
+		images[i] = fmt.Sprintf("sha256:%d", i)
+	}
+	plan := CleanPlan{Repositories: []RepositoryPlan{
+		{Repository: "repo-a", Images: images},
+		{Repository: "repo-b", Images: []string{"sha256:b"}},
+	}}
+	var done []string
+	opts := CleanOptions{Concurrency: 1, Stop: client.stop, Logs: logbuffer.New(), OnRepositoryDone: func(result RepositoryCleanResult) {
+		done = append(done, fmt.Sprintf("%s:%d:%t", result.Repository, result.Deleted, result.Interrupted))
+	}}
+	report, err := ExecutePlan(context.TODO(), client, plan, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// --- the batch in flight completes, no further batch or repository is started ---
+	if client.calls != 1 || !report.Interrupted || !reflect.DeepEqual(done, []string{"repo-a:100:true", "repo-b:0:true"}) {
+		t.Errorf("Expected one completed batch and both repositories interrupted, got %d calls, %v", client.calls, done)
+	}
+	if !strings.Contains(report.Summary(), "stopped before finishing 2 repos") {
+		t.Errorf("Unexpected summary: %s", report.Summary())
+	}
+}
+
+func TestExecutePlan_CallTimeout(t *testing.T) {
+	timeout := &apitimeout.CallTimeoutError{Operation: "ListImages", Timeout: 30 * time.Second, Err: context.DeadlineExceeded}
+	client := &mockECRClient{listImagesErr: timeout}
+	opts := CleanOptions{Logs: logbuffer.New()}
+	plan := PlanEmpty(context.TODO(), client, []string{"app"}, opts)
+	if !plan.Repositories[0].TimedOut || !strings.Contains(plan.Repositories[0].Error, "ListImages call timed out after 30s") {
+		t.Fatalf("Expected the plan to record the call timeout, got: %+v", plan.Repositories[0])
+	}
+	report, _ := ExecutePlan(context.TODO(), client, plan, opts)
+	if !report.Repositories[0].TimedOut || !strings.Contains(report.Summary(), "1 repos failed on a call timeout") {
+		t.Errorf("Expected the report to tell the timeout apart, got: %s, %+v", report.Summary(), report.Repositories[0])
+	}
+}
+
+func TestExecutePlan_RegistryContext(t *testing.T) {
+	client := &mockECRClient{batchDeleteErr: errors.New("access denied")}
+	registry := awsregistry.Registry{Account: "123456789012", Region: "eu-west-1"}
+	plan := RetryPlan([]FailedDeletion{{Repository: "app", Digest: "d1"}}, false)
+	report, err := ExecutePlan(context.TODO(), client, plan, CleanOptions{Logs: logbuffer.New(), Registry: registry})
+	if err == nil || !strings.Contains(err.Error(), "account 123456789012, region eu-west-1") {
+		t.Errorf("Expected error to name the account and region, got: %v", err)
+	}
+	if report.Registry != registry {
+		t.Errorf("Expected report registry %+v, got: %+v", registry, report.Registry)
+	}
+	if !strings.Contains(report.Repositories[0].Error, "account 123456789012, region eu-west-1") {
+		t.Errorf("Expected repository error to name the account and region, got: %q", report.Repositories[0].Error)
+	}
+}
+
+func TestImagesToDeleteWithLogging_OlderThanTag(t *testing.T) {
+	ctx := context.TODO()
+	latestPushed := time.Now().Add(-24 * time.Hour)
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("latest")},
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d3")},
+				{ImageDigest: aws.String("d4")},
+			},
+		},
+		// --- d4 is a child of the latest image ---
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"d4"}]}`)}},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("d1"), ImageTags: []string{"latest"}, ImagePushedAt: aws.Time(latestPushed)},
+				{ImageDigest: aws.String("d2"), ImagePushedAt: aws.Time(latestPushed.Add(-time.Hour))},
+				{ImageDigest: aws.String("d3"), ImagePushedAt: aws.Time(latestPushed.Add(time.Hour))},
+				{ImageDigest: aws.String("d4"), ImagePushedAt: aws.Time(latestPushed.Add(-time.Hour))},
+			},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	images, _, _, err := imagesToDeleteWithLogging(ctx, "repo", client, CleanOptions{OlderThanTag: "latest"}, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// --- d3 was pushed after latest and d4 is its child ---
+	if !reflect.DeepEqual(images, []string{"d2"}) {
+		t.Errorf("Expected [d2], got: %v", images)
+	}
+
+	// --- without the tag nothing is deleted ---
+	client.describeImagesOut = nil
+	client.describeImagesErr = &types.ImageNotFoundException{Message: aws.String("not found")}
+	logMessages = nil
+	images, _, _, err = imagesToDeleteWithLogging(ctx, "repo", client, CleanOptions{OlderThanTag: "latest"}, &logMessages, &mu)
+	if err != nil || len(images) != 0 {
+		t.Errorf("Expected nothing to delete, got: %v, %v", images, err)
+	}
+	if !strings.Contains(strings.Join(logbuffer.Order(logMessages, logbuffer.LogOrderChronological), "\n"), "[WARN] Repository: repo - No image tagged latest") {
+		t.Errorf("Expected a warning about the missing tag, got: %v", logMessages)
+	}
+}
+
+func TestImagesToDeleteWithLogging_PrefixRetention(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Now()
+	pushed := func(hoursAgo int) *time.Time { return aws.Time(now.Add(-time.Duration(hoursAgo) * time.Hour)) }
+	details := []types.ImageDetail{
+		{ImageDigest: aws.String("b1"), ImageTags: []string{"build-1"}, ImagePushedAt: pushed(4)},
+		{ImageDigest: aws.String("b2"), ImageTags: []string{"build-2"}, ImagePushedAt: pushed(3)},
+		{ImageDigest: aws.String("b3"), ImageTags: []string{"build-3"}, ImagePushedAt: pushed(2)},
+		// --- latest has no prefix group, so it protects the oldest build ---
+		{ImageDigest: aws.String("b4"), ImageTags: []string{"build-4", "latest"}, ImagePushedAt: pushed(5)},
+		// --- without a push date the image is kept ---
+		{ImageDigest: aws.String("b5"), ImageTags: []string{"build-5"}},
+		{ImageDigest: aws.String("p0"), ImageTags: []string{"pr-6"}, ImagePushedAt: pushed(10)},
+		{ImageDigest: aws.String("p1"), ImageTags: []string{"pr-7"}, ImagePushedAt: pushed(9)},
+		{ImageDigest: aws.String("p2"), ImageTags: []string{"pr-8"}, ImagePushedAt: pushed(8)},
+		{ImageDigest: aws.String("p3"), ImageTags: []string{"pr-9"}, ImagePushedAt: pushed(1)},
+		// --- the only image of its group ---
+		{ImageDigest: aws.String("r1"), ImageTags: []string{"release-1"}, ImagePushedAt: pushed(100)},
+	}
+	listed := []types.ImageIdentifier{{ImageDigest: aws.String("u1")}}
+	for _, detail := range details {
+		for _, tag := range detail.ImageTags {
+			listed = append(listed, types.ImageIdentifier{ImageDigest: detail.ImageDigest, ImageTag: aws.String(tag)})
+		}
+	}
+	client := &mockECRClient{
+		listImagesOut:     &ecr.ListImagesOutput{ImageIds: listed},
+		batchGetOut:       &ecr.BatchGetImageOutput{Images: []types.Image{{ImageManifest: aws.String(`{"layers":[]}`)}}},
+		describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: details},
+	}
+	opts := CleanOptions{
+		TagPatterns:     TagPatterns{Keep: []string{"pr-6"}},
+		PrefixRetention: PrefixRetention{KeepNewest: 2, Delimiter: "-"},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	images, tagged, untagged, err := imagesToDeleteWithLogging(ctx, "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"b1", "p1", "u1"}) {
+		t.Errorf("Expected [b1 p1 u1], got: %v", images)
+	}
+	if tagged != 10 || untagged != 1 {
+		t.Errorf("Expected 10 tagged and 1 untagged, got: %d, %d", tagged, untagged)
+	}
+	if !strings.Contains(strings.Join(logbuffer.Order(logMessages, logbuffer.LogOrderChronological), "\n"), "Found 2 tagged images older than the newest 2 of their tag prefix group") {
+		t.Errorf("Expected the expired images to be logged, got: %v", logMessages)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), false},
+		{"2024-01-31T10:30:00Z", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC), false},
+		{"31/01/2024", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v, err=%v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFilterCreatedBefore(t *testing.T) {
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := map[string]time.Time{
+		"old":    cutoff.Add(-24 * time.Hour),
+		"new":    cutoff.Add(24 * time.Hour),
+		"exact":  cutoff,
+		"zeroed": {},
+	}
+	got := FilterCreatedBefore([]string{"old", "new", "exact", "zeroed", "unknown"}, created, cutoff)
+	if !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("Expected [old], got %v", got)
+	}
+}
+
+func TestRepositoryCreationTimes(t *testing.T) {
+	createdAt := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	client := &mockECRClient{describeReposOut: &ecr.DescribeRepositoriesOutput{
+		Repositories: []types.Repository{{RepositoryName: aws.String("app"), CreatedAt: aws.Time(createdAt)}},
+	}}
+	created, err := RepositoryCreationTimes(context.TODO(), client)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !created["app"].Equal(createdAt) {
+		t.Errorf("Expected %v, got %v", createdAt, created["app"])
+	}
+}
+
+// --- records DeleteRepository calls and fails for the configured repositories ---
+type deleteRepositoryClient struct {
+	mockECRClient
+	deleted []string
+	fail    map[string]bool
+}
+
+func (m *deleteRepositoryClient) DeleteRepository(ctx context.Context, in *ecr.DeleteRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.DeleteRepositoryOutput, error) {
+	name := aws.ToString(in.RepositoryName)
+	if in.Force {
+		return nil, errors.New("force must not be used")
+	}
+	if m.fail[name] {
+		return nil, &types.RepositoryNotEmptyException{Message: aws.String("not empty")}
+	}
+	m.deleted = append(m.deleted, name)
+	return &ecr.DeleteRepositoryOutput{}, nil
+}
+
+func TestDeleteEmptyRepositories(t *testing.T) {
+	newReport := func() CleanReport {
+		return CleanReport{Repositories: []RepositoryCleanResult{
+			{Repository: "emptied", Untagged: 2, Orphans: 2, Deleted: 2},
+			{Repository: "already-empty"},
+			{Repository: "has-tags", Tagged: 1, Untagged: 1, Orphans: 1, Deleted: 1},
+			{Repository: "children", Untagged: 2, Orphans: 1, Deleted: 1},
+			{Repository: "partial", Untagged: 2, Orphans: 2, Deleted: 1, Failed: 1},
+			{Repository: "skipped", Untagged: 1, Orphans: 1, Skipped: true},
+			{Repository: "errored", Error: "boom"},
+			{Repository: "refilled", Untagged: 1, Orphans: 1, Deleted: 1},
+		}}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		client := &deleteRepositoryClient{}
+		report := newReport()
+		report.Repositories[0].Deleted = 0
+		if err := DeleteEmptyRepositories(context.TODO(), client, &report, true, logbuffer.New()); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if len(client.deleted) != 0 || len(report.DeletedRepositories) != 0 {
+			t.Errorf("Expected nothing deleted in dry run, got %v / %v", client.deleted, report.DeletedRepositories)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		client := &deleteRepositoryClient{fail: map[string]bool{"refilled": true}}
+		report := newReport()
+		err := DeleteEmptyRepositories(context.TODO(), client, &report, false, logbuffer.New())
+		var notEmpty *types.RepositoryNotEmptyException
+		if !errors.As(err, &notEmpty) {
+			t.Errorf("Expected a RepositoryNotEmptyException, got: %v", err)
+		}
+		want := []string{"emptied", "already-empty"}
+		if !reflect.DeepEqual(client.deleted, want) || !reflect.DeepEqual(report.DeletedRepositories, want) {
+			t.Errorf("Expected %v deleted, got %v / %v", want, client.deleted, report.DeletedRepositories)
+		}
+	})
+}
+
+// --- serves a lifecycle policy and image details per repository ---
+type unmanagedMockClient struct {
+	mockECRClient
+	policies map[string]string
+	images   map[string][]types.ImageDetail
+	failing  map[string]bool
+}
+
+func (m *unmanagedMockClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	policy, ok := m.policies[aws.ToString(in.RepositoryName)]
+	if !ok {
+		return nil, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+	}
+	return &ecr.GetLifecyclePolicyOutput{LifecyclePolicyText: aws.String(policy)}, nil
+}
+
+func (m *unmanagedMockClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	repo := aws.ToString(in.RepositoryName)
+	if m.failing[repo] {
+		return nil, errors.New("access denied")
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: m.images[repo]}, nil
+}
+
+func TestFindUnmanaged(t *testing.T) {
+	old := time.Now().AddDate(0, 0, -90)
+	recent := time.Now().AddDate(0, 0, -2)
+	details := func(tagged, untagged int, pushed time.Time) []types.ImageDetail {
+		var out []types.ImageDetail
+		for i := 0; i < tagged; i++ {
+			out = append(out, types.ImageDetail{ImageTags: []string{fmt.Sprintf("v%d", i)}, ImagePushedAt: aws.Time(pushed)})
+		}
+		for i := 0; i < untagged; i++ {
+			out = append(out, types.ImageDetail{ImagePushedAt: aws.Time(pushed.Add(time.Duration(i) * time.Hour))})
+		}
+		return out
+	}
+	expiring := `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}]}`
+	keepTagged := `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPrefixList":["v"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}}]}`
+	client := &unmanagedMockClient{
+		policies: map[string]string{"managed": expiring, "tagged-policy": keepTagged},
+		images: map[string][]types.ImageDetail{
+			"managed":       details(5, 5, old),
+			"tagged-policy": details(2, 4, old),
+			"no-policy":     details(1, 8, old),
+			"small":         details(1, 2, old),
+			"fresh":         details(1, 8, recent),
+			"tags-only":     details(9, 0, old),
+		},
+		failing: map[string]bool{"denied": true},
+	}
+	repos := []string{"managed", "tagged-policy", "no-policy", "small", "fresh", "tags-only", "denied"}
+
+	report := FindUnmanaged(context.TODO(), client, repos, UnmanagedOptions{MinImages: 5, ThresholdDays: 30, Concurrency: 3, Logs: logbuffer.New()})
+	var names []string
+	for _, repo := range report.Repositories {
+		names = append(names, repo.Repository)
+	}
+	if !reflect.DeepEqual(names, []string{"no-policy", "tagged-policy"}) {
+		t.Errorf("Expected no-policy and tagged-policy, largest first, got %v", names)
+	}
+	if first := report.Repositories[0]; first.Images != 9 || first.Untagged != 8 || first.HasPolicy || !first.OldestUntagged.Equal(old) {
+		t.Errorf("Unexpected entry: %+v", first)
+	}
+	if !report.Repositories[1].HasPolicy {
+		t.Errorf("Expected tagged-policy to have a policy: %+v", report.Repositories[1])
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Repository != "denied" || report.Checked != len(repos) {
+		t.Errorf("Unexpected errors: %+v", report.Errors)
+	}
+
+	// --- without an age threshold the image count alone decides ---
+	report = FindUnmanaged(context.TODO(), client, []string{"fresh", "tags-only", "small"}, UnmanagedOptions{MinImages: 5, Logs: logbuffer.New()})
+	names = nil
+	for _, repo := range report.Repositories {
+		names = append(names, repo.Repository)
+	}
+	if !reflect.DeepEqual(names, []string{"fresh", "tags-only"}) {
+		t.Errorf("Expected fresh and tags-only, got %v", names)
+	}
+}
+
+func TestCountByPrefix(t *testing.T) {
+	details := func(tagged, untagged int) []types.ImageDetail {
+		var out []types.ImageDetail
+		for i := 0; i < tagged; i++ {
+			out = append(out, types.ImageDetail{ImageTags: []string{fmt.Sprintf("v%d", i)}})
+		}
+		for i := 0; i < untagged; i++ {
+			out = append(out, types.ImageDetail{})
+		}
+		return out
+	}
+	client := &unmanagedMockClient{
+		images: map[string][]types.ImageDetail{
+			"payments/api":    details(3, 2),
+			"payments/worker": details(1, 4),
+			"search/indexer":  details(6, 4),
+			"legacy":          details(1, 0),
+		},
+		failing: map[string]bool{"search/denied": true},
+	}
+	repos := []string{"payments/api", "payments/worker", "search/indexer", "search/denied", "legacy"}
+
+	report := CountByPrefix(context.TODO(), client, repos, PrefixCountOptions{Concurrency: 2, Logs: logbuffer.New()})
+	expected := []PrefixCount{
+		{Prefix: "payments", Repositories: 2, Images: 10, Untagged: 6},
+		{Prefix: "search", Repositories: 1, Images: 10, Untagged: 4},
+		{Prefix: "legacy", Repositories: 1, Images: 1},
+	}
+	if !reflect.DeepEqual(report.Prefixes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, report.Prefixes)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Repository != "search/denied" {
+		t.Errorf("Unexpected errors: %+v", report.Errors)
+	}
+	if summary := report.Summary(); summary != "Counted 21 images in 4 repos under 3 prefixes, 1 repos could not be counted" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+}
+
+// --- synthetic repository that generates its untagged images page by page instead of holding them ---
+type streamMockClient struct {
+	mockECRClient
+	untagged   int
+	children   []string
+	pushedAt   func(i int) time.Time
+	pages      int
+	pagesAtDel []int
+	deleted    map[string]bool
+	maxBatch   int
+}
+
+const streamPageSize = 1000
+
+func (m *streamMockClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	// --- one tagged index listed under two tags ---
+	return &ecr.ListImagesOutput{ImageIds: []types.ImageIdentifier{
+		{ImageDigest: aws.String("sha256:index"), ImageTag: aws.String("latest")},
+		{ImageDigest: aws.String("sha256:index"), ImageTag: aws.String("v1")},
+	}}, nil
+}
+
+func (m *streamMockClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	var manifests []string
+	for _, child := range m.children {
+		manifests = append(manifests, fmt.Sprintf(`{"digest":%q}`, child))
+	}
+	manifest := fmt.Sprintf(`{"manifests":[%s]}`, strings.Join(manifests, ","))
+	return &ecr.BatchGetImageOutput{Images: []types.Image{{ImageId: &in.ImageIds[0], ImageManifest: aws.String(manifest)}}}, nil
+}
+
+func (m *streamMockClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	start := 0
+	if in.NextToken != nil {
+		start, _ = strconv.Atoi(*in.NextToken)
+	}
+	m.pages++
+	out := &ecr.DescribeImagesOutput{}
+	for i := start; i < start+streamPageSize && i < m.untagged; i++ {
+		out.ImageDetails = append(out.ImageDetails, types.ImageDetail{
+			ImageDigest:   aws.String(fmt.Sprintf("sha256:%064d", i)),
+			ImagePushedAt: aws.Time(m.pushedAt(i)),
+		})
+	}
+	if start+streamPageSize < m.untagged {
+		out.NextToken = aws.String(strconv.Itoa(start + streamPageSize))
+	}
+	return out, nil
+}
+
+func (m *streamMockClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	m.pagesAtDel = append(m.pagesAtDel, m.pages)
+	m.maxBatch = max(m.maxBatch, len(in.ImageIds))
+	for _, id := range in.ImageIds {
+		m.deleted[aws.ToString(id.ImageDigest)] = true
+	}
+	return &ecr.BatchDeleteImageOutput{ImageIds: in.ImageIds}, nil
+}
+
+func newStreamMockClient(untagged int) *streamMockClient {
+	old := time.Now().Add(-48 * time.Hour)
+	return &streamMockClient{
+		untagged: untagged,
+		children: []string{fmt.Sprintf("sha256:%064d", 3), fmt.Sprintf("sha256:%064d", untagged-1)},
+		pushedAt: func(int) time.Time { return old },
+		deleted:  map[string]bool{},
+	}
+}
+
+func TestStreamCleanup_LargeRepository(t *testing.T) {
+	const untagged = 250_000
+	client := newStreamMockClient(untagged)
+	report, err := StreamCleanup(context.TODO(), client, []string{"huge"}, CleanOptions{Logs: logbuffer.New()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	result := report.Repositories[0]
+	if result.Tagged != 1 || result.Untagged != untagged || result.Orphans != untagged-2 || result.Deleted != untagged-2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	for _, child := range client.children {
+		if client.deleted[child] {
+			t.Errorf("Child %s of the tagged index was deleted", child)
+		}
+	}
+	if client.maxBatch != deleteBatchSize {
+		t.Errorf("Expected batches of %d, got up to %d", deleteBatchSize, client.maxBatch)
+	}
+	// --- deletions start while the first page is being processed, long before the last page is read ---
+	if len(client.pagesAtDel) == 0 || client.pagesAtDel[0] != 1 {
+		t.Errorf("Expected the first deletion after the first page, got pages %v", client.pagesAtDel[:min(3, len(client.pagesAtDel))])
+	}
+}
+
+func TestStreamCleanup_DryRunAndMinAge(t *testing.T) {
+	client := newStreamMockClient(2500)
+	now := time.Now()
+	// --- every other image is recent ---
+	client.pushedAt = func(i int) time.Time {
+		if i%2 == 0 {
+			return now
+		}
+		return now.Add(-48 * time.Hour)
+	}
+	opts := CleanOptions{DryRun: true, MinAge: 24 * time.Hour, WarnAboveCount: 1000, Logs: logbuffer.New()}
+	report, err := StreamCleanup(context.TODO(), client, []string{"app"}, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	result := report.Repositories[0]
+	// --- the 1250 old images at odd indexes, minus the children at 3 and 2499 ---
+	if result.Orphans != 1248 || result.Deleted != 0 || len(client.deleted) != 0 || !result.ImageCountWarn {
+		t.Errorf("Unexpected dry run result: %+v, deleted %d", result, len(client.deleted))
+	}
+}
+
+func BenchmarkStreamCleanup(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		client := newStreamMockClient(100_000)
+		if _, err := StreamCleanup(context.TODO(), client, []string{"huge"}, CleanOptions{DryRun: true, Logs: logbuffer.New()}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// --- lists per-repository images, a repository in blocked waits until release is closed ---
+type repositoryImagesClient struct {
+	mockECRClient
+	images  map[string][]types.ImageIdentifier
+	blocked string
+	release chan struct{}
+}
+
+func (m *repositoryImagesClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	repo := aws.ToString(in.RepositoryName)
+	if repo == m.blocked {
+		select {
+		case <-m.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ids, ok := m.images[repo]
+	if !ok {
+		return nil, errors.New("repository not found")
+	}
+	return &ecr.ListImagesOutput{ImageIds: ids}, nil
+}
+
+func TestGetImagesForAllRepositories(t *testing.T) {
+	client := &repositoryImagesClient{
+		images: map[string][]types.ImageIdentifier{
+			"app": {
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("v1")},
+				{ImageDigest: aws.String("d2")},
+				{ImageDigest: aws.String("d1"), ImageTag: aws.String("latest")},
+			},
+			"web":  {{ImageDigest: aws.String("d3"), ImageTag: aws.String("v3")}},
+			"slow": {{ImageDigest: aws.String("d4")}},
+		},
+		blocked: "slow",
+		release: make(chan struct{}),
+	}
+	results := GetImagesForAllRepositories(context.TODO(), []string{"slow", "app", "web", "missing"}, client, 2)
+
+	// --- the other repositories arrive while slow is still being listed ---
+	got := map[string]RepositoryImages{}
+	for len(got) < 3 {
+		result := <-results
+		got[result.Repository] = result
+	}
+	if _, ok := got["slow"]; ok {
+		t.Fatalf("Expected slow to arrive last, got: %+v", got)
+	}
+	close(client.release)
+	for result := range results {
+		got[result.Repository] = result
+	}
+
+	want := map[string][]Image{
+		"app":  {{Digest: "d1", Tags: []string{"v1", "latest"}}, {Digest: "d2"}},
+		"web":  {{Digest: "d3", Tags: []string{"v3"}}},
+		"slow": {{Digest: "d4"}},
+	}
+	if len(got) != 4 {
+		t.Fatalf("Expected every repository once, got: %+v", got)
+	}
+	for repo, images := range want {
+		if got[repo].Err != nil || !reflect.DeepEqual(got[repo].Images, images) {
+			t.Errorf("%s: expected %+v, got: %+v", repo, images, got[repo])
+		}
+	}
+	if got["missing"].Err == nil || got["missing"].Images != nil {
+		t.Errorf("Expected an error for missing, got: %+v", got["missing"])
+	}
+}
+
+func TestGetImagesForAllRepositories_Cancel(t *testing.T) {
+	client := &repositoryImagesClient{
+		images: map[string][]types.ImageIdentifier{"app": {{ImageDigest: aws.String("d1")}}, "web": {{ImageDigest: aws.String("d2")}}},
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	results := GetImagesForAllRepositories(ctx, []string{"app", "web"}, client, 0)
+	<-results
+	cancel()
+
+	// --- after cancelling the remaining results are dropped and the channel is closed ---
+	done := make(chan struct{})
+	go func() {
+		for range results {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the channel to be closed after cancelling")
+	}
+}
+
+func TestImage_IsArtifact(t *testing.T) {
+	cases := []struct {
+		image Image
+		want  bool
+	}{
+		{Image{MediaType: OCIArtifactManifestMediaType}, true},
+		{Image{MediaType: "application/vnd.oci.image.manifest.v1+json", ArtifactType: "application/spdx+json"}, true},
+		{Image{MediaType: "application/vnd.oci.image.manifest.v1+json", ArtifactType: "application/vnd.oci.image.config.v1+json"}, false},
+		{Image{MediaType: "application/vnd.docker.distribution.manifest.v2+json", ArtifactType: "application/vnd.docker.container.image.v1+json"}, false},
+		{Image{MediaType: "application/vnd.oci.image.index.v1+json"}, false},
+		{Image{}, false},
+	}
+	for _, c := range cases {
+		if got := c.image.IsArtifact(); got != c.want {
+			t.Errorf("IsArtifact(%+v) = %v, want %v", c.image, got, c.want)
+		}
+	}
+}
+
+// --- manifestClient returns the manifest stored for each requested digest ---
+type manifestClient struct {
+	mockECRClient
+	manifests map[string]string
+}
+
+func (m *manifestClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	out := &ecr.BatchGetImageOutput{}
+	for _, id := range in.ImageIds {
+		if manifest, ok := m.manifests[aws.ToString(id.ImageDigest)]; ok {
+			out.Images = append(out.Images, types.Image{ImageId: &types.ImageIdentifier{ImageDigest: id.ImageDigest}, ImageManifest: aws.String(manifest)})
+		}
+	}
+	return out, nil
+}
+
+func TestImagesToDeleteWithLogging_KeepsArtifacts(t *testing.T) {
+	client := &manifestClient{
+		mockECRClient: mockECRClient{
+			listImagesOut: &ecr.ListImagesOutput{
+				ImageIds: []types.ImageIdentifier{
+					{ImageDigest: aws.String("image")},
+					{ImageDigest: aws.String("artifact")},
+					{ImageDigest: aws.String("sbom")},
+				},
+			},
+		},
+		manifests: map[string]string{
+			"image":    `{"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json"},"layers":[]}`,
+			"artifact": `{"mediaType":"application/vnd.oci.artifact.manifest.v1+json","artifactType":"application/vnd.dev.cosign.artifact.sig.v1+json"}`,
+			"sbom":     `{"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/spdx+json"},"layers":[]}`,
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{}, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"image"}) {
+		t.Errorf("Expected only the runnable image to be deleted, got: %v", images)
+	}
+	found := false
+	for _, entry := range logMessages {
+		if strings.Contains(entry.Message, "Keeping 2 untagged OCI artifacts") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the kept artifacts to be logged, got: %+v", logMessages)
+	}
+}
+
+func TestCleanReport_TotalAPICallsEstimate(t *testing.T) {
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", APICalls: PaginationStats{ListImagesPages: 3, BatchGetImageCalls: 2, BatchDeleteImageCalls: 1}},
+		{Repository: "web", APICalls: PaginationStats{ListImagesPages: 1, DescribeImagesCalls: 4}},
+	}}
+	if got := report.TotalAPICallsEstimate(); got != 11 {
+		t.Errorf("Expected 11 API calls, got: %d", got)
+	}
+}
+
+func TestCleanReport_RepositoryOutcomes(t *testing.T) {
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", Deleted: 2},
+		{Repository: "web", Error: "access denied"},
+		{Repository: "api", Deleted: 1, Failed: 1},
+		{Repository: "batch", Skipped: true},
+		{Repository: "jobs", Interrupted: true},
+	}}
+	failed, processed := report.RepositoryOutcomes()
+	if failed != 2 || processed != 3 {
+		t.Errorf("Expected 2 of 3 repositories failed, got %d of %d", failed, processed)
+	}
+}
+
+func TestCleanReport_ReclaimedBytes(t *testing.T) {
+	plan := CleanPlan{Repositories: []RepositoryPlan{
+		{Repository: "app", Images: []string{"a1", "a2"}},
+		{Repository: "web", Images: []string{"w1", "w2", "w3", "w4"}},
+		{Repository: "api", Images: []string{"p1"}},
+		{Repository: "batch", Images: []string{"b1"}},
+	}}
+	sizes := map[string]int64{"app": 200, "web": 400, "api": 100, "batch": 50}
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", Deleted: 2},
+		{Repository: "web", Deleted: 1, Failed: 3},
+		{Repository: "api", Error: "access denied"},
+		{Repository: "batch", Skipped: true},
+	}}
+	if got := report.ReclaimedBytes(plan, sizes); got != 300 {
+		t.Errorf("Expected 300 bytes reclaimed, got: %d", got)
+	}
+
+	report.DryRun = true
+	if got := report.ReclaimedBytes(plan, sizes); got != 600 {
+		t.Errorf("Expected a dry run to count the whole plan, got: %d", got)
+	}
+}
+
+func TestImagesToDeleteWithLogging_PushedSince(t *testing.T) {
+	lastRun := time.Now().Add(-24 * time.Hour)
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("old")},
+				{ImageDigest: aws.String("new")},
+				{ImageDigest: aws.String("unknown")},
+			},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("old"), ImagePushedAt: aws.Time(lastRun.Add(-time.Hour))},
+				{ImageDigest: aws.String("new"), ImagePushedAt: aws.Time(lastRun.Add(time.Hour))},
+				{ImageDigest: aws.String("unknown")},
+			},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+
+	// --- only orphans pushed since the last run are evaluated ---
+	opts := CleanOptions{PushedSince: map[string]time.Time{"repo": lastRun}}
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"new"}) {
+		t.Errorf("Expected [new], got: %v", images)
+	}
+
+	// --- a repository without a recorded run is scanned in full ---
+	images, _, _, err = imagesToDeleteWithLogging(context.TODO(), "other", client, opts, &logMessages, &mu)
+	if err != nil || len(images) != 3 {
+		t.Errorf("Expected all 3 orphans, got: %v, %v", images, err)
+	}
+}
+
+// --- pagedDescribeClient serves DescribeImages in pages linked by NextToken, failing the page named by failToken ---
+type pagedDescribeClient struct {
+	mockECRClient
+	pages      [][]types.ImageDetail
+	failToken  string
+	calls      int
+	maxResults []int32
+}
+
+func (m *pagedDescribeClient) DescribeImages(ctx context.Context, in *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	m.calls++
+	m.maxResults = append(m.maxResults, aws.ToInt32(in.MaxResults))
+	token := aws.ToString(in.NextToken)
+	if token != "" && token == m.failToken {
+		return nil, errors.New("throttled")
+	}
+	i := 0
+	if token != "" {
+		i, _ = strconv.Atoi(token)
+	}
+	out := &ecr.DescribeImagesOutput{ImageDetails: m.pages[i]}
+	if i+1 < len(m.pages) {
+		out.NextToken = aws.String(strconv.Itoa(i + 1))
+	}
+	return out, nil
+}
+
+func TestDescribeImagesPaginator_All(t *testing.T) {
+	pushed := time.Now().Add(-48 * time.Hour)
+	client := &pagedDescribeClient{pages: [][]types.ImageDetail{
+		{{ImageDigest: aws.String("d1"), ImagePushedAt: aws.Time(pushed)}},
+		{{ImageDigest: aws.String("d2"), ImagePushedAt: aws.Time(pushed)}},
+		{{ImageDigest: aws.String("d3"), ImagePushedAt: aws.Time(time.Now())}},
+	}}
+
+	// --- images on later pages are filtered like those on the first ---
+	kept, err := filterPushedBefore(context.TODO(), "repo", []string{"d1", "d2", "d3"}, time.Now().Add(-time.Hour), types.TagStatusUntagged, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(kept, []string{"d1", "d2"}) {
+		t.Errorf("Expected [d1 d2], got: %v", kept)
+	}
+	if client.calls != 3 || !reflect.DeepEqual(client.maxResults, []int32{DescribeImagesMaxResults, DescribeImagesMaxResults, DescribeImagesMaxResults}) {
+		t.Errorf("Expected 3 calls with the largest page size, got: %d calls, %v", client.calls, client.maxResults)
+	}
+
+	// --- a failed later page returns no partial result ---
+	client = &pagedDescribeClient{pages: client.pages, failToken: "2"}
+	details, err := NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{RepositoryName: aws.String("repo")}).All(context.TODO())
+	if err == nil || !strings.Contains(err.Error(), "failed to describe images for repository repo") || details != nil {
+		t.Errorf("Expected the failed page to fail the listing, got: %v, %v", details, err)
+	}
+}
+
+func TestCleanReport_TableAndRecords(t *testing.T) {
+	report := CleanReport{Repositories: []RepositoryCleanResult{
+		{Repository: "app", Tagged: 2, Untagged: 3, Orphans: 3, Deleted: 3},
+		{Repository: "web", Error: "access denied"},
+	}}
+	headers, rows := report.Table()
+	if len(headers) != len(rows[0]) {
+		t.Fatalf("Expected one value per header, got: %v and %v", headers, rows[0])
+	}
+	want := [][]string{
+		{"app", "2", "3", "3", "3", "0", "false", ""},
+		{"web", "0", "0", "0", "0", "0", "false", "access denied"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Expected %v, got: %v", want, rows)
+	}
+	if records := report.Records(); len(records) != 2 || records[1].(RepositoryCleanResult).Repository != "web" {
+		t.Errorf("Expected one record per repository, got: %v", records)
+	}
+}
+
+func TestDateRangeFilter(t *testing.T) {
+	jan10 := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	jan15 := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		filter DateRangeFilter
+		in     []time.Time
+		out    []time.Time
+	}{
+		{"both bounds", DateRangeFilter{After: jan10, Before: jan15}, []time.Time{jan10, jan10.Add(72 * time.Hour)}, []time.Time{jan10.Add(-time.Second), jan15}},
+		{"only after", DateRangeFilter{After: jan10}, []time.Time{jan10, jan15.AddDate(1, 0, 0)}, []time.Time{jan10.Add(-time.Second)}},
+		{"only before", DateRangeFilter{Before: jan15}, []time.Time{jan10.AddDate(-1, 0, 0), jan15.Add(-time.Second)}, []time.Time{jan15}},
+	}
+	for _, c := range cases {
+		for _, pushed := range c.in {
+			if !c.filter.Contains(pushed) {
+				t.Errorf("%s: expected %s to be in range", c.name, pushed)
+			}
+		}
+		for _, pushed := range c.out {
+			if c.filter.Contains(pushed) {
+				t.Errorf("%s: expected %s to be out of range", c.name, pushed)
+			}
+		}
+	}
+	if err := (DateRangeFilter{After: jan15, Before: jan10}).Validate(); err == nil {
+		t.Errorf("Expected reversed bounds to be rejected")
+	}
+	if !(DateRangeFilter{}).IsZero() || (DateRangeFilter{Before: jan15}).IsZero() {
+		t.Errorf("Unexpected IsZero results")
+	}
+}
+
+func TestImagesToDeleteWithLogging_PushedRange(t *testing.T) {
+	jan10 := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("before")},
+				{ImageDigest: aws.String("inside")},
+				{ImageDigest: aws.String("after")},
+				{ImageDigest: aws.String("unknown")},
+			},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("before"), ImagePushedAt: aws.Time(jan10.Add(-time.Hour))},
+				{ImageDigest: aws.String("inside"), ImagePushedAt: aws.Time(jan10.Add(48 * time.Hour))},
+				{ImageDigest: aws.String("after"), ImagePushedAt: aws.Time(jan10.AddDate(0, 0, 10))},
+				{ImageDigest: aws.String("unknown")},
+			},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	opts := CleanOptions{PushedRange: DateRangeFilter{After: jan10, Before: jan10.AddDate(0, 0, 5)}}
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"inside"}) {
+		t.Errorf("Expected [inside], got: %v", images)
+	}
+}
+
+func TestImagesToDeleteWithLogging_PushedRangeKeepsChildren(t *testing.T) {
+	jan10 := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("index"), ImageTag: aws.String("release-1")},
+				{ImageDigest: aws.String("c1")},
+				{ImageDigest: aws.String("c2")},
+			},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("index"), ImagePushedAt: aws.Time(jan10.AddDate(0, 0, 10))},
+				{ImageDigest: aws.String("c1"), ImagePushedAt: aws.Time(jan10.Add(48 * time.Hour))},
+				{ImageDigest: aws.String("c2"), ImagePushedAt: aws.Time(jan10.Add(48 * time.Hour))},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("index")}, ImageManifest: aws.String(`{"manifests":[{"digest":"c1"},{"digest":"c2"}]}`)}},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	// --- the index matches the delete pattern but was pushed after the range, so it is kept and so are its children ---
+	opts := CleanOptions{TagPatterns: TagPatterns{Delete: []string{"release-*"}}, PushedRange: DateRangeFilter{After: jan10, Before: jan10.AddDate(0, 0, 5)}}
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(images) != 0 {
+		t.Errorf("Expected the kept index to protect its children, got: %v", images)
+	}
+}
+
+func TestParseRepoTagOverride(t *testing.T) {
+	override, err := ParseRepoTagOverride([]types.Tag{
+		{Key: aws.String("team"), Value: aws.String("payments")},
+		{Key: aws.String(SkipTagKey), Value: aws.String("true")},
+		{Key: aws.String(MinAgeTagKey), Value: aws.String("90d")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := RepoTagOverride{Skip: true, MinAge: 90 * 24 * time.Hour, HasMinAge: true}
+	if override != want {
+		t.Errorf("Expected %+v, got: %+v", want, override)
+	}
+	for _, tag := range []types.Tag{
+		{Key: aws.String(SkipTagKey), Value: aws.String("maybe")},
+		{Key: aws.String(MinAgeTagKey), Value: aws.String("soon")},
+	} {
+		if _, err := ParseRepoTagOverride([]types.Tag{tag}); err == nil {
+			t.Errorf("Expected an error for %s=%s", aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+	}
+}
+
+func TestPlanCleanup_RepoTagOverrides(t *testing.T) {
+	now := time.Now()
+	client := &mockECRClient{
+		describeReposOut: &ecr.DescribeRepositoriesOutput{
+			Repositories: []types.Repository{{RepositoryName: aws.String("repo-a"), RepositoryArn: aws.String("arn:aws:ecr:eu-west-1:123456789012:repository/repo-a")}},
+		},
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("recent")},
+				{ImageDigest: aws.String("old")},
+			},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("recent"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -1))},
+				{ImageDigest: aws.String("old"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -30))},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{},
+		listTagsOut: &ecr.ListTagsForResourceOutput{
+			Tags: []types.Tag{{Key: aws.String(MinAgeTagKey), Value: aws.String("7d")}},
+		},
+	}
+
+	// --- the tag's minimum age applies even though none was configured ---
+	plan := PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, RepoTagOverrides: true})
+	if got := plan.Repositories[0].Images; !reflect.DeepEqual(got, []string{"old"}) {
+		t.Errorf("Expected [old], got: %v", got)
+	}
+
+	// --- without the option the tags are never read ---
+	plan = PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true})
+	if got := plan.Repositories[0].Images; len(got) != 2 {
+		t.Errorf("Expected both images, got: %v", got)
+	}
+
+	client.listTagsOut.Tags = []types.Tag{{Key: aws.String(SkipTagKey), Value: aws.String("true")}}
+	plan = PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, RepoTagOverrides: true})
+	if entry := plan.Repositories[0]; !entry.Skipped || len(entry.Images) != 0 {
+		t.Errorf("Expected the repository to be skipped, got: %+v", entry)
+	}
+
+	// --- a failed lookup warns and cleans the repository as configured ---
+	client.listTagsErr = errors.New("access denied")
+	logs := logbuffer.New()
+	plan = PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, RepoTagOverrides: true, Logs: logs})
+	if entry := plan.Repositories[0]; entry.Skipped || len(entry.Images) != 2 {
+		t.Errorf("Expected the repository to be cleaned as configured, got: %+v", entry)
+	}
+	var out bytes.Buffer
+	if err := logs.Flush(context.TODO(), &out); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(out.String(), "[WARN] Repository: repo-a - Could not apply repository tag overrides") {
+		t.Errorf("Expected tag lookup warning, got: %s", out.String())
+	}
+}
+
+func TestReclaimableSizes(t *testing.T) {
+	client := &pagedDescribeClient{pages: [][]types.ImageDetail{
+		{{ImageDigest: aws.String("d1"), ImageSizeInBytes: aws.Int64(100)}, {ImageDigest: aws.String("kept"), ImageSizeInBytes: aws.Int64(1000)}},
+		{{ImageDigest: aws.String("d2"), ImageSizeInBytes: aws.Int64(50)}},
+	}}
+	plan := CleanPlan{Repositories: []RepositoryPlan{
+		{Repository: "app", Images: []string{"d1", "d2"}},
+		{Repository: "idle"},
+	}}
+
+	// --- only planned images count, repositories with nothing to delete are not described ---
+	sizes, errs := ReclaimableSizes(context.TODO(), client, plan, 1)
+	if len(errs) != 0 {
+		t.Fatalf("Expected no errors, got: %v", errs)
+	}
+	if !reflect.DeepEqual(sizes, map[string]int64{"app": 150}) {
+		t.Errorf("Expected app to reclaim 150 bytes, got: %v", sizes)
+	}
+	if client.calls != 2 {
+		t.Errorf("Expected 2 DescribeImages calls, got: %d", client.calls)
+	}
+
+	// --- a failed listing leaves the repository out ---
+	client = &pagedDescribeClient{pages: client.pages, failToken: "1"}
+	sizes, errs = ReclaimableSizes(context.TODO(), client, plan, 1)
+	if len(errs) != 1 || len(sizes) != 0 {
+		t.Errorf("Expected one error and no sizes, got: %v, %v", sizes, errs)
+	}
+}
+
+// --- holds each BatchGetImage call until want calls are in flight or wait passes, one second when unset ---
+// --- answers with an index listing a child of the batch's first image ---
+type concurrentBatchGetClient struct {
+	mockECRClient
+	want     int
+	wait     time.Duration
+	mu       sync.Mutex
+	calls    int
+	inFlight int
+	peak     int
+	ready    chan struct{}
+	once     sync.Once
+}
+
+func (m *concurrentBatchGetClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	m.mu.Lock()
+	m.calls++
+	m.inFlight++
+	m.peak = max(m.peak, m.inFlight)
+	if m.inFlight == m.want {
+		m.once.Do(func() { close(m.ready) })
+	}
+	m.mu.Unlock()
+	wait := m.wait
+	if wait == 0 {
+		wait = time.Second
+	}
+	select {
+	case <-m.ready:
+	case <-time.After(wait):
+	}
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+
+	parent := aws.ToString(in.ImageIds[0].ImageDigest)
+	manifest := fmt.Sprintf(`{"manifests": [{"digest": "child-of-%s"}]}`, parent)
+	return &ecr.BatchGetImageOutput{Images: []types.Image{{ImageManifest: aws.String(manifest)}}}, nil
+}
+
+func TestGetChildImagesConcurrently(t *testing.T) {
+	tagged := make([]string, 300)
+	for i := range tagged {
+		tagged[i] = fmt.Sprintf("d%03d", i)
+	}
+	client := &concurrentBatchGetClient{want: 3, ready: make(chan struct{})}
+
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	children, _, err := getChildImagesConcurrently(context.TODO(), "repo", tagged, client, 3, nil, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if client.calls != 3 || client.peak != 3 {
+		t.Errorf("Expected 3 BatchGetImage calls in flight at once, got: %d calls, peak %d", client.calls, client.peak)
+	}
+	sort.Strings(children)
+	if want := []string{"child-of-d000", "child-of-d100", "child-of-d200"}; !reflect.DeepEqual(children, want) {
+		t.Errorf("Expected %v, got: %v", want, children)
+	}
+
+	// --- the limit bounds the calls in flight ---
+	client = &concurrentBatchGetClient{want: 1, ready: make(chan struct{})}
+	if _, _, err := getChildImagesConcurrently(context.TODO(), "repo", tagged, client, 1, nil, &logMessages, &mu); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if client.calls != 3 || client.peak != 1 {
+		t.Errorf("Expected 3 sequential BatchGetImage calls, got: %d calls, peak %d", client.calls, client.peak)
+	}
+
+	// --- the repository concurrency does not raise the calls in flight above the fixed per-repository limit ---
+	var ids []types.ImageIdentifier
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, types.ImageIdentifier{ImageDigest: aws.String(fmt.Sprintf("d%04d", i)), ImageTag: aws.String(fmt.Sprintf("v%d", i))})
+	}
+	client = &concurrentBatchGetClient{mockECRClient: mockECRClient{listImagesOut: &ecr.ListImagesOutput{ImageIds: ids}}, want: childLookupConcurrency + 1, wait: 50 * time.Millisecond, ready: make(chan struct{})}
+	if _, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{Concurrency: 50}, &logMessages, &mu); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if client.calls != 10 || client.peak != childLookupConcurrency {
+		t.Errorf("Expected 10 BatchGetImage calls, at most %d in flight, got: %d calls, peak %d", childLookupConcurrency, client.calls, client.peak)
+	}
+}
+
+func TestImagesToDeleteWithLogging_ProtectedDigests(t *testing.T) {
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("index")},
+				{ImageDigest: aws.String("child")},
+				{ImageDigest: aws.String("stale")},
+			},
+		},
+		// --- the protected index is untagged, its child must survive with it ---
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[{"digest":"child"}]}`)}},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	opts := CleanOptions{ProtectedDigests: map[string]struct{}{"index": {}}}
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"stale"}) {
+		t.Errorf("Expected [stale], got: %v", images)
+	}
+}
+
+func TestImagesToDeleteWithLogging_DropPlatforms(t *testing.T) {
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("index"), ImageTag: aws.String("v1")},
+				{ImageDigest: aws.String("amd64")},
+				{ImageDigest: aws.String("armv7")},
+				{ImageDigest: aws.String("armv6")},
+				{ImageDigest: aws.String("attestation")},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageManifest: aws.String(`{"manifests":[
+				{"digest":"amd64","platform":{"os":"linux","architecture":"amd64"}},
+				{"digest":"armv7","platform":{"os":"linux","architecture":"arm","variant":"v7"}},
+				{"digest":"armv6","platform":{"os":"linux","architecture":"arm","variant":"v6"}},
+				{"digest":"attestation","platform":{"os":"unknown","architecture":"unknown"}}
+			]}`)}},
+		},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	drop, err := ParsePlatforms([]string{"linux/arm/v7"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{DropPlatforms: drop}, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"armv7"}) {
+		t.Errorf("Expected only the linux/arm/v7 child, got: %v", images)
+	}
+
+	// --- a platform without variant drops every variant ---
+	drop, _ = ParsePlatforms([]string{"linux/arm"})
+	images, _, _, _ = imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{DropPlatforms: drop}, &logMessages, &mu)
+	if !reflect.DeepEqual(images, []string{"armv7", "armv6"}) {
+		t.Errorf("Expected both arm children, got: %v", images)
+	}
+}
+
+func TestParsePlatforms(t *testing.T) {
+	platforms, err := ParsePlatforms([]string{"linux/arm/v7", " linux/386 "})
+	if err != nil || !reflect.DeepEqual(platforms, []Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}, {OS: "linux", Architecture: "386"}}) {
+		t.Errorf("Unexpected platforms: %+v, %v", platforms, err)
+	}
+	if platforms[0].String() != "linux/arm/v7" {
+		t.Errorf("Unexpected string: %s", platforms[0])
+	}
+	for _, value := range []string{"linux", "linux//v7", "linux/arm/v7/extra"} {
+		if _, err := ParsePlatforms([]string{value}); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestNotPulledFilter_Selects(t *testing.T) {
+	now := time.Now()
+	filter := NotPulledFilter{Since: 30 * 24 * time.Hour}
+	tests := []struct {
+		name   string
+		detail types.ImageDetail
+		want   bool
+	}{
+		{"pulled long ago", types.ImageDetail{LastRecordedPullTime: aws.Time(now.AddDate(0, 0, -60)), ImagePushedAt: aws.Time(now.AddDate(0, 0, -90))}, true},
+		{"pulled recently", types.ImageDetail{LastRecordedPullTime: aws.Time(now.AddDate(0, 0, -1)), ImagePushedAt: aws.Time(now.AddDate(0, 0, -90))}, false},
+		{"never pulled, old push", types.ImageDetail{ImagePushedAt: aws.Time(now.AddDate(0, 0, -90))}, true},
+		{"never pulled, fresh push", types.ImageDetail{ImagePushedAt: aws.Time(now.Add(-time.Hour))}, false},
+		{"no dates", types.ImageDetail{}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.Selects(tt.detail, now); got != tt.want {
+			t.Errorf("%s: Selects() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImagesToDeleteWithLogging_NotPulled(t *testing.T) {
+	now := time.Now()
+	stale, fresh := aws.Time(now.AddDate(0, 0, -60)), aws.Time(now.AddDate(0, 0, -1))
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("old-build"), ImageTag: aws.String("build-1")},
+				{ImageDigest: aws.String("release"), ImageTag: aws.String("release-1")},
+				{ImageDigest: aws.String("in-use"), ImageTag: aws.String("build-2")},
+				{ImageDigest: aws.String("unpulled")},
+				{ImageDigest: aws.String("pulled")},
+			},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("old-build"), ImageTags: []string{"build-1"}, LastRecordedPullTime: stale},
+				{ImageDigest: aws.String("release"), ImageTags: []string{"release-1"}, LastRecordedPullTime: stale},
+				{ImageDigest: aws.String("in-use"), ImageTags: []string{"build-2"}, LastRecordedPullTime: fresh},
+				{ImageDigest: aws.String("unpulled"), ImagePushedAt: stale},
+				{ImageDigest: aws.String("pulled"), ImagePushedAt: stale, LastRecordedPullTime: fresh},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	filter := NotPulledFilter{Since: 30 * 24 * time.Hour}
+
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{NotPulled: filter}, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{"unpulled"}) {
+		t.Errorf("Expected [unpulled], got: %v", images)
+	}
+
+	// --- tagged images not pulled are deleted too, unless a keep pattern protects them ---
+	filter.IncludeTagged = true
+	opts := CleanOptions{NotPulled: filter, TagPatterns: TagPatterns{Keep: []string{"release-*"}}}
+	images, _, _, err = imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	sort.Strings(images)
+	if !reflect.DeepEqual(images, []string{"old-build", "unpulled"}) {
+		t.Errorf("Expected [old-build unpulled], got: %v", images)
+	}
+}
+
+func TestClassifyOrphans(t *testing.T) {
+	client := &mockECRClient{describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: []types.ImageDetail{
+		{ImageDigest: aws.String("moved"), LastRecordedPullTime: aws.Time(time.Now().AddDate(0, 0, -3))},
+		{ImageDigest: aws.String("cache")},
+		{ImageDigest: aws.String("arm64"), LastRecordedPullTime: aws.Time(time.Now())},
+	}}}
+	orphans := []Image{{Digest: "moved"}, {Digest: "cache"}, {Digest: "arm64"}}
+
+	classes, err := ClassifyOrphans(context.Background(), "repo", orphans, []string{"arm64"}, client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]OrphanClass{"moved": OrphanTagMoved, "cache": OrphanNeverTagged, "arm64": OrphanMultiArchChild}
+	if !reflect.DeepEqual(classes, want) {
+		t.Errorf("expected %v, got %v", want, classes)
+	}
+
+	client.describeImagesErr = errors.New("throttled")
+	if _, err := ClassifyOrphans(context.Background(), "repo", orphans, nil, client); err == nil {
+		t.Error("expected the DescribeImages error to be returned")
+	}
+}
+
+type inspectMockClient struct {
+	mockECRClient
+	manifests map[string]string
+}
+
+func (m *inspectMockClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	out := &ecr.BatchGetImageOutput{}
+	for _, id := range in.ImageIds {
+		manifest, ok := m.manifests[aws.ToString(id.ImageDigest)]
+		if !ok {
+			manifest = `{"layers":[]}`
+		}
+		out.Images = append(out.Images, types.Image{ImageId: &id, ImageManifest: aws.String(manifest)})
+	}
+	return out, nil
+}
+
+func TestInspectOrphans(t *testing.T) {
+	client := &inspectMockClient{
+		mockECRClient: mockECRClient{
+			listImagesOut: &ecr.ListImagesOutput{ImageIds: []types.ImageIdentifier{
+				{ImageDigest: aws.String("release"), ImageTag: aws.String("v1")},
+				{ImageDigest: aws.String("release-amd64")},
+				{ImageDigest: aws.String("old-index")},
+				{ImageDigest: aws.String("old-amd64")},
+				{ImageDigest: aws.String("old-v0")},
+				{ImageDigest: aws.String("cache")},
+			}},
+			describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("old-index"), LastRecordedPullTime: aws.Time(time.Now())},
+				{ImageDigest: aws.String("old-v0"), LastRecordedPullTime: aws.Time(time.Now())},
+				{ImageDigest: aws.String("cache")},
+			}},
+		},
+		manifests: map[string]string{
+			"release":   `{"manifests":[{"digest":"release-amd64"}]}`,
+			"old-index": `{"manifests":[{"digest":"old-amd64"}]}`,
+		},
+	}
+
+	report := InspectOrphans(context.Background(), client, []string{"repo"}, InspectOptions{Logs: logbuffer.New()})
+	if len(report.Errors) != 0 || len(report.Repositories) != 1 {
+		t.Fatalf("expected one inspected repository, got %+v", report)
+	}
+	got := report.Repositories[0]
+	want := InspectedRepository{
+		Repository:     "repo",
+		Tagged:         1,
+		NeverTagged:    1,
+		TagMoved:       2,
+		MultiArchChild: 1,
+		Orphans: map[string]OrphanClass{
+			"old-index": OrphanTagMoved,
+			"old-amd64": OrphanMultiArchChild,
+			"old-v0":    OrphanTagMoved,
+			"cache":     OrphanNeverTagged,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if _, rows := report.Table(); len(rows) != 4 || rows[0][1] != "cache" {
+		t.Errorf("expected one row per orphan sorted by digest, got %v", rows)
+	}
+}
+
+func TestCleanReport_Metrics(t *testing.T) {
+	report := CleanReport{Duration: 2 * time.Second, DryRun: true, Repositories: []RepositoryCleanResult{
+		{Repository: "app", Deleted: 4, Failed: 1},
+		{Repository: "web", Error: "access denied"},
+	}}
+	got := map[string]float64{}
+	for _, m := range report.Metrics() {
+		got[m.Name+"/"+m.Labels["repository"]] = m.Value
+	}
+	want := map[string]float64{
+		"ecr_lifecycle_cleaner_images_deleted/app":      4,
+		"ecr_lifecycle_cleaner_images_failed/app":       1,
+		"ecr_lifecycle_cleaner_repository_error/app":    0,
+		"ecr_lifecycle_cleaner_images_deleted/web":      0,
+		"ecr_lifecycle_cleaner_images_failed/web":       0,
+		"ecr_lifecycle_cleaner_repository_error/web":    1,
+		"ecr_lifecycle_cleaner_clean_duration_seconds/": 2,
+		"ecr_lifecycle_cleaner_clean_dry_run/":          1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// --- pagedListImagesClient serves ListImages one page per entry of pages, linked by NextToken ---
+type pagedListImagesClient struct {
+	mockECRClient
+	pages [][]types.ImageIdentifier
+}
+
+func (m *pagedListImagesClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	i := 0
+	if in.NextToken != nil {
+		i, _ = strconv.Atoi(*in.NextToken)
+	}
+	out := &ecr.ListImagesOutput{ImageIds: m.pages[i]}
+	if i+1 < len(m.pages) {
+		out.NextToken = aws.String(strconv.Itoa(i + 1))
+	}
+	return out, nil
+}
+
+func TestGetImages_GroupsDigestsAcrossPages(t *testing.T) {
+	client := &pagedListImagesClient{pages: [][]types.ImageIdentifier{
+		{{ImageDigest: aws.String("multi")}, {ImageDigest: aws.String("orphan")}},
+		{{ImageDigest: aws.String("orphan")}, {ImageDigest: aws.String("multi"), ImageTag: aws.String("v1")}},
+		{{ImageDigest: aws.String("multi"), ImageTag: aws.String("latest")}},
+	}}
+
+	images, err := getImages(context.TODO(), "repo", client, TagPatterns{}, TagStatusAny)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(images["tagged"], []string{"multi"}) || !reflect.DeepEqual(images["orphan"], []string{"orphan"}) {
+		t.Errorf("expected multi to be tagged and orphan listed once, got %v", images)
+	}
+
+	legacy, err := listImages(context.TODO(), "repo", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(legacy["tagged"], []string{"multi"}) || !reflect.DeepEqual(legacy["orphan"], []string{"orphan"}) {
+		t.Errorf("expected multi to be tagged and orphan listed once, got %v", legacy)
+	}
+}
+
+// --- serves tagged and untagged images in pages of 1000, honouring the tag status filter, and counts the pages served ---
+type tagStatusListClient struct {
+	mockECRClient
+	tagged, untagged int
+	filters          []*types.ListImagesFilter
+	pages            int
+	ids              []types.ImageIdentifier
+}
+
+func (m *tagStatusListClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	m.pages++
+	if in.NextToken == nil {
+		m.filters = append(m.filters, in.Filter)
+	}
+	if m.ids == nil {
+		for i := 0; i < m.tagged; i++ {
+			m.ids = append(m.ids, types.ImageIdentifier{ImageDigest: aws.String(fmt.Sprintf("sha256:tagged-%d", i)), ImageTag: aws.String(fmt.Sprintf("v%d", i))})
+		}
+		for i := 0; i < m.untagged; i++ {
+			m.ids = append(m.ids, types.ImageIdentifier{ImageDigest: aws.String(fmt.Sprintf("sha256:untagged-%d", i))})
+		}
+	}
+	ids := m.ids
+	if in.Filter != nil && in.Filter.TagStatus == types.TagStatusTagged {
+		ids = ids[:m.tagged]
+	} else if in.Filter != nil && in.Filter.TagStatus == types.TagStatusUntagged {
+		ids = ids[m.tagged:]
+	}
+	start := 0
+	if in.NextToken != nil {
+		start, _ = strconv.Atoi(*in.NextToken)
+	}
+	end := min(start+1000, len(ids))
+	out := &ecr.ListImagesOutput{ImageIds: ids[start:end]}
+	if end < len(ids) {
+		out.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func TestGetImages_TagStatusFilter(t *testing.T) {
+	for _, tc := range []struct {
+		filter          TagStatusFilter
+		want            []types.TagStatus
+		tagged, orphans int
+	}{
+		{TagStatusAny, []types.TagStatus{types.TagStatusUntagged, types.TagStatusTagged}, 3, 2},
+		{TagStatusTagged, []types.TagStatus{types.TagStatusTagged}, 3, 0},
+	} {
+		client := &tagStatusListClient{tagged: 3, untagged: 2}
+		images, err := getImages(context.TODO(), "repo", client, TagPatterns{}, tc.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.filter, err)
+		}
+		var got []types.TagStatus
+		for _, filter := range client.filters {
+			got = append(got, filter.TagStatus)
+		}
+		if !reflect.DeepEqual(got, tc.want) || len(images["tagged"]) != tc.tagged || len(images["orphan"]) != tc.orphans {
+			t.Errorf("%s: expected filters %v with %d tagged and %d orphans, got %v with %v", tc.filter, tc.want, tc.tagged, tc.orphans, got, images)
+		}
+	}
+
+	for value, want := range map[string]TagStatusFilter{"": TagStatusAny, "any": TagStatusAny, " TAGGED ": TagStatusTagged} {
+		if got, err := ParseTagStatusFilter(value); err != nil || got != want {
+			t.Errorf("ParseTagStatusFilter(%q): expected %s, got %s, %v", value, want, got, err)
+		}
+	}
+	for _, value := range []string{"SOME", "UNTAGGED"} {
+		if _, err := ParseTagStatusFilter(value); err == nil {
+			t.Errorf("expected an error for tag status %s", value)
+		}
+	}
+}
+
+func TestImagesToDeleteWithLogging_TagStatusKeepsChildren(t *testing.T) {
+	for _, filter := range []TagStatusFilter{TagStatusAny, TagStatusTagged} {
+		client := &tagStatusListClient{tagged: 1, untagged: 2}
+		client.batchGetOut = &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("sha256:tagged-0")}, ImageManifest: aws.String(`{"manifests":[{"digest":"sha256:untagged-0"}]}`)}},
+		}
+		var mu sync.Mutex
+		var logMessages []logbuffer.Entry
+		orphans, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, CleanOptions{ListTagStatus: filter}, &logMessages, &mu)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filter, err)
+		}
+		if slices.Contains(orphans, "sha256:untagged-0") {
+			t.Errorf("%s: expected the child of the tagged index to be kept, got %v", filter, orphans)
+		}
+		if filter == TagStatusAny && !reflect.DeepEqual(orphans, []string{"sha256:untagged-1"}) {
+			t.Errorf("%s: expected only the real orphan to be deleted, got %v", filter, orphans)
+		}
+	}
+}
+
+// --- a repository with 50,000 tagged and 500 untagged images, as CI repositories pushing a tag per build tend to be ---
+func BenchmarkGetImages(b *testing.B) {
+	for _, filter := range []TagStatusFilter{TagStatusAny, TagStatusTagged} {
+		b.Run(string(filter), func(b *testing.B) {
+			b.ReportAllocs()
+			client := &tagStatusListClient{tagged: 50_000, untagged: 500}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := getImages(context.TODO(), "repo", client, TagPatterns{}, filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(client.pages)/float64(b.N), "calls/op")
+		})
+	}
+}
+
+type tagResolvingClient struct {
+	mockECRClient
+	tags map[string]string
+}
+
+func (m *tagResolvingClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	out := &ecr.BatchGetImageOutput{}
+	for _, id := range in.ImageIds {
+		ref := aws.ToString(in.RepositoryName) + ":" + aws.ToString(id.ImageTag)
+		if digest, ok := m.tags[ref]; ok {
+			out.Images = append(out.Images, types.Image{ImageId: &types.ImageIdentifier{ImageDigest: aws.String(digest), ImageTag: id.ImageTag}})
+		} else {
+			out.Failures = append(out.Failures, types.ImageFailure{ImageId: &types.ImageIdentifier{ImageTag: id.ImageTag}, FailureReason: aws.String("Requested image not found")})
+		}
+	}
+	return out, nil
+}
+
+func TestLoadProtectedManifests(t *testing.T) {
+	const registry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+	client := &tagResolvingClient{tags: map[string]string{"base/python:3.12": "sha256:python", "base/node:22": "sha256:node"}}
+	path := filepath.Join(t.TempDir(), "protected.txt")
+	content := "# base images\nsha256:pinned\n\nbase/python:3.12\n" + registry + "/base/node:22\nghcr.io/org/tool@sha256:tool\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	digests, err := LoadProtectedManifests(context.TODO(), client, path, registry)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]struct{}{"sha256:pinned": {}, "sha256:python": {}, "sha256:node": {}, "sha256:tool": {}}
+	if !reflect.DeepEqual(digests, want) {
+		t.Errorf("expected %v, got %v", want, digests)
+	}
+
+	for content, wantErr := range map[string]string{
+		"base/gone:1\n":        "Requested image not found",
+		"ghcr.io/org/tool:1\n": "is not in registry",
+		"base/python\n":        "neither a tag nor a digest",
+		"base/python@sha256\n": "no algorithm",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProtectedManifests(context.TODO(), client, path, registry); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: expected an error containing %q, got %v", content, wantErr, err)
+		}
+	}
+}
+
+func TestParseTagAgePolicies(t *testing.T) {
+	policies, err := ParseTagAgePolicies([]string{"release-*:90d", " pr-* : 12h"})
+	want := []TagAgePolicy{{Pattern: "release-*", MinAge: 90 * 24 * time.Hour}, {Pattern: "pr-*", MinAge: 12 * time.Hour}}
+	if err != nil || !reflect.DeepEqual(policies, want) {
+		t.Errorf("expected %v, got %v, %v", want, policies, err)
+	}
+	for _, spec := range []string{"release-*", ":7d", "tmp-[:7d", "pr-*:soon"} {
+		if _, err := ParseTagAgePolicies([]string{spec}); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+	if got := tagMinAge(want, []string{"latest", "release-3"}, time.Hour); got != want[0].MinAge {
+		t.Errorf("expected the release policy, got %s", got)
+	}
+	if got := tagMinAge(want, []string{"latest"}, time.Hour); got != time.Hour {
+		t.Errorf("expected the fallback, got %s", got)
+	}
+}
+
+func TestImagesToDeleteWithLogging_TagAgePolicies(t *testing.T) {
+	day := 24 * time.Hour
+	pushed := func(age time.Duration) *time.Time { return aws.Time(time.Now().Add(-age)) }
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{ImageIds: []types.ImageIdentifier{
+			{ImageDigest: aws.String("pr-old"), ImageTag: aws.String("pr-1")},
+			{ImageDigest: aws.String("pr-new"), ImageTag: aws.String("pr-2")},
+			{ImageDigest: aws.String("build"), ImageTag: aws.String("build-1")},
+			{ImageDigest: aws.String("tmp"), ImageTag: aws.String("tmp-1")},
+			{ImageDigest: aws.String("orphan")},
+		}},
+		describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: []types.ImageDetail{
+			{ImageDigest: aws.String("pr-old"), ImageTags: []string{"pr-1"}, ImagePushedAt: pushed(3 * day)},
+			{ImageDigest: aws.String("pr-new"), ImageTags: []string{"pr-2"}, ImagePushedAt: pushed(12 * time.Hour)},
+			{ImageDigest: aws.String("build"), ImageTags: []string{"build-1"}, ImagePushedAt: pushed(10 * day)},
+			{ImageDigest: aws.String("tmp"), ImageTags: []string{"tmp-1"}, ImagePushedAt: pushed(3 * day)},
+			{ImageDigest: aws.String("orphan"), ImagePushedAt: pushed(8 * day)},
+		}},
+		batchGetOut: &ecr.BatchGetImageOutput{},
+	}
+	opts := CleanOptions{
+		MinAge:         7 * day,
+		TagPatterns:    TagPatterns{Delete: []string{"pr-*", "build-*", "tmp-*"}},
+		TagAgePolicies: []TagAgePolicy{{Pattern: "pr-*", MinAge: day}, {Pattern: "build-*", MinAge: 30 * day}},
+	}
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	images, _, _, err := imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// --- pr-1 is past its 1 day, build-1 is within its 30 days and tmp-1 within the 7 days of --minAge ---
+	if !reflect.DeepEqual(images, []string{"pr-old", "orphan"}) {
+		t.Errorf("expected [pr-old orphan], got %v", images)
+	}
+
+	// --- an index kept by its tag age policy protects its platform manifests ---
+	client = &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{ImageIds: []types.ImageIdentifier{
+			{ImageDigest: aws.String("index"), ImageTag: aws.String("release-1")},
+			{ImageDigest: aws.String("c1")},
+			{ImageDigest: aws.String("c2")},
+		}},
+		describeImagesOut: &ecr.DescribeImagesOutput{ImageDetails: []types.ImageDetail{
+			{ImageDigest: aws.String("index"), ImageTags: []string{"release-1"}, ImagePushedAt: pushed(10 * day)},
+			{ImageDigest: aws.String("c1"), ImagePushedAt: pushed(10 * day)},
+			{ImageDigest: aws.String("c2"), ImagePushedAt: pushed(10 * day)},
+		}},
+		batchGetOut: &ecr.BatchGetImageOutput{
+			Images: []types.Image{{ImageId: &types.ImageIdentifier{ImageDigest: aws.String("index")}, ImageManifest: aws.String(`{"manifests":[{"digest":"c1"},{"digest":"c2"}]}`)}},
+		},
+	}
+	opts = CleanOptions{
+		TagPatterns:    TagPatterns{Delete: []string{"release-*"}},
+		TagAgePolicies: []TagAgePolicy{{Pattern: "release-*", MinAge: 90 * day}},
+	}
+	images, _, _, err = imagesToDeleteWithLogging(context.TODO(), "repo", client, opts, &logMessages, &mu)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(images) != 0 {
+		t.Errorf("expected the kept index and its children to stay, got %v", images)
+	}
+}
+
+func TestPlanCleanup_ReclaimTarget(t *testing.T) {
+	now := time.Now()
+	client := &mockECRClient{
+		listImagesOut: &ecr.ListImagesOutput{
+			ImageIds: []types.ImageIdentifier{{ImageDigest: aws.String("newest")}, {ImageDigest: aws.String("old")}, {ImageDigest: aws.String("oldest")}, {ImageDigest: aws.String("newer")}, {ImageDigest: aws.String("unsized")}},
+		},
+		describeImagesOut: &ecr.DescribeImagesOutput{
+			ImageDetails: []types.ImageDetail{
+				{ImageDigest: aws.String("oldest"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -4)), ImageSizeInBytes: aws.Int64(10)},
+				{ImageDigest: aws.String("old"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -3)), ImageSizeInBytes: aws.Int64(20)},
+				{ImageDigest: aws.String("newer"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -2)), ImageSizeInBytes: aws.Int64(30)},
+				{ImageDigest: aws.String("newest"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -1)), ImageSizeInBytes: aws.Int64(40)},
+				{ImageDigest: aws.String("unsized"), ImagePushedAt: aws.Time(now.AddDate(0, 0, -5))},
+			},
+		},
+		batchGetOut: &ecr.BatchGetImageOutput{},
+	}
+
+	// --- the oldest images are deleted until the target is reached, the one crossing it included ---
+	plan := PlanCleanup(context.TODO(), client, []string{"repo-a", "repo-b"}, CleanOptions{DryRun: true, Concurrency: 1, ReclaimTarget: NewReclaimTarget(25, false)})
+	for _, entry := range plan.Repositories {
+		if !reflect.DeepEqual(entry.Images, []string{"oldest", "old"}) {
+			t.Errorf("Expected %s to delete [oldest old], got: %v", entry.Repository, entry.Images)
+		}
+	}
+
+	// --- a global target is one budget for the whole run ---
+	plan = PlanCleanup(context.TODO(), client, []string{"repo-a", "repo-b"}, CleanOptions{DryRun: true, Concurrency: 1, ReclaimTarget: NewReclaimTarget(25, true)})
+	total := 0
+	for _, entry := range plan.Repositories {
+		total += len(entry.Images)
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 images across both repositories, got: %+v", plan.Repositories)
+	}
+
+	// --- a target above the total deletes every sized image ---
+	plan = PlanCleanup(context.TODO(), client, []string{"repo-a"}, CleanOptions{DryRun: true, ReclaimTarget: NewReclaimTarget(1<<30, false)})
+	if got := plan.Repositories[0].Images; len(got) != 4 {
+		t.Errorf("Expected the 4 sized images, got: %v", got)
+	}
+}
+
+func TestGetRepositoryImageManifest(t *testing.T) {
+	index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+	client := &mockECRClient{batchGetOut: &ecr.BatchGetImageOutput{Images: []types.Image{{
+		ImageId:                &types.ImageIdentifier{ImageDigest: aws.String("sha256:index")},
+		ImageManifest:          aws.String(index),
+		ImageManifestMediaType: aws.String("application/vnd.oci.image.index.v1+json"),
+	}}}}
+	manifest, mediaType, err := GetRepositoryImageManifest(context.TODO(), "app", "sha256:index", client)
+	if err != nil || manifest != index || mediaType != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("Expected the index and its media type, got %q, %q, %v", manifest, mediaType, err)
+	}
+
+	// --- without a media type from ECR the manifest's own field is used ---
+	client.batchGetOut.Images[0].ImageManifestMediaType = nil
+	if _, mediaType, err := GetRepositoryImageManifest(context.TODO(), "app", "sha256:index", client); err != nil || mediaType != "application/vnd.oci.image.index.v1+json" {
+		t.Errorf("Expected the media type from the manifest, got %q, %v", mediaType, err)
+	}
+
+	client.batchGetOut = &ecr.BatchGetImageOutput{Failures: []types.ImageFailure{{FailureCode: types.ImageFailureCodeImageNotFound, FailureReason: aws.String("Requested image not found")}}}
+	if _, _, err := GetRepositoryImageManifest(context.TODO(), "app", "sha256:gone", client); err == nil || !strings.Contains(err.Error(), "ImageNotFound") {
+		t.Errorf("Expected the image failure, got: %v", err)
+	}
+	client.batchGetOut, client.batchGetErr = nil, errors.New("access denied")
+	if _, _, err := GetRepositoryImageManifest(context.TODO(), "app", "sha256:index", client); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Expected the API error, got: %v", err)
+	}
+}
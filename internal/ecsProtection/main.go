@@ -0,0 +1,138 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package ecsprotection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awsjson "ecr-lifecycle-cleaner/internal/awsJSON"
+	imageref "ecr-lifecycle-cleaner/internal/imageReference"
+	"ecr-lifecycle-cleaner/internal/sliceutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// --- ECSAPI defines the ECS operations needed to find the images of running tasks ---
+type ECSAPI interface {
+	ListTasks(ctx context.Context, in *ListTasksInput) (*ListTasksOutput, error)
+	DescribeTasks(ctx context.Context, in *DescribeTasksInput) (*DescribeTasksOutput, error)
+}
+
+// --- the request and response fields of ListTasks and DescribeTasks the protection needs ---
+type ListTasksInput struct {
+	Cluster       string `json:"cluster"`
+	DesiredStatus string `json:"desiredStatus,omitempty"`
+	MaxResults    int32  `json:"maxResults,omitempty"`
+	NextToken     string `json:"nextToken,omitempty"`
+}
+
+type ListTasksOutput struct {
+	TaskArns  []string `json:"taskArns"`
+	NextToken string   `json:"nextToken"`
+}
+
+type DescribeTasksInput struct {
+	Cluster string   `json:"cluster"`
+	Tasks   []string `json:"tasks"`
+}
+
+type DescribeTasksOutput struct {
+	Tasks    []Task    `json:"tasks"`
+	Failures []Failure `json:"failures"`
+}
+
+// --- Task is an ECS task with the containers it runs ---
+type Task struct {
+	TaskArn    string      `json:"taskArn"`
+	Containers []Container `json:"containers"`
+}
+
+// --- Container holds the image of a task's container as given in the task definition and the digest ECS resolved it to ---
+type Container struct {
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	ImageDigest string `json:"imageDigest"`
+}
+
+// --- Failure is a task DescribeTasks could not return, e.g. one that stopped after it was listed ---
+type Failure struct {
+	Arn    string `json:"arn"`
+	Reason string `json:"reason"`
+}
+
+// --- the largest page ListTasks returns and the most tasks DescribeTasks accepts ---
+const tasksPerCall = 100
+
+// --- returns the digests of the images of registryURI run by the RUNNING tasks of the clusters ---
+// --- ECS records the digest each container was started from, so tasks whose task definition names a tag are covered too ---
+func ECSProtectedDigests(ctx context.Context, client ECSAPI, clusters []string, registryURI string) (map[string]struct{}, error) {
+	digests := map[string]struct{}{}
+	for _, cluster := range clusters {
+		var arns []string
+		in := &ListTasksInput{Cluster: cluster, DesiredStatus: "RUNNING", MaxResults: tasksPerCall}
+		for {
+			out, err := client.ListTasks(ctx, in)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tasks of cluster %s: %w", cluster, err)
+			}
+			arns = append(arns, out.TaskArns...)
+			if out.NextToken == "" {
+				break
+			}
+			in.NextToken = out.NextToken
+		}
+
+		for _, part := range sliceutil.Partition(arns, tasksPerCall) {
+			out, err := client.DescribeTasks(ctx, &DescribeTasksInput{Cluster: cluster, Tasks: part})
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe tasks of cluster %s: %w", cluster, err)
+			}
+			for _, task := range out.Tasks {
+				for _, container := range task.Containers {
+					image, err := imageref.Parse(container.Image)
+					if err != nil || !strings.EqualFold(image.Registry, registryURI) {
+						continue
+					}
+					if container.ImageDigest != "" {
+						digests[container.ImageDigest] = struct{}{}
+					}
+					if image.Digest != "" {
+						digests[image.Digest] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+	return digests, nil
+}
+
+// --- Client calls the ECS JSON API, only the two read calls are needed, which keeps the full ECS SDK out of the build ---
+type Client struct {
+	api *awsjson.Client
+}
+
+var _ ECSAPI = (*Client)(nil)
+
+// --- returns a client for the region of cfg ---
+func NewClient(cfg aws.Config) *Client {
+	endpoint := fmt.Sprintf("https://ecs.%s.%s/", cfg.Region, awsjson.Domain(cfg.Region))
+	return &Client{api: awsjson.NewClient(cfg, endpoint, "ecs", cfg.Region, "AmazonEC2ContainerServiceV20141113")}
+}
+
+func (c *Client) ListTasks(ctx context.Context, in *ListTasksInput) (*ListTasksOutput, error) {
+	var out ListTasksOutput
+	if err := c.api.Call(ctx, "ListTasks", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) DescribeTasks(ctx context.Context, in *DescribeTasksInput) (*DescribeTasksOutput, error) {
+	var out DescribeTasksOutput
+	if err := c.api.Call(ctx, "DescribeTasks", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,117 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package ecsprotection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const registry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+// --- serves the tasks of each cluster in pages of pageSize, recording the tasks asked for per DescribeTasks call ---
+type fakeECS struct {
+	tasks     map[string][]Task
+	pageSize  int
+	described [][]string
+}
+
+func (f *fakeECS) ListTasks(ctx context.Context, in *ListTasksInput) (*ListTasksOutput, error) {
+	tasks, ok := f.tasks[in.Cluster]
+	if !ok {
+		return nil, errors.New("ClusterNotFoundException")
+	}
+	start := 0
+	if in.NextToken != "" {
+		fmt.Sscan(in.NextToken, &start)
+	}
+	end := min(start+f.pageSize, len(tasks))
+	out := &ListTasksOutput{}
+	for _, task := range tasks[start:end] {
+		out.TaskArns = append(out.TaskArns, task.TaskArn)
+	}
+	if end < len(tasks) {
+		out.NextToken = fmt.Sprint(end)
+	}
+	return out, nil
+}
+
+func (f *fakeECS) DescribeTasks(ctx context.Context, in *DescribeTasksInput) (*DescribeTasksOutput, error) {
+	f.described = append(f.described, in.Tasks)
+	out := &DescribeTasksOutput{}
+	for _, task := range f.tasks[in.Cluster] {
+		for _, arn := range in.Tasks {
+			if task.TaskArn == arn {
+				out.Tasks = append(out.Tasks, task)
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestECSProtectedDigests(t *testing.T) {
+	var tasks []Task
+	for i := 0; i < 150; i++ {
+		tasks = append(tasks, Task{TaskArn: fmt.Sprintf("task-%d", i), Containers: []Container{{Image: "public.ecr.aws/nginx/nginx:1.27", ImageDigest: "sha256:nginx"}}})
+	}
+	tasks[0].Containers = append(tasks[0].Containers, Container{Image: registry + "/app:1.2", ImageDigest: "sha256:tagged"})
+	tasks[149].Containers = append(tasks[149].Containers, Container{Image: registry + "/worker@sha256:pinned"})
+	client := &fakeECS{tasks: map[string][]Task{"prod": tasks}, pageSize: 40}
+
+	digests, err := ECSProtectedDigests(context.TODO(), client, []string{"prod"}, registry)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := map[string]struct{}{"sha256:tagged": {}, "sha256:pinned": {}}
+	if !reflect.DeepEqual(digests, want) {
+		t.Errorf("Expected %v, got: %v", want, digests)
+	}
+	// --- every listed task is described, at most 100 per call ---
+	if len(client.described) != 2 || len(client.described[0]) != 100 || len(client.described[1]) != 50 {
+		t.Errorf("Expected tasks described in calls of 100 and 50, got: %d calls", len(client.described))
+	}
+
+	if _, err := ECSProtectedDigests(context.TODO(), client, []string{"prod", "missing"}, registry); err == nil || !strings.Contains(err.Error(), "cluster missing") {
+		t.Errorf("Expected an error naming the missing cluster, got: %v", err)
+	}
+}
+
+func TestClient_Call(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("Content-Type") != "application/x-amz-json-1.1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var in ListTasksInput
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if r.Header.Get("X-Amz-Target") != "AmazonEC2ContainerServiceV20141113.ListTasks" || in.Cluster != "prod" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "ClusterNotFoundException", "message": "Cluster not found."}`)
+			return
+		}
+		fmt.Fprint(w, `{"taskArns": ["task-1"]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(aws.Config{Region: "eu-west-1", Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	})})
+	client.api.Endpoint = server.URL
+
+	out, err := client.ListTasks(context.TODO(), &ListTasksInput{Cluster: "prod"})
+	if err != nil || !reflect.DeepEqual(out.TaskArns, []string{"task-1"}) {
+		t.Fatalf("Expected [task-1], got: %v, %v", out, err)
+	}
+	if _, err := client.ListTasks(context.TODO(), &ListTasksInput{Cluster: "staging"}); err == nil || !strings.Contains(err.Error(), "ClusterNotFoundException") {
+		t.Errorf("Expected the API error, got: %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package eksprotection
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	imageref "ecr-lifecycle-cleaner/internal/imageReference"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Pod holds the image references of a pod, from its spec and from the statuses of its running containers ---
+type Pod struct {
+	Name string
+	// --- images as written in the pod spec, e.g. 123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:1.2 ---
+	Images []string
+	// --- images the kubelet resolved, usually pinned to a digest, e.g. docker-pullable://...app@sha256:... ---
+	ImageIDs []string
+}
+
+// --- PodLister lists the pods of a namespace, implemented by Client and by fakes in tests ---
+type PodLister interface {
+	ListPods(ctx context.Context, namespace string) ([]Pod, error)
+}
+
+// --- returns the digests of the images pulled from registryURI by the pods of the namespaces ---
+// --- pods pinning a digest in their spec and running containers whose resolved image carries one are both covered ---
+// --- references that cannot be parsed are ignored, they cannot point at an image of the registry ---
+func EKSProtectedDigests(ctx context.Context, k8sClient PodLister, namespaces []string, registryURI string) (map[string]struct{}, error) {
+	digests := map[string]struct{}{}
+	for _, namespace := range namespaces {
+		pods, err := k8sClient.ListPods(ctx, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
+		}
+		for _, pod := range pods {
+			for _, ref := range append(append([]string{}, pod.Images...), pod.ImageIDs...) {
+				image, err := imageref.Parse(ref)
+				if err != nil || image.Digest == "" || !strings.EqualFold(image.Registry, registryURI) {
+					continue
+				}
+				digests[image.Digest] = struct{}{}
+			}
+		}
+	}
+	return digests, nil
+}
+
+// --- Client lists pods through the Kubernetes REST API ---
+type Client struct {
+	server string
+	http   *http.Client
+	// --- returns the bearer token of a request, nil when the client authenticates with a certificate ---
+	token func(ctx context.Context) (string, error)
+}
+
+var _ PodLister = (*Client)(nil)
+
+// --- pods requested per page, the API server continues larger lists with a continue token ---
+const podPageSize = 500
+
+// --- the fields of a core/v1 PodList the protection needs ---
+type podList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			InitContainers      []container `json:"initContainers"`
+			Containers          []container `json:"containers"`
+			EphemeralContainers []container `json:"ephemeralContainers"`
+		} `json:"spec"`
+		Status struct {
+			InitContainerStatuses      []containerStatus `json:"initContainerStatuses"`
+			ContainerStatuses          []containerStatus `json:"containerStatuses"`
+			EphemeralContainerStatuses []containerStatus `json:"ephemeralContainerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type container struct {
+	Image string `json:"image"`
+}
+
+type containerStatus struct {
+	ImageID string `json:"imageID"`
+}
+
+// --- lists the pods of namespace page by page ---
+func (c *Client) ListPods(ctx context.Context, namespace string) ([]Pod, error) {
+	var pods []Pod
+	next := ""
+	for {
+		query := url.Values{"limit": {fmt.Sprint(podPageSize)}}
+		if next != "" {
+			query.Set("continue", next)
+		}
+		endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/pods?%s", c.server, url.PathEscape(namespace), query.Encode())
+		page, err := c.get(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			pod := Pod{Name: item.Metadata.Name}
+			for _, group := range [][]container{item.Spec.InitContainers, item.Spec.Containers, item.Spec.EphemeralContainers} {
+				for _, c := range group {
+					pod.Images = append(pod.Images, c.Image)
+				}
+			}
+			for _, group := range [][]containerStatus{item.Status.InitContainerStatuses, item.Status.ContainerStatuses, item.Status.EphemeralContainerStatuses} {
+				for _, s := range group {
+					if s.ImageID != "" {
+						pod.ImageIDs = append(pod.ImageIDs, s.ImageID)
+					}
+				}
+			}
+			pods = append(pods, pod)
+		}
+		if next = page.Metadata.Continue; next == "" {
+			return pods, nil
+		}
+	}
+}
+
+// --- requests one page of pods ---
+func (c *Client) get(ctx context.Context, endpoint string) (*podList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build pods request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != nil {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list pods: %s", resp.Status)
+	}
+	var page podList
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode pods: %w", err)
+	}
+	return &page, nil
+}
+
+// --- where a pod finds the credentials of its service account ---
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// --- returns a client for the cluster of the kubeconfig's current context ---
+// --- an empty kubeconfig uses the in-cluster service account when running in a pod, $KUBECONFIG or ~/.kube/config otherwise ---
+func NewClient(kubeconfig string) (*Client, error) {
+	if kubeconfig == "" {
+		if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" && port != "" {
+			return newInClusterClient("https://"+net.JoinHostPort(host, port), serviceAccountDir)
+		}
+		kubeconfig = defaultKubeconfig()
+	}
+	return newKubeconfigClient(kubeconfig)
+}
+
+// --- returns the first path of $KUBECONFIG, or ~/.kube/config ---
+func defaultKubeconfig() string {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0]
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".kube", "config")
+}
+
+// --- authenticates with the pod's service account token, re-read on every request as the kubelet rotates it ---
+func newInClusterClient(server, dir string) (*Client, error) {
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read in-cluster CA: %w", err)
+	}
+	transport, err := newTransport(ca, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	tokenPath := filepath.Join(dir, "token")
+	return &Client{server: server, http: &http.Client{Transport: transport}, token: func(context.Context) (string, error) {
+		token, err := os.ReadFile(tokenPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read service account token: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}}, nil
+}
+
+// --- the fields of a kubeconfig the client supports ---
+type kubeconfigFile struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string   `yaml:"name"`
+		User kubeUser `yaml:"user"`
+	} `yaml:"users"`
+}
+
+type kubeUser struct {
+	Token                 string `yaml:"token"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKeyData         string `yaml:"client-key-data"`
+	// --- credential plugin such as aws eks get-token, the usual way to authenticate to EKS ---
+	Exec *struct {
+		Command string   `yaml:"command"`
+		Args    []string `yaml:"args"`
+		Env     []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+		} `yaml:"env"`
+	} `yaml:"exec"`
+}
+
+// --- builds a client from the current context of the kubeconfig at path ---
+func newKubeconfigClient(path string) (*Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	var config kubeconfigFile
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+	}
+
+	var clusterName, userName string
+	for _, c := range config.Contexts {
+		if c.Name == config.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("kubeconfig %s has no current context", path)
+	}
+
+	client := &Client{}
+	var ca []byte
+	var insecure bool
+	for _, c := range config.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		client.server = strings.TrimSuffix(c.Cluster.Server, "/")
+		insecure = c.Cluster.InsecureSkipTLSVerify
+		switch {
+		case c.Cluster.CertificateAuthorityData != "":
+			if ca, err = base64.StdEncoding.DecodeString(c.Cluster.CertificateAuthorityData); err != nil {
+				return nil, fmt.Errorf("invalid certificate-authority-data of cluster %s: %w", clusterName, err)
+			}
+		case c.Cluster.CertificateAuthority != "":
+			if ca, err = os.ReadFile(c.Cluster.CertificateAuthority); err != nil {
+				return nil, fmt.Errorf("failed to read certificate authority of cluster %s: %w", clusterName, err)
+			}
+		}
+	}
+	if client.server == "" {
+		return nil, fmt.Errorf("kubeconfig %s has no server for cluster %s", path, clusterName)
+	}
+
+	var user kubeUser
+	for _, u := range config.Users {
+		if u.Name == userName {
+			user = u.User
+		}
+	}
+	var cert *tls.Certificate
+	if user.ClientCertificateData != "" {
+		certPEM, certErr := base64.StdEncoding.DecodeString(user.ClientCertificateData)
+		keyPEM, keyErr := base64.StdEncoding.DecodeString(user.ClientKeyData)
+		if certErr != nil || keyErr != nil {
+			return nil, fmt.Errorf("invalid client certificate of user %s", userName)
+		}
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate of user %s: %w", userName, err)
+		}
+		cert = &pair
+	}
+	transport, err := newTransport(ca, insecure, cert)
+	if err != nil {
+		return nil, err
+	}
+	client.http = &http.Client{Transport: transport}
+
+	switch {
+	case user.Token != "":
+		token := user.Token
+		client.token = func(context.Context) (string, error) { return token, nil }
+	case user.Exec != nil:
+		plugin := *user.Exec
+		client.token = func(ctx context.Context) (string, error) {
+			env := os.Environ()
+			for _, e := range plugin.Env {
+				env = append(env, e.Name+"="+e.Value)
+			}
+			return execToken(ctx, plugin.Command, plugin.Args, env)
+		}
+	}
+	return client, nil
+}
+
+// --- runs a client.authentication.k8s.io credential plugin and returns the token of its ExecCredential ---
+func execToken(ctx context.Context, command string, args, env []string) (string, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Env = env
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run credential plugin %s: %w", command, err)
+	}
+	var credential struct {
+		Status struct {
+			Token string `json:"token"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(out, &credential); err != nil || credential.Status.Token == "" {
+		return "", fmt.Errorf("credential plugin %s returned no token", command)
+	}
+	return credential.Status.Token, nil
+}
+
+// --- returns a transport trusting the cluster certificate authority ca, or the system roots when ca is empty ---
+func newTransport(ca []byte, insecure bool, cert *tls.Certificate) (*http.Transport, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in the cluster certificate authority")
+		}
+		config.RootCAs = pool
+	}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	return transport, nil
+}
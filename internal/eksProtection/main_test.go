@@ -0,0 +1,124 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package eksprotection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const registry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+// --- serves fixed pods per namespace ---
+type fakePodLister map[string][]Pod
+
+func (f fakePodLister) ListPods(ctx context.Context, namespace string) ([]Pod, error) {
+	pods, ok := f[namespace]
+	if !ok {
+		return nil, errors.New("forbidden")
+	}
+	return pods, nil
+}
+
+func TestEKSProtectedDigests(t *testing.T) {
+	lister := fakePodLister{
+		"web": {
+			{Name: "pinned", Images: []string{registry + "/app@sha256:pinned"}},
+			{Name: "running", Images: []string{registry + "/app:1.2"}, ImageIDs: []string{"docker-pullable://" + registry + "/app@sha256:running"}},
+		},
+		"jobs": {
+			{Name: "other-registry", Images: []string{"210987654321.dkr.ecr.eu-west-1.amazonaws.com/app@sha256:elsewhere"}},
+			{Name: "pending", Images: []string{registry + "/worker:latest"}},
+		},
+	}
+
+	digests, err := EKSProtectedDigests(context.TODO(), lister, []string{"web", "jobs"}, registry)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := map[string]struct{}{"sha256:pinned": {}, "sha256:running": {}}
+	if !reflect.DeepEqual(digests, want) {
+		t.Errorf("Expected %v, got: %v", want, digests)
+	}
+
+	// --- an unreadable namespace fails the lookup, a partial set could let running images be deleted ---
+	if _, err := EKSProtectedDigests(context.TODO(), lister, []string{"web", "kube-system"}, registry); err == nil {
+		t.Errorf("Expected an error for an unreadable namespace")
+	}
+}
+
+func TestClient_ListPods(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Path != "/api/v1/namespaces/web/pods" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		// --- two pages linked by a continue token ---
+		if r.URL.Query().Get("continue") == "" {
+			fmt.Fprint(w, `{"metadata": {"continue": "next"}, "items": [{"metadata": {"name": "a"}, "spec": {"initContainers": [{"image": "init:1"}], "containers": [{"image": "app:1"}]}, "status": {"containerStatuses": [{"imageID": "app@sha256:a"}, {"imageID": ""}]}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"metadata": {}, "items": [{"metadata": {"name": "b"}, "spec": {"containers": [{"image": "app:2"}]}}]}`)
+	}))
+	defer server.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test-cluster
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+contexts:
+- name: test
+  context:
+    cluster: test-cluster
+    user: test-user
+users:
+- name: test-user
+  user:
+    token: secret
+`, server.URL)
+	if err := os.WriteFile(kubeconfig, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(kubeconfig)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	pods, err := client.ListPods(context.TODO(), "web")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := []Pod{
+		{Name: "a", Images: []string{"init:1", "app:1"}, ImageIDs: []string{"app@sha256:a"}},
+		{Name: "b", Images: []string{"app:2"}},
+	}
+	if !reflect.DeepEqual(pods, want) {
+		t.Errorf("Expected %+v, got: %+v", want, pods)
+	}
+
+	if _, err := client.ListPods(context.TODO(), "other"); err == nil {
+		t.Errorf("Expected an error for a forbidden namespace")
+	}
+}
+
+func TestNewClient_InvalidKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("current-context: missing\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(path); err == nil {
+		t.Errorf("Expected an error for a kubeconfig without the current context")
+	}
+}
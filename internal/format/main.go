@@ -0,0 +1,589 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package format
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	// --- embeds the IANA time zone database, so --timezone works on hosts and images without one ---
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"ecr-lifecycle-cleaner/internal/sliceutil"
+
+	"github.com/spf13/cast"
+	"golang.org/x/term"
+)
+
+// --- writes the report as indented JSON to path, "-" writes to stdout ---
+// --- files are written to a temporary file next to the target and renamed, so readers never see a partial report ---
+func WriteReport(path string, report interface{}) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	data = append(data, '\n')
+	return writeOutput(path, data)
+}
+
+// --- writes data to path atomically, "-" writes to stdout ---
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			return fmt.Errorf("failed to write report to stdout: %w", err)
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary report file: %w", err)
+	}
+	defer func() {
+		// --- no-op once the rename succeeded ---
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move report into place: %w", err)
+	}
+	return nil
+}
+
+// --- OutputFormat selects how the final report is written to --outputFile ---
+type OutputFormat string
+
+const (
+	// --- the report structure as indented JSON ---
+	OutputJSON OutputFormat = "json"
+	// --- JUnit XML, one test case per repository, for CI test dashboards ---
+	OutputJUnit OutputFormat = "junit"
+	// --- delimiter-separated values with a header row, one row per repository ---
+	OutputCSV OutputFormat = "csv"
+)
+
+// --- parses an output format name, the names are case-insensitive ---
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch OutputFormat(strings.ToLower(strings.TrimSpace(name))) {
+	case OutputJSON:
+		return OutputJSON, nil
+	case OutputJUnit:
+		return OutputJUnit, nil
+	case OutputCSV:
+		return OutputCSV, nil
+	}
+	return "", fmt.Errorf("unknown output format %q, expected json, junit or csv", name)
+}
+
+// --- JSONMode selects how --output json is laid out ---
+type JSONMode string
+
+const (
+	// --- the whole report as one indented JSON document ---
+	JSONArray JSONMode = "array"
+	// --- one compact JSON object per line, per repository for reports listing repositories ---
+	JSONLines JSONMode = "lines"
+)
+
+// --- parses a JSON mode name, the names are case-insensitive ---
+func ParseJSONMode(name string) (JSONMode, error) {
+	switch JSONMode(strings.ToLower(strings.TrimSpace(name))) {
+	case JSONArray:
+		return JSONArray, nil
+	case JSONLines:
+		return JSONLines, nil
+	}
+	return "", fmt.Errorf("unknown JSON mode %q, expected array or lines", name)
+}
+
+// --- RecordLister is implemented by reports made of one record per repository, written one per line in JSONLines mode ---
+type RecordLister interface {
+	Records() []interface{}
+}
+
+// --- writes each record as compact JSON on its own line to path, "-" writes to stdout ---
+func WriteJSONLines(path string, records []interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+	}
+	return writeOutput(path, buf.Bytes())
+}
+
+// --- Separator is the delimiter between the values of --output csv ---
+type Separator rune
+
+const (
+	SeparatorComma Separator = ','
+	SeparatorTab   Separator = '\t'
+	SeparatorPipe  Separator = '|'
+)
+
+// --- parses a separator name: comma, tab or pipe ---
+func ParseSeparator(name string) (Separator, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "comma":
+		return SeparatorComma, nil
+	case "tab":
+		return SeparatorTab, nil
+	case "pipe":
+		return SeparatorPipe, nil
+	}
+	return 0, fmt.Errorf("unknown separator %q, expected comma, tab or pipe", name)
+}
+
+// --- Tabler is implemented by reports that can be written as rows, one per repository ---
+type Tabler interface {
+	Table() (headers []string, rows [][]string)
+}
+
+// --- writes the header and rows separated by sep to path, values holding sep, quotes or newlines are quoted ---
+func WriteDelimited(path string, headers []string, rows [][]string, sep Separator) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = rune(sep)
+	if err := w.Write(headers); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return writeOutput(path, buf.Bytes())
+}
+
+// --- JUnitReporter is implemented by reports that can be shown as JUnit test results ---
+type JUnitReporter interface {
+	JUnit() JUnitSuite
+}
+
+// --- JUnitSuite is a set of test cases, usually one per repository ---
+type JUnitSuite struct {
+	Name     string
+	Duration time.Duration
+	Cases    []JUnitCase
+}
+
+// --- JUnitCase is a single test case, failing when Failure is set ---
+type JUnitCase struct {
+	Name      string
+	ClassName string
+	Failure   string
+	// --- the long form of the failure, e.g. one line per failed image ---
+	Details string
+	Skipped string
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// --- renders the suite as a JUnit XML document ---
+func (s JUnitSuite) Marshal() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:  s.Name,
+		Tests: len(s.Cases),
+		Time:  fmt.Sprintf("%.3f", s.Duration.Seconds()),
+		Cases: make([]junitTestCase, 0, len(s.Cases)),
+	}
+	for _, c := range s.Cases {
+		tc := junitTestCase{Name: c.Name, ClassName: c.ClassName}
+		switch {
+		case c.Failure != "":
+			tc.Failure = &junitFailure{Message: c.Failure, Text: c.Details}
+			suite.Failures++
+		case c.Skipped != "":
+			tc.Skipped = &junitSkipped{Message: c.Skipped}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// --- writes the suite as JUnit XML to path, "-" writes to stdout ---
+func WriteJUnit(path string, suite JUnitSuite) error {
+	data, err := suite.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, data)
+}
+
+// --- PrometheusReporter is implemented by reports that can be exported as Prometheus metrics ---
+type PrometheusReporter interface {
+	Metrics() []Metric
+}
+
+// --- Metric is a single gauge sample, samples sharing a name share its help text ---
+type Metric struct {
+	Name   string
+	Help   string
+	Labels map[string]string
+	Value  float64
+}
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// --- renders the metrics in the Prometheus text format, the samples of a name are grouped under one HELP and TYPE line ---
+func MarshalPrometheus(metrics []Metric) []byte {
+	var names []string
+	byName := map[string][]Metric{}
+	for _, m := range metrics {
+		if _, ok := byName[m.Name]; !ok {
+			names = append(names, m.Name)
+		}
+		byName[m.Name] = append(byName[m.Name], m)
+	}
+	var buf bytes.Buffer
+	for _, name := range names {
+		samples := byName[name]
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, samples[0].Help, name)
+		for _, m := range samples {
+			buf.WriteString(name)
+			if len(m.Labels) > 0 {
+				keys := make([]string, 0, len(m.Labels))
+				for key := range m.Labels {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				pairs := make([]string, len(keys))
+				for i, key := range keys {
+					pairs[i] = fmt.Sprintf(`%s="%s"`, key, prometheusLabelEscaper.Replace(m.Labels[key]))
+				}
+				buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+			}
+			fmt.Fprintf(&buf, " %g\n", m.Value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// --- writes the metrics in the Prometheus text format to path, e.g. for the node exporter textfile collector, "-" writes to stdout ---
+func WritePrometheus(path string, metrics []Metric) error {
+	return writeOutput(path, MarshalPrometheus(metrics))
+}
+
+// --- functions available to --outputTemplate templates ---
+var templateFuncs = template.FuncMap{
+	"humanBytes": HumanBytes,
+	"pluralize":  Pluralize,
+	"join":       strings.Join,
+	"timeAgo":    TimeAgo,
+}
+
+// --- parses a Go text/template file used to render the final report ---
+func ParseTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// --- built-in templates, named <name>.tmpl for every report with a Summary and <family>-<name>.tmpl for one kind of report ---
+// --- generic names never contain a -, so they cannot be mistaken for the template of another family ---
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// --- BuiltinTemplatePrefix selects a built-in template instead of a file, e.g. --outputTemplate @slack ---
+const BuiltinTemplatePrefix = "@"
+
+// --- returns the names of the built-in templates available for the family, sorted ---
+func BuiltinTemplates(family string) []string {
+	entries, _ := builtinTemplates.ReadDir("templates")
+	prefix := strings.ToLower(family) + "-"
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".tmpl")
+		if strings.Contains(name, "-") && !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, strings.TrimPrefix(name, prefix))
+	}
+	names = sliceutil.Unique(names)
+	sort.Strings(names)
+	return names
+}
+
+// --- parses a built-in template, preferring the one written for the family (e.g. clean) over the generic one ---
+func ParseBuiltinTemplate(name, family string) (*template.Template, error) {
+	for _, file := range []string{strings.ToLower(family) + "-" + name + ".tmpl", name + ".tmpl"} {
+		data, err := builtinTemplates.ReadFile("templates/" + file)
+		if err != nil {
+			continue
+		}
+		tmpl, err := template.New(BuiltinTemplatePrefix + name).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse built-in template %s%s: %w", BuiltinTemplatePrefix, name, err)
+		}
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("unknown built-in template %s%s, available: %s", BuiltinTemplatePrefix, name, strings.Join(BuiltinTemplates(family), ", "))
+}
+
+// --- renders the report with the template and writes it to path, "-" writes to stdout ---
+func WriteTemplate(path string, tmpl *template.Template, report interface{}) error {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return fmt.Errorf("failed to render template %s: %w", tmpl.Name(), err)
+	}
+	return writeOutput(path, []byte(buf.String()))
+}
+
+// --- formats a byte count with binary units, e.g. 1.5 MiB ---
+func HumanBytes(value interface{}) string {
+	n := cast.ToInt64(value)
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit || m <= -unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// --- multipliers of the units ParseBytes accepts, binary units are powers of 1024 and decimal ones of 1000 ---
+var byteUnits = map[string]float64{
+	"": 1, "B": 1,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+}
+
+// --- parses a size such as 50GiB, 1.5 TB or 1048576, the inverse of HumanBytes ---
+func ParseBytes(value string) (int64, error) {
+	trimmed := strings.TrimSpace(value)
+	split := strings.IndexFunc(trimmed, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	number, unit := trimmed, ""
+	if split >= 0 {
+		number, unit = trimmed[:split], strings.ToUpper(strings.TrimSpace(trimmed[split:]))
+	}
+	multiplier, ok := byteUnits[unit]
+	n, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional unit such as 500MB or 50GiB", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// --- returns the count with the singular or plural word, e.g. 3 images ---
+// --- the plural adds an s unless given, e.g. pluralize 2 "repository" "repositories" ---
+func Pluralize(count interface{}, singular string, plural ...string) string {
+	n := cast.ToInt64(count)
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	if len(plural) > 0 {
+		return fmt.Sprintf("%d %s", n, plural[0])
+	}
+	return fmt.Sprintf("%d %ss", n, singular)
+}
+
+// --- describes how long ago a time was, rounded to the largest unit, e.g. 3h ago ---
+func TimeAgo(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed.Minutes()))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(elapsed.Hours()/24))
+}
+
+// --- parses an IANA time zone name such as Europe/Berlin, empty means UTC ---
+func ParseTimezone(name string) (*time.Location, error) {
+	if strings.TrimSpace(name) == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(name))
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q, expected an IANA name such as Europe/Berlin", name)
+	}
+	return loc, nil
+}
+
+// --- Localizer is implemented by reports holding timestamps, InLocation returns a copy showing them in loc ---
+type Localizer interface {
+	InLocation(loc *time.Location) interface{}
+}
+
+// --- spaces between table columns ---
+const columnPadding = 2
+
+// --- marks a cell shortened to fit the terminal ---
+const ellipsis = "..."
+
+// --- TerminalTable renders rows as aligned columns sized to the longest value in each column ---
+// --- on a terminal the table is narrowed to the terminal width by shortening the TruncateColumn cells with an ellipsis ---
+type TerminalTable struct {
+	Headers []string
+	Rows    [][]string
+	// --- column shortened when the table is wider than the terminal, usually the repository name ---
+	TruncateColumn int
+	// --- width to fit the table into, 0 detects the terminal width and leaves the table at full width when not writing to a terminal ---
+	Width int
+}
+
+// --- returns a table with the given headers that shortens truncateColumn to fit the terminal ---
+func NewTerminalTable(headers []string, truncateColumn int) *TerminalTable {
+	return &TerminalTable{Headers: headers, TruncateColumn: truncateColumn}
+}
+
+// --- appends a row of cells ---
+func (t *TerminalTable) AddRow(cells ...string) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// --- writes the header line and rows to w ---
+func (t *TerminalTable) Write(w io.Writer) error {
+	widths := t.columnWidths()
+	t.fitWidth(widths, t.maxWidth(w))
+
+	var b strings.Builder
+	for _, row := range append([][]string{t.Headers}, t.Rows...) {
+		for i, cell := range row {
+			cell = truncate(cell, widths[i])
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+columnPadding))
+			}
+		}
+		b.WriteString("\n")
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
+
+// --- returns the length of the longest value in each column, headers included ---
+func (t *TerminalTable) columnWidths() []int {
+	widths := make([]int, len(t.Headers))
+	for _, row := range append([][]string{t.Headers}, t.Rows...) {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	return widths
+}
+
+// --- shrinks the truncated column so the table fits into maxWidth, keeping room for at least one character and the ellipsis ---
+func (t *TerminalTable) fitWidth(widths []int, maxWidth int) {
+	if maxWidth <= 0 || t.TruncateColumn < 0 || t.TruncateColumn >= len(widths) {
+		return
+	}
+	total := columnPadding * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+	if total <= maxWidth {
+		return
+	}
+	minWidth := min(widths[t.TruncateColumn], len(ellipsis)+1)
+	widths[t.TruncateColumn] = max(widths[t.TruncateColumn]-(total-maxWidth), minWidth)
+}
+
+// --- returns the width to fit the table into, 0 when w is not a terminal ---
+func (t *TerminalTable) maxWidth(w io.Writer) int {
+	if t.Width != 0 {
+		return t.Width
+	}
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// --- shortens cell to width characters, ending in an ellipsis ---
+func truncate(cell string, width int) string {
+	runes := []rune(cell)
+	if len(runes) <= width {
+		return cell
+	}
+	if width <= len(ellipsis) {
+		return string(runes[:width])
+	}
+	return string(runes[:width-len(ellipsis)]) + ellipsis
+}
+
+// --- writes rows as an aligned plain text table with a header line, shortening the first column to fit the terminal ---
+func WriteTable(w io.Writer, headers []string, rows [][]string) error {
+	return (&TerminalTable{Headers: headers, Rows: rows}).Write(w)
+}
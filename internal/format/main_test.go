@@ -0,0 +1,382 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sampleReport struct {
+	Applied []string `json:"applied"`
+	DryRun  bool     `json:"dryRun"`
+}
+
+func TestWriteReport_File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	want := sampleReport{Applied: []string{"repo1"}, DryRun: true}
+
+	if err := WriteReport(path, want); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var got sampleReport
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Report is not valid JSON: %v", err)
+	}
+	if len(got.Applied) != 1 || got.Applied[0] != "repo1" || !got.DryRun {
+		t.Errorf("Unexpected report content: %+v", got)
+	}
+
+	// --- no temporary files should be left behind ---
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the report file in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestWriteReport_Stdout(t *testing.T) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := WriteReport("-", sampleReport{Applied: []string{"repo1"}})
+
+	if cerr := w.Close(); cerr != nil {
+		t.Fatalf("Failed to close pipe writer: %v", cerr)
+	}
+	os.Stdout = oldStdout
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		t.Fatalf("io.Copy failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"applied": [`)) {
+		t.Errorf("Expected indented JSON on stdout, got: %s", buf.String())
+	}
+}
+
+func TestWriteReport_MissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "report.json")
+	if err := WriteReport(path, sampleReport{}); err == nil {
+		t.Errorf("Expected error for missing directory, got nil")
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.jsonl")
+	records := []interface{}{sampleReport{Applied: []string{"a"}}, sampleReport{DryRun: true}}
+	if err := WriteJSONLines(path, records); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	want := `{"applied":["a"],"dryRun":false}` + "\n" + `{"applied":null,"dryRun":true}` + "\n"
+	if string(data) != want {
+		t.Errorf("Expected %q, got: %q", want, data)
+	}
+	if _, err := ParseJSONMode("ndjson"); err == nil {
+		t.Errorf("Expected error for unknown JSON mode")
+	}
+}
+
+func TestWriteDelimited(t *testing.T) {
+	headers := []string{"repository", "error"}
+	rows := [][]string{{"app", ""}, {"web", "denied, twice"}}
+	for name, want := range map[string]string{
+		"comma": "repository,error\napp,\nweb,\"denied, twice\"\n",
+		"tab":   "repository\terror\napp\t\nweb\tdenied, twice\n",
+		"pipe":  "repository|error\napp|\nweb|denied, twice\n",
+	} {
+		sep, err := ParseSeparator(name)
+		if err != nil {
+			t.Fatalf("Expected %s to parse, got: %v", name, err)
+		}
+		path := filepath.Join(t.TempDir(), "report.csv")
+		if err := WriteDelimited(path, headers, rows, sep); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != want {
+			t.Errorf("%s: expected %q, got: %q", name, want, data)
+		}
+	}
+	if _, err := ParseSeparator(";"); err == nil {
+		t.Errorf("Expected error for unknown separator")
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTable(&buf, []string{"REPOSITORY", "IMAGES"}, [][]string{
+		{"a", "1"},
+		{"long-repository", "12"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "REPOSITORY       IMAGES\na                1\nlong-repository  12\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nwant:\n%q", buf.String(), expected)
+	}
+}
+
+func TestTerminalTable_Truncate(t *testing.T) {
+	table := NewTerminalTable([]string{"REPOSITORY", "IMAGES"}, 0)
+	table.AddRow("team/very-long-repository-name", "3")
+	table.AddRow("app", "12")
+	table.Width = 20
+
+	var buf bytes.Buffer
+	if err := table.Write(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "REPOSITORY    IMAGES\nteam/very...  3\napp           12\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nwant:\n%q", buf.String(), expected)
+	}
+}
+
+func TestTerminalTable_NotATerminal(t *testing.T) {
+	// --- a file is not a terminal, so the table keeps its full width ---
+	f, err := os.Create(filepath.Join(t.TempDir(), "table.txt"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	table := NewTerminalTable([]string{"REPOSITORY", "IMAGES"}, 0)
+	table.AddRow("team/very-long-repository-name", "3")
+	if err := table.Write(f); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.Contains(data, []byte("team/very-long-repository-name  3\n")) {
+		t.Errorf("Expected the full repository name, got:\n%s", data)
+	}
+}
+
+func TestTerminalTable_MinimumWidth(t *testing.T) {
+	table := NewTerminalTable([]string{"REPOSITORY", "ACTION"}, 0)
+	table.AddRow("long-repository", "delete")
+	table.Width = 5
+
+	var buf bytes.Buffer
+	if err := table.Write(&buf); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "R...  ACTION\nl...  delete\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nwant:\n%q", buf.String(), expected)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xml")
+	suite := JUnitSuite{
+		Name:     "clean",
+		Duration: 1500 * time.Millisecond,
+		Cases: []JUnitCase{
+			{Name: "app", ClassName: "clean"},
+			{Name: "web", ClassName: "clean", Failure: "failed to delete 1 of 2 images", Details: "sha256:1: denied (AccessDenied)"},
+			{Name: "legacy", ClassName: "clean", Skipped: "skipped"},
+		},
+	}
+	if err := WriteJUnit(path, suite); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+
+	var parsed junitTestSuites
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("Expected valid XML, got: %v\n%s", err, data)
+	}
+	if len(parsed.Suites) != 1 {
+		t.Fatalf("Expected one test suite, got: %+v", parsed)
+	}
+	got := parsed.Suites[0]
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || got.Time != "1.500" {
+		t.Errorf("Unexpected suite counts: %+v", got)
+	}
+	if got.Cases[1].Failure == nil || got.Cases[1].Failure.Text != "sha256:1: denied (AccessDenied)" || got.Cases[0].Failure != nil {
+		t.Errorf("Expected only the web case to fail, got: %+v", got.Cases)
+	}
+}
+
+func TestParseOutputFormat(t *testing.T) {
+	for name, want := range map[string]OutputFormat{"json": OutputJSON, "JUnit": OutputJUnit} {
+		if got, err := ParseOutputFormat(name); err != nil || got != want {
+			t.Errorf("ParseOutputFormat(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseOutputFormat("yaml"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	for name, want := range map[string]string{"": "UTC", " Europe/Berlin ": "Europe/Berlin", "America/New_York": "America/New_York"} {
+		if got, err := ParseTimezone(name); err != nil || got.String() != want {
+			t.Errorf("ParseTimezone(%q) = %v, %v, want %s", name, got, err, want)
+		}
+	}
+	if _, err := ParseTimezone("Mars/Olympus"); err == nil {
+		t.Errorf("Expected error for unknown time zone")
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	for value, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 5 * 1024 * 1024: "5.0 MiB", 3 << 30: "3.0 GiB"} {
+		if got := HumanBytes(value); got != want {
+			t.Errorf("HumanBytes(%d) = %q, want %q", value, got, want)
+		}
+	}
+	if got := HumanBytes(2048); got != "2.0 KiB" {
+		t.Errorf("Expected int values to be accepted, got %q", got)
+	}
+	if got := Pluralize(1, "image"); got != "1 image" {
+		t.Errorf("Pluralize(1) = %q", got)
+	}
+	if got := Pluralize(3, "image"); got != "3 images" {
+		t.Errorf("Pluralize(3) = %q", got)
+	}
+	if got := Pluralize(0, "repository", "repositories"); got != "0 repositories" {
+		t.Errorf("Pluralize with plural form = %q", got)
+	}
+	if got := TimeAgo(time.Now().Add(-3 * time.Hour)); got != "3h ago" {
+		t.Errorf("TimeAgo(3h) = %q", got)
+	}
+	if got := TimeAgo(time.Now().Add(-50 * time.Hour)); got != "2d ago" {
+		t.Errorf("TimeAgo(50h) = %q", got)
+	}
+	if got := TimeAgo(time.Time{}); got != "never" {
+		t.Errorf("TimeAgo(zero) = %q", got)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for value, want := range map[string]int64{"1024": 1024, "50GiB": 50 << 30, "1.5 KiB": 1536, "500MB": 500e6, "2tb": 2e12, "0": 0} {
+		if got, err := ParseBytes(value); err != nil || got != want {
+			t.Errorf("ParseBytes(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "GiB", "50 PB", "-1GiB", "1.2.3MB"} {
+		if _, err := ParseBytes(value); err == nil {
+			t.Errorf("Expected ParseBytes(%q) to fail", value)
+		}
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	if got := BuiltinTemplates("clean"); !reflect.DeepEqual(got, []string{"markdown-table", "minimal", "slack"}) {
+		t.Errorf("Unexpected clean templates: %v", got)
+	}
+	if got := BuiltinTemplates("analyzeLayers"); !reflect.DeepEqual(got, []string{"minimal", "slack"}) {
+		t.Errorf("Unexpected analyzeLayers templates: %v", got)
+	}
+
+	// --- the family template wins over the generic one of the same name ---
+	tmpl, err := ParseBuiltinTemplate("slack", "setPolicy")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, struct {
+		Applied, Skipped []string
+		Failed           []struct{ Repository, Message string }
+		DryRun           bool
+		PolicyID         string
+	}{Applied: []string{"a", "b"}, PolicyID: "v2"}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "`v2` applied to 2 repositories") {
+		t.Errorf("Unexpected rendering: %q", buf.String())
+	}
+
+	if _, err := ParseBuiltinTemplate("markdown-table", "analyzeLayers"); err == nil || !strings.Contains(err.Error(), "available: minimal, slack") {
+		t.Errorf("Expected unknown template error listing the available ones, got: %v", err)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	dir := t.TempDir()
+	tmplPath := filepath.Join(dir, "report.tmpl")
+	content := `{{ pluralize (len .Applied) "repo" }}: {{ join .Applied ", " }}{{ if .DryRun }} (dry run){{ end }}`
+	if err := os.WriteFile(tmplPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	tmpl, err := ParseTemplate(tmplPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	path := filepath.Join(dir, "report.txt")
+	if err := WriteTemplate(path, tmpl, sampleReport{Applied: []string{"a", "b"}, DryRun: true}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if string(data) != "2 repos: a, b (dry run)" {
+		t.Errorf("Unexpected rendering: %q", data)
+	}
+
+	// --- unknown fields fail at render time, broken templates at parse time ---
+	if err := os.WriteFile(tmplPath, []byte("{{ .Missing }}"), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	tmpl, _ = ParseTemplate(tmplPath)
+	if err := WriteTemplate(path, tmpl, sampleReport{}); err == nil {
+		t.Errorf("Expected render error for unknown field")
+	}
+	if err := os.WriteFile(tmplPath, []byte("{{ if }}"), 0o600); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+	if _, err := ParseTemplate(tmplPath); err == nil {
+		t.Errorf("Expected parse error")
+	}
+}
+
+func TestMarshalPrometheus(t *testing.T) {
+	got := string(MarshalPrometheus([]Metric{
+		{Name: "deleted", Help: "Deleted images.", Labels: map[string]string{"repository": "app", "account": "1"}, Value: 3},
+		{Name: "duration_seconds", Help: "Run duration.", Value: 1.5},
+		{Name: "deleted", Help: "Deleted images.", Labels: map[string]string{"repository": `we"b`}, Value: 0},
+	}))
+	want := `# HELP deleted Deleted images.
+# TYPE deleted gauge
+deleted{account="1",repository="app"} 3
+deleted{repository="we\"b"} 0
+# HELP duration_seconds Run duration.
+# TYPE duration_seconds gauge
+duration_seconds 1.5
+`
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,40 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package imagedigest
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	algorithm = "sha256"
+	hexLength = 64
+)
+
+// --- canonicalizes a pasted digest such as "sha256:ABC…", 'abc…' or abc… to sha256:<lowercase hex> and validates it ---
+func Normalize(value string) (string, error) {
+	digest := strings.TrimSpace(value)
+	for _, quote := range []string{`"`, `'`, "`"} {
+		if len(digest) >= 2 && strings.HasPrefix(digest, quote) && strings.HasSuffix(digest, quote) {
+			digest = strings.TrimSpace(digest[1 : len(digest)-1])
+			break
+		}
+	}
+	hex := strings.ToLower(digest)
+	if name, rest, found := strings.Cut(hex, ":"); found {
+		if name != algorithm {
+			return "", fmt.Errorf("invalid digest %q: unsupported algorithm %q, only %s is supported", value, name, algorithm)
+		}
+		hex = rest
+	}
+	if len(hex) != hexLength {
+		return "", fmt.Errorf("invalid digest %q: expected %d hex characters, got %d", value, hexLength, len(hex))
+	}
+	for i, c := range hex {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return "", fmt.Errorf("invalid digest %q: non-hex character %q at position %d", value, c, i+1)
+		}
+	}
+	return algorithm + ":" + hex, nil
+}
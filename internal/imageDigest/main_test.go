@@ -0,0 +1,41 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package imagedigest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	hex := strings.Repeat("ab12", 16)
+	want := "sha256:" + hex
+	for _, value := range []string{
+		want,
+		hex,
+		strings.ToUpper(want),
+		` "` + want + `" `,
+		"'" + hex + "'",
+		"`" + want + "`",
+	} {
+		got, err := Normalize(value)
+		if err != nil || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	invalid := map[string]string{
+		"":                         "expected 64 hex characters, got 0",
+		"sha256:abc":               "expected 64 hex characters, got 3",
+		"sha512:" + hex:            `unsupported algorithm "sha512"`,
+		"sha256:" + hex[:63] + "g": `non-hex character 'g' at position 64`,
+		`"` + hex:                  "expected 64 hex characters, got 65",
+		"sha256:" + hex + strings.Repeat("0", 64): "expected 64 hex characters, got 128",
+	}
+	for value, wantErr := range invalid {
+		_, err := Normalize(value)
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Expected error containing %q for %q, got: %v", wantErr, value, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package imageref
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- ImageReference is a parsed registry/repository:tag@digest image reference ---
+type ImageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// --- parses an image reference, the runtime prefixes such as docker-pullable:// found in container statuses are dropped ---
+// --- references without a registry (e.g. nginx:1.27) are returned with an empty Registry ---
+func Parse(ref string) (ImageReference, error) {
+	if _, rest, ok := strings.Cut(ref, "://"); ok {
+		ref = rest
+	}
+	if ref == "" {
+		return ImageReference{}, fmt.Errorf("empty image reference")
+	}
+	var parsed ImageReference
+	name := ref
+	if before, digest, ok := strings.Cut(name, "@"); ok {
+		name, parsed.Digest = before, digest
+		if !strings.Contains(digest, ":") {
+			return ImageReference{}, fmt.Errorf("invalid image reference %q: digest %q has no algorithm", ref, digest)
+		}
+	}
+	// --- a colon after the last slash separates the tag, one before it belongs to a registry port ---
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, parsed.Tag = name[:i], name[i+1:]
+	}
+	if registry, repository, ok := strings.Cut(name, "/"); ok && strings.ContainsAny(registry, ".:") {
+		parsed.Registry, name = registry, repository
+	}
+	if name == "" {
+		return ImageReference{}, fmt.Errorf("invalid image reference %q: the repository is missing", ref)
+	}
+	parsed.Repository = name
+	return parsed, nil
+}
@@ -0,0 +1,34 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package imageref
+
+import "testing"
+
+const registry = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want ImageReference
+	}{
+		{registry + "/team/app:1.2", ImageReference{Registry: registry, Repository: "team/app", Tag: "1.2"}},
+		{registry + "/app@sha256:abc", ImageReference{Registry: registry, Repository: "app", Digest: "sha256:abc"}},
+		{registry + "/app:1.2@sha256:abc", ImageReference{Registry: registry, Repository: "app", Tag: "1.2", Digest: "sha256:abc"}},
+		{"docker-pullable://" + registry + "/app@sha256:abc", ImageReference{Registry: registry, Repository: "app", Digest: "sha256:abc"}},
+		{"localhost:5000/app", ImageReference{Registry: "localhost:5000", Repository: "app"}},
+		{"library/nginx:1.27", ImageReference{Repository: "library/nginx", Tag: "1.27"}},
+		{"nginx", ImageReference{Repository: "nginx"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+
+	for _, ref := range []string{"", registry + "/app@abc", registry + "/"} {
+		if _, err := Parse(ref); err == nil {
+			t.Errorf("Expected an error for %q", ref)
+		}
+	}
+}
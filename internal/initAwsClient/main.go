@@ -5,9 +5,13 @@ package initawsclient
 import (
 	"context"
 
+	ecsprotection "ecr-lifecycle-cleaner/internal/ecsProtection"
+	orgaccounts "ecr-lifecycle-cleaner/internal/orgAccounts"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
@@ -47,3 +51,51 @@ func NewECRClient(ctx context.Context, loadConfig ConfigLoader) (*ecr.Client, st
 	client := ecr.NewFromConfig(cfg)
 	return client, aws.ToString(identity.Account), cfg.Region, nil
 }
+
+// --- returns the ARN of the identity making the calls, e.g. to record who changed something ---
+func CallerARN(ctx context.Context, loadConfig ConfigLoader) (string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(identity.Arn), nil
+}
+
+// --- returns an S3 client from the same configuration as the ECR client, with the account and region it runs in ---
+func NewS3Client(ctx context.Context, loadConfig ConfigLoader) (*s3.Client, string, string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, "", "", err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return s3.NewFromConfig(cfg), aws.ToString(identity.Account), cfg.Region, nil
+}
+
+// --- returns an ECS client from the same configuration as the ECR client, to read the images of running tasks ---
+func NewECSClient(ctx context.Context, loadConfig ConfigLoader) (*ecsprotection.Client, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ecsprotection.NewClient(cfg), nil
+}
+
+// --- returns an Organizations client from the same configuration as the ECR client, with the account and region it runs in ---
+func NewOrganizationsClient(ctx context.Context, loadConfig ConfigLoader) (*orgaccounts.Client, string, string, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, "", "", err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, "", "", err
+	}
+	return orgaccounts.NewClient(cfg), aws.ToString(identity.Account), cfg.Region, nil
+}
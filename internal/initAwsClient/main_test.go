@@ -136,3 +136,71 @@ func TestNewECRClient(t *testing.T) {
 	}
 	// --- account will be empty string in this mock, which is fine for this test ---
 }
+
+func TestCallerARN(t *testing.T) {
+	getCallerIdentityMiddleware := middleware.FinalizeMiddlewareFunc(
+		"GetCallerIdentityMock",
+		func(ctx context.Context, input middleware.FinalizeInput, handler middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if middleware.GetOperationName(ctx) == "GetCallerIdentity" {
+				return middleware.FinalizeOutput{
+					Result: &sts.GetCallerIdentityOutput{Arn: aws.String("arn:aws:iam::123456789012:role/ci")},
+				}, middleware.Metadata{}, nil
+			}
+			return handler.HandleFinalize(ctx, input)
+		},
+	)
+
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion("us-west-2"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			func(stack *middleware.Stack) error {
+				return stack.Finalize.Add(getCallerIdentityMiddleware, middleware.Before)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	arn, err := CallerARN(context.TODO(), func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return cfg, nil
+	})
+	if err != nil || arn != "arn:aws:iam::123456789012:role/ci" {
+		t.Errorf("Unexpected caller ARN: %s, %v", arn, err)
+	}
+}
+
+func TestNewS3Client(t *testing.T) {
+	getCallerIdentityMiddleware := middleware.FinalizeMiddlewareFunc(
+		"GetCallerIdentityMock",
+		func(ctx context.Context, input middleware.FinalizeInput, handler middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			if middleware.GetOperationName(ctx) == "GetCallerIdentity" {
+				return middleware.FinalizeOutput{
+					Result: &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")},
+				}, middleware.Metadata{}, nil
+			}
+			return handler.HandleFinalize(ctx, input)
+		},
+	)
+
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion("us-west-2"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			func(stack *middleware.Stack) error {
+				return stack.Finalize.Add(getCallerIdentityMiddleware, middleware.Before)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	client, account, region, err := NewS3Client(context.TODO(), func(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return cfg, nil
+	})
+	if err != nil || client == nil || account != "123456789012" || region != "us-west-2" {
+		t.Errorf("Unexpected result: %v, %s, %s, %v", client, account, region, err)
+	}
+}
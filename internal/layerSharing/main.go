@@ -0,0 +1,171 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package layersharing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"ecr-lifecycle-cleaner/internal/sliceutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- number of layers listed in TopSharedLayers ---
+const topSharedLayers = 10
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to read manifests ---
+type ECRAPI interface {
+	ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error)
+	BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+}
+
+// --- LayerUsage describes how often a single layer is referenced ---
+type LayerUsage struct {
+	Digest       string   `json:"digest"`
+	Size         int64    `json:"size"`
+	References   int      `json:"references"`
+	Repositories []string `json:"repositories"`
+}
+
+// --- LayerSharingReport compares the layer bytes referenced by manifests with the unique bytes ECR stores ---
+type LayerSharingReport struct {
+	Repositories         int          `json:"repositories"`
+	Images               int          `json:"images"`
+	UniqueLayerCount     int          `json:"uniqueLayerCount"`
+	TotalLayerReferences int          `json:"totalLayerReferences"`
+	UniqueBytes          int64        `json:"uniqueBytes"`
+	ReferencedBytes      int64        `json:"referencedBytes"`
+	TopSharedLayers      []LayerUsage `json:"topSharedLayers"`
+	// --- bytes that would be stored twice or more if layers were not shared ---
+	EstimatedDeduplicationSavings int64 `json:"estimatedDeduplicationSavings"`
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r LayerSharingReport) Summary() string {
+	return fmt.Sprintf("Analyzed %d images in %d repos: %d unique layers, %d layer references, %d bytes saved by sharing",
+		r.Images, r.Repositories, r.UniqueLayerCount, r.TotalLayerReferences, r.EstimatedDeduplicationSavings)
+}
+
+// --- the subset of an image manifest holding layer digests, index manifests have no layers ---
+type imageManifest struct {
+	Layers []struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"layers"`
+}
+
+// --- reads the manifests of every image in the repositories and counts how many images reference each layer ---
+func AnalyzeLayerSharing(ctx context.Context, repositories []string, client ECRAPI) (LayerSharingReport, error) {
+	report := LayerSharingReport{Repositories: len(repositories)}
+	layers := map[string]*LayerUsage{}
+
+	for _, repo := range repositories {
+		digests, err := imageDigests(ctx, repo, client)
+		if err != nil {
+			return report, err
+		}
+		for _, part := range sliceutil.Partition(digests, 100) {
+			manifests, err := getManifests(ctx, repo, part, client)
+			if err != nil {
+				return report, err
+			}
+			for _, manifest := range manifests {
+				// --- index manifests only point at other images, which are counted on their own ---
+				if len(manifest.Layers) == 0 {
+					continue
+				}
+				report.Images++
+				for _, layer := range manifest.Layers {
+					usage, ok := layers[layer.Digest]
+					if !ok {
+						usage = &LayerUsage{Digest: layer.Digest, Size: layer.Size}
+						layers[layer.Digest] = usage
+					}
+					usage.References++
+					if n := len(usage.Repositories); n == 0 || usage.Repositories[n-1] != repo {
+						usage.Repositories = append(usage.Repositories, repo)
+					}
+					report.TotalLayerReferences++
+					report.ReferencedBytes += layer.Size
+				}
+			}
+		}
+	}
+
+	usages := make([]LayerUsage, 0, len(layers))
+	for _, usage := range layers {
+		report.UniqueBytes += usage.Size
+		usages = append(usages, *usage)
+	}
+	report.UniqueLayerCount = len(usages)
+	report.EstimatedDeduplicationSavings = report.ReferencedBytes - report.UniqueBytes
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].References != usages[j].References {
+			return usages[i].References > usages[j].References
+		}
+		if usages[i].Size != usages[j].Size {
+			return usages[i].Size > usages[j].Size
+		}
+		return usages[i].Digest < usages[j].Digest
+	})
+	for _, usage := range usages {
+		if usage.References < 2 || len(report.TopSharedLayers) == topSharedLayers {
+			break
+		}
+		report.TopSharedLayers = append(report.TopSharedLayers, usage)
+	}
+	return report, nil
+}
+
+// --- returns the distinct image digests of a repository, an image with several tags is listed once ---
+func imageDigests(ctx context.Context, repository string, client ECRAPI) ([]string, error) {
+	var digests []string
+	seen := map[string]bool{}
+	paginator := ecr.NewListImagesPaginator(client, &ecr.ListImagesInput{RepositoryName: aws.String(repository)})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images for repository %s: %w", repository, err)
+		}
+		for _, image := range page.ImageIds {
+			digest := aws.ToString(image.ImageDigest)
+			if !seen[digest] {
+				seen[digest] = true
+				digests = append(digests, digest)
+			}
+		}
+	}
+	return digests, nil
+}
+
+// --- returns the parsed manifests for a batch of image digests ---
+func getManifests(ctx context.Context, repository string, digests []string, client ECRAPI) ([]imageManifest, error) {
+	imageIds := make([]types.ImageIdentifier, 0, len(digests))
+	for _, digest := range digests {
+		imageIds = append(imageIds, types.ImageIdentifier{ImageDigest: aws.String(digest)})
+	}
+	result, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       imageIds,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to batch get images for repository %s: %w", repository, err)
+	}
+
+	manifests := make([]imageManifest, 0, len(result.Images))
+	for _, image := range result.Images {
+		var manifest imageManifest
+		if err := json.Unmarshal([]byte(aws.ToString(image.ImageManifest)), &manifest); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal image manifest for repository %s: %w", repository, err)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
@@ -0,0 +1,89 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package layersharing
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- mock ECR client serving manifests by repository and digest ---
+type mockECRClient struct {
+	images    map[string][]types.ImageIdentifier
+	manifests map[string]string
+	getErr    error
+}
+
+func (m *mockECRClient) ListImages(ctx context.Context, in *ecr.ListImagesInput, optFns ...func(*ecr.Options)) (*ecr.ListImagesOutput, error) {
+	return &ecr.ListImagesOutput{ImageIds: m.images[aws.ToString(in.RepositoryName)]}, nil
+}
+
+func (m *mockECRClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	out := &ecr.BatchGetImageOutput{}
+	for _, id := range in.ImageIds {
+		out.Images = append(out.Images, types.Image{ImageId: &id, ImageManifest: aws.String(m.manifests[aws.ToString(id.ImageDigest)])})
+	}
+	return out, nil
+}
+
+func TestAnalyzeLayerSharing(t *testing.T) {
+	client := &mockECRClient{
+		images: map[string][]types.ImageIdentifier{
+			"repo-a": {
+				{ImageDigest: aws.String("img1"), ImageTag: aws.String("v1")},
+				{ImageDigest: aws.String("img1"), ImageTag: aws.String("latest")},
+				{ImageDigest: aws.String("img2"), ImageTag: aws.String("v2")},
+			},
+			"repo-b": {
+				{ImageDigest: aws.String("img3"), ImageTag: aws.String("v1")},
+				{ImageDigest: aws.String("index")},
+			},
+		},
+		manifests: map[string]string{
+			"img1":  `{"layers":[{"digest":"base","size":100},{"digest":"app1","size":10}]}`,
+			"img2":  `{"layers":[{"digest":"base","size":100},{"digest":"app2","size":20}]}`,
+			"img3":  `{"layers":[{"digest":"base","size":100},{"digest":"app2","size":20}]}`,
+			"index": `{"manifests":[{"digest":"img3"}]}`,
+		},
+	}
+
+	report, err := AnalyzeLayerSharing(context.TODO(), []string{"repo-a", "repo-b"}, client)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if report.Images != 3 || report.UniqueLayerCount != 3 || report.TotalLayerReferences != 6 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	if report.ReferencedBytes != 350 || report.UniqueBytes != 130 || report.EstimatedDeduplicationSavings != 220 {
+		t.Errorf("Unexpected byte totals: %+v", report)
+	}
+	want := []LayerUsage{
+		{Digest: "base", Size: 100, References: 3, Repositories: []string{"repo-a", "repo-b"}},
+		{Digest: "app2", Size: 20, References: 2, Repositories: []string{"repo-a", "repo-b"}},
+	}
+	if !reflect.DeepEqual(report.TopSharedLayers, want) {
+		t.Errorf("Expected %+v, got: %+v", want, report.TopSharedLayers)
+	}
+	if got := report.Summary(); got != "Analyzed 3 images in 2 repos: 3 unique layers, 6 layer references, 220 bytes saved by sharing" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+}
+
+func TestAnalyzeLayerSharing_Error(t *testing.T) {
+	client := &mockECRClient{
+		images: map[string][]types.ImageIdentifier{"repo-a": {{ImageDigest: aws.String("img1")}}},
+		getErr: errors.New("fail"),
+	}
+	if _, err := AnalyzeLayerSharing(context.TODO(), []string{"repo-a"}, client); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
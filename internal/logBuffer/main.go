@@ -0,0 +1,196 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package logbuffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// --- how long commands wait for buffered messages to be written before exiting ---
+const FlushTimeout = 5 * time.Second
+
+// --- LogOrder controls how the messages of a phase are ordered before they are buffered ---
+type LogOrder int
+
+const (
+	// --- sorted by message text, the default, keeps the output stable between runs ---
+	LogOrderAlphabetical LogOrder = iota
+	// --- in the order the messages were recorded ---
+	LogOrderChronological
+	// --- grouped by repository with repositories sorted by name, in the order recorded within a repository ---
+	LogOrderByRepository
+)
+
+var logOrderNames = map[LogOrder]string{
+	LogOrderAlphabetical:  "alphabetical",
+	LogOrderChronological: "chronological",
+	LogOrderByRepository:  "repository",
+}
+
+func (o LogOrder) String() string {
+	return logOrderNames[o]
+}
+
+// --- parses alphabetical, chronological or repository ---
+func ParseLogOrder(value string) (LogOrder, error) {
+	for order, name := range logOrderNames {
+		if name == value {
+			return order, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log order %q, expected alphabetical, chronological or repository", value)
+}
+
+// --- Entry is a log message with the time it was recorded ---
+type Entry struct {
+	Time    time.Time
+	Message string
+}
+
+// --- returns message stamped with the current time, call it when the message is recorded ---
+func NewEntry(message string) Entry {
+	return Entry{Time: time.Now(), Message: message}
+}
+
+// --- matches the repository name in messages such as "Repository: app - ..." or "Checking repository: app" ---
+var repositoryPattern = regexp.MustCompile(`(?i)repository:?\s+([^\s,;]+)`)
+
+// --- returns the repository a message is about, empty when it names none ---
+func repositoryOf(message string) string {
+	if match := repositoryPattern.FindStringSubmatch(message); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// --- returns the messages of entries in the given order ---
+func Order(entries []Entry, order LogOrder) []string {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	switch order {
+	case LogOrderChronological:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Time.Before(sorted[j].Time)
+		})
+	case LogOrderByRepository:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if ri, rj := repositoryOf(sorted[i].Message), repositoryOf(sorted[j].Message); ri != rj {
+				return ri < rj
+			}
+			return sorted[i].Time.Before(sorted[j].Time)
+		})
+	default:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Message < sorted[j].Message
+		})
+	}
+	messages := make([]string, len(sorted))
+	for i, entry := range sorted {
+		messages[i] = entry.Message
+	}
+	return messages
+}
+
+// --- LogBuffer holds log messages until the caller flushes them, so nothing is printed half way through a phase ---
+type LogBuffer struct {
+	mu       sync.Mutex
+	messages []string
+	order    LogOrder
+}
+
+// --- Option configures a LogBuffer ---
+type Option func(*LogBuffer)
+
+// --- orders the messages of each phase by order instead of alphabetically ---
+func WithLogOrder(order LogOrder) Option {
+	return func(b *LogBuffer) {
+		b.order = order
+	}
+}
+
+// --- returns an empty buffer ---
+func New(opts ...Option) *LogBuffer {
+	b := &LogBuffer{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// --- appends messages to the buffer ---
+func (b *LogBuffer) Add(messages ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, messages...)
+}
+
+// --- orders the entries of a phase and adds them to the buffer ---
+// --- a nil buffer prints them right away with the standard logger, in alphabetical order ---
+func (b *LogBuffer) Emit(entries []Entry) {
+	if b == nil {
+		for _, message := range Order(entries, LogOrderAlphabetical) {
+			log.Println(message)
+		}
+		return
+	}
+	b.Add(Order(entries, b.order)...)
+}
+
+// --- drops the buffered messages keep rejects, e.g. the info lines a summary replaces ---
+func (b *LogBuffer) Filter(keep func(message string) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	kept := b.messages[:0]
+	for _, message := range b.messages {
+		if keep(message) {
+			kept = append(kept, message)
+		}
+	}
+	b.messages = kept
+}
+
+// --- returns the number of messages waiting to be flushed ---
+func (b *LogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.messages)
+}
+
+// --- writes the buffered messages to w in the standard log format and syncs w when it supports it ---
+// --- stops when ctx is done, messages not yet written stay in the buffer ---
+func (b *LogBuffer) Flush(ctx context.Context, w io.Writer) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	logger := log.New(w, log.Prefix(), log.Flags())
+	written := 0
+	defer func() {
+		b.messages = b.messages[written:]
+	}()
+	for _, message := range b.messages {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("flushed %d of %d log messages: %w", written, len(b.messages), err)
+		}
+		if err := logger.Output(2, message); err != nil {
+			return fmt.Errorf("failed to write log message: %w", err)
+		}
+		written++
+	}
+
+	if syncer, ok := w.(interface{ Sync() error }); ok {
+		// --- terminals and pipes cannot be synced, that is not a failure ---
+		if err := syncer.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+			return fmt.Errorf("failed to sync log output: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package logbuffer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- records whether Sync was called ---
+type syncWriter struct {
+	bytes.Buffer
+	synced bool
+}
+
+func (w *syncWriter) Sync() error {
+	w.synced = true
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	buf := New(WithLogOrder(LogOrderChronological))
+	buf.Add("[INFO] first")
+	buf.Emit([]Entry{NewEntry("[INFO] second"), NewEntry("[ERROR] third")})
+	if buf.Len() != 3 {
+		t.Fatalf("Expected 3 buffered messages, got: %d", buf.Len())
+	}
+
+	w := &syncWriter{}
+	if err := buf.Flush(context.TODO(), w); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	out := w.String()
+	if !strings.Contains(out, "[INFO] first\n") || strings.Index(out, "second") > strings.Index(out, "third") {
+		t.Errorf("Expected messages in insertion order, got: %s", out)
+	}
+	if !w.synced {
+		t.Errorf("Expected writer to be synced")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected buffer to be empty after flush, got: %d", buf.Len())
+	}
+}
+
+func TestFlush_Deadline(t *testing.T) {
+	buf := New()
+	buf.Add("[INFO] kept")
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	var w bytes.Buffer
+	err := buf.Flush(ctx, &w)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context error, got: %v", err)
+	}
+	if w.Len() != 0 || buf.Len() != 1 {
+		t.Errorf("Expected unwritten messages to stay buffered, wrote %q, %d left", w.String(), buf.Len())
+	}
+}
+
+func TestEmit_NilBuffer(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(io.Discard)
+
+	var buf *LogBuffer
+	buf.Emit([]Entry{NewEntry("[INFO] direct")})
+	if !strings.Contains(out.String(), "[INFO] direct") {
+		t.Errorf("Expected nil buffer to log directly, got: %s", out.String())
+	}
+}
+
+func TestOrder(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start.Add(3 * time.Second), Message: "[INFO] Repository: web - Deleted 1 images"},
+		{Time: start, Message: "[INFO] Checking repository: web"},
+		{Time: start.Add(1 * time.Second), Message: "[INFO] Checking repository: app"},
+		{Time: start.Add(4 * time.Second), Message: "[ERROR] Repository: app - Failed to delete sha256:1"},
+		{Time: start.Add(2 * time.Second), Message: "[DRY RUN] Would delete 2 images from repository: db"},
+	}
+	tests := []struct {
+		order LogOrder
+		want  []string
+	}{
+		{LogOrderAlphabetical, []string{
+			"[DRY RUN] Would delete 2 images from repository: db",
+			"[ERROR] Repository: app - Failed to delete sha256:1",
+			"[INFO] Checking repository: app",
+			"[INFO] Checking repository: web",
+			"[INFO] Repository: web - Deleted 1 images",
+		}},
+		{LogOrderChronological, []string{
+			"[INFO] Checking repository: web",
+			"[INFO] Checking repository: app",
+			"[DRY RUN] Would delete 2 images from repository: db",
+			"[INFO] Repository: web - Deleted 1 images",
+			"[ERROR] Repository: app - Failed to delete sha256:1",
+		}},
+		{LogOrderByRepository, []string{
+			"[INFO] Checking repository: app",
+			"[ERROR] Repository: app - Failed to delete sha256:1",
+			"[DRY RUN] Would delete 2 images from repository: db",
+			"[INFO] Checking repository: web",
+			"[INFO] Repository: web - Deleted 1 images",
+		}},
+	}
+	for _, tt := range tests {
+		if got := Order(entries, tt.order); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Order(%s) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+	if entries[0].Time != start.Add(3*time.Second) {
+		t.Errorf("Expected Order not to reorder its input")
+	}
+}
+
+func TestParseLogOrder(t *testing.T) {
+	for _, order := range []LogOrder{LogOrderAlphabetical, LogOrderChronological, LogOrderByRepository} {
+		if got, err := ParseLogOrder(order.String()); err != nil || got != order {
+			t.Errorf("ParseLogOrder(%q) = %v, %v", order.String(), got, err)
+		}
+	}
+	if _, err := ParseLogOrder("random"); err == nil {
+		t.Errorf("Expected error for an unknown order")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	buf := New()
+	buf.Add("[INFO] dropped", "[WARN] kept", "[INFO] also dropped", "[ERROR] kept too")
+	buf.Filter(func(message string) bool {
+		return !strings.HasPrefix(message, "[INFO]")
+	})
+	if got := buf.Len(); got != 2 {
+		t.Fatalf("Expected 2 buffered messages, got: %d", got)
+	}
+
+	var w bytes.Buffer
+	if err := buf.Flush(context.TODO(), &w); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if out := w.String(); strings.Contains(out, "[INFO]") || !strings.Contains(out, "[WARN] kept") || !strings.Contains(out, "[ERROR] kept too") {
+		t.Errorf("Expected only the warning and error, got: %s", out)
+	}
+}
@@ -0,0 +1,104 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package orgaccounts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awsjson "ecr-lifecycle-cleaner/internal/awsJSON"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// --- OrganizationsAPI defines the AWS Organizations operation needed to enumerate the member accounts ---
+type OrganizationsAPI interface {
+	ListAccounts(ctx context.Context, in *ListAccountsInput) (*ListAccountsOutput, error)
+}
+
+// --- the request and response fields of ListAccounts the enumeration needs ---
+type ListAccountsInput struct {
+	MaxResults int32  `json:"MaxResults,omitempty"`
+	NextToken  string `json:"NextToken,omitempty"`
+}
+
+type ListAccountsOutput struct {
+	Accounts  []Account `json:"Accounts"`
+	NextToken string    `json:"NextToken"`
+}
+
+// --- Account is a member account of the organization ---
+type Account struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Status string `json:"Status"`
+}
+
+// --- the status of accounts that can be worked in, suspended and closing accounts are left out ---
+const StatusActive = "ACTIVE"
+
+// --- the largest page ListAccounts returns ---
+const accountsPerPage = 20
+
+// --- returns the IDs of the active accounts of the organization, in the order ListAccounts returns them ---
+// --- must be called with credentials of the management account or a delegated administrator ---
+func OrgAccountLister(ctx context.Context, orgClient OrganizationsAPI) ([]string, error) {
+	var accounts []string
+	in := &ListAccountsInput{MaxResults: accountsPerPage}
+	for {
+		out, err := orgClient.ListAccounts(ctx, in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the accounts of the organization: %w", err)
+		}
+		for _, account := range out.Accounts {
+			if account.Status == StatusActive {
+				accounts = append(accounts, account.ID)
+			}
+		}
+		if out.NextToken == "" {
+			return accounts, nil
+		}
+		in.NextToken = out.NextToken
+	}
+}
+
+// --- returns the ARN of the role roleName in account, in the partition of region ---
+func RoleARN(region, account, roleName string) string {
+	partition := "aws"
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		partition = "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		partition = "aws-us-gov"
+	}
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, account, roleName)
+}
+
+// --- Client calls the Organizations JSON API, only ListAccounts is needed, which keeps the full SDK out of the build ---
+type Client struct {
+	api *awsjson.Client
+}
+
+var _ OrganizationsAPI = (*Client)(nil)
+
+// --- returns a client for the global Organizations endpoint of the partition of cfg's region ---
+func NewClient(cfg aws.Config) *Client {
+	region := "us-east-1"
+	switch {
+	case strings.HasPrefix(cfg.Region, "cn-"):
+		region = "cn-northwest-1"
+	case strings.HasPrefix(cfg.Region, "us-gov-"):
+		region = "us-gov-west-1"
+	}
+	endpoint := fmt.Sprintf("https://organizations.%s.%s/", region, awsjson.Domain(region))
+	return &Client{api: awsjson.NewClient(cfg, endpoint, "organizations", region, "AWSOrganizationsV20161128")}
+}
+
+func (c *Client) ListAccounts(ctx context.Context, in *ListAccountsInput) (*ListAccountsOutput, error) {
+	var out ListAccountsOutput
+	if err := c.api.Call(ctx, "ListAccounts", in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
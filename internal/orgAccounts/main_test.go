@@ -0,0 +1,102 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package orgaccounts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// --- serves accounts in pages of pageSize linked by NextToken ---
+type fakeOrganizations struct {
+	accounts []Account
+	pageSize int
+	calls    int
+	err      error
+}
+
+func (f *fakeOrganizations) ListAccounts(ctx context.Context, in *ListAccountsInput) (*ListAccountsOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	start := 0
+	if in.NextToken != "" {
+		start, _ = strconv.Atoi(in.NextToken)
+	}
+	end := min(start+f.pageSize, len(f.accounts))
+	out := &ListAccountsOutput{Accounts: f.accounts[start:end]}
+	if end < len(f.accounts) {
+		out.NextToken = strconv.Itoa(end)
+	}
+	return out, nil
+}
+
+func TestOrgAccountLister(t *testing.T) {
+	org := &fakeOrganizations{pageSize: 2, accounts: []Account{
+		{ID: "111111111111", Status: StatusActive},
+		{ID: "222222222222", Status: "SUSPENDED"},
+		{ID: "333333333333", Status: StatusActive},
+		{ID: "444444444444", Status: "PENDING_CLOSURE"},
+		{ID: "555555555555", Status: StatusActive},
+	}}
+	accounts, err := OrgAccountLister(context.TODO(), org)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if want := []string{"111111111111", "333333333333", "555555555555"}; !reflect.DeepEqual(accounts, want) {
+		t.Errorf("Expected %v, got: %v", want, accounts)
+	}
+	if org.calls != 3 {
+		t.Errorf("Expected 3 pages, got: %d", org.calls)
+	}
+
+	if _, err := OrgAccountLister(context.TODO(), &fakeOrganizations{err: errors.New("AccessDeniedException")}); err == nil {
+		t.Error("Expected the ListAccounts error")
+	}
+}
+
+func TestRoleARN(t *testing.T) {
+	for region, want := range map[string]string{
+		"eu-west-1":     "arn:aws:iam::123456789012:role/Cleaner",
+		"cn-north-1":    "arn:aws-cn:iam::123456789012:role/Cleaner",
+		"us-gov-west-1": "arn:aws-us-gov:iam::123456789012:role/Cleaner",
+	} {
+		if got := RoleARN(region, "123456789012", "Cleaner"); got != want {
+			t.Errorf("%s: expected %s, got %s", region, want, got)
+		}
+	}
+}
+
+func TestClient_ListAccounts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in ListAccountsInput
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if r.Header.Get("X-Amz-Target") != "AWSOrganizationsV20161128.ListAccounts" || in.MaxResults != accountsPerPage {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type": "InvalidInputException", "message": "bad request"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Accounts": [{"Id": "111111111111", "Name": "prod", "Status": "ACTIVE"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(aws.Config{Region: "eu-west-1", Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	})})
+	client.api.Endpoint = server.URL
+
+	accounts, err := OrgAccountLister(context.TODO(), client)
+	if err != nil || !reflect.DeepEqual(accounts, []string{"111111111111"}) {
+		t.Errorf("Expected [111111111111], got: %v, %v", accounts, err)
+	}
+}
@@ -0,0 +1,232 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package promoteimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	imagedigest "ecr-lifecycle-cleaner/internal/imageDigest"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- prefix of the repository resource tags recording the last promotion per environment ---
+const historyTagPrefix = "ecr-lifecycle-cleaner/promoted-to-"
+
+// --- manifest types BatchGetImage may return, so the manifest is copied byte for byte and the digest is kept ---
+var acceptedMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to promote an image ---
+type ECRAPI interface {
+	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+	BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error)
+	PutImage(ctx context.Context, in *ecr.PutImageInput, optFns ...func(*ecr.Options)) (*ecr.PutImageOutput, error)
+	BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+	TagResource(ctx context.Context, in *ecr.TagResourceInput, optFns ...func(*ecr.Options)) (*ecr.TagResourceOutput, error)
+}
+
+// --- PromoteOptions describes a single promotion ---
+type PromoteOptions struct {
+	Repository string
+	Digest     string
+	// --- tag added to the image, e.g. prod ---
+	Env string
+	// --- tag the image must already carry, e.g. staging, empty skips the check ---
+	PreviousEnv string
+	// --- removes the PreviousEnv tag once the image carries the Env tag ---
+	RemovePreviousTag bool
+	DryRun            bool
+	// --- identity recorded in the history tag ---
+	CallerARN string
+}
+
+// --- PromotionResult records what a promotion did ---
+type PromotionResult struct {
+	Repository         string    `json:"repository"`
+	Digest             string    `json:"digest"`
+	Env                string    `json:"env"`
+	PreviousEnv        string    `json:"previousEnv,omitempty"`
+	PromotedAt         time.Time `json:"promotedAt"`
+	PromotedBy         string    `json:"promotedBy"`
+	HistoryTag         string    `json:"historyTag"`
+	AlreadyTagged      bool      `json:"alreadyTagged,omitempty"`
+	RemovedPreviousTag bool      `json:"removedPreviousTag,omitempty"`
+	DryRun             bool      `json:"dryRun"`
+}
+
+// --- splits an image reference of the form repo:sha256:abc into repository and digest ---
+func ParseImageRef(ref string) (string, string, error) {
+	repository, digest, found := strings.Cut(ref, ":")
+	if !found || repository == "" || !strings.HasPrefix(digest, "sha256:") || len(digest) == len("sha256:") {
+		return "", "", fmt.Errorf("invalid image reference %q, expected repository:sha256:<digest>", ref)
+	}
+	return repository, digest, nil
+}
+
+// --- like ParseImageRef, but also accepts repo@digest and digests pasted without the sha256: prefix, quoted or in uppercase ---
+func ParseImageRefNormalized(ref string) (string, string, error) {
+	ref = strings.TrimSpace(ref)
+	repository, digest, found := strings.Cut(ref, "@")
+	if !found {
+		repository, digest, found = strings.Cut(ref, ":")
+	}
+	if !found || repository == "" {
+		return "", "", fmt.Errorf("invalid image reference %q, expected repository:<digest> or repository@<digest>", ref)
+	}
+	digest, err := imagedigest.Normalize(digest)
+	if err != nil {
+		return "", "", err
+	}
+	return repository, digest, nil
+}
+
+// --- returns the repository tag key recording promotions to env ---
+func HistoryTagKey(env string) string {
+	return historyTagPrefix + env
+}
+
+// --- returns a copy of the result with the promotion time in loc, for display, the history tag always records UTC ---
+func (r PromotionResult) InLocation(loc *time.Location) interface{} {
+	r.PromotedAt = r.PromotedAt.In(loc)
+	return r
+}
+
+// --- returns the history tag value, AWS tag values do not allow commas so timestamp and caller are separated by a space ---
+func historyTagValue(promotedAt time.Time, callerARN string) string {
+	return promotedAt.UTC().Format(time.RFC3339) + " " + callerARN
+}
+
+// --- verifies the image carries the previous environment tag, tags it with the new environment and records the promotion ---
+func Promote(ctx context.Context, client ECRAPI, opts PromoteOptions) (PromotionResult, error) {
+	result := PromotionResult{
+		Repository:  opts.Repository,
+		Digest:      opts.Digest,
+		Env:         opts.Env,
+		PreviousEnv: opts.PreviousEnv,
+		PromotedAt:  time.Now().UTC(),
+		PromotedBy:  opts.CallerARN,
+		HistoryTag:  HistoryTagKey(opts.Env),
+		DryRun:      opts.DryRun,
+	}
+	if opts.Env == "" {
+		return result, fmt.Errorf("the target environment must not be empty")
+	}
+	if opts.RemovePreviousTag && opts.PreviousEnv == "" {
+		return result, fmt.Errorf("removing the previous tag requires the previous environment")
+	}
+
+	image, err := getImage(ctx, client, opts)
+	if err != nil {
+		return result, err
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	_, err = client.PutImage(ctx, &ecr.PutImageInput{
+		RepositoryName:         aws.String(opts.Repository),
+		ImageManifest:          image.ImageManifest,
+		ImageManifestMediaType: image.ImageManifestMediaType,
+		ImageDigest:            aws.String(opts.Digest),
+		ImageTag:               aws.String(opts.Env),
+	})
+	if err != nil {
+		var exists *types.ImageAlreadyExistsException
+		if !errors.As(err, &exists) {
+			return result, fmt.Errorf("failed to tag %s@%s as %s: %w", opts.Repository, opts.Digest, opts.Env, err)
+		}
+		result.AlreadyTagged = true
+	}
+
+	if err := recordPromotion(ctx, client, opts.Repository, result); err != nil {
+		return result, err
+	}
+
+	if opts.RemovePreviousTag {
+		if err := removeTag(ctx, client, opts.Repository, opts.PreviousEnv); err != nil {
+			return result, err
+		}
+		result.RemovedPreviousTag = true
+	}
+	return result, nil
+}
+
+// --- fetches the image, by the previous environment tag when one is given so the tag is verified in the same call ---
+func getImage(ctx context.Context, client ECRAPI, opts PromoteOptions) (types.Image, error) {
+	imageID := types.ImageIdentifier{ImageDigest: aws.String(opts.Digest)}
+	if opts.PreviousEnv != "" {
+		imageID = types.ImageIdentifier{ImageTag: aws.String(opts.PreviousEnv)}
+	}
+	resp, err := client.BatchGetImage(ctx, &ecr.BatchGetImageInput{
+		RepositoryName:     aws.String(opts.Repository),
+		ImageIds:           []types.ImageIdentifier{imageID},
+		AcceptedMediaTypes: acceptedMediaTypes,
+	})
+	if err != nil {
+		return types.Image{}, fmt.Errorf("failed to get image from repository %s: %w", opts.Repository, err)
+	}
+	if len(resp.Images) == 0 {
+		if opts.PreviousEnv != "" {
+			return types.Image{}, fmt.Errorf("repository %s has no image tagged %s", opts.Repository, opts.PreviousEnv)
+		}
+		return types.Image{}, fmt.Errorf("repository %s has no image %s", opts.Repository, opts.Digest)
+	}
+
+	image := resp.Images[0]
+	if image.ImageId == nil {
+		return types.Image{}, fmt.Errorf("repository %s returned an image without an id", opts.Repository)
+	}
+	if digest := aws.ToString(image.ImageId.ImageDigest); digest != opts.Digest {
+		return types.Image{}, fmt.Errorf("image %s is not tagged %s, the tag points at %s", opts.Digest, opts.PreviousEnv, digest)
+	}
+	return image, nil
+}
+
+// --- stores the promotion time and caller as a resource tag on the repository ---
+func recordPromotion(ctx context.Context, client ECRAPI, repository string, result PromotionResult) error {
+	resp, err := client.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{repository}})
+	if err != nil {
+		return fmt.Errorf("failed to describe repository %s: %w", repository, err)
+	}
+	if len(resp.Repositories) == 0 {
+		return fmt.Errorf("repository %s not found", repository)
+	}
+	_, err = client.TagResource(ctx, &ecr.TagResourceInput{
+		ResourceArn: resp.Repositories[0].RepositoryArn,
+		Tags: []types.Tag{{
+			Key:   aws.String(result.HistoryTag),
+			Value: aws.String(historyTagValue(result.PromotedAt, result.PromotedBy)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record promotion on repository %s: %w", repository, err)
+	}
+	return nil
+}
+
+// --- removes a tag, the image itself stays because it still carries the new environment tag ---
+func removeTag(ctx context.Context, client ECRAPI, repository, tag string) error {
+	resp, err := client.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       []types.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove tag %s from repository %s: %w", tag, repository, err)
+	}
+	if len(resp.Failures) > 0 {
+		failure := resp.Failures[0]
+		return fmt.Errorf("failed to remove tag %s from repository %s: %s - %s", tag, repository, string(failure.FailureCode), aws.ToString(failure.FailureReason))
+	}
+	return nil
+}
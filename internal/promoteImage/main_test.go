@@ -0,0 +1,152 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package promoteimage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- mock ECR client with a single repository whose tags point at digests ---
+type mockECRClient struct {
+	tags       map[string]string
+	putErr     error
+	putTags    []string
+	resTags    []types.Tag
+	deleteTags []string
+}
+
+func (m *mockECRClient) DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	return &ecr.DescribeRepositoriesOutput{Repositories: []types.Repository{{
+		RepositoryName: aws.String(in.RepositoryNames[0]),
+		RepositoryArn:  aws.String("arn:aws:ecr:us-east-1:123456789012:repository/" + in.RepositoryNames[0]),
+	}}}, nil
+}
+
+func (m *mockECRClient) BatchGetImage(ctx context.Context, in *ecr.BatchGetImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetImageOutput, error) {
+	id := in.ImageIds[0]
+	digest := aws.ToString(id.ImageDigest)
+	if id.ImageTag != nil {
+		var ok bool
+		if digest, ok = m.tags[aws.ToString(id.ImageTag)]; !ok {
+			return &ecr.BatchGetImageOutput{}, nil
+		}
+	}
+	return &ecr.BatchGetImageOutput{Images: []types.Image{{
+		ImageId:                &types.ImageIdentifier{ImageDigest: aws.String(digest), ImageTag: id.ImageTag},
+		ImageManifest:          aws.String(`{"schemaVersion":2}`),
+		ImageManifestMediaType: aws.String("application/vnd.oci.image.manifest.v1+json"),
+	}}}, nil
+}
+
+func (m *mockECRClient) PutImage(ctx context.Context, in *ecr.PutImageInput, optFns ...func(*ecr.Options)) (*ecr.PutImageOutput, error) {
+	if m.putErr != nil {
+		return nil, m.putErr
+	}
+	m.putTags = append(m.putTags, aws.ToString(in.ImageTag))
+	return &ecr.PutImageOutput{}, nil
+}
+
+func (m *mockECRClient) BatchDeleteImage(ctx context.Context, in *ecr.BatchDeleteImageInput, optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	m.deleteTags = append(m.deleteTags, aws.ToString(in.ImageIds[0].ImageTag))
+	return &ecr.BatchDeleteImageOutput{ImageIds: in.ImageIds}, nil
+}
+
+func (m *mockECRClient) TagResource(ctx context.Context, in *ecr.TagResourceInput, optFns ...func(*ecr.Options)) (*ecr.TagResourceOutput, error) {
+	m.resTags = append(m.resTags, in.Tags...)
+	return &ecr.TagResourceOutput{}, nil
+}
+
+func TestParseImageRef(t *testing.T) {
+	repo, digest, err := ParseImageRef("team/app:sha256:abc")
+	if err != nil || repo != "team/app" || digest != "sha256:abc" {
+		t.Errorf("Unexpected result: %s, %s, %v", repo, digest, err)
+	}
+	for _, ref := range []string{"team/app", "team/app:latest", ":sha256:abc", "team/app:sha256:"} {
+		if _, _, err := ParseImageRef(ref); err == nil {
+			t.Errorf("Expected error for %q", ref)
+		}
+	}
+}
+
+func TestParseImageRefNormalized(t *testing.T) {
+	hex := strings.Repeat("0f", 32)
+	for _, ref := range []string{"team/app:sha256:" + hex, "team/app@sha256:" + hex, "team/app:" + hex, ` team/app@"SHA256:` + strings.ToUpper(hex) + `"`} {
+		repo, digest, err := ParseImageRefNormalized(ref)
+		if err != nil || repo != "team/app" || digest != "sha256:"+hex {
+			t.Errorf("ParseImageRefNormalized(%q) = %s, %s, %v", ref, repo, digest, err)
+		}
+	}
+	for _, ref := range []string{"team/app", "@sha256:" + hex, "team/app:sha256:abc", "team/app:latest"} {
+		if _, _, err := ParseImageRefNormalized(ref); err == nil {
+			t.Errorf("Expected error for %q", ref)
+		}
+	}
+}
+
+func TestPromote(t *testing.T) {
+	client := &mockECRClient{tags: map[string]string{"staging": "sha256:abc"}}
+	result, err := Promote(context.TODO(), client, PromoteOptions{
+		Repository:        "app",
+		Digest:            "sha256:abc",
+		Env:               "prod",
+		PreviousEnv:       "staging",
+		RemovePreviousTag: true,
+		CallerARN:         "arn:aws:iam::123456789012:role/ci",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(client.putTags) != 1 || client.putTags[0] != "prod" {
+		t.Errorf("Expected image to be tagged prod, got: %v", client.putTags)
+	}
+	if len(client.resTags) != 1 || aws.ToString(client.resTags[0].Key) != "ecr-lifecycle-cleaner/promoted-to-prod" {
+		t.Fatalf("Expected promotion history tag, got: %+v", client.resTags)
+	}
+	if value := aws.ToString(client.resTags[0].Value); !strings.HasSuffix(value, " arn:aws:iam::123456789012:role/ci") || strings.Contains(value, ",") {
+		t.Errorf("Unexpected history tag value: %s", value)
+	}
+	if len(client.deleteTags) != 1 || client.deleteTags[0] != "staging" || !result.RemovedPreviousTag {
+		t.Errorf("Expected staging tag to be removed, got: %v", client.deleteTags)
+	}
+}
+
+func TestPromote_PreviousEnvMismatch(t *testing.T) {
+	client := &mockECRClient{tags: map[string]string{"staging": "sha256:other"}}
+	_, err := Promote(context.TODO(), client, PromoteOptions{Repository: "app", Digest: "sha256:abc", Env: "prod", PreviousEnv: "staging"})
+	if err == nil || !strings.Contains(err.Error(), "not tagged staging") {
+		t.Errorf("Expected tag mismatch error, got: %v", err)
+	}
+	if len(client.putTags) != 0 {
+		t.Errorf("Expected no tag to be added, got: %v", client.putTags)
+	}
+
+	_, err = Promote(context.TODO(), client, PromoteOptions{Repository: "app", Digest: "sha256:abc", Env: "prod", PreviousEnv: "qa"})
+	if err == nil || !strings.Contains(err.Error(), "no image tagged qa") {
+		t.Errorf("Expected missing tag error, got: %v", err)
+	}
+}
+
+func TestPromote_DryRunAndAlreadyTagged(t *testing.T) {
+	client := &mockECRClient{tags: map[string]string{}}
+	if _, err := Promote(context.TODO(), client, PromoteOptions{Repository: "app", Digest: "sha256:abc", Env: "prod", DryRun: true}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(client.putTags) != 0 || len(client.resTags) != 0 {
+		t.Errorf("Expected no changes in dry run, got: %v, %v", client.putTags, client.resTags)
+	}
+
+	client.putErr = &types.ImageAlreadyExistsException{Message: aws.String("exists")}
+	result, err := Promote(context.TODO(), client, PromoteOptions{Repository: "app", Digest: "sha256:abc", Env: "prod"})
+	if err != nil || !result.AlreadyTagged {
+		t.Errorf("Expected an existing tag to be accepted, got: %+v, %v", result, err)
+	}
+	if len(client.resTags) != 1 {
+		t.Errorf("Expected promotion to be recorded, got: %v", client.resTags)
+	}
+}
@@ -3,13 +3,339 @@
 package readpolicyfile
 
 import (
+	"bytes"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
 )
 
+// --- LifecyclePolicy mirrors the ECR lifecycle policy document ---
+type LifecyclePolicy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// --- Rule is a single lifecycle policy rule ---
+type Rule struct {
+	RulePriority int       `json:"rulePriority"`
+	Description  string    `json:"description,omitempty"`
+	Selection    Selection `json:"selection"`
+	Action       Action    `json:"action"`
+}
+
+// --- Selection describes which images a rule applies to ---
+type Selection struct {
+	TagStatus      string   `json:"tagStatus"`
+	TagPatternList []string `json:"tagPatternList,omitempty"`
+	TagPrefixList  []string `json:"tagPrefixList,omitempty"`
+	CountType      string   `json:"countType"`
+	CountUnit      string   `json:"countUnit,omitempty"`
+	CountNumber    int      `json:"countNumber"`
+}
+
+// --- Action is what happens to the selected images ---
+type Action struct {
+	Type string `json:"type"`
+}
+
+// --- parses a lifecycle policy document ---
+func ParsePolicy(policyText string) (LifecyclePolicy, error) {
+	var policy LifecyclePolicy
+	if err := json.Unmarshal([]byte(policyText), &policy); err != nil {
+		return LifecyclePolicy{}, fmt.Errorf("invalid lifecycle policy: %w", err)
+	}
+	return policy, nil
+}
+
+// --- rejects policies with fields the lifecycle policy document does not define, such as rulePriorty or Rules ---
+// --- ECR ignores unknown fields, so a typo silently turns a rule into one that does something else ---
+func ValidateStrict(policyText string) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(policyText)))
+	decoder.DisallowUnknownFields()
+	var policy LifecyclePolicy
+	if err := decoder.Decode(&policy); err != nil {
+		return fmt.Errorf("invalid lifecycle policy: %w", err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid lifecycle policy: unexpected content after the policy document")
+	}
+	// --- encoding/json matches field names case-insensitively, ECR does not ---
+	var raw interface{}
+	if err := json.Unmarshal([]byte(policyText), &raw); err != nil {
+		return fmt.Errorf("invalid lifecycle policy: %w", err)
+	}
+	if err := checkFieldNames(raw, reflect.TypeOf(policy), "policy"); err != nil {
+		return fmt.Errorf("invalid lifecycle policy: %w", err)
+	}
+	return nil
+}
+
+// --- walks a decoded document alongside the struct it was decoded into and requires every key to match a json tag exactly ---
+func checkFieldNames(value interface{}, t reflect.Type, path string) error {
+	switch t.Kind() {
+	case reflect.Slice:
+		items, _ := value.([]interface{})
+		for i, item := range items {
+			if err := checkFieldNames(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		object, _ := value.(map[string]interface{})
+		for key, item := range object {
+			field, ok := fieldByTag(t, key)
+			if !ok {
+				return fmt.Errorf("unknown field %q in %s, field names are case-sensitive", key, path)
+			}
+			if err := checkFieldNames(item, field.Type, path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// --- returns the struct field whose json tag is exactly name ---
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// --- name of the JSON Schema (draft-07) of the lifecycle policy document, as written by validatePolicy --writeSchema ---
+const SchemaFileName = "lifecycle-policy-schema.json"
+
+// --- where editors can load the schema from, the $id of the embedded schema ---
+const SchemaURL = "https://raw.githubusercontent.com/gjorgji-ts/ecr-lifecycle-cleaner/main/internal/readPolicyFile/" + SchemaFileName
+
+//go:embed lifecycle-policy-schema.json
+var schemaJSON []byte
+
+// --- returns the embedded JSON Schema of the lifecycle policy document, for editors and CI ---
+func Schema() []byte {
+	return bytes.Clone(schemaJSON)
+}
+
+// --- schemaViolation is a value that does not match the schema, at a JSON path such as policy.rules[0].selection ---
+type schemaViolation struct {
+	path    string
+	message string
+}
+
+func (v schemaViolation) String() string {
+	return v.path + ": " + v.message
+}
+
+// --- validates a policy against the embedded schema and reports every violation, not only the first ---
+// --- covers the draft-07 keywords the schema uses: type, properties, required, additionalProperties, items, ---
+// --- enum, const, minimum, minItems, minLength, $ref, allOf, anyOf, not and if/then/else ---
+func ValidatePolicySchema(policyText string) error {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		return fmt.Errorf("invalid embedded schema: %w", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal([]byte(policyText), &document); err != nil {
+		return fmt.Errorf("invalid lifecycle policy: %w", err)
+	}
+	violations := validateSchema(schema, schema, document, "policy")
+	if len(violations) == 0 {
+		return nil
+	}
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.String()
+	}
+	return fmt.Errorf("invalid lifecycle policy: %s", strings.Join(messages, "; "))
+}
+
+// --- checks value against schema, root resolves $ref pointers such as #/definitions/rule ---
+func validateSchema(root, schema map[string]interface{}, value interface{}, path string) []schemaViolation {
+	if ref, ok := schema["$ref"].(string); ok {
+		return validateSchema(root, resolveRef(root, ref), value, path)
+	}
+	if kind, ok := schema["type"].(string); ok && !hasType(value, kind) {
+		return []schemaViolation{{path, "must be " + article(kind) + " " + kind}}
+	}
+	var violations []schemaViolation
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.ContainsFunc(enum, func(allowed interface{}) bool { return reflect.DeepEqual(allowed, value) }) {
+		names := make([]string, len(enum))
+		for i, allowed := range enum {
+			names[i] = fmt.Sprint(allowed)
+		}
+		violations = append(violations, schemaViolation{path, fmt.Sprintf("must be one of %s, got %v", strings.Join(names, ", "), value)})
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		violations = append(violations, schemaViolation{path, fmt.Sprintf("must be %v", constant)})
+	}
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if number, ok := value.(float64); ok && number < minimum {
+			violations = append(violations, schemaViolation{path, fmt.Sprintf("must be at least %v, got %v", minimum, number)})
+		}
+	}
+	if minLength, ok := schema["minLength"].(float64); ok {
+		if text, ok := value.(string); ok && len(text) < int(minLength) {
+			violations = append(violations, schemaViolation{path, fmt.Sprintf("must be at least %v characters long", minLength)})
+		}
+	}
+	if items, ok := value.([]interface{}); ok {
+		if minItems, ok := schema["minItems"].(float64); ok && len(items) < int(minItems) {
+			violations = append(violations, schemaViolation{path, fmt.Sprintf("must have at least %v items", minItems)})
+		}
+		if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range items {
+				violations = append(violations, validateSchema(root, itemSchema, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	if object, ok := value.(map[string]interface{}); ok {
+		violations = append(violations, validateObject(root, schema, object, path)...)
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			violations = append(violations, validateSchema(root, sub.(map[string]interface{}), value, path)...)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		var alternatives []string
+		for _, sub := range anyOf {
+			branch := validateSchema(root, sub.(map[string]interface{}), value, path)
+			if len(branch) == 0 {
+				alternatives = nil
+				break
+			}
+			alternatives = append(alternatives, branch[0].message)
+		}
+		if len(alternatives) > 0 {
+			violations = append(violations, schemaViolation{path, strings.Join(alternatives, " or ")})
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok && len(validateSchema(root, not, value, path)) == 0 {
+		message, _ := not["description"].(string)
+		if message == "" {
+			message = "matches a schema it must not match"
+		}
+		violations = append(violations, schemaViolation{path, message})
+	}
+	if condition, ok := schema["if"].(map[string]interface{}); ok {
+		branch := "else"
+		if len(validateSchema(root, condition, value, path)) == 0 {
+			branch = "then"
+		}
+		if sub, ok := schema[branch].(map[string]interface{}); ok {
+			violations = append(violations, validateSchema(root, sub, value, path)...)
+		}
+	}
+	return violations
+}
+
+// --- checks required, properties and additionalProperties, field names in a stable order ---
+func validateObject(root, schema map[string]interface{}, object map[string]interface{}, path string) []schemaViolation {
+	var violations []schemaViolation
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		if _, ok := object[name.(string)]; !ok {
+			violations = append(violations, schemaViolation{path, fmt.Sprintf("missing required field %q", name)})
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		property, ok := properties[key].(map[string]interface{})
+		if !ok {
+			if schema["additionalProperties"] == false {
+				violations = append(violations, schemaViolation{path, fmt.Sprintf("unknown field %q, field names are case-sensitive", key)})
+			}
+			continue
+		}
+		violations = append(violations, validateSchema(root, property, object[key], path+"."+key)...)
+	}
+	return violations
+}
+
+// --- resolves a local JSON pointer such as #/definitions/rule ---
+func resolveRef(root map[string]interface{}, ref string) map[string]interface{} {
+	node := root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node, _ = node[part].(map[string]interface{})
+	}
+	return node
+}
+
+// --- reports whether a decoded JSON value has the JSON Schema type kind ---
+func hasType(value interface{}, kind string) bool {
+	switch kind {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+func article(kind string) string {
+	if strings.ContainsRune("aeiou", rune(kind[0])) {
+		return "an"
+	}
+	return "a"
+}
+
+// --- reports whether any rule expires untagged images ---
+func (p LifecyclePolicy) ExpiresUntagged() bool {
+	for _, rule := range p.Rules {
+		if strings.EqualFold(rule.Selection.TagStatus, "untagged") && strings.EqualFold(rule.Action.Type, "expire") {
+			return true
+		}
+	}
+	return false
+}
+
+// --- reports whether any rule selects images by the tag prefix, through tagPrefixList or a tagPatternList entry prefix* ---
+// --- a trailing * on prefix is ignored, so pr- and pr-* ask the same question ---
+func ContainsTagPrefixRule(policyText, prefix string) (bool, error) {
+	policy, err := ParsePolicy(policyText)
+	if err != nil {
+		return false, err
+	}
+	prefix = strings.TrimSuffix(prefix, "*")
+	for _, rule := range policy.Rules {
+		if slices.Contains(rule.Selection.TagPrefixList, prefix) || slices.Contains(rule.Selection.TagPatternList, prefix+"*") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // --- reads the content of a policy file and returns it as a string (with logging) ---
 func readPolicyFileWithLogging(filePath string) (string, error) {
 	log.Println("============================================")
@@ -44,8 +370,15 @@ func readPolicyFileWithLogging(filePath string) (string, error) {
 	return string(bytes), nil
 }
 
+// --- prefix of a policy file path that names an environment variable instead, e.g. env:LIFECYCLE_POLICY ---
+const EnvSourcePrefix = "env:"
+
 // --- reads and validates policy file, no logging or side effects ---
+// --- a path starting with env: is read from the named environment variable instead ---
 func ReadPolicyFile(filePath string) (string, error) {
+	if varName, ok := strings.CutPrefix(filePath, EnvSourcePrefix); ok {
+		return ReadPolicyFromEnv(varName)
+	}
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to open policy file: %w", err)
@@ -68,3 +401,35 @@ func ReadPolicyFile(filePath string) (string, error) {
 
 	return string(bytes), nil
 }
+
+// --- reads and validates a policy from an environment variable, e.g. one filled from a ConfigMap or secret ---
+func ReadPolicyFromEnv(varName string) (string, error) {
+	if varName == "" {
+		return "", fmt.Errorf("missing environment variable name after %q", EnvSourcePrefix)
+	}
+	policyText := os.Getenv(varName)
+	if strings.TrimSpace(policyText) == "" {
+		return "", fmt.Errorf("environment variable %s is empty or not set", varName)
+	}
+
+	var jsonObj map[string]interface{}
+	if err := json.Unmarshal([]byte(policyText), &jsonObj); err != nil {
+		return "", fmt.Errorf("invalid JSON in environment variable %s: %w", varName, err)
+	}
+
+	return policyText, nil
+}
+
+// --- validates a policy passed inline, e.g. through --policyJson in a CI one-liner, and returns it unchanged ---
+func ReadPolicyJSON(policyText string) (string, error) {
+	if strings.TrimSpace(policyText) == "" {
+		return "", fmt.Errorf("inline policy is empty")
+	}
+
+	var jsonObj map[string]interface{}
+	if err := json.Unmarshal([]byte(policyText), &jsonObj); err != nil {
+		return "", fmt.Errorf("invalid JSON in inline policy: %w", err)
+	}
+
+	return policyText, nil
+}
@@ -4,9 +4,11 @@ package readpolicyfile
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -188,3 +190,177 @@ func TestReadPolicyFile_CloseError(t *testing.T) {
 		t.Errorf("Expected warning about failed file close, got: %s", output)
 	}
 }
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy(`{"rules":[
+		{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPrefixList":["prod"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}},
+		{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(policy.Rules) != 2 || policy.Rules[0].Selection.TagPrefixList[0] != "prod" || policy.Rules[1].Selection.CountNumber != 7 {
+		t.Errorf("Unexpected parsed policy: %+v", policy)
+	}
+	if !policy.ExpiresUntagged() {
+		t.Errorf("Expected policy to expire untagged images")
+	}
+
+	tagged, _ := ParsePolicy(`{"rules":[{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPrefixList":["x"],"countType":"imageCountMoreThan","countNumber":1},"action":{"type":"expire"}}]}`)
+	if tagged.ExpiresUntagged() {
+		t.Errorf("Expected tagged-only policy not to expire untagged images")
+	}
+
+	if _, err := ParsePolicy(`{"rules": [`); err == nil {
+		t.Errorf("Expected error for invalid JSON, got nil")
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	valid := `{"rules":[
+		{"rulePriority":1,"description":"keep 10","selection":{"tagStatus":"tagged","tagPrefixList":["prod"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}},
+		{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}
+	]}`
+	if err := ValidateStrict(valid); err != nil {
+		t.Errorf("Expected a valid policy, got: %v", err)
+	}
+
+	invalid := map[string]string{
+		`{"Rules":[]}`:                  `unknown field "Rules" in policy`,
+		`{"rules":[{"rulePriorty":1}]}`: `unknown field "rulePriorty"`,
+		`{"rules":[{"rulePriority":1,"selection":{"TagStatus":"any"}}]}`: `unknown field "TagStatus" in policy.rules[0].selection`,
+		`{"rules":[{"rulePriority":"1"}]}`:                               "cannot unmarshal string",
+		`{"rules":[]} {}`:                                                "unexpected content",
+	}
+	for policy, want := range invalid {
+		if err := ValidateStrict(policy); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateStrict(%s): expected error containing %q, got: %v", policy, want, err)
+		}
+	}
+}
+
+func TestValidatePolicySchema(t *testing.T) {
+	valid := `{"rules":[
+		{"rulePriority":1,"description":"keep 10","selection":{"tagStatus":"tagged","tagPatternList":["prod-*"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}},
+		{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}
+	]}`
+	if err := ValidatePolicySchema(valid); err != nil {
+		t.Errorf("Expected a valid policy, got: %v", err)
+	}
+
+	rule := func(selection string) string {
+		return `{"rules":[{"rulePriority":1,"selection":` + selection + `,"action":{"type":"expire"}}]}`
+	}
+	invalid := map[string]string{
+		`{"Rules":[]}`: `policy: missing required field "rules"; policy: unknown field "Rules"`,
+		`{"rules":[]}`: "policy.rules: must have at least 1 items",
+		`{"rules":[{"rulePriority":0,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":1},"action":{"type":"delete"}}]}`: "policy.rules[0].action.type: must be one of expire, got delete; policy.rules[0].rulePriority: must be at least 1, got 0",
+		`{"rules":[{"rulePriority":1.5}]}`:                                                                                            "policy.rules[0].rulePriority: must be an integer",
+		rule(`{"tagStatus":"tagged","countType":"imageCountMoreThan","countNumber":1}`):                                               `missing required field "tagPatternList" or missing required field "tagPrefixList"`,
+		rule(`{"tagStatus":"tagged","tagPatternList":["a*"],"tagPrefixList":["b"],"countType":"imageCountMoreThan","countNumber":1}`): "tagPatternList and tagPrefixList cannot be combined",
+		rule(`{"tagStatus":"untagged","tagPrefixList":["b"],"countType":"imageCountMoreThan","countNumber":1}`):                       "only allowed with tagStatus tagged",
+		rule(`{"tagStatus":"any","countType":"sinceImagePushed","countNumber":7}`):                                                    `policy.rules[0].selection: missing required field "countUnit"`,
+		rule(`{"tagStatus":"any","countType":"imageCountMoreThan","countUnit":"days","countNumber":7}`):                               "countUnit is only allowed with countType sinceImagePushed",
+		rule(`{"tagStatus":"any","countType":"sinceImagePushed","countUnit":"weeks","countNumber":7}`):                                "policy.rules[0].selection.countUnit: must be one of days, got weeks",
+		rule(`{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":"7"}`):                                                "policy.rules[0].selection.countNumber: must be an integer",
+		`[]`: "policy: must be an object",
+	}
+	for policy, want := range invalid {
+		if err := ValidatePolicySchema(policy); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidatePolicySchema(%s): expected error containing %q, got: %v", policy, want, err)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("Expected the embedded schema to be valid JSON, got: %v", err)
+	}
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" || schema["$id"] != SchemaURL {
+		t.Errorf("Unexpected schema header: %v, %v", schema["$schema"], schema["$id"])
+	}
+}
+
+func TestContainsTagPrefixRule(t *testing.T) {
+	policy := `{"rules":[
+		{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPrefixList":["release-","prod"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}},
+		{"rulePriority":2,"selection":{"tagStatus":"tagged","tagPatternList":["pr-*"],"countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}
+	]}`
+	cases := map[string]bool{"release-": true, "prod": true, "pr-": true, "pr-*": true, "pro": false, "feature-": false}
+	for prefix, want := range cases {
+		got, err := ContainsTagPrefixRule(policy, prefix)
+		if err != nil || got != want {
+			t.Errorf("ContainsTagPrefixRule(%q): expected %v, got %v, %v", prefix, want, got, err)
+		}
+	}
+	if _, err := ContainsTagPrefixRule(`{"rules":`, "pr-"); err == nil {
+		t.Errorf("Expected an error for an invalid policy")
+	}
+}
+
+func TestReadPolicyFromEnv(t *testing.T) {
+	const varName = "ECR_CLEANER_TEST_POLICY"
+	policyContent := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	defer func() {
+		if err := os.Unsetenv(varName); err != nil {
+			t.Logf("Failed to unset environment variable: %v", err)
+		}
+	}()
+
+	// --- valid policy, also reached through the env: prefix of ReadPolicyFile ---
+	t.Run("Valid policy", func(t *testing.T) {
+		if err := os.Setenv(varName, policyContent); err != nil {
+			t.Fatalf("Failed to set environment variable: %v", err)
+		}
+		content, err := ReadPolicyFromEnv(varName)
+		if err != nil || content != policyContent {
+			t.Errorf("Expected policy content, got %q, %v", content, err)
+		}
+		content, err = ReadPolicyFile("env:" + varName)
+		if err != nil || content != policyContent {
+			t.Errorf("Expected policy content through env: prefix, got %q, %v", content, err)
+		}
+	})
+
+	// --- invalid JSON is rejected like in a file ---
+	t.Run("Invalid JSON", func(t *testing.T) {
+		if err := os.Setenv(varName, `{"rules": [`); err != nil {
+			t.Fatalf("Failed to set environment variable: %v", err)
+		}
+		if _, err := ReadPolicyFromEnv(varName); err == nil {
+			t.Errorf("Expected error for invalid JSON, got nil")
+		}
+	})
+
+	// --- empty, unset or unnamed variables are errors ---
+	t.Run("Empty variable", func(t *testing.T) {
+		if err := os.Setenv(varName, ""); err != nil {
+			t.Fatalf("Failed to set environment variable: %v", err)
+		}
+		if _, err := ReadPolicyFromEnv(varName); err == nil {
+			t.Errorf("Expected error for empty variable, got nil")
+		}
+		if err := os.Unsetenv(varName); err != nil {
+			t.Fatalf("Failed to unset environment variable: %v", err)
+		}
+		if _, err := ReadPolicyFile("env:" + varName); err == nil {
+			t.Errorf("Expected error for unset variable, got nil")
+		}
+		if _, err := ReadPolicyFile("env:"); err == nil {
+			t.Errorf("Expected error for missing variable name, got nil")
+		}
+	})
+}
+
+func TestReadPolicyJSON(t *testing.T) {
+	policyContent := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	if content, err := ReadPolicyJSON(policyContent); err != nil || content != policyContent {
+		t.Errorf("Expected policy content, got %q, %v", content, err)
+	}
+	for _, invalid := range []string{"", "  ", `{"rules": [`, `["rules"]`} {
+		if _, err := ReadPolicyJSON(invalid); err == nil {
+			t.Errorf("Expected error for %q, got nil", invalid)
+		}
+	}
+}
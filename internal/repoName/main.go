@@ -0,0 +1,61 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reponame
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	minLength = 2
+	maxLength = 256
+)
+
+// --- lists longer than this are more likely a pasted account inventory than a hand-picked selection ---
+const DefaultListLimit = 1000
+
+// --- ECR's repository name pattern: lowercase components separated by . _ or -, optionally namespaced with / ---
+var namePattern = regexp.MustCompile(`^(?:[a-z0-9]+(?:[._-][a-z0-9]+)*/)*[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+// --- checks a repository name against ECR's naming constraints and explains the first violation ---
+func Validate(name string) error {
+	switch {
+	case len(name) < minLength || len(name) > maxLength:
+		return fmt.Errorf("invalid repository name %q: must be between %d and %d characters long", name, minLength, maxLength)
+	case strings.ToLower(name) != name:
+		return fmt.Errorf("invalid repository name %q: must be lowercase, did you mean %q?", name, strings.ToLower(name))
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//"):
+		return fmt.Errorf("invalid repository name %q: namespaces must be separated by a single / with no leading or trailing /", name)
+	case !namePattern.MatchString(name):
+		return fmt.Errorf("invalid repository name %q: only lowercase letters, digits and single . _ - separators between them are allowed", name)
+	}
+	return nil
+}
+
+// --- splits a comma-separated list, trims whitespace, drops empty, whitespace-only and duplicate entries and validates every name ---
+func ParseList(value string) ([]string, error) {
+	var names []string
+	var invalid []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if err := Validate(name); err != nil {
+			invalid = append(invalid, err.Error())
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(invalid, "; "))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no repository names given")
+	}
+	return names, nil
+}
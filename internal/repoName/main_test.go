@@ -0,0 +1,60 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reponame
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []string{"my-repo", "team/service", "a1", "org/team/app_v2", "app.web"}
+	for _, name := range valid {
+		if err := Validate(name); err != nil {
+			t.Errorf("Expected %q to be valid, got: %v", name, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"a":                      "between 2 and 256",
+		strings.Repeat("a", 257): "between 2 and 256",
+		"My-Repo":                `did you mean "my-repo"`,
+		"/team/app":              "single /",
+		"team//app":              "single /",
+		"team/app/":              "single /",
+		"my--repo":               "separators",
+		"-repo":                  "separators",
+		"my repo":                "separators",
+	}
+	for name, want := range invalid {
+		err := Validate(name)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q for %q, got: %v", want, name, err)
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	names, err := ParseList(" repo1, team/repo2,,repo1 ")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"repo1", "team/repo2"}) {
+		t.Errorf("Unexpected names: %v", names)
+	}
+
+	_, err = ParseList("repo1,Repo2,bad repo")
+	if err == nil || !strings.Contains(err.Error(), "Repo2") || !strings.Contains(err.Error(), "bad repo") {
+		t.Errorf("Expected every invalid name to be reported, got: %v", err)
+	}
+
+	names, err = ParseList("repo1,\t, \n,repo2")
+	if err != nil || !reflect.DeepEqual(names, []string{"repo1", "repo2"}) {
+		t.Errorf("Expected whitespace-only entries to be dropped, got %v, %v", names, err)
+	}
+
+	if _, err := ParseList(" , \t"); err == nil {
+		t.Errorf("Expected error for an empty list")
+	}
+}
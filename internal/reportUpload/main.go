@@ -0,0 +1,65 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reportupload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// --- S3API defines the subset of s3.Client methods needed to upload a report ---
+type S3API interface {
+	PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// --- Location is where reports are uploaded, parsed from s3://bucket/prefix ---
+type Location struct {
+	Bucket string
+	Prefix string
+}
+
+// --- parses an s3://bucket/prefix URI, the prefix is optional ---
+func ParseURI(uri string) (Location, error) {
+	rest, ok := strings.CutPrefix(uri, "s3://")
+	if !ok {
+		return Location{}, fmt.Errorf("invalid S3 URI %q, expected s3://bucket/prefix", uri)
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return Location{}, fmt.Errorf("invalid S3 URI %q, the bucket is missing", uri)
+	}
+	return Location{Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// --- returns the object key of a report, e.g. prefix/123456789012/eu-west-1/clean-20250301T120000Z.json ---
+func (l Location) Key(command, account, region string, at time.Time) string {
+	name := fmt.Sprintf("%s-%s.json", command, at.UTC().Format("20060102T150405Z"))
+	return path.Join(l.Prefix, account, region, name)
+}
+
+// --- uploads the report as indented JSON and returns its s3:// URI ---
+func Upload(ctx context.Context, client S3API, location Location, key string, report interface{}) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal report: %w", err)
+	}
+	data = append(data, '\n')
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(location.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload report to s3://%s/%s: %w", location.Bucket, key, err)
+	}
+	return fmt.Sprintf("s3://%s/%s", location.Bucket, key), nil
+}
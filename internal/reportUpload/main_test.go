@@ -0,0 +1,77 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reportupload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// --- mock S3 client recording the uploaded object ---
+type mockS3Client struct {
+	bucket, key, contentType, body string
+	err                            error
+}
+
+func (m *mockS3Client) PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	data, _ := io.ReadAll(in.Body)
+	m.bucket, m.key, m.contentType, m.body = aws.ToString(in.Bucket), aws.ToString(in.Key), aws.ToString(in.ContentType), string(data)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want Location
+	}{
+		{"s3://audit/ecr/reports/", Location{Bucket: "audit", Prefix: "ecr/reports"}},
+		{"s3://audit", Location{Bucket: "audit"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseURI(tt.uri)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseURI(%q) = %+v, %v; want %+v", tt.uri, got, err, tt.want)
+		}
+	}
+	for _, uri := range []string{"audit/reports", "s3:///reports", "https://audit.s3.amazonaws.com/reports"} {
+		if _, err := ParseURI(uri); err == nil {
+			t.Errorf("Expected error for %q", uri)
+		}
+	}
+}
+
+func TestLocation_Key(t *testing.T) {
+	at := time.Date(2025, 3, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+	if got := (Location{Bucket: "audit", Prefix: "ecr"}).Key("clean", "123456789012", "eu-west-1", at); got != "ecr/123456789012/eu-west-1/clean-20250301T120000Z.json" {
+		t.Errorf("Unexpected key: %s", got)
+	}
+	if got := (Location{Bucket: "audit"}).Key("setPolicy", "123456789012", "eu-west-1", at); got != "123456789012/eu-west-1/setPolicy-20250301T120000Z.json" {
+		t.Errorf("Unexpected key without prefix: %s", got)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	client := &mockS3Client{}
+	uri, err := Upload(context.TODO(), client, Location{Bucket: "audit"}, "a/b.json", map[string]int{"deleted": 3})
+	if err != nil || uri != "s3://audit/a/b.json" {
+		t.Fatalf("Unexpected result: %s, %v", uri, err)
+	}
+	if client.bucket != "audit" || client.key != "a/b.json" || client.contentType != "application/json" || !strings.Contains(client.body, `"deleted": 3`) {
+		t.Errorf("Unexpected upload: %+v", client)
+	}
+
+	_, err = Upload(context.TODO(), &mockS3Client{err: errors.New("access denied")}, Location{Bucket: "audit"}, "a/b.json", nil)
+	if err == nil || !strings.Contains(err.Error(), "s3://audit/a/b.json") {
+		t.Errorf("Expected upload error naming the object, got: %v", err)
+	}
+}
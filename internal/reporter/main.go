@@ -0,0 +1,180 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	format "ecr-lifecycle-cleaner/internal/format"
+)
+
+// --- Reporter receives the final report of a run, every destination of --report is one ---
+type Reporter interface {
+	Report(report interface{}) error
+	// --- describes the destination for log messages, e.g. json:out.json ---
+	String() string
+}
+
+// --- Summarizer is implemented by reports with a one-line human readable summary ---
+type Summarizer interface {
+	Summary() string
+}
+
+// --- the kinds of --report destinations ---
+const (
+	KindConsole = "console"
+	KindJSON    = "json"
+	KindJSONL   = "jsonl"
+	KindCSV     = "csv"
+	KindJUnit   = "junit"
+	KindProm    = "prom"
+)
+
+// --- UnsupportedError is returned when the report cannot be written in the format of a destination ---
+type UnsupportedError struct {
+	Kind string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Kind + " is not supported by this report"
+}
+
+// --- returns the reporter writing to path the way --output, --jsonMode and --outputTemplate ask for, a template wins over the others ---
+func ForOutput(path string, output format.OutputFormat, mode format.JSONMode, sep format.Separator, tmpl *template.Template) Reporter {
+	switch {
+	case tmpl != nil:
+		return Template{Path: path, Template: tmpl}
+	case output == format.OutputJUnit:
+		return File{Kind: KindJUnit, Path: path}
+	case output == format.OutputCSV:
+		return File{Kind: KindCSV, Path: path, Separator: sep}
+	case mode == format.JSONLines:
+		return File{Kind: KindJSONL, Path: path}
+	}
+	return File{Kind: KindJSON, Path: path}
+}
+
+// --- parses a --report destination: console, or kind:path with kind one of json, jsonl, csv, junit or prom ---
+// --- console writes to w, csv values are separated by sep, a path of - writes to stdout ---
+func Parse(spec string, w io.Writer, sep format.Separator) (Reporter, error) {
+	kind, path, hasPath := strings.Cut(strings.TrimSpace(spec), ":")
+	kind = strings.ToLower(kind)
+	if kind == KindConsole {
+		if hasPath {
+			return nil, fmt.Errorf("invalid report %q, console takes no path", spec)
+		}
+		return Console{w: w}, nil
+	}
+	if !hasPath || path == "" {
+		return nil, fmt.Errorf("invalid report %q, expected console or kind:path with kind one of json, jsonl, csv, junit or prom", spec)
+	}
+	switch kind {
+	case KindJSON, KindJSONL, KindJUnit, KindProm:
+		return File{Kind: kind, Path: path}, nil
+	case KindCSV:
+		return File{Kind: kind, Path: path, Separator: sep}, nil
+	}
+	return nil, fmt.Errorf("unknown report kind %q in %q, expected console, json, jsonl, csv, junit or prom", kind, spec)
+}
+
+// --- parses every --report destination, failing on the first invalid one ---
+func ParseAll(specs []string, w io.Writer, sep format.Separator) ([]Reporter, error) {
+	reporters := make([]Reporter, 0, len(specs))
+	for _, spec := range specs {
+		r, err := Parse(spec, w, sep)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, r)
+	}
+	return reporters, nil
+}
+
+// --- Console prints the summary of the report and, for reports with one row per repository, a table ---
+type Console struct {
+	w io.Writer
+}
+
+func (c Console) String() string {
+	return KindConsole
+}
+
+func (c Console) Report(report interface{}) error {
+	if summarizer, ok := report.(Summarizer); ok {
+		if _, err := fmt.Fprintln(c.w, summarizer.Summary()); err != nil {
+			return err
+		}
+	}
+	table, ok := report.(format.Tabler)
+	if !ok {
+		return nil
+	}
+	headers, rows := table.Table()
+	if len(rows) == 0 {
+		return nil
+	}
+	upper := make([]string, len(headers))
+	for i, header := range headers {
+		upper[i] = strings.ToUpper(header)
+	}
+	return format.WriteTable(c.w, upper, rows)
+}
+
+// --- File writes the report to Path in the format of Kind ---
+type File struct {
+	Kind      string
+	Path      string
+	Separator format.Separator
+}
+
+func (f File) String() string {
+	return f.Kind + ":" + f.Path
+}
+
+func (f File) Report(report interface{}) error {
+	switch f.Kind {
+	case KindJSONL:
+		records := []interface{}{report}
+		if lister, ok := report.(format.RecordLister); ok {
+			records = lister.Records()
+		}
+		return format.WriteJSONLines(f.Path, records)
+	case KindCSV:
+		table, ok := report.(format.Tabler)
+		if !ok {
+			return &UnsupportedError{Kind: KindCSV}
+		}
+		headers, rows := table.Table()
+		return format.WriteDelimited(f.Path, headers, rows, f.Separator)
+	case KindJUnit:
+		junit, ok := report.(format.JUnitReporter)
+		if !ok {
+			return &UnsupportedError{Kind: KindJUnit}
+		}
+		return format.WriteJUnit(f.Path, junit.JUnit())
+	case KindProm:
+		prom, ok := report.(format.PrometheusReporter)
+		if !ok {
+			return &UnsupportedError{Kind: KindProm}
+		}
+		return format.WritePrometheus(f.Path, prom.Metrics())
+	}
+	return format.WriteReport(f.Path, report)
+}
+
+// --- Template renders the report with a Go text/template to Path ---
+type Template struct {
+	Path     string
+	Template *template.Template
+}
+
+func (t Template) String() string {
+	return "template:" + t.Path
+}
+
+func (t Template) Report(report interface{}) error {
+	return format.WriteTemplate(t.Path, t.Template, report)
+}
@@ -0,0 +1,115 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package reporter
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	format "ecr-lifecycle-cleaner/internal/format"
+)
+
+type testReport struct {
+	Deleted int `json:"deleted"`
+}
+
+func (r testReport) Summary() string { return "deleted 3 images" }
+
+func (r testReport) Table() ([]string, [][]string) {
+	return []string{"repository", "deleted"}, [][]string{{"app", "3"}}
+}
+
+func (r testReport) Metrics() []format.Metric {
+	return []format.Metric{{Name: "deleted", Help: "Deleted images.", Labels: map[string]string{"repository": "app"}, Value: 3}}
+}
+
+func TestParse(t *testing.T) {
+	for spec, want := range map[string]string{
+		"console":            "console",
+		"JSON:out.json":      "json:out.json",
+		"prom:metrics.prom":  "prom:metrics.prom",
+		"csv:C:/reports.csv": "csv:C:/reports.csv",
+	} {
+		r, err := Parse(spec, new(bytes.Buffer), format.SeparatorComma)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", spec, err)
+			continue
+		}
+		if r.String() != want {
+			t.Errorf("%s: expected %s, got %s", spec, want, r)
+		}
+	}
+	for _, spec := range []string{"", "json", "json:", "console:out.txt", "xml:out.xml"} {
+		if _, err := Parse(spec, new(bytes.Buffer), format.SeparatorComma); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestReportersShareOneReport(t *testing.T) {
+	dir := t.TempDir()
+	console := new(bytes.Buffer)
+	reporters, err := ParseAll([]string{"console", "json:" + filepath.Join(dir, "out.json"), "prom:" + filepath.Join(dir, "metrics.prom")}, console, format.SeparatorComma)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range reporters {
+		if err := r.Report(testReport{Deleted: 3}); err != nil {
+			t.Fatalf("%s: unexpected error: %v", r, err)
+		}
+	}
+
+	if !strings.Contains(console.String(), "deleted 3 images") || !strings.Contains(console.String(), "REPOSITORY") {
+		t.Errorf("expected the summary and table on the console, got %q", console.String())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out.json"))
+	if err != nil || !strings.Contains(string(data), `"deleted": 3`) {
+		t.Errorf("expected the JSON report, got %q (%v)", data, err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "metrics.prom"))
+	if err != nil || !strings.Contains(string(data), `deleted{repository="app"} 3`) {
+		t.Errorf("expected the metrics, got %q (%v)", data, err)
+	}
+}
+
+func TestFile_Unsupported(t *testing.T) {
+	r := File{Kind: KindJUnit, Path: filepath.Join(t.TempDir(), "out.xml")}
+	var unsupported *UnsupportedError
+	if err := r.Report(testReport{}); !errors.As(err, &unsupported) || unsupported.Kind != KindJUnit {
+		t.Errorf("expected an unsupported error, got %v", err)
+	}
+}
+
+func TestForOutput(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse("{{.Deleted}} deleted\n"))
+	cases := []struct {
+		output format.OutputFormat
+		mode   format.JSONMode
+		tmpl   *template.Template
+		want   string
+	}{
+		{format.OutputJSON, format.JSONArray, nil, "json:out"},
+		{format.OutputJSON, format.JSONLines, nil, "jsonl:out"},
+		{format.OutputCSV, format.JSONArray, nil, "csv:out"},
+		{format.OutputJUnit, format.JSONLines, nil, "junit:out"},
+		{format.OutputCSV, format.JSONArray, tmpl, "template:out"},
+	}
+	for _, c := range cases {
+		if got := ForOutput("out", c.output, c.mode, format.SeparatorComma, c.tmpl).String(); got != c.want {
+			t.Errorf("ForOutput(%s, %s, template %v) = %s, want %s", c.output, c.mode, c.tmpl != nil, got, c.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := ForOutput(path, format.OutputJSON, format.JSONArray, format.SeparatorComma, tmpl).Report(testReport{Deleted: 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "2 deleted\n" {
+		t.Errorf("expected the rendered template, got %q", data)
+	}
+}
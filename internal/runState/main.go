@@ -0,0 +1,82 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package runstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	format "ecr-lifecycle-cleaner/internal/format"
+)
+
+// --- State records when each repository was last cleaned successfully, for incremental runs ---
+type State struct {
+	mu      sync.Mutex
+	path    string
+	lastRun map[string]time.Time
+}
+
+// --- the on-disk form of the state ---
+type state struct {
+	Repositories map[string]time.Time `json:"repositories"`
+	UpdatedAt    time.Time            `json:"updatedAt"`
+}
+
+// --- reads the state at path, a missing file yields an empty state so the first run scans everything ---
+func Load(path string) (*State, error) {
+	s := &State{path: path, lastRun: map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var onDisk state
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	for repo, at := range onDisk.Repositories {
+		s.lastRun[repo] = at
+	}
+	return s, nil
+}
+
+// --- returns, for each repository with a recorded run, the time from which images have to be looked at again ---
+// --- overlap is subtracted from the last run, so images pushed while that run was listing are looked at again ---
+// --- repositories without a recorded run are left out and scanned in full ---
+func (s *State) Since(repositories []string, overlap time.Duration) map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since := map[string]time.Time{}
+	for _, repo := range repositories {
+		if at, ok := s.lastRun[repo]; ok {
+			since[repo] = at.Add(-overlap)
+		}
+	}
+	return since
+}
+
+// --- records at as the last successful run of the repositories ---
+func (s *State) Record(repositories []string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, repo := range repositories {
+		s.lastRun[repo] = at.UTC()
+	}
+}
+
+// --- rewrites the state file atomically, repositories not part of this run keep their earlier time ---
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := format.WriteReport(s.path, state{Repositories: s.lastRun, UpdatedAt: time.Now().UTC()}); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package runstate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestState_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected missing file to load as empty, got: %v", err)
+	}
+	if since := s.Since([]string{"repo-a"}, time.Hour); len(since) != 0 {
+		t.Errorf("Expected no recorded runs, got: %v", since)
+	}
+
+	first := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.Record([]string{"repo-a", "repo-b"}, first)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// --- a later run of repo-a only keeps the earlier time of repo-b ---
+	s, err = Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	second := first.Add(6 * time.Hour)
+	s.Record([]string{"repo-a"}, second)
+	if err := s.Save(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := map[string]time.Time{
+		"repo-a": second.Add(-time.Hour),
+		"repo-b": first.Add(-time.Hour),
+	}
+	if got := s.Since([]string{"repo-a", "repo-b", "repo-c"}, time.Hour); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got: %v", want, got)
+	}
+}
+
+func TestState_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("not-json"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Expected error for invalid state file")
+	}
+}
@@ -0,0 +1,337 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package scanningconfig
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	format "ecr-lifecycle-cleaner/internal/format"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+	"ecr-lifecycle-cleaner/internal/sliceutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to read and change the scanning configuration ---
+// --- DescribeRegistry only returns the replication configuration, the scanning rules come from GetRegistryScanningConfiguration ---
+type ECRAPI interface {
+	GetRegistryScanningConfiguration(ctx context.Context, in *ecr.GetRegistryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.GetRegistryScanningConfigurationOutput, error)
+	BatchGetRepositoryScanningConfiguration(ctx context.Context, in *ecr.BatchGetRepositoryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetRepositoryScanningConfigurationOutput, error)
+	PutImageScanningConfiguration(ctx context.Context, in *ecr.PutImageScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutImageScanningConfigurationOutput, error)
+}
+
+var _ ECRAPI = (*ecr.Client)(nil)
+
+// --- EnhancedScanningHint explains how to turn on enhanced scanning for the registry before repositories can use it ---
+const EnhancedScanningHint = `enhanced scanning is not enabled for this registry, repositories can only be scanned by Amazon Inspector once the registry scanning configuration uses the ENHANCED scan type with at least one rule, e.g.:
+  aws ecr put-registry-scanning-configuration --scan-type ENHANCED --rules '[{"scanFrequency":"SCAN_ON_PUSH","repositoryFilters":[{"filter":"*","filterType":"WILDCARD"}]}]'
+or in the console under Amazon ECR > Private registry > Settings > Scanning`
+
+// --- reports whether the registry scanning configuration uses the ENHANCED scan type with at least one rule ---
+func VerifyEnhancedScanningEnabled(ctx context.Context, client ECRAPI) (bool, error) {
+	config, err := registryScanning(ctx, client)
+	if err != nil {
+		return false, err
+	}
+	if config == nil || config.ScanType != types.ScanTypeEnhanced {
+		return false, nil
+	}
+	return len(config.Rules) > 0, nil
+}
+
+func registryScanning(ctx context.Context, client ECRAPI) (*types.RegistryScanningConfiguration, error) {
+	out, err := client.GetRegistryScanningConfiguration(ctx, &ecr.GetRegistryScanningConfigurationInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry scanning configuration: %w", err)
+	}
+	return out.ScanningConfiguration, nil
+}
+
+// --- returns the scan type of the registry, BASIC when none is configured ---
+func RegistryScanType(ctx context.Context, client ECRAPI) (types.ScanType, error) {
+	config, err := registryScanning(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	if config == nil || config.ScanType == "" {
+		return types.ScanTypeBasic, nil
+	}
+	return config.ScanType, nil
+}
+
+// --- ScanFrequency is how often the images of a repository are scanned ---
+type ScanFrequency string
+
+const (
+	ScanOnPush     ScanFrequency = ScanFrequency(types.ScanFrequencyScanOnPush)
+	ContinuousScan ScanFrequency = ScanFrequency(types.ScanFrequencyContinuousScan)
+	// --- images are only scanned when someone starts a scan, which usually means never ---
+	Manual ScanFrequency = ScanFrequency(types.ScanFrequencyManual)
+)
+
+// --- parses a scan frequency, case-insensitive and with - accepted for _, e.g. scan-on-push ---
+func ParseScanFrequency(value string) (ScanFrequency, error) {
+	frequency := ScanFrequency(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(value), "-", "_")))
+	switch frequency {
+	case ScanOnPush, ContinuousScan, Manual:
+		return frequency, nil
+	}
+	return "", fmt.Errorf("invalid scan frequency %q, expected %s, %s or %s", value, ScanOnPush, ContinuousScan, Manual)
+}
+
+// --- BatchGetRepositoryScanningConfiguration accepts at most 25 repositories per call ---
+const scanningBatchSize = 25
+
+// --- RepositoryScan is the effective scanning configuration of a repository ---
+type RepositoryScan struct {
+	Repository    string        `json:"repository"`
+	ScanFrequency ScanFrequency `json:"scanFrequency,omitempty"`
+	ScanOnPush    bool          `json:"scanOnPush"`
+	// --- MANUAL scanning counts as not enabled ---
+	Enabled bool   `json:"enabled"`
+	Error   string `json:"error,omitempty"`
+}
+
+// --- returns the effective scanning configuration of the repositories in input order ---
+// --- repositories ECR reports a failure for, such as ones that do not exist, carry the reason in Error ---
+func GetRepositoryScanning(ctx context.Context, client ECRAPI, repositories []string) ([]RepositoryScan, error) {
+	found := map[string]RepositoryScan{}
+	for _, batch := range sliceutil.Partition(repositories, scanningBatchSize) {
+		out, err := client.BatchGetRepositoryScanningConfiguration(ctx, &ecr.BatchGetRepositoryScanningConfigurationInput{RepositoryNames: batch})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository scanning configuration: %w", err)
+		}
+		for _, config := range out.ScanningConfigurations {
+			frequency := ScanFrequency(config.ScanFrequency)
+			name := aws.ToString(config.RepositoryName)
+			found[name] = RepositoryScan{Repository: name, ScanFrequency: frequency, ScanOnPush: config.ScanOnPush, Enabled: frequency != Manual}
+		}
+		for _, failure := range out.Failures {
+			name := aws.ToString(failure.RepositoryName)
+			found[name] = RepositoryScan{Repository: name, Error: fmt.Sprintf("%s: %s", failure.FailureCode, aws.ToString(failure.FailureReason))}
+		}
+	}
+	scans := make([]RepositoryScan, 0, len(repositories))
+	for _, repo := range repositories {
+		scan, ok := found[repo]
+		if !ok {
+			scan = RepositoryScan{Repository: repo, Error: "no scanning configuration returned"}
+		}
+		scans = append(scans, scan)
+	}
+	return scans, nil
+}
+
+// --- CheckReport lists the scanning configuration of every checked repository ---
+type CheckReport struct {
+	ScanType     types.ScanType   `json:"scanType"`
+	Repositories []RepositoryScan `json:"repositories"`
+	Duration     time.Duration    `json:"duration"`
+}
+
+// --- returns the repositories that are only scanned on demand ---
+func (r CheckReport) NonCompliant() []string {
+	var repos []string
+	for _, repo := range r.Repositories {
+		if repo.Error == "" && !repo.Enabled {
+			repos = append(repos, repo.Repository)
+		}
+	}
+	return repos
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r CheckReport) Summary() string {
+	counts := map[ScanFrequency]int{}
+	failed := 0
+	for _, repo := range r.Repositories {
+		if repo.Error != "" {
+			failed++
+			continue
+		}
+		counts[repo.ScanFrequency]++
+	}
+	return fmt.Sprintf("%s scanning: %d repos scanned on push, %d continuously, %d manual (non-compliant), %d failed", r.ScanType, counts[ScanOnPush], counts[ContinuousScan], counts[Manual], failed)
+}
+
+// --- renders one test case per repository, MANUAL scanning and errors become failures ---
+func (r CheckReport) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner checkScanConfig", Duration: r.Duration}
+	for _, repo := range r.Repositories {
+		c := format.JUnitCase{Name: repo.Repository, ClassName: "checkScanConfig"}
+		switch {
+		case repo.Error != "":
+			c.Failure = repo.Error
+		case !repo.Enabled:
+			c.Failure = "images are only scanned manually"
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	return suite
+}
+
+// --- reads the registry scan type and the scanning configuration of the repositories ---
+func Check(ctx context.Context, client ECRAPI, repositories []string) (CheckReport, error) {
+	start := time.Now()
+	scanType, err := RegistryScanType(ctx, client)
+	if err != nil {
+		return CheckReport{}, err
+	}
+	scans, err := GetRepositoryScanning(ctx, client, repositories)
+	if err != nil {
+		return CheckReport{}, err
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		return scans[i].Repository < scans[j].Repository
+	})
+	return CheckReport{ScanType: scanType, Repositories: scans, Duration: time.Since(start)}, nil
+}
+
+// --- Status is the outcome of setting the scan frequency of a single repository ---
+type Status string
+
+const (
+	StatusAlreadySet Status = "ALREADY_SET"
+	StatusChanged    Status = "CHANGED"
+	StatusFailed     Status = "FAILED"
+)
+
+// --- Options controls how the scan frequency is set ---
+type Options struct {
+	DryRun      bool
+	Concurrency int
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- RepositoryResult records the frequency found and the outcome for a single repository ---
+type RepositoryResult struct {
+	Repository string        `json:"repository"`
+	Previous   ScanFrequency `json:"previous,omitempty"`
+	Status     Status        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// --- Report summarizes a run setting the scan frequency ---
+type Report struct {
+	Target       ScanFrequency      `json:"target"`
+	Repositories []RepositoryResult `json:"repositories"`
+	DryRun       bool               `json:"dryRun"`
+	Duration     time.Duration      `json:"duration"`
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r Report) Summary() string {
+	counts := map[Status]int{}
+	for _, repo := range r.Repositories {
+		counts[repo.Status]++
+	}
+	verb := "changed"
+	if r.DryRun {
+		verb = "would change"
+	}
+	return fmt.Sprintf("Scan frequency %s: %d repos already set, %d %s, %d failed in %s", r.Target, counts[StatusAlreadySet], counts[StatusChanged], verb, counts[StatusFailed], r.Duration.Round(time.Millisecond))
+}
+
+// --- renders one test case per repository, failed changes become failures ---
+func (r Report) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner enableScan", Duration: r.Duration}
+	for _, repo := range r.Repositories {
+		c := format.JUnitCase{Name: repo.Repository, ClassName: "enableScan"}
+		switch repo.Status {
+		case StatusFailed:
+			c.Failure = repo.Error
+		case StatusAlreadySet:
+			c.Skipped = "scan frequency already " + string(r.Target)
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	return suite
+}
+
+// --- sets the scan frequency of the repositories with PutImageScanningConfiguration, leaving those already set untouched ---
+// --- with basic scanning a repository scans on push or manually, CONTINUOUS_SCAN needs enhanced scanning, where the ---
+// --- registry scanning rules decide the frequency, so repositories no CONTINUOUS_SCAN rule matches are reported as failed ---
+func SetScanFrequency(ctx context.Context, client ECRAPI, repositories []string, frequency ScanFrequency, opts Options) (Report, error) {
+	start := time.Now()
+	report := Report{Target: frequency, DryRun: opts.DryRun}
+
+	scanType, err := RegistryScanType(ctx, client)
+	if err != nil {
+		return report, err
+	}
+	if frequency == ContinuousScan {
+		enabled, err := VerifyEnhancedScanningEnabled(ctx, client)
+		if err != nil {
+			return report, err
+		}
+		if !enabled {
+			return report, fmt.Errorf("%s requires enhanced scanning: %s", ContinuousScan, EnhancedScanningHint)
+		}
+	}
+	scans, err := GetRepositoryScanning(ctx, client, repositories)
+	if err != nil {
+		return report, err
+	}
+
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	failed := 0
+	concurrency.ForEach(scans, opts.Concurrency, func(scan RepositoryScan) {
+		repo := scan.Repository
+		result := RepositoryResult{Repository: repo, Previous: scan.ScanFrequency}
+		var msg string
+		switch {
+		case scan.Error != "":
+			result.Status, result.Error = StatusFailed, scan.Error
+			msg = fmt.Sprintf("[ERROR] Repository: %s - Failed to read scanning configuration: %s", repo, scan.Error)
+		case scan.ScanFrequency == frequency:
+			result.Status = StatusAlreadySet
+			msg = fmt.Sprintf("[INFO] Repository: %s - Scan frequency already %s", repo, frequency)
+		case scanType == types.ScanTypeEnhanced:
+			result.Status = StatusFailed
+			result.Error = fmt.Sprintf("with enhanced scanning the frequency is set by the registry scanning rules, add a %s rule whose filter matches %s", frequency, repo)
+			msg = fmt.Sprintf("[ERROR] Repository: %s - Cannot change scan frequency from %s to %s: %s", repo, scan.ScanFrequency, frequency, result.Error)
+		case opts.DryRun:
+			result.Status = StatusChanged
+			msg = fmt.Sprintf("[DRY RUN] Repository: %s - Would change scan frequency from %s to %s", repo, scan.ScanFrequency, frequency)
+		default:
+			_, err := client.PutImageScanningConfiguration(ctx, &ecr.PutImageScanningConfigurationInput{
+				RepositoryName:             aws.String(repo),
+				ImageScanningConfiguration: &types.ImageScanningConfiguration{ScanOnPush: frequency == ScanOnPush},
+			})
+			if err != nil {
+				result.Status, result.Error = StatusFailed, err.Error()
+				msg = fmt.Sprintf("[ERROR] Repository: %s - Failed to set scan frequency to %s: %v", repo, frequency, err)
+			} else {
+				result.Status = StatusChanged
+				msg = fmt.Sprintf("[INFO] Repository: %s - Changed scan frequency from %s to %s", repo, scan.ScanFrequency, frequency)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Repositories = append(report.Repositories, result)
+		logMessages = append(logMessages, logbuffer.NewEntry(msg))
+		if result.Status == StatusFailed {
+			failed++
+		}
+	})
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+	report.Duration = time.Since(start)
+	if failed > 0 {
+		return report, fmt.Errorf("failed to set scan frequency for %d repositories", failed)
+	}
+	return report, nil
+}
@@ -0,0 +1,152 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package scanningconfig
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- repositories maps a repository name to its effective scan frequency, unknown repositories are reported as failures ---
+type mockECRClient struct {
+	config       *types.RegistryScanningConfiguration
+	err          error
+	repositories map[string]types.ScanFrequency
+	batches      [][]string
+	puts         map[string]bool
+}
+
+func (m *mockECRClient) GetRegistryScanningConfiguration(ctx context.Context, in *ecr.GetRegistryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.GetRegistryScanningConfigurationOutput, error) {
+	return &ecr.GetRegistryScanningConfigurationOutput{ScanningConfiguration: m.config}, m.err
+}
+
+func (m *mockECRClient) BatchGetRepositoryScanningConfiguration(ctx context.Context, in *ecr.BatchGetRepositoryScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.BatchGetRepositoryScanningConfigurationOutput, error) {
+	m.batches = append(m.batches, in.RepositoryNames)
+	out := &ecr.BatchGetRepositoryScanningConfigurationOutput{}
+	for _, name := range in.RepositoryNames {
+		frequency, ok := m.repositories[name]
+		if !ok {
+			out.Failures = append(out.Failures, types.RepositoryScanningConfigurationFailure{RepositoryName: aws.String(name), FailureCode: types.ScanningConfigurationFailureCodeRepositoryNotFound, FailureReason: aws.String("not found")})
+			continue
+		}
+		out.ScanningConfigurations = append(out.ScanningConfigurations, types.RepositoryScanningConfiguration{RepositoryName: aws.String(name), ScanFrequency: frequency, ScanOnPush: frequency == types.ScanFrequencyScanOnPush})
+	}
+	return out, nil
+}
+
+func (m *mockECRClient) PutImageScanningConfiguration(ctx context.Context, in *ecr.PutImageScanningConfigurationInput, optFns ...func(*ecr.Options)) (*ecr.PutImageScanningConfigurationOutput, error) {
+	if m.puts == nil {
+		m.puts = map[string]bool{}
+	}
+	m.puts[aws.ToString(in.RepositoryName)] = in.ImageScanningConfiguration.ScanOnPush
+	return &ecr.PutImageScanningConfigurationOutput{}, nil
+}
+
+func TestVerifyEnhancedScanningEnabled(t *testing.T) {
+	rule := types.RegistryScanningRule{
+		ScanFrequency:     types.ScanFrequencyScanOnPush,
+		RepositoryFilters: []types.ScanningRepositoryFilter{{Filter: aws.String("*"), FilterType: types.ScanningRepositoryFilterTypeWildcard}},
+	}
+	cases := map[string]struct {
+		config *types.RegistryScanningConfiguration
+		want   bool
+	}{
+		"no configuration": {nil, false},
+		"basic scanning":   {&types.RegistryScanningConfiguration{ScanType: types.ScanTypeBasic, Rules: []types.RegistryScanningRule{rule}}, false},
+		"enhanced no rule": {&types.RegistryScanningConfiguration{ScanType: types.ScanTypeEnhanced}, false},
+		"enhanced":         {&types.RegistryScanningConfiguration{ScanType: types.ScanTypeEnhanced, Rules: []types.RegistryScanningRule{rule}}, true},
+	}
+	for name, c := range cases {
+		got, err := VerifyEnhancedScanningEnabled(context.TODO(), &mockECRClient{config: c.config})
+		if err != nil || got != c.want {
+			t.Errorf("%s: VerifyEnhancedScanningEnabled = %v, %v; want %v, nil", name, got, err, c.want)
+		}
+	}
+
+	if _, err := VerifyEnhancedScanningEnabled(context.TODO(), &mockECRClient{err: errors.New("access denied")}); err == nil {
+		t.Errorf("Expected error when the configuration cannot be read")
+	}
+}
+
+func TestParseScanFrequency(t *testing.T) {
+	for value, want := range map[string]ScanFrequency{"SCAN_ON_PUSH": ScanOnPush, "continuous_scan": ContinuousScan, "manual": Manual, " scan-on-push ": ScanOnPush} {
+		if got, err := ParseScanFrequency(value); err != nil || got != want {
+			t.Errorf("ParseScanFrequency(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseScanFrequency("DAILY"); err == nil {
+		t.Errorf("Expected an error for an unknown frequency")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	client := &mockECRClient{repositories: map[string]types.ScanFrequency{}}
+	var repos []string
+	for i := 0; i < 30; i++ {
+		name := string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+		client.repositories[name] = types.ScanFrequencyScanOnPush
+		repos = append(repos, name)
+	}
+	client.repositories["a"] = types.ScanFrequencyManual
+	repos = append(repos, "missing")
+
+	report, err := Check(context.TODO(), client, repos)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// --- at most 25 repositories per call ---
+	if len(client.batches) != 2 || len(client.batches[0]) != 25 || len(client.batches[1]) != 6 {
+		t.Errorf("Expected calls of 25 and 6 repositories, got: %d calls", len(client.batches))
+	}
+	if report.ScanType != types.ScanTypeBasic {
+		t.Errorf("Expected BASIC without a registry configuration, got: %s", report.ScanType)
+	}
+	if !reflect.DeepEqual(report.NonCompliant(), []string{"a"}) {
+		t.Errorf("Expected a to be non-compliant, got: %v", report.NonCompliant())
+	}
+	want := "BASIC scanning: 29 repos scanned on push, 0 continuously, 1 manual (non-compliant), 1 failed"
+	if report.Summary() != want {
+		t.Errorf("Expected %q, got %q", want, report.Summary())
+	}
+}
+
+func TestSetScanFrequency(t *testing.T) {
+	client := &mockECRClient{repositories: map[string]types.ScanFrequency{"app": types.ScanFrequencyManual, "web": types.ScanFrequencyScanOnPush}}
+	report, err := SetScanFrequency(context.TODO(), client, []string{"app", "web"}, ScanOnPush, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(client.puts, map[string]bool{"app": true}) {
+		t.Errorf("Expected only app to be changed to scan on push, got: %v", client.puts)
+	}
+	if report.Repositories[0].Status != StatusChanged || report.Repositories[1].Status != StatusAlreadySet {
+		t.Errorf("Unexpected report: %+v", report.Repositories)
+	}
+
+	// --- a dry run changes nothing ---
+	client.puts = nil
+	if _, err := SetScanFrequency(context.TODO(), client, []string{"web"}, Manual, Options{DryRun: true}); err != nil || client.puts != nil {
+		t.Errorf("Expected no changes in a dry run, got: %v, %v", client.puts, err)
+	}
+
+	// --- continuous scanning needs enhanced scanning on the registry ---
+	if _, err := SetScanFrequency(context.TODO(), client, []string{"app"}, ContinuousScan, Options{}); err == nil || !strings.Contains(err.Error(), "enhanced scanning is not enabled") {
+		t.Errorf("Expected the enhanced scanning hint, got: %v", err)
+	}
+
+	// --- with enhanced scanning the registry rules decide, repositories they do not cover fail ---
+	client.config = &types.RegistryScanningConfiguration{ScanType: types.ScanTypeEnhanced, Rules: []types.RegistryScanningRule{{ScanFrequency: types.ScanFrequencyContinuousScan}}}
+	client.repositories = map[string]types.ScanFrequency{"app": types.ScanFrequencyContinuousScan, "web": types.ScanFrequencyScanOnPush}
+	client.puts = nil
+	report, err = SetScanFrequency(context.TODO(), client, []string{"app", "web"}, ContinuousScan, Options{})
+	if err == nil || report.Repositories[0].Status != StatusAlreadySet || report.Repositories[1].Status != StatusFailed || client.puts != nil {
+		t.Errorf("Expected web to fail without a matching rule, got: %+v, %v", report.Repositories, err)
+	}
+}
@@ -4,45 +4,200 @@ package setlifecyclepolicy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	concurrency "ecr-lifecycle-cleaner/internal/concurrency"
+	format "ecr-lifecycle-cleaner/internal/format"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
 )
 
+// --- LifecyclePolicyAPI defines the subset of ecr.Client methods needed to list repositories and plan and apply policies ---
+type LifecyclePolicyAPI interface {
+	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+	GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error)
+	PutLifecyclePolicy(ctx context.Context, in *ecr.PutLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error)
+}
+
+// --- RepositoryCreator is the optional method needed by CreateMissingRepos ---
+type RepositoryCreator interface {
+	CreateRepository(ctx context.Context, in *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error)
+}
+
+// --- PolicyPreviewer is the optional pair of methods needed by PreviewImpact ---
+type PolicyPreviewer interface {
+	StartLifecyclePolicyPreview(ctx context.Context, in *ecr.StartLifecyclePolicyPreviewInput, optFns ...func(*ecr.Options)) (*ecr.StartLifecyclePolicyPreviewOutput, error)
+	GetLifecyclePolicyPreview(ctx context.Context, in *ecr.GetLifecyclePolicyPreviewInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyPreviewOutput, error)
+}
+
+// --- ECRAPI is the full set of ecr.Client methods the package can use ---
+type ECRAPI interface {
+	LifecyclePolicyAPI
+	RepositoryCreator
+	PolicyPreviewer
+}
+
+var _ ECRAPI = (*ecr.Client)(nil)
+
+// --- limitedClient caps the policy calls in flight per operation, PutLifecyclePolicy throttles well below the read calls ---
+type limitedClient struct {
+	ECRAPI
+	limiter concurrency.Limiter
+}
+
+// --- wraps client with per-operation limits, operations without a limit pass straight through ---
+func WithOperationLimits(client ECRAPI, limits concurrency.OperationLimits) ECRAPI {
+	if len(limits) == 0 {
+		return client
+	}
+	return &limitedClient{ECRAPI: client, limiter: concurrency.NewLimiter(limits)}
+}
+
+func (c *limitedClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "GetLifecyclePolicy", func() (*ecr.GetLifecyclePolicyOutput, error) {
+		return c.ECRAPI.GetLifecyclePolicy(ctx, in, optFns...)
+	})
+}
+
+func (c *limitedClient) PutLifecyclePolicy(ctx context.Context, in *ecr.PutLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error) {
+	return concurrency.Do(ctx, c.limiter, "PutLifecyclePolicy", func() (*ecr.PutLifecyclePolicyOutput, error) {
+		return c.ECRAPI.PutLifecyclePolicy(ctx, in, optFns...)
+	})
+}
+
+// --- RepositoryError records a failure for a single repository ---
+type RepositoryError struct {
+	Repository string `json:"repository"`
+	Message    string `json:"error"`
+}
+
+func (e RepositoryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Repository, e.Message)
+}
+
+// --- SetPolicyOptions controls how a policy is applied ---
+type SetPolicyOptions struct {
+	DryRun       bool
+	OnlyIfAbsent bool
+	PolicyID     string
+	// --- create repositories that do not exist yet with default settings before putting the policy ---
+	CreateMissingRepos bool
+	// --- account and region the client operates on, added to per-repository errors and the report ---
+	Registry awsregistry.Registry
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+	// --- maximum number of repositories planned or applied at once, 0 means unbounded ---
+	Concurrency int
+	// --- previews the policy on every repository it would be applied to and counts the images it would expire ---
+	PreviewImpact bool
+}
+
+// --- SetPolicyReport summarizes the outcome of a policy run ---
+type SetPolicyReport struct {
+	Applied        []string          `json:"applied"`
+	Skipped        []string          `json:"skipped"`
+	Failed         []RepositoryError `json:"failed"`
+	DryRun         bool              `json:"dryRun"`
+	Duration       time.Duration     `json:"duration"`
+	PolicyID       string            `json:"policyId,omitempty"`
+	PolicyChecksum string            `json:"policyChecksum,omitempty"`
+	// --- account and region the run operated on ---
+	Registry awsregistry.Registry `json:"registry"`
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r SetPolicyReport) Summary() string {
+	return fmt.Sprintf("Applied to %d repos, skipped %d, failed %d", len(r.Applied), len(r.Skipped), len(r.Failed))
+}
+
+// --- returns the number of repositories by outcome and the duration as Prometheus gauges ---
+func (r SetPolicyReport) Metrics() []format.Metric {
+	help := "Repositories of the last setPolicy run by outcome."
+	return []format.Metric{
+		{Name: "ecr_lifecycle_cleaner_policy_repositories", Help: help, Labels: map[string]string{"outcome": "applied"}, Value: float64(len(r.Applied))},
+		{Name: "ecr_lifecycle_cleaner_policy_repositories", Help: help, Labels: map[string]string{"outcome": "skipped"}, Value: float64(len(r.Skipped))},
+		{Name: "ecr_lifecycle_cleaner_policy_repositories", Help: help, Labels: map[string]string{"outcome": "failed"}, Value: float64(len(r.Failed))},
+		{Name: "ecr_lifecycle_cleaner_set_policy_duration_seconds", Help: "Duration of the last setPolicy run.", Value: r.Duration.Seconds()},
+	}
+}
+
+// --- returns the report as JUnit test results, one case per repository, failed repositories fail their case ---
+func (r SetPolicyReport) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner setPolicy", Duration: r.Duration}
+	for _, repo := range r.Applied {
+		suite.Cases = append(suite.Cases, format.JUnitCase{Name: repo, ClassName: "setPolicy"})
+	}
+	for _, repo := range r.Skipped {
+		suite.Cases = append(suite.Cases, format.JUnitCase{Name: repo, ClassName: "setPolicy", Skipped: "policy not changed"})
+	}
+	for _, failure := range r.Failed {
+		suite.Cases = append(suite.Cases, format.JUnitCase{Name: failure.Repository, ClassName: "setPolicy", Failure: failure.Message})
+	}
+	sort.SliceStable(suite.Cases, func(i, j int) bool {
+		return suite.Cases[i].Name < suite.Cases[j].Name
+	})
+	return suite
+}
+
 // --- the entry point for setting ECR lifecycle policies ---
 // --- It fetches the list of repositories based on the provided parameters and sets the lifecycle policy for each ---
-func Main(client *ecr.Client, policyText string, allRepos bool, repositoryList []string, repoPattern string, dryRun bool) error {
+func Main(client LifecyclePolicyAPI, policyText string, allRepos bool, repositoryList []string, repoPatterns []string, opts SetPolicyOptions) (SetPolicyReport, error) {
 	ctx := context.TODO()
+	empty := SetPolicyReport{DryRun: opts.DryRun, PolicyID: opts.PolicyID, PolicyChecksum: PolicyChecksum(policyText)}
 	if allRepos {
 		var err error
 		repositoryList, err = GetRepositories(ctx, client)
 		if err != nil {
-			return err
+			return empty, err
 		}
-	} else if len(repoPattern) > 0 {
+	} else if len(repoPatterns) > 0 {
 		var err error
-		repositoryList, err = GetRepositoriesByPattern(ctx, client, repoPattern)
+		repositoryList, err = GetRepositoriesByPattern(ctx, client, repoPatterns)
 		if err != nil {
-			return err
+			return empty, err
 		}
 	}
 	if len(repositoryList) == 0 {
-		return nil
+		return empty, nil
 	}
 
-	if err := setPolicyForAll(ctx, client, policyText, repositoryList, dryRun); err != nil {
-		return err
+	return setPolicyForAll(ctx, client, policyText, repositoryList, opts)
+}
+
+// --- RepositoryFilter decides from the DescribeRepositories output whether a repository is targeted ---
+type RepositoryFilter func(repo types.Repository) bool
+
+// --- keeps only repositories with tag immutability enabled, or only mutable ones when immutable is false ---
+// --- immutability with exclusions counts as immutable ---
+func TagImmutabilityFilter(immutable bool) RepositoryFilter {
+	return func(repo types.Repository) bool {
+		switch repo.ImageTagMutability {
+		case types.ImageTagMutabilityImmutable, types.ImageTagMutabilityImmutableWithExclusion:
+			return immutable
+		}
+		return !immutable
 	}
-	return nil
 }
 
-// --- returns all repository names ---
-func GetRepositories(ctx context.Context, client *ecr.Client) ([]string, error) {
+// --- returns all repository names, keeping only those every filter accepts ---
+func GetRepositories(ctx context.Context, client LifecyclePolicyAPI, filters ...RepositoryFilter) ([]string, error) {
 	var repositories []string
 	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
 
@@ -52,96 +207,663 @@ func GetRepositories(ctx context.Context, client *ecr.Client) ([]string, error)
 			return nil, fmt.Errorf("failed to get next page of repositories: %w", err)
 		}
 		for _, repo := range page.Repositories {
-			repositories = append(repositories, aws.ToString(repo.RepositoryName))
+			if matchesFilters(repo, filters) {
+				repositories = append(repositories, aws.ToString(repo.RepositoryName))
+			}
 		}
 	}
 	return repositories, nil
 }
 
-// --- returns repositories matching a pattern ---
-func GetRepositoriesByPattern(ctx context.Context, client *ecr.Client, repoPattern string) ([]string, error) {
+// --- reports whether all filters accept the repository ---
+func matchesFilters(repo types.Repository, filters []RepositoryFilter) bool {
+	for _, filter := range filters {
+		if !filter(repo) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- returns repositories matching any of the patterns, keeping only those every filter accepts ---
+func GetRepositoriesByPattern(ctx context.Context, client LifecyclePolicyAPI, repoPatterns []string, filters ...RepositoryFilter) ([]string, error) {
+	patterns := make([]*regexp.Regexp, len(repoPatterns))
+	for i, repoPattern := range repoPatterns {
+		pattern, err := regexp.Compile(repoPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", repoPattern, err)
+		}
+		patterns[i] = pattern
+	}
+
 	var repositories []string
-	allRepositories, err := GetRepositories(ctx, client)
+	allRepositories, err := GetRepositories(ctx, client, filters...)
 	if err != nil {
 		return nil, err
 	}
-
 	for _, repo := range allRepositories {
-		matched, err := regexp.MatchString(repoPattern, repo)
-		if err != nil {
-			return nil, fmt.Errorf("failed to match pattern %s: %w", repoPattern, err)
-		}
-		if matched {
-			repositories = append(repositories, repo)
+		for _, pattern := range patterns {
+			if pattern.MatchString(repo) {
+				repositories = append(repositories, repo)
+				break
+			}
 		}
 	}
 	return repositories, nil
 }
 
+// --- returns the current lifecycle policy of a repository and whether one exists ---
+func getLifecyclePolicy(ctx context.Context, client LifecyclePolicyAPI, repository string) (string, bool, error) {
+	resp, err := client.GetLifecyclePolicy(ctx, &ecr.GetLifecyclePolicyInput{RepositoryName: aws.String(repository)})
+	if err != nil {
+		var notFound *types.LifecyclePolicyNotFoundException
+		if errors.As(err, &notFound) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get lifecycle policy for %s: %w", repository, err)
+	}
+	return aws.ToString(resp.LifecyclePolicyText), true, nil
+}
+
+// --- returns the sha256 checksum of the canonical JSON form of a policy ---
+// --- key order and whitespace do not affect the result, invalid JSON is hashed as-is ---
+func PolicyChecksum(policyText string) string {
+	canonical := []byte(policyText)
+	decoder := json.NewDecoder(strings.NewReader(policyText))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err == nil {
+		if data, err := json.Marshal(parsed); err == nil {
+			canonical = data
+		}
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
+
+// --- returns the label logged next to each apply so operators can correlate policy versions ---
+func policyLabel(policyID, checksum string) string {
+	if policyID != "" {
+		return fmt.Sprintf("policyId: %s, checksum: %s", policyID, checksum[:12])
+	}
+	return fmt.Sprintf("checksum: %s", checksum[:12])
+}
+
 // --- sets the lifecycle policy for a repository ---
-func setPolicy(ctx context.Context, client *ecr.Client, repository string, policyText string, dryRun bool) (string, error) {
+// --- with createMissing a repository that does not exist is created with default settings and the put is retried once ---
+func setPolicy(ctx context.Context, client LifecyclePolicyAPI, repository string, policyText string, dryRun bool, createMissing bool, label string) (string, error) {
 	if dryRun {
-		return fmt.Sprintf("[DRY RUN] Would set lifecycle policy for repository: %s", repository), nil
+		return fmt.Sprintf("[DRY RUN] Would set lifecycle policy for repository: %s (%s)", repository, label), nil
 	}
 	input := &ecr.PutLifecyclePolicyInput{
 		RepositoryName:      aws.String(repository),
 		LifecyclePolicyText: aws.String(policyText),
 	}
 	resp, err := client.PutLifecyclePolicy(ctx, input)
+	var notFound *types.RepositoryNotFoundException
+	if err != nil && createMissing && errors.As(err, &notFound) {
+		creator, ok := client.(RepositoryCreator)
+		if !ok {
+			return "", fmt.Errorf("failed to create repository %s: client does not support CreateRepository", repository)
+		}
+		if _, err := creator.CreateRepository(ctx, &ecr.CreateRepositoryInput{RepositoryName: aws.String(repository)}); err != nil {
+			return "", fmt.Errorf("failed to create repository %s: %w", repository, err)
+		}
+		resp, err = client.PutLifecyclePolicy(ctx, input)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to set lifecycle policy for %s: %w", repository, err)
 	}
-	return fmt.Sprintf("[INFO] Successfully set lifecycle policy for repository %s:\n %s", repository, aws.ToString(resp.LifecyclePolicyText)), nil
+	return fmt.Sprintf("[INFO] Successfully set lifecycle policy for repository %s (%s): %s", repository, label, SummarizePolicy(aws.ToString(resp.LifecyclePolicyText))), nil
+}
+
+// --- PolicySummary is a short description of a lifecycle policy for logs ---
+type PolicySummary struct {
+	RuleCount int `json:"ruleCount"`
+	// --- number of rules per tagStatus (tagged, untagged, any) ---
+	RulesByTagStatus map[string]int `json:"rulesByTagStatus"`
+	// --- the smallest countNumber among the rules of each tagStatus ---
+	MinCountByTagStatus map[string]int `json:"minCountByTagStatus"`
+	// --- one entry per rule in priority order, e.g. keep tagged(prod-*)=10 or expire any>90 days ---
+	Rules []string `json:"rules"`
+}
+
+// --- extracts rule counts and retention from a policy, an unparsable policy gives an empty summary ---
+func SummarizePolicy(policyText string) PolicySummary {
+	summary := PolicySummary{RulesByTagStatus: map[string]int{}, MinCountByTagStatus: map[string]int{}}
+	policy, err := readpolicyfile.ParsePolicy(policyText)
+	if err != nil {
+		return summary
+	}
+	rules := append([]readpolicyfile.Rule(nil), policy.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].RulePriority < rules[j].RulePriority
+	})
+
+	for _, rule := range rules {
+		status := strings.ToLower(rule.Selection.TagStatus)
+		summary.RuleCount++
+		summary.RulesByTagStatus[status]++
+		if current, ok := summary.MinCountByTagStatus[status]; !ok || rule.Selection.CountNumber < current {
+			summary.MinCountByTagStatus[status] = rule.Selection.CountNumber
+		}
+		summary.Rules = append(summary.Rules, describeRule(rule.Selection, status))
+	}
+	return summary
 }
 
-// --- sets the policy for all repositories in the list ---
-func setPolicyForAll(ctx context.Context, client *ecr.Client, policyText string, repoList []string, dryRun bool) error {
-	var wg sync.WaitGroup
+// --- describes a single rule, image count rules keep images and age rules expire them ---
+func describeRule(selection readpolicyfile.Selection, status string) string {
+	patterns := append([]string(nil), selection.TagPatternList...)
+	for _, prefix := range selection.TagPrefixList {
+		patterns = append(patterns, prefix+"*")
+	}
+	if len(patterns) > 0 {
+		status = fmt.Sprintf("%s(%s)", status, strings.Join(patterns, ","))
+	}
+	if selection.CountType == "sinceImagePushed" {
+		return fmt.Sprintf("expire %s>%d %s", status, selection.CountNumber, strings.ToLower(selection.CountUnit))
+	}
+	return fmt.Sprintf("keep %s=%d", status, selection.CountNumber)
+}
+
+// --- returns the summary as one line, e.g. Applied 2 rules: keep tagged(prod-*)=10, expire any>90 days ---
+func (s PolicySummary) String() string {
+	noun := "rules"
+	if s.RuleCount == 1 {
+		noun = "rule"
+	}
+	if s.RuleCount == 0 {
+		return fmt.Sprintf("Applied 0 %s", noun)
+	}
+	return fmt.Sprintf("Applied %d %s: %s", s.RuleCount, noun, strings.Join(s.Rules, ", "))
+}
+
+// --- RepositoryPolicyPlan is what the planning phase decided for a single repository ---
+type RepositoryPolicyPlan struct {
+	Repository string `json:"repository"`
+	Apply      bool   `json:"apply"`
+	HasPolicy  bool   `json:"hasPolicy"`
+	Create     bool   `json:"create,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// --- images the policy would expire according to ECR's lifecycle policy preview, set with PreviewImpact ---
+	ExpiringImages *int `json:"expiringImages,omitempty"`
+	// --- why the preview failed, a failed preview does not stop the policy from being applied ---
+	PreviewError string `json:"previewError,omitempty"`
+}
+
+// --- PolicyPlan lists the repositories a run would change, computed before any policy is put ---
+type PolicyPlan struct {
+	Repositories   []RepositoryPolicyPlan `json:"repositories"`
+	PolicyID       string                 `json:"policyId,omitempty"`
+	PolicyChecksum string                 `json:"policyChecksum"`
+	DryRun         bool                   `json:"dryRun"`
+	Registry       awsregistry.Registry   `json:"registry"`
+}
+
+// --- returns the images the policy would expire across the previewed repositories and how many were previewed ---
+func (p PolicyPlan) ExpiringImages() (images int, repositories int) {
+	for _, repo := range p.Repositories {
+		if repo.ExpiringImages != nil {
+			images += *repo.ExpiringImages
+			repositories++
+		}
+	}
+	return images, repositories
+}
+
+// --- ECR previews one repository in seconds to a few minutes, longer means the preview is stuck ---
+const previewMaxWait = 10 * time.Minute
+
+// --- previews policyText on a repository with StartLifecyclePolicyPreview and returns the number of images it would expire ---
+func PreviewPolicy(ctx context.Context, client PolicyPreviewer, repo string, policyText string) (int, error) {
+	_, err := client.StartLifecyclePolicyPreview(ctx, &ecr.StartLifecyclePolicyPreviewInput{
+		RepositoryName:      aws.String(repo),
+		LifecyclePolicyText: aws.String(policyText),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start lifecycle policy preview: %w", err)
+	}
+	waiter := ecr.NewLifecyclePolicyPreviewCompleteWaiter(client)
+	out, err := waiter.WaitForOutput(ctx, &ecr.GetLifecyclePolicyPreviewInput{RepositoryName: aws.String(repo)}, previewMaxWait)
+	if err != nil {
+		return 0, fmt.Errorf("lifecycle policy preview did not complete: %w", err)
+	}
+	if out.Summary == nil {
+		return 0, nil
+	}
+	return int(aws.ToInt32(out.Summary.ExpiringImageTotalCount)), nil
+}
+
+// --- fills in the expiring image count of a planned repository, failures are logged and kept on the entry ---
+func previewRepository(ctx context.Context, client LifecyclePolicyAPI, entry *RepositoryPolicyPlan, policyText string) string {
+	previewer, ok := client.(PolicyPreviewer)
+	if !ok {
+		entry.PreviewError = "client does not support lifecycle policy previews"
+		return fmt.Sprintf("[WARN] Repository: %s - Could not preview the policy: %s", entry.Repository, entry.PreviewError)
+	}
+	expiring, err := PreviewPolicy(ctx, previewer, entry.Repository, policyText)
+	if err != nil {
+		entry.PreviewError = err.Error()
+		return fmt.Sprintf("[WARN] Repository: %s - Could not preview the policy: %v", entry.Repository, err)
+	}
+	entry.ExpiringImages = &expiring
+	return fmt.Sprintf("[INFO] Repository: %s - %d images would be expired under this policy", entry.Repository, expiring)
+}
+
+// --- read-only phase for a single repository, decides whether the policy needs to be applied ---
+func planRepository(ctx context.Context, client LifecyclePolicyAPI, repo string, checksum string, opts SetPolicyOptions) (RepositoryPolicyPlan, string) {
+	label := policyLabel(opts.PolicyID, checksum)
+	plan := RepositoryPolicyPlan{Repository: repo}
+	current, exists, err := getLifecyclePolicy(ctx, client, repo)
+	var notFound *types.RepositoryNotFoundException
+	if err != nil && opts.CreateMissingRepos && errors.As(err, &notFound) {
+		plan.Apply = true
+		plan.Create = true
+		if opts.DryRun {
+			return plan, fmt.Sprintf("[DRY RUN] Repository: %s - Does not exist, would be created", repo)
+		}
+		return plan, fmt.Sprintf("[INFO] Repository: %s - Does not exist, will be created", repo)
+	}
+	if err != nil {
+		err = opts.Registry.Wrap(err)
+		plan.Error = err.Error()
+		return plan, fmt.Sprintf("[ERROR] Repository: %s - Failed to check existing policy: %v", repo, err)
+	}
+	plan.HasPolicy = exists
+	if exists && opts.OnlyIfAbsent {
+		plan.Reason = "policy already present"
+		return plan, fmt.Sprintf("[INFO] Repository: %s - Lifecycle policy already present, skipping", repo)
+	}
+	if exists && PolicyChecksum(current) == checksum {
+		plan.Reason = "policy already up to date"
+		return plan, fmt.Sprintf("[INFO] Repository: %s - Lifecycle policy already up to date (%s), skipping", repo, label)
+	}
+	plan.Apply = true
+	return plan, ""
+}
+
+// --- builds the policy plan for all repositories without changing anything ---
+func PlanPolicy(ctx context.Context, client LifecyclePolicyAPI, policyText string, repoList []string, opts SetPolicyOptions) PolicyPlan {
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	checksum := PolicyChecksum(policyText)
+	plan := PolicyPlan{PolicyID: opts.PolicyID, PolicyChecksum: checksum, DryRun: opts.DryRun, Registry: opts.Registry}
+
+	concurrency.ForEach(repoList, opts.Concurrency, func(repo string) {
+		entry, logMessage := planRepository(ctx, client, repo, checksum, opts)
+		var previewMessage string
+		// --- a repository that does not exist yet has no images to preview against ---
+		if opts.PreviewImpact && entry.Apply && !entry.Create {
+			previewMessage = previewRepository(ctx, client, &entry, policyText)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		plan.Repositories = append(plan.Repositories, entry)
+		for _, message := range []string{logMessage, previewMessage} {
+			if message != "" {
+				logMessages = append(logMessages, logbuffer.NewEntry(message))
+			}
+		}
+	})
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(plan.Repositories, func(i, j int) bool {
+		return plan.Repositories[i].Repository < plan.Repositories[j].Repository
+	})
+	return plan
+}
+
+// --- applies a previously computed plan ---
+func ExecutePolicyPlan(ctx context.Context, client LifecyclePolicyAPI, policyText string, plan PolicyPlan, opts SetPolicyOptions) (SetPolicyReport, error) {
 	var mu sync.Mutex
 	var errs []error
-	var logMessages []string
-
-	for _, repository := range repoList {
-		wg.Add(1)
-		go func(repo string) {
-			defer wg.Done()
-			if dryRun {
-				logMessage := fmt.Sprintf("[DRY RUN] Would set lifecycle policy for repository: %s", repo)
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				mu.Unlock()
-				return
+	var logMessages []logbuffer.Entry
+	var toApply []string
+	label := policyLabel(plan.PolicyID, plan.PolicyChecksum)
+	report := SetPolicyReport{DryRun: opts.DryRun, PolicyID: plan.PolicyID, PolicyChecksum: plan.PolicyChecksum, Registry: opts.Registry}
+	start := time.Now()
+
+	for _, entry := range plan.Repositories {
+		switch {
+		case entry.Error != "":
+			errs = append(errs, fmt.Errorf("failed to plan repository %s: %s", entry.Repository, entry.Error))
+			report.Failed = append(report.Failed, RepositoryError{Repository: entry.Repository, Message: entry.Error})
+		case !entry.Apply:
+			report.Skipped = append(report.Skipped, entry.Repository)
+		default:
+			toApply = append(toApply, entry.Repository)
+		}
+	}
+
+	concurrency.ForEach(toApply, opts.Concurrency, func(repo string) {
+		var messages []logbuffer.Entry
+		if !opts.DryRun {
+			messages = append(messages, logbuffer.NewEntry(fmt.Sprintf("[INFO] Setting policy for repository: %s", repo)))
+		}
+		logMsg, err := setPolicy(ctx, client, repo, policyText, opts.DryRun, opts.CreateMissingRepos, label)
+		err = opts.Registry.Wrap(err)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			logMessages = append(logMessages, messages...)
+			logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[ERROR] Repository: %s - Failed to set policy: %v", repo, err)))
+			errs = append(errs, err)
+			report.Failed = append(report.Failed, RepositoryError{Repository: repo, Message: err.Error()})
+			return
+		}
+		logMessages = append(logMessages, messages...)
+		logMessages = append(logMessages, logbuffer.NewEntry(logMsg))
+		report.Applied = append(report.Applied, repo)
+	})
+	report.Duration = time.Since(start)
+
+	opts.Logs.Emit(logMessages)
+
+	sort.Strings(report.Applied)
+	sort.Strings(report.Skipped)
+	sort.Slice(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Repository < report.Failed[j].Repository
+	})
+
+	if len(errs) > 0 {
+		return report, fmt.Errorf("encountered errors during policy setup: %v", errs)
+	}
+	return report, nil
+}
+
+// --- sets the policy for all repositories in the list, planning first and applying second ---
+func setPolicyForAll(ctx context.Context, client LifecyclePolicyAPI, policyText string, repoList []string, opts SetPolicyOptions) (SetPolicyReport, error) {
+	start := time.Now()
+	plan := PlanPolicy(ctx, client, policyText, repoList, opts)
+	report, err := ExecutePolicyPlan(ctx, client, policyText, plan, opts)
+	report.Duration = time.Since(start)
+	return report, err
+}
+
+// --- DirPolicy is a policy file of a policy directory, applied to the repositories its file name matches ---
+type DirPolicy struct {
+	// --- the file name without .json, a glob over repository names such as prod-* ---
+	Pattern    string
+	File       string
+	PolicyText string
+}
+
+// --- reads the .json files of dir in file name order, each file name without the extension is a repository glob ---
+func LoadPolicyDir(dir string) ([]DirPolicy, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy directory: %w", err)
+	}
+	var policies []DirPolicy
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		pattern := strings.TrimSuffix(entry.Name(), ".json")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob in policy file name %s: %w", entry.Name(), err)
+		}
+		policyText, err := readpolicyfile.ReadPolicyFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		policies = append(policies, DirPolicy{Pattern: pattern, File: entry.Name(), PolicyText: policyText})
+	}
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no .json policy files in %s", dir)
+	}
+	return policies, nil
+}
+
+// --- returns the first policy whose glob matches the repository, file names cannot hold a / so * also matches the / of namespaces ---
+func MatchPolicy(policies []DirPolicy, repo string) (DirPolicy, bool) {
+	// --- | is not allowed in repository names, so it stands in for / without colliding ---
+	name := strings.ReplaceAll(repo, "/", "|")
+	for _, policy := range policies {
+		if ok, _ := path.Match(policy.Pattern, name); ok {
+			return policy, true
+		}
+	}
+	return DirPolicy{}, false
+}
+
+// --- applies to each repository the policy of the first file in dir whose glob matches its name ---
+// --- repositories no file matches are skipped, a directory that cannot be read fails every repository ---
+func SetPolicyFromDir(ctx context.Context, client ECRAPI, dir string, repos []string, opts SetPolicyOptions) SetPolicyReport {
+	start := time.Now()
+	report := SetPolicyReport{DryRun: opts.DryRun, PolicyID: opts.PolicyID, Registry: opts.Registry}
+	policies, err := LoadPolicyDir(dir)
+	if err != nil {
+		for _, repo := range repos {
+			report.Failed = append(report.Failed, RepositoryError{Repository: repo, Message: err.Error()})
+		}
+		report.Duration = time.Since(start)
+		return report
+	}
+
+	groups := map[string][]string{}
+	var logMessages []logbuffer.Entry
+	for _, repo := range repos {
+		policy, ok := MatchPolicy(policies, repo)
+		if !ok {
+			logMessages = append(logMessages, logbuffer.NewEntry(fmt.Sprintf("[INFO] Repository: %s - No policy file in %s matches, skipping", repo, dir)))
+			report.Skipped = append(report.Skipped, repo)
+			continue
+		}
+		groups[policy.File] = append(groups[policy.File], repo)
+	}
+	opts.Logs.Emit(logMessages)
+
+	for _, policy := range policies {
+		group, ok := groups[policy.File]
+		if !ok {
+			continue
+		}
+		groupOpts := opts
+		if groupOpts.PolicyID == "" {
+			groupOpts.PolicyID = policy.File
+		}
+		// --- failures are recorded in the group report, the error only summarizes them ---
+		groupReport, _ := setPolicyForAll(ctx, client, policy.PolicyText, group, groupOpts)
+		report.Applied = append(report.Applied, groupReport.Applied...)
+		report.Skipped = append(report.Skipped, groupReport.Skipped...)
+		report.Failed = append(report.Failed, groupReport.Failed...)
+	}
+
+	sort.Strings(report.Applied)
+	sort.Strings(report.Skipped)
+	sort.Slice(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Repository < report.Failed[j].Repository
+	})
+	report.Duration = time.Since(start)
+	return report
+}
+
+// --- DriftStatus is how the lifecycle policy of a repository compares to a reference policy ---
+type DriftStatus string
+
+const (
+	PolicyInSync  DriftStatus = "IN_SYNC"
+	PolicyDrifted DriftStatus = "DRIFTED"
+	PolicyMissing DriftStatus = "MISSING"
+)
+
+// --- RepositoryDrift is the audit result of one repository, Status is empty when its policy could not be read ---
+type RepositoryDrift struct {
+	Repository string      `json:"repository"`
+	Status     DriftStatus `json:"status,omitempty"`
+	// --- checksum of the repository's current policy, so repositories drifted the same way can be grouped ---
+	PolicyChecksum string `json:"policyChecksum,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// --- AccountDrift is the audit of the repositories of one account, Error is set when the account could not be audited ---
+type AccountDrift struct {
+	Registry awsregistry.Registry `json:"registry"`
+	// --- AWS profile the account was reached with, empty for the default configuration ---
+	Profile      string            `json:"profile,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Repositories []RepositoryDrift `json:"repositories"`
+}
+
+// --- DriftReport consolidates the audits of several accounts against one reference policy ---
+type DriftReport struct {
+	PolicyChecksum string         `json:"policyChecksum"`
+	Accounts       []AccountDrift `json:"accounts"`
+	Duration       time.Duration  `json:"duration"`
+}
+
+// --- returns the number of drifted, missing and failed repositories and of accounts that could not be audited ---
+func (r DriftReport) Counts() (inSync, drifted, missing, failed, failedAccounts int) {
+	for _, account := range r.Accounts {
+		if account.Error != "" {
+			failedAccounts++
+		}
+		for _, repo := range account.Repositories {
+			switch repo.Status {
+			case PolicyInSync:
+				inSync++
+			case PolicyDrifted:
+				drifted++
+			case PolicyMissing:
+				missing++
+			default:
+				failed++
 			}
-			logMessage := fmt.Sprintf("[INFO] Setting policy for repository: %s", repo)
-			mu.Lock()
-			logMessages = append(logMessages, logMessage)
-			mu.Unlock()
-
-			if logMsg, err := setPolicy(ctx, client, repo, policyText, dryRun); err != nil {
-				logMessage = fmt.Sprintf("[ERROR] Repository: %s - Failed to set policy: %v", repo, err)
-				mu.Lock()
-				logMessages = append(logMessages, logMessage)
-				errs = append(errs, err)
-				mu.Unlock()
-			} else {
-				mu.Lock()
-				logMessages = append(logMessages, logMsg)
-				mu.Unlock()
+		}
+	}
+	return inSync, drifted, missing, failed, failedAccounts
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r DriftReport) Summary() string {
+	inSync, drifted, missing, failed, failedAccounts := r.Counts()
+	return fmt.Sprintf("Audited %d accounts: %d repos in sync, %d drifted, %d without a policy, %d failed, %d accounts could not be audited", len(r.Accounts), inSync, drifted, missing, failed, failedAccounts)
+}
+
+// --- renders one test case per account and repository, deviating repositories and unreachable accounts fail their case ---
+func (r DriftReport) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner auditPolicy", Duration: r.Duration}
+	for _, account := range r.Accounts {
+		className := "auditPolicy." + account.Registry.Account
+		if account.Error != "" {
+			suite.Cases = append(suite.Cases, format.JUnitCase{Name: account.Profile, ClassName: className, Failure: account.Error})
+			continue
+		}
+		for _, repo := range account.Repositories {
+			c := format.JUnitCase{Name: repo.Repository, ClassName: className}
+			switch repo.Status {
+			case "":
+				c.Failure = repo.Error
+			case PolicyDrifted:
+				c.Failure = "lifecycle policy differs from the reference policy"
+			case PolicyMissing:
+				c.Failure = "no lifecycle policy"
 			}
-		}(repository)
+			suite.Cases = append(suite.Cases, c)
+		}
 	}
-	wg.Wait()
+	return suite
+}
 
-	sort.Slice(logMessages, func(i, j int) bool {
-		return logMessages[i] < logMessages[j]
+// --- compares the lifecycle policy of every repository with policyText, key order and whitespace do not count ---
+func AuditPolicy(ctx context.Context, client LifecyclePolicyAPI, policyText string, repos []string, limit int) []RepositoryDrift {
+	var mu sync.Mutex
+	checksum := PolicyChecksum(policyText)
+	results := make([]RepositoryDrift, 0, len(repos))
+	concurrency.ForEach(repos, limit, func(repo string) {
+		result := RepositoryDrift{Repository: repo}
+		current, exists, err := getLifecyclePolicy(ctx, client, repo)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case !exists:
+			result.Status = PolicyMissing
+		default:
+			result.PolicyChecksum = PolicyChecksum(current)
+			result.Status = PolicyDrifted
+			if result.PolicyChecksum == checksum {
+				result.Status = PolicyInSync
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, result)
 	})
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Repository < results[j].Repository
+	})
+	return results
+}
 
-	for _, logMessage := range logMessages {
-		log.Println(logMessage)
+// --- RepositoryPolicy is the lifecycle policy of a repository, Policy is empty when it has none ---
+type RepositoryPolicy struct {
+	Repository string `json:"repository"`
+	Policy     string `json:"policy,omitempty"`
+	// --- one entry per rule in priority order, as in PolicySummary ---
+	Rules []string `json:"rules,omitempty"`
+}
+
+// --- PolicyListReport lists the lifecycle policies of the checked repositories ---
+type PolicyListReport struct {
+	Checked int `json:"checked"`
+	// --- when set, only repositories with a rule selecting this tag prefix are listed ---
+	TagFilter    string               `json:"tagFilter,omitempty"`
+	Repositories []RepositoryPolicy   `json:"repositories"`
+	Failed       []RepositoryError    `json:"failed,omitempty"`
+	Registry     awsregistry.Registry `json:"registry"`
+}
+
+// --- returns one record per listed repository, for --jsonMode lines ---
+func (r PolicyListReport) Records() []interface{} {
+	records := make([]interface{}, 0, len(r.Repositories))
+	for _, repo := range r.Repositories {
+		records = append(records, repo)
 	}
+	return records
+}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("encountered errors during policy setup: %v", errs)
+// --- returns a one-line human readable summary of the report ---
+func (r PolicyListReport) Summary() string {
+	if r.TagFilter != "" {
+		return fmt.Sprintf("Checked %d repos, %d with a rule for tag prefix %s, %d failed", r.Checked, len(r.Repositories), r.TagFilter, len(r.Failed))
 	}
-	return nil
+	return fmt.Sprintf("Checked %d repos, listed %d policies, %d failed", r.Checked, len(r.Repositories), len(r.Failed))
+}
+
+// --- fetches the lifecycle policy of every repository, with tagFilter only the ones with a rule for that tag prefix ---
+// --- (see readpolicyfile.ContainsTagPrefixRule), repositories without a policy are only listed without a filter ---
+func ListPolicies(ctx context.Context, client LifecyclePolicyAPI, repos []string, tagFilter string, limit int) PolicyListReport {
+	report := PolicyListReport{Checked: len(repos), TagFilter: tagFilter}
+	var mu sync.Mutex
+	concurrency.ForEach(repos, limit, func(repo string) {
+		policyText, exists, err := getLifecyclePolicy(ctx, client, repo)
+		listed := err == nil && tagFilter == ""
+		if err == nil && exists && tagFilter != "" {
+			listed, err = readpolicyfile.ContainsTagPrefixRule(policyText, tagFilter)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, RepositoryError{Repository: repo, Message: err.Error()})
+		case listed:
+			entry := RepositoryPolicy{Repository: repo, Policy: policyText}
+			if exists {
+				entry.Rules = SummarizePolicy(policyText).Rules
+			}
+			report.Repositories = append(report.Repositories, entry)
+		}
+	})
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+	sort.Slice(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Repository < report.Failed[j].Repository
+	})
+	return report
 }
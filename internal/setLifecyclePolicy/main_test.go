@@ -4,9 +4,21 @@ package setlifecyclepolicy
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	awsregistry "ecr-lifecycle-cleaner/internal/awsRegistry"
+	concurrency "ecr-lifecycle-cleaner/internal/concurrency"
+	readpolicyfile "ecr-lifecycle-cleaner/internal/readPolicyFile"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsMiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
@@ -16,6 +28,99 @@ import (
 	"github.com/aws/smithy-go/middleware"
 )
 
+// --- mockLifecyclePolicyClient keeps repositories and their policies in memory ---
+// --- GetLifecyclePolicy and PutLifecyclePolicy fail with RepositoryNotFoundException for unknown repositories ---
+type mockLifecyclePolicyClient struct {
+	mu           sync.Mutex
+	repositories []types.Repository
+	policies     map[string]string
+	applied      []string
+	created      []string
+	puts         int
+	// --- images a lifecycle policy preview reports as expiring, per repository ---
+	expiring map[string]int
+	previews []string
+}
+
+func newMockLifecyclePolicyClient(repositories ...string) *mockLifecyclePolicyClient {
+	m := &mockLifecyclePolicyClient{policies: map[string]string{}}
+	for _, name := range repositories {
+		m.repositories = append(m.repositories, types.Repository{RepositoryName: aws.String(name)})
+	}
+	return m
+}
+
+func (m *mockLifecyclePolicyClient) exists(name string) bool {
+	for _, repo := range m.repositories {
+		if aws.ToString(repo.RepositoryName) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *mockLifecyclePolicyClient) DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &ecr.DescribeRepositoriesOutput{Repositories: m.repositories}, nil
+}
+
+func (m *mockLifecyclePolicyClient) GetLifecyclePolicy(ctx context.Context, in *ecr.GetLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := aws.ToString(in.RepositoryName)
+	if !m.exists(name) {
+		return nil, &types.RepositoryNotFoundException{Message: aws.String("repository does not exist")}
+	}
+	policy, ok := m.policies[name]
+	if !ok {
+		return nil, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+	}
+	return &ecr.GetLifecyclePolicyOutput{LifecyclePolicyText: aws.String(policy)}, nil
+}
+
+func (m *mockLifecyclePolicyClient) PutLifecyclePolicy(ctx context.Context, in *ecr.PutLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.puts++
+	name := aws.ToString(in.RepositoryName)
+	if !m.exists(name) {
+		return nil, &types.RepositoryNotFoundException{Message: aws.String("repository does not exist")}
+	}
+	m.policies[name] = aws.ToString(in.LifecyclePolicyText)
+	m.applied = append(m.applied, name)
+	return &ecr.PutLifecyclePolicyOutput{LifecyclePolicyText: in.LifecyclePolicyText}, nil
+}
+
+func (m *mockLifecyclePolicyClient) CreateRepository(ctx context.Context, in *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.repositories = append(m.repositories, types.Repository{RepositoryName: in.RepositoryName})
+	m.created = append(m.created, aws.ToString(in.RepositoryName))
+	return &ecr.CreateRepositoryOutput{}, nil
+}
+
+func (m *mockLifecyclePolicyClient) StartLifecyclePolicyPreview(ctx context.Context, in *ecr.StartLifecyclePolicyPreviewInput, optFns ...func(*ecr.Options)) (*ecr.StartLifecyclePolicyPreviewOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := aws.ToString(in.RepositoryName)
+	if _, ok := m.expiring[name]; !ok {
+		return nil, &types.LifecyclePolicyPreviewInProgressException{Message: aws.String("preview in progress")}
+	}
+	m.previews = append(m.previews, name)
+	return &ecr.StartLifecyclePolicyPreviewOutput{Status: types.LifecyclePolicyPreviewStatusInProgress}, nil
+}
+
+func (m *mockLifecyclePolicyClient) GetLifecyclePolicyPreview(ctx context.Context, in *ecr.GetLifecyclePolicyPreviewInput, optFns ...func(*ecr.Options)) (*ecr.GetLifecyclePolicyPreviewOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := int32(m.expiring[aws.ToString(in.RepositoryName)])
+	return &ecr.GetLifecyclePolicyPreviewOutput{
+		Status:  types.LifecyclePolicyPreviewStatusComplete,
+		Summary: &types.LifecyclePolicyPreviewSummary{ExpiringImageTotalCount: aws.Int32(count)},
+	}, nil
+}
+
 func TestSetLifecyclePolicy(t *testing.T) {
 	describeRepositoriesMiddleware := middleware.FinalizeMiddlewareFunc(
 		"DescribeRepositoriesMock",
@@ -41,7 +146,7 @@ func TestSetLifecyclePolicy(t *testing.T) {
 			if operationName == "GetLifecyclePolicy" {
 				return middleware.FinalizeOutput{
 					Result: &ecr.GetLifecyclePolicyOutput{
-						LifecyclePolicyText: aws.String("mock-policy-text"),
+						LifecyclePolicyText: aws.String("existing-policy-text"),
 					},
 				}, middleware.Metadata{}, nil
 			}
@@ -90,15 +195,18 @@ func TestSetLifecyclePolicy(t *testing.T) {
 
 	// --- test with allRepos = true ---
 	t.Run("Test with allRepos = true", func(t *testing.T) {
-		err := Main(client, "mock-policy-text", true, nil, "", false)
+		report, err := Main(client, "mock-policy-text", true, nil, nil, SetPolicyOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
+		if !reflect.DeepEqual(report.Applied, []string{"test-repo"}) || report.DryRun {
+			t.Errorf("Expected test-repo to be applied, got: %+v", report)
+		}
 	})
 
 	// --- test with specific repository list ---
 	t.Run("Test with specific repository list", func(t *testing.T) {
-		err := Main(client, "mock-policy-text", false, []string{"test-repo"}, "", false)
+		_, err := Main(client, "mock-policy-text", false, []string{"test-repo"}, nil, SetPolicyOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -106,7 +214,7 @@ func TestSetLifecyclePolicy(t *testing.T) {
 
 	// --- test with repository pattern ---
 	t.Run("Test with repository pattern", func(t *testing.T) {
-		err := Main(client, "mock-policy-text", false, nil, "test-.*", false)
+		_, err := Main(client, "mock-policy-text", false, nil, []string{"test-.*"}, SetPolicyOptions{})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
@@ -114,9 +222,526 @@ func TestSetLifecyclePolicy(t *testing.T) {
 
 	// --- test with dryRun = true ---
 	t.Run("Test with dryRun = true", func(t *testing.T) {
-		err := Main(client, "mock-policy-text", true, nil, "", true)
+		report, err := Main(client, "mock-policy-text", true, nil, nil, SetPolicyOptions{DryRun: true})
 		if err != nil {
 			t.Errorf("Expected no error, got: %v", err)
 		}
+		if !report.DryRun || len(report.Applied) != 1 {
+			t.Errorf("Expected dry run report with 1 repo, got: %+v", report)
+		}
 	})
 }
+
+func TestSetLifecyclePolicy_OnlyIfAbsent(t *testing.T) {
+	var mu sync.Mutex
+	var applied []string
+
+	// --- managed-repo already has a policy, new-repo does not ---
+	mockMiddleware := middleware.InitializeMiddlewareFunc(
+		"LifecyclePolicyMock",
+		func(ctx context.Context, input middleware.InitializeInput, handler middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			switch params := input.Parameters.(type) {
+			case *ecr.GetLifecyclePolicyInput:
+				if aws.ToString(params.RepositoryName) == "managed-repo" {
+					return middleware.InitializeOutput{
+						Result: &ecr.GetLifecyclePolicyOutput{LifecyclePolicyText: aws.String("team-policy")},
+					}, middleware.Metadata{}, nil
+				}
+				return middleware.InitializeOutput{}, middleware.Metadata{}, &types.LifecyclePolicyNotFoundException{Message: aws.String("not found")}
+			case *ecr.PutLifecyclePolicyInput:
+				mu.Lock()
+				applied = append(applied, aws.ToString(params.RepositoryName))
+				mu.Unlock()
+				return middleware.InitializeOutput{
+					Result: &ecr.PutLifecyclePolicyOutput{LifecyclePolicyText: params.LifecyclePolicyText},
+				}, middleware.Metadata{}, nil
+			}
+			return handler.HandleInitialize(ctx, input)
+		},
+	)
+
+	cfg, err := config.LoadDefaultConfig(
+		context.TODO(),
+		config.WithRegion("us-west-2"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			func(stack *middleware.Stack) error {
+				return stack.Initialize.Add(mockMiddleware, middleware.Before)
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	log.SetOutput(io.Discard)
+	client := ecr.NewFromConfig(cfg)
+
+	report, err := Main(client, "mock-policy-text", false, []string{"managed-repo", "new-repo"}, nil, SetPolicyOptions{OnlyIfAbsent: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{"managed-repo"}) {
+		t.Errorf("Expected managed-repo to be skipped, got: %v", report.Skipped)
+	}
+	if len(applied) != 1 || applied[0] != "new-repo" {
+		t.Errorf("Expected policy to be applied only to new-repo, got: %v", applied)
+	}
+}
+
+func TestSetPolicyReport_JSON(t *testing.T) {
+	report := SetPolicyReport{
+		Applied: []string{"a"},
+		Skipped: []string{"b"},
+		Failed:  []RepositoryError{{Repository: "c", Message: "boom"}},
+		DryRun:  true,
+	}
+	if got := report.Summary(); got != "Applied to 1 repos, skipped 1, failed 1" {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(string(data), `"failed":[{"repository":"c","error":"boom"}]`) {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+	var decoded SetPolicyReport
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, report) {
+		t.Errorf("Round trip mismatch: %+v, %v", decoded, err)
+	}
+}
+
+func TestPolicyChecksum(t *testing.T) {
+	a := PolicyChecksum(`{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`)
+	b := PolicyChecksum(`{
+  "rules": [
+    {"action": {"type": "expire"}, "rulePriority": 1}
+  ]
+}`)
+	if a != b {
+		t.Errorf("Expected equal checksums for equivalent policies, got %s and %s", a, b)
+	}
+	if len(a) != 64 {
+		t.Errorf("Expected a sha256 hex checksum, got: %s", a)
+	}
+	if c := PolicyChecksum(`{"rules":[{"rulePriority":2,"action":{"type":"expire"}}]}`); c == a {
+		t.Errorf("Expected different checksums for different policies")
+	}
+	if PolicyChecksum("not-json") != PolicyChecksum("not-json") {
+		t.Errorf("Expected stable checksum for non-JSON input")
+	}
+}
+
+func TestSetLifecyclePolicy_Idempotent(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	log.SetOutput(io.Discard)
+
+	// --- in-sync-repo has the same policy with different formatting, drifted-repo has another one ---
+	client := newMockLifecyclePolicyClient("in-sync-repo", "drifted-repo")
+	client.policies["in-sync-repo"] = `{ "rules": [ { "action": { "type": "expire" }, "rulePriority": 1 } ] }`
+	client.policies["drifted-repo"] = `{"rules":[{"rulePriority":5,"action":{"type":"expire"}}]}`
+
+	report, err := Main(client, policy, false, []string{"in-sync-repo", "drifted-repo"}, nil, SetPolicyOptions{PolicyID: "v2"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(client.applied, []string{"drifted-repo"}) {
+		t.Errorf("Expected policy to be applied only to drifted-repo, got: %v", client.applied)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{"in-sync-repo"}) {
+		t.Errorf("Expected in-sync-repo to be skipped, got: %v", report.Skipped)
+	}
+	if report.PolicyID != "v2" || report.PolicyChecksum != PolicyChecksum(policy) {
+		t.Errorf("Expected policy id and checksum in report, got: %+v", report)
+	}
+}
+
+func TestPlanPolicy(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	log.SetOutput(io.Discard)
+
+	// --- in-sync-repo matches, drifted-repo differs, new-repo has no policy ---
+	client := newMockLifecyclePolicyClient("in-sync-repo", "drifted-repo", "new-repo")
+	client.policies["in-sync-repo"] = policy
+	client.policies["drifted-repo"] = `{"rules":[]}`
+
+	plan := PlanPolicy(context.TODO(), client, policy, []string{"new-repo", "in-sync-repo", "drifted-repo"}, SetPolicyOptions{})
+	want := []RepositoryPolicyPlan{
+		{Repository: "drifted-repo", Apply: true, HasPolicy: true},
+		{Repository: "in-sync-repo", HasPolicy: true, Reason: "policy already up to date"},
+		{Repository: "new-repo", Apply: true},
+	}
+	if !reflect.DeepEqual(plan.Repositories, want) {
+		t.Errorf("Expected %+v, got: %+v", want, plan.Repositories)
+	}
+	if len(client.applied) != 0 {
+		t.Errorf("Expected no policy to be applied while planning, got: %v", client.applied)
+	}
+
+	report, err := ExecutePolicyPlan(context.TODO(), client, policy, plan, SetPolicyOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(report.Applied, []string{"drifted-repo", "new-repo"}) || !reflect.DeepEqual(report.Skipped, []string{"in-sync-repo"}) {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestPlanPolicy_InlinePolicy(t *testing.T) {
+	log.SetOutput(io.Discard)
+	policy, err := readpolicyfile.ReadPolicyJSON(`{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}]}`)
+	if err != nil {
+		t.Fatalf("Expected a valid inline policy, got: %v", err)
+	}
+
+	client := newMockLifecyclePolicyClient("app")
+	plan := PlanPolicy(context.TODO(), client, policy, []string{"app"}, SetPolicyOptions{})
+	if _, err := ExecutePolicyPlan(context.TODO(), client, policy, plan, SetPolicyOptions{}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if client.policies["app"] != policy {
+		t.Errorf("Expected the inline policy to be applied as given, got: %s", client.policies["app"])
+	}
+}
+
+func TestPlanPolicy_RegistryContext(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	log.SetOutput(io.Discard)
+
+	// --- missing-repo is unknown to the client so checking its policy fails ---
+	client := newMockLifecyclePolicyClient()
+	opts := SetPolicyOptions{Registry: awsregistry.Registry{Account: "123456789012", Region: "eu-west-1"}}
+	plan := PlanPolicy(context.TODO(), client, policy, []string{"missing-repo"}, opts)
+	if plan.Registry != opts.Registry {
+		t.Errorf("Expected plan registry %+v, got: %+v", opts.Registry, plan.Registry)
+	}
+	if len(plan.Repositories) != 1 || !strings.Contains(plan.Repositories[0].Error, "account 123456789012, region eu-west-1") {
+		t.Errorf("Expected planning error to name the account and region, got: %+v", plan.Repositories)
+	}
+}
+
+func TestSetLifecyclePolicy_CreateMissingRepos(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	log.SetOutput(io.Discard)
+
+	// --- without the option a missing repository fails planning and nothing is created ---
+	client := newMockLifecyclePolicyClient()
+	report, err := setPolicyForAll(context.TODO(), client, policy, []string{"new-repo"}, SetPolicyOptions{})
+	if err == nil || len(report.Failed) != 1 || len(client.created) != 0 {
+		t.Errorf("Expected missing repository to fail without creating it, got: %+v, %v", report, err)
+	}
+
+	// --- with the option the repository is created and the policy put is retried ---
+	client = newMockLifecyclePolicyClient()
+	opts := SetPolicyOptions{CreateMissingRepos: true}
+	plan := PlanPolicy(context.TODO(), client, policy, []string{"new-repo"}, opts)
+	if len(plan.Repositories) != 1 || !plan.Repositories[0].Apply || !plan.Repositories[0].Create {
+		t.Fatalf("Expected plan to create and apply, got: %+v", plan.Repositories)
+	}
+	report, err = ExecutePolicyPlan(context.TODO(), client, policy, plan, opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(client.created, []string{"new-repo"}) || client.puts != 2 {
+		t.Errorf("Expected repository creation and a retried put, got created=%v puts=%d", client.created, client.puts)
+	}
+	if !reflect.DeepEqual(report.Applied, []string{"new-repo"}) {
+		t.Errorf("Expected policy applied to new-repo, got: %+v", report)
+	}
+
+	// --- a client without CreateRepository fails the repository instead of creating it ---
+	client = newMockLifecyclePolicyClient()
+	readOnly := struct{ LifecyclePolicyAPI }{client}
+	report, err = ExecutePolicyPlan(context.TODO(), readOnly, policy, plan, opts)
+	if err == nil || len(report.Failed) != 1 || len(client.created) != 0 {
+		t.Errorf("Expected failure without CreateRepository, got: %+v, %v", report, err)
+	}
+}
+
+func TestTagImmutabilityFilter(t *testing.T) {
+	client := &mockLifecyclePolicyClient{repositories: []types.Repository{
+		{RepositoryName: aws.String("app-mutable"), ImageTagMutability: types.ImageTagMutabilityMutable},
+		{RepositoryName: aws.String("app-immutable"), ImageTagMutability: types.ImageTagMutabilityImmutable},
+		{RepositoryName: aws.String("web-immutable"), ImageTagMutability: types.ImageTagMutabilityImmutableWithExclusion},
+		{RepositoryName: aws.String("web-mutable"), ImageTagMutability: types.ImageTagMutabilityMutableWithExclusion},
+	}}
+
+	immutable, err := GetRepositories(context.TODO(), client, TagImmutabilityFilter(true))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(immutable, []string{"app-immutable", "web-immutable"}) {
+		t.Errorf("Expected immutable repositories, got: %v", immutable)
+	}
+
+	mutable, err := GetRepositories(context.TODO(), client, TagImmutabilityFilter(false))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(mutable, []string{"app-mutable", "web-mutable"}) {
+		t.Errorf("Expected mutable repositories, got: %v", mutable)
+	}
+
+	// --- filters combine with the pattern ---
+	matched, err := GetRepositoriesByPattern(context.TODO(), client, []string{"^app-"}, TagImmutabilityFilter(true))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(matched, []string{"app-immutable"}) {
+		t.Errorf("Expected app-immutable, got: %v", matched)
+	}
+}
+
+func TestSummarizePolicy(t *testing.T) {
+	policy := `{"rules":[
+		{"rulePriority":3,"selection":{"tagStatus":"any","countType":"sinceImagePushed","countUnit":"days","countNumber":90},"action":{"type":"expire"}},
+		{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPatternList":["prod-*"],"countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}},
+		{"rulePriority":2,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}},
+		{"rulePriority":4,"selection":{"tagStatus":"tagged","tagPrefixList":["dev"],"countType":"imageCountMoreThan","countNumber":3},"action":{"type":"expire"}}
+	]}`
+	summary := SummarizePolicy(policy)
+	if summary.RuleCount != 4 {
+		t.Errorf("Expected 4 rules, got %d", summary.RuleCount)
+	}
+	if !reflect.DeepEqual(summary.RulesByTagStatus, map[string]int{"tagged": 2, "untagged": 1, "any": 1}) {
+		t.Errorf("Unexpected rule counts: %v", summary.RulesByTagStatus)
+	}
+	if !reflect.DeepEqual(summary.MinCountByTagStatus, map[string]int{"tagged": 3, "untagged": 7, "any": 90}) {
+		t.Errorf("Unexpected minimum counts: %v", summary.MinCountByTagStatus)
+	}
+	want := "Applied 4 rules: keep tagged(prod-*)=10, expire untagged>7 days, expire any>90 days, keep tagged(dev*)=3"
+	if summary.String() != want {
+		t.Errorf("Expected %q, got %q", want, summary.String())
+	}
+
+	// --- a policy that cannot be parsed gives an empty summary ---
+	if got := SummarizePolicy("not json"); got.RuleCount != 0 || got.String() != "Applied 0 rules" {
+		t.Errorf("Expected empty summary, got: %+v", got)
+	}
+}
+
+// --- records the peak number of PutLifecyclePolicy calls in flight ---
+type putCountingClient struct {
+	*mockLifecyclePolicyClient
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (m *putCountingClient) PutLifecyclePolicy(ctx context.Context, in *ecr.PutLifecyclePolicyInput, optFns ...func(*ecr.Options)) (*ecr.PutLifecyclePolicyOutput, error) {
+	m.mu.Lock()
+	m.inFlight++
+	m.peak = max(m.peak, m.inFlight)
+	m.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+	return m.mockLifecyclePolicyClient.PutLifecyclePolicy(ctx, in, optFns...)
+}
+
+func TestSetPolicyForAll_Concurrency(t *testing.T) {
+	repos := make([]string, 20)
+	for i := range repos {
+		repos[i] = fmt.Sprintf("repo-%02d", i)
+	}
+	policy := `{"rules":[]}`
+
+	// --- --maxConcurrency bounds the repositories applied at once ---
+	base := &putCountingClient{mockLifecyclePolicyClient: newMockLifecyclePolicyClient(repos...)}
+	report, err := setPolicyForAll(context.TODO(), base, policy, repos, SetPolicyOptions{Concurrency: 3})
+	if err != nil || len(report.Applied) != len(repos) {
+		t.Fatalf("Expected all repositories applied, got: %v, %v", report.Applied, err)
+	}
+	if base.peak > 3 {
+		t.Errorf("Expected at most 3 PutLifecyclePolicy calls in flight, got: %d", base.peak)
+	}
+
+	// --- a per-operation limit shapes PutLifecyclePolicy tighter than the repository pool ---
+	base = &putCountingClient{mockLifecyclePolicyClient: newMockLifecyclePolicyClient(repos...)}
+	if WithOperationLimits(base, nil) != ECRAPI(base) {
+		t.Errorf("Expected client to be returned unchanged without limits")
+	}
+	client := WithOperationLimits(base, concurrency.OperationLimits{"PutLifecyclePolicy": 1})
+	report, err = setPolicyForAll(context.TODO(), client, policy, repos, SetPolicyOptions{Concurrency: 10})
+	if err != nil || len(report.Applied) != len(repos) {
+		t.Fatalf("Expected all repositories applied, got: %v, %v", report.Applied, err)
+	}
+	if base.peak > 1 {
+		t.Errorf("Expected at most 1 PutLifecyclePolicy call in flight, got: %d", base.peak)
+	}
+}
+
+func TestSetPolicyFromDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"prod-*.json":    `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":50},"action":{"type":"expire"}}]}`,
+		"*.json":         `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":5},"action":{"type":"expire"}}]}`,
+		"staging-*.json": `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"any","countType":"imageCountMoreThan","countNumber":10},"action":{"type":"expire"}}]}`,
+		"README.md":      "not a policy",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policies, err := LoadPolicyDir(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var patterns []string
+	for _, policy := range policies {
+		patterns = append(patterns, policy.Pattern)
+	}
+	// --- file name order, * sorts first ---
+	if !reflect.DeepEqual(patterns, []string{"*", "prod-*", "staging-*"}) {
+		t.Errorf("Expected policies in file name order, got: %v", patterns)
+	}
+	// --- first match wins ---
+	if policy, _ := MatchPolicy(policies[1:], "prod-api"); policy.File != "prod-*.json" {
+		t.Errorf("Expected prod-*.json for prod-api, got: %s", policy.File)
+	}
+	if policy, _ := MatchPolicy(policies, "prod-api"); policy.File != "*.json" {
+		t.Errorf("Expected the first matching file to win, got: %s", policy.File)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "*.json")); err != nil {
+		t.Fatal(err)
+	}
+	client := newMockLifecyclePolicyClient("prod-api", "team/prod-web", "staging-api", "dev-api")
+	report := SetPolicyFromDir(context.TODO(), client, dir, []string{"prod-api", "team/prod-web", "staging-api", "dev-api"}, SetPolicyOptions{})
+	if !reflect.DeepEqual(report.Applied, []string{"prod-api", "staging-api"}) || !reflect.DeepEqual(report.Skipped, []string{"dev-api", "team/prod-web"}) || len(report.Failed) != 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if !strings.Contains(client.policies["prod-api"], `"countNumber":50`) || !strings.Contains(client.policies["staging-api"], `"countNumber":10`) {
+		t.Errorf("Expected each repository to get the policy of its file, got: %v", client.policies)
+	}
+
+	// --- * matches across the / of namespaced repositories ---
+	if err := os.WriteFile(filepath.Join(dir, "team*.json"), []byte(files["prod-*.json"]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	report = SetPolicyFromDir(context.TODO(), client, dir, []string{"team/prod-web"}, SetPolicyOptions{})
+	if !reflect.DeepEqual(report.Applied, []string{"team/prod-web"}) {
+		t.Errorf("Expected team/prod-web applied, got: %+v", report)
+	}
+
+	// --- an unreadable directory fails every repository ---
+	report = SetPolicyFromDir(context.TODO(), client, filepath.Join(dir, "missing"), []string{"prod-api"}, SetPolicyOptions{})
+	if len(report.Failed) != 1 || !strings.Contains(report.Failed[0].Message, "failed to read policy directory") {
+		t.Errorf("Expected prod-api to fail, got: %+v", report)
+	}
+}
+
+func TestPlanPolicy_PreviewImpact(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}]}`
+	client := newMockLifecyclePolicyClient("app", "web", "busy", "in-sync")
+	client.policies["in-sync"] = policy
+	client.expiring = map[string]int{"app": 12, "web": 0, "in-sync": 3}
+
+	opts := SetPolicyOptions{DryRun: true, PreviewImpact: true, CreateMissingRepos: true}
+	plan := PlanPolicy(context.TODO(), client, policy, []string{"app", "web", "busy", "in-sync", "new-repo"}, opts)
+
+	// --- repositories that are skipped or would be created are not previewed ---
+	if !reflect.DeepEqual(client.previews, []string{"app", "web"}) && !reflect.DeepEqual(client.previews, []string{"web", "app"}) {
+		t.Errorf("Expected app and web previewed, got: %v", client.previews)
+	}
+	byRepo := map[string]RepositoryPolicyPlan{}
+	for _, entry := range plan.Repositories {
+		byRepo[entry.Repository] = entry
+	}
+	if got := byRepo["app"].ExpiringImages; got == nil || *got != 12 {
+		t.Errorf("Expected 12 expiring images in app, got: %v", got)
+	}
+	if got := byRepo["web"].ExpiringImages; got == nil || *got != 0 {
+		t.Errorf("Expected 0 expiring images in web, got: %v", got)
+	}
+	// --- a failed preview is recorded but does not stop the apply ---
+	if byRepo["busy"].ExpiringImages != nil || !strings.Contains(byRepo["busy"].PreviewError, "preview in progress") || !byRepo["busy"].Apply {
+		t.Errorf("Expected busy to keep its apply with a preview error, got: %+v", byRepo["busy"])
+	}
+	if images, repos := plan.ExpiringImages(); images != 12 || repos != 2 {
+		t.Errorf("Expected 12 images across 2 repositories, got: %d across %d", images, repos)
+	}
+
+	// --- without PreviewImpact nothing is previewed ---
+	client.previews = nil
+	PlanPolicy(context.TODO(), client, policy, []string{"app"}, SetPolicyOptions{DryRun: true})
+	if len(client.previews) != 0 {
+		t.Errorf("Expected no previews, got: %v", client.previews)
+	}
+}
+
+func TestAuditPolicy(t *testing.T) {
+	policy := `{"rules":[{"rulePriority":1,"action":{"type":"expire"}}]}`
+	client := newMockLifecyclePolicyClient("in-sync", "drifted", "missing")
+	client.policies["in-sync"] = "{\n  \"rules\": [ { \"action\": {\"type\": \"expire\"}, \"rulePriority\": 1 } ]\n}"
+	client.policies["drifted"] = `{"rules":[{"rulePriority":2,"action":{"type":"expire"}}]}`
+
+	got := AuditPolicy(context.Background(), client, policy, []string{"missing", "in-sync", "unknown", "drifted"}, 2)
+	want := map[string]DriftStatus{"drifted": PolicyDrifted, "in-sync": PolicyInSync, "missing": PolicyMissing, "unknown": ""}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), got)
+	}
+	for i, repo := range got {
+		if i > 0 && got[i-1].Repository > repo.Repository {
+			t.Errorf("results not sorted: %+v", got)
+		}
+		if repo.Status != want[repo.Repository] {
+			t.Errorf("%s: expected status %q, got %q", repo.Repository, want[repo.Repository], repo.Status)
+		}
+		if (repo.Error != "") != (repo.Repository == "unknown") {
+			t.Errorf("%s: unexpected error %q", repo.Repository, repo.Error)
+		}
+	}
+
+	report := DriftReport{Accounts: []AccountDrift{
+		{Registry: awsregistry.Registry{Account: "111111111111"}, Repositories: got},
+		{Profile: "prod", Error: "no credentials"},
+	}}
+	if summary := report.Summary(); summary != "Audited 2 accounts: 1 repos in sync, 1 drifted, 1 without a policy, 1 failed, 1 accounts could not be audited" {
+		t.Errorf("unexpected summary: %s", summary)
+	}
+	failures := 0
+	for _, c := range report.JUnit().Cases {
+		if c.Failure != "" {
+			failures++
+		}
+	}
+	if failures != 4 {
+		t.Errorf("expected 4 failing cases, got %d", failures)
+	}
+}
+
+func TestListPolicies(t *testing.T) {
+	client := newMockLifecyclePolicyClient("web", "api", "batch", "plain")
+	client.policies["web"] = `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPrefixList":["pr-"],"countType":"imageCountMoreThan","countNumber":5},"action":{"type":"expire"}}]}`
+	client.policies["api"] = `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"tagged","tagPatternList":["pr-*"],"countType":"sinceImagePushed","countUnit":"days","countNumber":3},"action":{"type":"expire"}}]}`
+	client.policies["batch"] = `{"rules":[{"rulePriority":1,"selection":{"tagStatus":"untagged","countType":"sinceImagePushed","countUnit":"days","countNumber":7},"action":{"type":"expire"}}]}`
+	repos := []string{"web", "api", "batch", "plain", "unknown"}
+
+	report := ListPolicies(context.Background(), client, repos, "pr-*", 2)
+	var names []string
+	for _, repo := range report.Repositories {
+		names = append(names, repo.Repository)
+	}
+	if !reflect.DeepEqual(names, []string{"api", "web"}) {
+		t.Errorf("Expected api and web, got %v", names)
+	}
+	if report.Repositories[1].Policy != client.policies["web"] || !reflect.DeepEqual(report.Repositories[1].Rules, []string{"keep tagged(pr-*)=5"}) {
+		t.Errorf("Unexpected entry: %+v", report.Repositories[1])
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Repository != "unknown" {
+		t.Errorf("Expected unknown to fail, got %+v", report.Failed)
+	}
+	if summary := report.Summary(); summary != "Checked 5 repos, 2 with a rule for tag prefix pr-*, 1 failed" {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+
+	// --- without a filter every repository is listed, including the ones without a policy ---
+	report = ListPolicies(context.Background(), client, repos[:4], "", 2)
+	if len(report.Repositories) != 4 || report.Repositories[2].Repository != "plain" || report.Repositories[2].Policy != "" {
+		t.Errorf("Expected all repositories, got %+v", report.Repositories)
+	}
+}
@@ -0,0 +1,34 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package sliceutil
+
+// --- splits a list into chunks of a given size ---
+// --- the chunks share the backing array of the input, nothing is copied ---
+func Partition[T any](lst []T, size int) [][]T {
+	if size <= 0 || len(lst) == 0 {
+		return nil
+	}
+	partitions := make([][]T, 0, (len(lst)+size-1)/size)
+	for i := 0; i < len(lst); i += size {
+		end := i + size
+		if end > len(lst) {
+			end = len(lst)
+		}
+		partitions = append(partitions, lst[i:end])
+	}
+	return partitions
+}
+
+// --- returns the items in their first-seen order with duplicates removed ---
+func Unique[T comparable](lst []T) []T {
+	seen := make(map[T]struct{}, len(lst))
+	unique := make([]T, 0, len(lst))
+	for _, item := range lst {
+		if _, ok := seen[item]; ok {
+			continue
+		}
+		seen[item] = struct{}{}
+		unique = append(unique, item)
+	}
+	return unique
+}
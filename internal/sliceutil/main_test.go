@@ -0,0 +1,66 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package sliceutil
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		name string
+		lst  []string
+		size int
+		want [][]string
+	}{
+		{"empty list", nil, 2, nil},
+		{"exact chunks", []string{"a", "b", "c", "d"}, 2, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"remainder chunk", []string{"a", "b", "c"}, 2, [][]string{{"a", "b"}, {"c"}}},
+		{"size larger than list", []string{"a"}, 100, [][]string{{"a"}}},
+		{"invalid size", []string{"a"}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Partition(tt.lst, tt.size)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Partition(%v, %d) = %v; want %v", tt.lst, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartition_Ints(t *testing.T) {
+	got := Partition([]int{1, 2, 3, 4, 5}, 3)
+	want := [][]int{{1, 2, 3}, {4, 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Partition = %v; want %v", got, want)
+	}
+}
+
+func TestUnique(t *testing.T) {
+	got := Unique([]string{"sha256:b", "sha256:a", "sha256:b", "sha256:c", "sha256:a"})
+	want := []string{"sha256:b", "sha256:a", "sha256:c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unique = %v; want %v", got, want)
+	}
+	if got := Unique([]int(nil)); len(got) != 0 {
+		t.Errorf("Unique(nil) = %v; want empty", got)
+	}
+}
+
+func BenchmarkPartitionList(b *testing.B) {
+	for _, size := range []int{10, 100, 1000, 10000} {
+		lst := make([]string, size)
+		for i := range lst {
+			lst[i] = fmt.Sprintf("sha256:%064d", i)
+		}
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = Partition(lst, 100)
+			}
+		})
+	}
+}
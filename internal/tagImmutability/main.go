@@ -0,0 +1,187 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package tagimmutability
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"ecr-lifecycle-cleaner/internal/concurrency"
+	format "ecr-lifecycle-cleaner/internal/format"
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- ECRAPI defines the subset of ecr.Client methods needed to read and change tag mutability ---
+type ECRAPI interface {
+	DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+	PutImageTagMutability(ctx context.Context, in *ecr.PutImageTagMutabilityInput, optFns ...func(*ecr.Options)) (*ecr.PutImageTagMutabilityOutput, error)
+}
+
+var _ ECRAPI = (*ecr.Client)(nil)
+
+// --- Status is the outcome for a single repository ---
+type Status string
+
+const (
+	StatusAlreadyImmutable Status = "ALREADY_IMMUTABLE"
+	StatusMadeImmutable    Status = "MADE_IMMUTABLE"
+	StatusAlreadyMutable   Status = "ALREADY_MUTABLE"
+	StatusMadeMutable      Status = "MADE_MUTABLE"
+	StatusFailed           Status = "FAILED"
+)
+
+// --- Options controls which setting is enforced ---
+type Options struct {
+	// --- make the repositories mutable instead, e.g. for dev repositories ---
+	Mutable     bool
+	DryRun      bool
+	Concurrency int
+	// --- when set, log messages are buffered for the caller to flush instead of printed ---
+	Logs *logbuffer.LogBuffer
+}
+
+// --- RepositoryResult records the setting found and the outcome for a single repository ---
+type RepositoryResult struct {
+	Repository string                   `json:"repository"`
+	Previous   types.ImageTagMutability `json:"previous,omitempty"`
+	Status     Status                   `json:"status"`
+	Error      string                   `json:"error,omitempty"`
+}
+
+// --- Report summarizes an enforcement run ---
+type Report struct {
+	Target       types.ImageTagMutability `json:"target"`
+	Repositories []RepositoryResult       `json:"repositories"`
+	DryRun       bool                     `json:"dryRun"`
+	Duration     time.Duration            `json:"duration"`
+}
+
+// --- returns a one-line human readable summary of the report ---
+func (r Report) Summary() string {
+	counts := map[Status]int{}
+	for _, repo := range r.Repositories {
+		counts[repo.Status]++
+	}
+	already, made := counts[StatusAlreadyImmutable], counts[StatusMadeImmutable]
+	if r.Target == types.ImageTagMutabilityMutable {
+		already, made = counts[StatusAlreadyMutable], counts[StatusMadeMutable]
+	}
+	verb := "changed"
+	if r.DryRun {
+		verb = "would change"
+	}
+	return fmt.Sprintf("Tag mutability %s: %d repos already set, %d %s, %d failed in %s", r.Target, already, made, verb, counts[StatusFailed], r.Duration.Round(time.Millisecond))
+}
+
+// --- renders one test case per repository, failed changes become failures ---
+func (r Report) JUnit() format.JUnitSuite {
+	suite := format.JUnitSuite{Name: "ecr-lifecycle-cleaner enforceTagImmutability", Duration: r.Duration}
+	for _, repo := range r.Repositories {
+		c := format.JUnitCase{Name: repo.Repository, ClassName: "enforceTagImmutability"}
+		switch repo.Status {
+		case StatusFailed:
+			c.Failure = repo.Error
+		case StatusAlreadyImmutable, StatusAlreadyMutable:
+			c.Skipped = "tag mutability already " + string(r.Target)
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	return suite
+}
+
+// --- reports whether the setting already matches, the *_WITH_EXCLUSION variants count as their base setting ---
+func matches(current types.ImageTagMutability, mutable bool) bool {
+	switch current {
+	case types.ImageTagMutabilityImmutable, types.ImageTagMutabilityImmutableWithExclusion:
+		return !mutable
+	}
+	return mutable
+}
+
+// --- returns the tag mutability of every repository ---
+func repositoryMutability(ctx context.Context, client ECRAPI) (map[string]types.ImageTagMutability, error) {
+	settings := map[string]types.ImageTagMutability{}
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			settings[aws.ToString(repo.RepositoryName)] = repo.ImageTagMutability
+		}
+	}
+	return settings, nil
+}
+
+// --- makes the repositories immutable (or mutable with Options.Mutable), leaving those already set untouched ---
+func Enforce(ctx context.Context, client ECRAPI, repositories []string, opts Options) (Report, error) {
+	start := time.Now()
+	target, already, made := types.ImageTagMutabilityImmutable, StatusAlreadyImmutable, StatusMadeImmutable
+	if opts.Mutable {
+		target, already, made = types.ImageTagMutabilityMutable, StatusAlreadyMutable, StatusMadeMutable
+	}
+	report := Report{Target: target, DryRun: opts.DryRun}
+
+	settings, err := repositoryMutability(ctx, client)
+	if err != nil {
+		return report, err
+	}
+
+	var mu sync.Mutex
+	var logMessages []logbuffer.Entry
+	failed := 0
+	concurrency.ForEach(repositories, opts.Concurrency, func(repo string) {
+		result := RepositoryResult{Repository: repo}
+		var msg string
+		current, found := settings[repo]
+		switch {
+		case !found:
+			result.Status, result.Error = StatusFailed, "repository not found"
+			msg = fmt.Sprintf("[ERROR] Repository: %s - Not found", repo)
+		case matches(current, opts.Mutable):
+			result.Previous, result.Status = current, already
+			msg = fmt.Sprintf("[INFO] Repository: %s - Tag mutability already %s", repo, current)
+		case opts.DryRun:
+			result.Previous, result.Status = current, made
+			msg = fmt.Sprintf("[DRY RUN] Repository: %s - Would change tag mutability from %s to %s", repo, current, target)
+		default:
+			result.Previous = current
+			_, err := client.PutImageTagMutability(ctx, &ecr.PutImageTagMutabilityInput{
+				RepositoryName:     aws.String(repo),
+				ImageTagMutability: target,
+			})
+			if err != nil {
+				result.Status, result.Error = StatusFailed, err.Error()
+				msg = fmt.Sprintf("[ERROR] Repository: %s - Failed to set tag mutability to %s: %v", repo, target, err)
+			} else {
+				result.Status = made
+				msg = fmt.Sprintf("[INFO] Repository: %s - Changed tag mutability from %s to %s", repo, current, target)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		report.Repositories = append(report.Repositories, result)
+		logMessages = append(logMessages, logbuffer.NewEntry(msg))
+		if result.Status == StatusFailed {
+			failed++
+		}
+	})
+	opts.Logs.Emit(logMessages)
+
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+	report.Duration = time.Since(start)
+	if failed > 0 {
+		return report, fmt.Errorf("failed to set tag mutability for %d repositories", failed)
+	}
+	return report, nil
+}
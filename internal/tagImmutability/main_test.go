@@ -0,0 +1,149 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package tagimmutability
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	logbuffer "ecr-lifecycle-cleaner/internal/logBuffer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// --- mock registry holding the tag mutability per repository ---
+type mockECRClient struct {
+	mu       sync.Mutex
+	settings map[string]types.ImageTagMutability
+	fail     map[string]bool
+	puts     []string
+}
+
+func (m *mockECRClient) DescribeRepositories(ctx context.Context, in *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	out := &ecr.DescribeRepositoriesOutput{}
+	for name, setting := range m.settings {
+		out.Repositories = append(out.Repositories, types.Repository{RepositoryName: aws.String(name), ImageTagMutability: setting})
+	}
+	return out, nil
+}
+
+func (m *mockECRClient) PutImageTagMutability(ctx context.Context, in *ecr.PutImageTagMutabilityInput, optFns ...func(*ecr.Options)) (*ecr.PutImageTagMutabilityOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := aws.ToString(in.RepositoryName)
+	if m.fail[name] {
+		return nil, errors.New("access denied")
+	}
+	m.puts = append(m.puts, name)
+	m.settings[name] = in.ImageTagMutability
+	return &ecr.PutImageTagMutabilityOutput{}, nil
+}
+
+func newMockClient() *mockECRClient {
+	return &mockECRClient{
+		settings: map[string]types.ImageTagMutability{
+			"locked":    types.ImageTagMutabilityImmutable,
+			"excluded":  types.ImageTagMutabilityImmutableWithExclusion,
+			"open":      types.ImageTagMutabilityMutable,
+			"denied":    types.ImageTagMutabilityMutable,
+			"open-excl": types.ImageTagMutabilityMutableWithExclusion,
+		},
+		fail: map[string]bool{"denied": true},
+	}
+}
+
+func statuses(report Report) map[string]Status {
+	got := map[string]Status{}
+	for _, repo := range report.Repositories {
+		got[repo.Repository] = repo.Status
+	}
+	return got
+}
+
+func TestEnforce(t *testing.T) {
+	client := newMockClient()
+	repos := []string{"locked", "excluded", "open", "denied", "open-excl", "missing"}
+	report, err := Enforce(context.TODO(), client, repos, Options{Concurrency: 2, Logs: logbuffer.New()})
+	if err == nil {
+		t.Error("Expected an error for the failed repositories")
+	}
+	want := map[string]Status{
+		"locked":    StatusAlreadyImmutable,
+		"excluded":  StatusAlreadyImmutable,
+		"open":      StatusMadeImmutable,
+		"denied":    StatusFailed,
+		"open-excl": StatusMadeImmutable,
+		"missing":   StatusFailed,
+	}
+	if got := statuses(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	sort.Strings(client.puts)
+	if !reflect.DeepEqual(client.puts, []string{"open", "open-excl"}) {
+		t.Errorf("Unexpected puts: %v", client.puts)
+	}
+	if client.settings["open"] != types.ImageTagMutabilityImmutable {
+		t.Errorf("Expected open to be immutable, got %s", client.settings["open"])
+	}
+	if !sort.SliceIsSorted(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	}) {
+		t.Errorf("Expected results sorted by repository: %+v", report.Repositories)
+	}
+}
+
+func TestEnforce_DryRun(t *testing.T) {
+	client := newMockClient()
+	report, err := Enforce(context.TODO(), client, []string{"locked", "open"}, Options{DryRun: true, Logs: logbuffer.New()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(client.puts) != 0 {
+		t.Errorf("Expected no changes in dry run, got %v", client.puts)
+	}
+	if got := statuses(report); got["open"] != StatusMadeImmutable || got["locked"] != StatusAlreadyImmutable {
+		t.Errorf("Unexpected statuses: %v", got)
+	}
+}
+
+func TestEnforce_Mutable(t *testing.T) {
+	client := newMockClient()
+	report, err := Enforce(context.TODO(), client, []string{"locked", "excluded", "open", "open-excl"}, Options{Mutable: true, Logs: logbuffer.New()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := map[string]Status{
+		"locked":    StatusMadeMutable,
+		"excluded":  StatusMadeMutable,
+		"open":      StatusAlreadyMutable,
+		"open-excl": StatusAlreadyMutable,
+	}
+	if got := statuses(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if report.Target != types.ImageTagMutabilityMutable || client.settings["locked"] != types.ImageTagMutabilityMutable {
+		t.Errorf("Expected MUTABLE target, got %s / %s", report.Target, client.settings["locked"])
+	}
+	if summary := report.Summary(); !strings.HasPrefix(summary, "Tag mutability MUTABLE: 2 repos already set, 2 changed, 0 failed") {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+}
+
+func TestReport_JUnit(t *testing.T) {
+	report := Report{Target: types.ImageTagMutabilityImmutable, Repositories: []RepositoryResult{
+		{Repository: "a", Status: StatusAlreadyImmutable},
+		{Repository: "b", Status: StatusMadeImmutable},
+		{Repository: "c", Status: StatusFailed, Error: "access denied"},
+	}}
+	suite := report.JUnit()
+	if len(suite.Cases) != 3 || suite.Cases[0].Skipped == "" || suite.Cases[1].Failure != "" || suite.Cases[2].Failure != "access denied" {
+		t.Errorf("Unexpected cases: %+v", suite.Cases)
+	}
+}
@@ -0,0 +1,106 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "ecr-lifecycle-cleaner"
+	serviceName = "ecr-lifecycle-cleaner"
+	tracesPath  = "/v1/traces"
+)
+
+// --- ShutdownFunc flushes pending spans and stops the exporter ---
+type ShutdownFunc func(context.Context) error
+
+// --- installs an OTLP/HTTP exporter sending spans to endpoint ---
+// --- with an empty endpoint the global no-op provider stays in place and spans cost nothing ---
+func Setup(ctx context.Context, endpoint string) (ShutdownFunc, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporterOpts, err := exporterOptions(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// --- accepts either a URL (http://collector:4318) or a bare host:port, which is sent over https ---
+func exporterOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	if !strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported OTLP endpoint scheme %q, use http or https", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	return []otlptracehttp.Option{otlptracehttp.WithEndpointURL(u.String())}, nil
+}
+
+// --- returns ctx carrying the parent trace from TRACEPARENT/TRACESTATE, as set by CI systems ---
+func FromEnvironment(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	if traceParent := os.Getenv("TRACEPARENT"); traceParent != "" {
+		carrier.Set("traceparent", traceParent)
+	}
+	if traceState := os.Getenv("TRACESTATE"); traceState != "" {
+		carrier.Set("tracestate", traceState)
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// --- starts a span named name as a child of the span in ctx ---
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// --- ends span, marking it as failed when err is set ---
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,78 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_NoEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.TODO(), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := shutdown(context.TODO()); err != nil {
+		t.Errorf("Expected no-op shutdown, got: %v", err)
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "http://localhost:4318", "https://collector.example.com/custom/traces"} {
+		if _, err := exporterOptions(endpoint); err != nil {
+			t.Errorf("Expected %s to be accepted, got: %v", endpoint, err)
+		}
+	}
+	for _, endpoint := range []string{"grpc://localhost:4317", "http://"} {
+		if _, err := exporterOptions(endpoint); err == nil {
+			t.Errorf("Expected %s to be rejected", endpoint)
+		}
+	}
+}
+
+func TestStart_NestsUnderEnvironmentParent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	if _, err := Setup(context.TODO(), ""); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := FromEnvironment(context.TODO())
+	_, span := Start(ctx, "DeleteImages")
+	End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got: %d", len(spans))
+	}
+	got := spans[0]
+	if got.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected span in the propagated trace, got: %s", got.SpanContext().TraceID())
+	}
+	if got.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected propagated span as parent, got: %s", got.Parent().SpanID())
+	}
+	if got.Status().Code != codes.Error || len(got.Events()) != 1 {
+		t.Errorf("Expected error status and recorded error, got: %+v", got.Status())
+	}
+}
+
+func TestFromEnvironment_NoParent(t *testing.T) {
+	t.Setenv("TRACEPARENT", "")
+	ctx := FromEnvironment(context.TODO())
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Errorf("Expected no parent span without TRACEPARENT")
+	}
+}
@@ -0,0 +1,204 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	format "ecr-lifecycle-cleaner/internal/format"
+)
+
+// --- the chat platforms a summary can be posted to ---
+const (
+	TypeSlack = "slack"
+	TypeTeams = "teams"
+)
+
+// --- DefaultTimeout bounds the whole delivery, the run is never held up longer by a slow webhook ---
+const DefaultTimeout = 10 * time.Second
+
+// --- Target is an incoming webhook and the platform its payload is formatted for ---
+type Target struct {
+	URL  string
+	Type string
+}
+
+// --- parses an incoming webhook URL, the platform is detected from the host unless kind names it ---
+func Parse(rawURL, kind string) (Target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Target{}, fmt.Errorf("invalid webhook URL %q, expected an https:// incoming webhook URL", rawURL)
+	}
+	if u.Scheme != "https" {
+		return Target{}, fmt.Errorf("invalid webhook URL %q, only https is supported", rawURL)
+	}
+	switch kind = strings.ToLower(strings.TrimSpace(kind)); kind {
+	case TypeSlack, TypeTeams:
+		return Target{URL: rawURL, Type: kind}, nil
+	case "":
+	default:
+		return Target{}, fmt.Errorf("unknown webhook type %q, expected slack or teams", kind)
+	}
+	kind = DetectType(u.Hostname())
+	if kind == "" {
+		return Target{}, fmt.Errorf("cannot tell the platform of webhook host %s, set the type to slack or teams", u.Hostname())
+	}
+	return Target{URL: rawURL, Type: kind}, nil
+}
+
+// --- returns the platform of a webhook host, or an empty string for a host it does not know ---
+// --- Teams webhooks are served by Office 365 connectors, Logic Apps and Power Automate workflows ---
+func DetectType(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case host == "hooks.slack.com":
+		return TypeSlack
+	case host == "outlook.office.com", strings.HasSuffix(host, ".webhook.office.com"), strings.HasSuffix(host, ".logic.azure.com"), strings.HasSuffix(host, ".powerplatform.com"):
+		return TypeTeams
+	}
+	return ""
+}
+
+// --- Summary is the outcome of a run as posted to the webhook ---
+type Summary struct {
+	Command            string
+	Account            string
+	Region             string
+	DryRun             bool
+	Interrupted        bool
+	Repositories       int
+	FailedRepositories int
+	Deleted            int
+	Failed             int
+	// --- estimated storage freed, only shown when ReclaimedKnown is set ---
+	Reclaimed      int64
+	ReclaimedKnown bool
+}
+
+// --- Fact is one labelled value of a summary ---
+type Fact struct {
+	Name  string
+	Value string
+}
+
+// --- returns the headline of the summary, e.g. ecr-lifecycle-cleaner clean: 1 of 4 repositories failed ---
+func (s Summary) Title() string {
+	title := "ecr-lifecycle-cleaner " + s.Command
+	if s.DryRun {
+		title += " (dry run)"
+	}
+	switch {
+	case s.Interrupted:
+		return title + ": interrupted"
+	case s.FailedRepositories > 0:
+		return fmt.Sprintf("%s: %d of %d repositories failed", title, s.FailedRepositories, s.Repositories)
+	}
+	return title + ": succeeded"
+}
+
+// --- returns the facts of the summary in display order ---
+func (s Summary) Facts() []Fact {
+	deleted := "Images deleted"
+	if s.DryRun {
+		deleted = "Images to delete"
+	}
+	facts := []Fact{
+		{Name: "Registry", Value: s.Account + " / " + s.Region},
+		{Name: "Repositories processed", Value: strconv.Itoa(s.Repositories)},
+		{Name: "Repositories failed", Value: strconv.Itoa(s.FailedRepositories)},
+		{Name: deleted, Value: strconv.Itoa(s.Deleted)},
+		{Name: "Failed deletions", Value: strconv.Itoa(s.Failed)},
+	}
+	if s.ReclaimedKnown {
+		facts = append(facts, Fact{Name: "Reclaimed (estimate)", Value: format.HumanBytes(s.Reclaimed)})
+	}
+	return facts
+}
+
+// --- returns the JSON payload of the summary in the format of the platform ---
+func Payload(kind string, s Summary) ([]byte, error) {
+	switch kind {
+	case TypeSlack:
+		return json.Marshal(slackPayload(s))
+	case TypeTeams:
+		return json.Marshal(teamsPayload(s))
+	}
+	return nil, fmt.Errorf("unknown webhook type %q, expected slack or teams", kind)
+}
+
+// --- a Block Kit message, text is the fallback shown in notifications ---
+func slackPayload(s Summary) map[string]interface{} {
+	var fields []map[string]string
+	for _, fact := range s.Facts() {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", fact.Name, fact.Value)})
+	}
+	return map[string]interface{}{
+		"text": s.Title(),
+		"blocks": []interface{}{
+			map[string]interface{}{"type": "header", "text": map[string]string{"type": "plain_text", "text": s.Title()}},
+			map[string]interface{}{"type": "section", "fields": fields},
+		},
+	}
+}
+
+// --- an Adaptive Card message, accepted by both Office 365 connectors and Workflows webhooks ---
+func teamsPayload(s Summary) map[string]interface{} {
+	var facts []map[string]string
+	for _, fact := range s.Facts() {
+		facts = append(facts, map[string]string{"title": fact.Name, "value": fact.Value})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": s.Title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+	}
+	return map[string]interface{}{
+		"type":        "message",
+		"attachments": []interface{}{map[string]interface{}{"contentType": "application/vnd.microsoft.card.adaptive", "content": card}},
+	}
+}
+
+// --- posts the summary to the webhook, a response other than 2xx is an error ---
+func Send(ctx context.Context, client *http.Client, target Target, s Summary) error {
+	body, err := Payload(target.Type, s)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// --- the URL carries the webhook secret, so only the host is reported ---
+		return fmt.Errorf("failed to post to %s webhook at %s: %w", target.Type, req.URL.Hostname(), unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s webhook at %s returned %s: %s", target.Type, req.URL.Hostname(), resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// --- drops the *url.Error wrapper, its message repeats the full URL ---
+func unwrapURLError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+// --- Copyright © 2025 Gjorgji J. ---
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		url, kind string
+		want      string
+		err       string
+	}{
+		{url: "https://hooks.slack.com/services/T000/B000/XXXX", want: TypeSlack},
+		{url: "https://contoso.webhook.office.com/webhookb2/abc", want: TypeTeams},
+		{url: "https://prod-12.westeurope.logic.azure.com:443/workflows/abc/triggers/manual/paths/invoke", want: TypeTeams},
+		{url: "https://chat.example.com/hook", kind: "Slack", want: TypeSlack},
+		{url: "https://chat.example.com/hook", err: "cannot tell the platform of webhook host chat.example.com"},
+		{url: "https://hooks.slack.com/services/x", kind: "discord", err: `unknown webhook type "discord"`},
+		{url: "http://hooks.slack.com/services/x", err: "only https is supported"},
+		{url: "hooks.slack.com/services/x", err: "expected an https:// incoming webhook URL"},
+	}
+	for _, c := range cases {
+		target, err := Parse(c.url, c.kind)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("Parse(%q, %q): expected error containing %q, got: %v", c.url, c.kind, c.err, err)
+			}
+			continue
+		}
+		if err != nil || target.Type != c.want || target.URL != c.url {
+			t.Errorf("Parse(%q, %q): expected %s, got %+v, %v", c.url, c.kind, c.want, target, err)
+		}
+	}
+}
+
+func TestSummary_TitleAndFacts(t *testing.T) {
+	s := Summary{Command: "clean", Account: "123456789012", Region: "eu-west-1", Repositories: 4, FailedRepositories: 1, Deleted: 120, Failed: 3}
+	if got := s.Title(); got != "ecr-lifecycle-cleaner clean: 1 of 4 repositories failed" {
+		t.Errorf("Unexpected title: %s", got)
+	}
+	if len(s.Facts()) != 5 {
+		t.Errorf("Expected no reclaimed size when it is unknown, got: %v", s.Facts())
+	}
+
+	s = Summary{Command: "clean", DryRun: true, Repositories: 2, Deleted: 7, Reclaimed: 3 << 20, ReclaimedKnown: true}
+	if got := s.Title(); got != "ecr-lifecycle-cleaner clean (dry run): succeeded" {
+		t.Errorf("Unexpected title: %s", got)
+	}
+	facts := s.Facts()
+	if facts[3] != (Fact{Name: "Images to delete", Value: "7"}) || facts[len(facts)-1].Name != "Reclaimed (estimate)" {
+		t.Errorf("Unexpected facts for a dry run: %v", facts)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	s := Summary{Command: "empty", Account: "123456789012", Region: "eu-west-1", Repositories: 1, Deleted: 5}
+
+	data, err := Payload(TypeSlack, s)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var slack struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type   string `json:"type"`
+			Fields []struct {
+				Text string `json:"text"`
+			} `json:"fields"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &slack); err != nil {
+		t.Fatalf("Invalid Slack payload: %v", err)
+	}
+	if slack.Text != s.Title() || len(slack.Blocks) != 2 || slack.Blocks[1].Fields[3].Text != "*Images deleted*\n5" {
+		t.Errorf("Unexpected Slack payload: %s", data)
+	}
+
+	data, err = Payload(TypeTeams, s)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var teams struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type string `json:"type"`
+				Body []struct {
+					Type  string              `json:"type"`
+					Facts []map[string]string `json:"facts"`
+				} `json:"body"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(data, &teams); err != nil {
+		t.Fatalf("Invalid Teams payload: %v", err)
+	}
+	card := teams.Attachments[0]
+	if teams.Type != "message" || card.ContentType != "application/vnd.microsoft.card.adaptive" || card.Content.Type != "AdaptiveCard" {
+		t.Errorf("Unexpected Teams payload: %s", data)
+	}
+	if want := map[string]string{"title": "Registry", "value": "123456789012 / eu-west-1"}; !reflect.DeepEqual(card.Content.Body[1].Facts[0], want) {
+		t.Errorf("Expected the first fact to be %v, got: %v", want, card.Content.Body[1].Facts[0])
+	}
+
+	if _, err := Payload("discord", s); err == nil {
+		t.Error("Expected an error for an unknown type")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	s := Summary{Command: "clean", Repositories: 1}
+	if err := Send(context.Background(), server.Client(), Target{URL: server.URL, Type: TypeSlack}, s); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got["text"] != s.Title() {
+		t.Errorf("Expected the summary to be posted, got: %v", got)
+	}
+}
+
+func TestSend_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "invalid_token")
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), server.Client(), Target{URL: server.URL + "/secret", Type: TypeTeams}, Summary{})
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: invalid_token") {
+		t.Errorf("Expected the rejected post to be reported, got: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = Send(ctx, server.Client(), Target{URL: server.URL + "/slow", Type: TypeSlack}, Summary{})
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Errorf("Expected the post to give up after the timeout, got: %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "/slow") {
+		t.Errorf("Expected the webhook path to be left out of the error, got: %v", err)
+	}
+}